@@ -0,0 +1,209 @@
+// Package admin serves metrics, pprof and the other operational endpoints
+// on a listener of their own, away from the public API.
+package admin
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"runtime"
+	"runtime/debug"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
+	"github.com/gofiber/fiber/v2/middleware/pprof"
+	"github.com/gofiber/fiber/v2/middleware/recover"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"gopkg.in/yaml.v3"
+
+	"kit-fiber-example/config"
+	"kit-fiber-example/health"
+	"kit-fiber-example/logging"
+	"kit-fiber-example/problem"
+	"kit-fiber-example/validation"
+)
+
+// Version is set at build time, e.g. -ldflags "-X kit-fiber-example/admin.Version=v1.2.0"
+var Version = "dev"
+
+// HealthReporter runs the health checks
+type HealthReporter interface {
+	Report(ctx context.Context) health.Report
+}
+
+type server struct {
+	cfg    *config.Config
+	health HealthReporter
+}
+
+// NewFromConfig builds the admin app. It must only be reachable by
+// operators: it exposes profiles and lets callers change the log level. It
+// requires server.adminToken as a bearer token when set, and refuses to be
+// served on other than a loopback address without one.
+func NewFromConfig(cfg *config.Config, h HealthReporter) (*fiber.App, error) {
+	token := cfg.Server.AdminToken
+	if token == "" && !loopback(cfg.Server.AdminAddr) {
+		return nil, fmt.Errorf("server.adminToken is required to serve the admin server on %s", cfg.Server.AdminAddr)
+	}
+	s := &server{cfg: cfg, health: h}
+
+	app := fiber.New(fiber.Config{
+		ErrorHandler:          errorHandler,
+		DisableStartupMessage: true,
+	})
+	app.Use(recover.New())
+	if token != "" {
+		app.Use(bearer(token))
+	}
+	app.Use(pprof.New())
+
+	app.Get("/metrics", adaptor.HTTPHandler(promhttp.Handler()))
+	app.Get("/health", s.HandleHealth)
+	app.Get("/config", s.HandleConfig)
+	app.Get("/buildinfo", s.HandleBuildInfo)
+	app.Get("/loglevel", s.HandleGetLogLevel)
+	app.Put("/loglevel", s.HandleSetLogLevel)
+	return app, nil
+}
+
+// loopback reports whether addr only accepts local connections. An empty
+// host listens on every interface.
+func loopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// bearer rejects requests without the token
+func bearer(token string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		got, ok := strings.CutPrefix(c.Get(fiber.HeaderAuthorization), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			c.Set(fiber.HeaderWWWAuthenticate, "Bearer")
+			return problem.New(problem.CodeUnauthorized, "a valid admin token is required")
+		}
+		return c.Next()
+	}
+}
+
+// HandleHealth reports every registered check, 503 when the service is unhealthy
+func (s *server) HandleHealth(c *fiber.Ctx) error {
+	report := s.health.Report(c.UserContext())
+	status := fiber.StatusOK
+	if report.Status == health.StatusUnhealthy {
+		status = fiber.StatusServiceUnavailable
+	}
+	return c.Status(status).JSON(report)
+}
+
+// HandleConfig shows the effective config as YAML, without secrets
+func (s *server) HandleConfig(c *fiber.Ctx) error {
+	redacted, err := s.cfg.Redacted()
+	if err != nil {
+		return err
+	}
+	data, err := yaml.Marshal(redacted)
+	if err != nil {
+		return err
+	}
+	c.Set(fiber.HeaderContentType, "application/yaml")
+	return c.Send(data)
+}
+
+type BuildInfo struct {
+	Version   string `json:"version"`
+	GoVersion string `json:"go_version"`
+	Module    string `json:"module,omitempty"`
+	Revision  string `json:"revision,omitempty"`
+	Time      string `json:"time,omitempty"`
+	Modified  bool   `json:"modified,omitempty"`
+	OS        string `json:"os"`
+	Arch      string `json:"arch"`
+}
+
+// HandleBuildInfo reports the version and the VCS state the binary was built from
+func (s *server) HandleBuildInfo(c *fiber.Ctx) error {
+	info := BuildInfo{
+		Version:   Version,
+		GoVersion: runtime.Version(),
+		OS:        runtime.GOOS,
+		Arch:      runtime.GOARCH,
+	}
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		info.Module = bi.Main.Path
+		for _, setting := range bi.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				info.Revision = setting.Value
+			case "vcs.time":
+				info.Time = setting.Value
+			case "vcs.modified":
+				info.Modified = setting.Value == "true"
+			}
+		}
+	}
+	return c.JSON(info)
+}
+
+type LogLevel struct {
+	Level string `json:"level" validate:"required"`
+}
+
+func (s *server) HandleGetLogLevel(c *fiber.Ctx) error {
+	return c.JSON(LogLevel{Level: logging.Level().String()})
+}
+
+// HandleSetLogLevel changes the level until the next restart
+func (s *server) HandleSetLogLevel(c *fiber.Ctx) error {
+	var req LogLevel
+	if err := validation.DecodeJSON(c.Body(), &req); err != nil {
+		return err
+	}
+	if err := validation.Struct(req); err != nil {
+		return err
+	}
+	level, err := logging.ParseLevel(req.Level)
+	if err != nil {
+		return problem.Wrap(problem.CodeValidation, err).With("fields", []validation.FieldError{
+			{Field: "level", Code: validation.CodeNotAllowed, Message: "must be debug, info, warn or error"},
+		})
+	}
+
+	previous := logging.Level()
+	logging.SetLevel(level)
+	slog.Warn("log level changed", "from", previous, "to", level)
+	return c.JSON(LogLevel{Level: level.String()})
+}
+
+// errorHandler writes problem documents like the public API
+func errorHandler(c *fiber.Ctx, err error) error {
+	var (
+		pe     *problem.Error
+		fields validation.Errors
+		fe     *fiber.Error
+	)
+	switch {
+	case errors.As(err, &pe):
+	case errors.As(err, &fields):
+		pe = problem.Wrap(problem.CodeValidation, err).With("fields", []validation.FieldError(fields))
+	case errors.Is(err, validation.ErrMalformed):
+		pe = problem.Wrap(problem.CodeBadRequest, err)
+	case errors.As(err, &fe):
+		pe = &problem.Error{Code: problem.CodeFromStatus(fe.Code), Status: fe.Code, Detail: fe.Message, Err: err}
+	default:
+		pe = problem.Wrap(problem.CodeInternal, err)
+	}
+	p := pe.Problem()
+	p.Instance = c.Path()
+	return c.Status(p.Status).JSON(p, problem.ContentType)
+}
@@ -0,0 +1,88 @@
+package admin
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"kit-fiber-example/config"
+	"kit-fiber-example/health"
+)
+
+type fakeHealth struct{}
+
+func (fakeHealth) Report(context.Context) health.Report {
+	return health.Report{Status: health.StatusOK}
+}
+
+func TestLoopback(t *testing.T) {
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{"127.0.0.1:8081", true},
+		{"127.0.0.2:8081", true},
+		{"[::1]:8081", true},
+		{"localhost:8081", true},
+		{":8081", false},
+		{"0.0.0.0:8081", false},
+		{"10.0.0.1:8081", false},
+		{"admin.internal:8081", false},
+		{"8081", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.addr, func(t *testing.T) {
+			if got := loopback(tt.addr); got != tt.want {
+				t.Errorf("loopback(%q) = %t, want %t", tt.addr, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewFromConfig(t *testing.T) {
+	tests := []struct {
+		name   string
+		addr   string
+		token  string
+		header string
+		status int // 0 when the config is rejected
+	}{
+		{"loopback without a token", "127.0.0.1:8081", "", "", 200},
+		{"public without a token", ":8081", "", "", 0},
+		{"public with a token", ":8081", "secret", "Bearer secret", 200},
+		{"missing token", ":8081", "secret", "", 401},
+		{"wrong token", "127.0.0.1:8081", "secret", "Bearer guess", 401},
+		{"not a bearer token", ":8081", "secret", "Basic secret", 401},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var cfg config.Config
+			cfg.Server.AdminAddr, cfg.Server.AdminToken = tt.addr, tt.token
+			app, err := NewFromConfig(&cfg, fakeHealth{})
+			if tt.status == 0 {
+				if err == nil {
+					t.Error("NewFromConfig succeeded")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req := httptest.NewRequest("GET", "/health", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			resp, err := app.Test(req, -1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tt.status {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.status)
+			}
+			if tt.status == 401 && resp.Header.Get("WWW-Authenticate") != "Bearer" {
+				t.Errorf("WWW-Authenticate = %q", resp.Header.Get("WWW-Authenticate"))
+			}
+		})
+	}
+}
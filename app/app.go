@@ -0,0 +1,230 @@
+// Package app runs the components of the service: it starts them in order,
+// waits for a signal or a failure, lets them drain their work in flight,
+// then stops them in reverse order within the shutdown budget. A second
+// signal cuts the shutdown short.
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"kit-fiber-example/admin"
+	"kit-fiber-example/health"
+	"kit-fiber-example/transport"
+)
+
+// runGrace is how long Run may take to return once its OnStop returned
+const runGrace = 100 * time.Millisecond
+
+// HealthChecker is the health state the app maintains and reports
+type HealthChecker interface {
+	transport.HealthChecker
+	admin.HealthReporter
+	SetHealthy()
+	SetUnhealthy()
+	SetReady(bool)
+	Register(name string, check health.Check)
+}
+
+// Hook is one component of the app. All functions are optional.
+type Hook struct {
+	Name string
+	// OnStart must not block, a failure stops the components started before
+	OnStart func(ctx context.Context) error
+	// Run is called after OnStart and blocks while the component works, like
+	// serving. Returning before the app is stopping stops the app.
+	Run func() error
+	// OnDrain waits for the work in flight while every component still
+	// runs. The drains of all components run at once, ctx is done when the
+	// shutdown budget ran out and the work left should be aborted.
+	OnDrain func(ctx context.Context) error
+	// OnStop gets what the drain left of the shutdown budget
+	OnStop func(ctx context.Context) error
+}
+
+type App struct {
+	logger          *slog.Logger
+	health          HealthChecker
+	preStopDelay    time.Duration
+	shutdownTimeout time.Duration
+
+	hooks    []Hook
+	running  sync.WaitGroup
+	stopping atomic.Bool
+}
+
+// New creates an app. On shutdown it keeps serving for preStopDelay after
+// turning not ready, long enough for load balancers to notice.
+func New(logger *slog.Logger, h HealthChecker, preStopDelay, shutdownTimeout time.Duration) *App {
+	return &App{logger: logger, health: h, preStopDelay: preStopDelay, shutdownTimeout: shutdownTimeout}
+}
+
+// Append adds components, they're started in the order they're appended
+func (a *App) Append(hooks ...Hook) {
+	a.hooks = append(a.hooks, hooks...)
+}
+
+// Run makes the logger of the app the default one, starts every component
+// and blocks until SIGINT, SIGTERM, ctx being done or a component failing.
+// Everything that started is stopped before it returns, whatever the reason.
+func (a *App) Run(ctx context.Context) error {
+	slog.SetDefault(a.logger)
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	failed := make(chan error, len(a.hooks))
+	started, startErr := a.start(ctx, failed)
+
+	var runErr error
+	if startErr == nil {
+		a.health.SetHealthy()
+		a.health.SetReady(true)
+		a.logger.Info("app ready", "components", started)
+
+		select {
+		case sig := <-signals:
+			a.logger.Info("shutting down", "signal", sig.String())
+		case <-ctx.Done():
+			a.logger.Info("shutting down", "cause", ctx.Err())
+		case runErr = <-failed:
+			a.logger.Error("shutting down", "err", runErr)
+		}
+	}
+
+	stopErr := a.stop(a.hooks[:started], signals)
+	return errors.Join(startErr, runErr, stopErr)
+}
+
+// start returns the number of components started
+func (a *App) start(ctx context.Context, failed chan<- error) (int, error) {
+	for i, h := range a.hooks {
+		if h.OnStart != nil {
+			if err := h.OnStart(ctx); err != nil {
+				a.logger.Error("start failed", "component", h.Name, "err", err)
+				return i, fmt.Errorf("start %s: %w", h.Name, err)
+			}
+		}
+		if h.Run != nil {
+			a.running.Add(1)
+			go func() {
+				defer a.running.Done()
+				err := h.Run()
+				if a.stopping.Load() {
+					return
+				}
+				if err == nil {
+					err = errors.New("stopped unexpectedly")
+				}
+				failed <- fmt.Errorf("%s: %w", h.Name, err)
+			}()
+		}
+		a.logger.Debug("started", "component", h.Name)
+	}
+	return len(a.hooks), nil
+}
+
+// stop marks the service as not ready first, so that load balancers stop
+// sending traffic while the components drain. The drain and the stops share
+// one shutdown budget, so that the whole shutdown takes at most the pre-stop
+// delay plus shutdownTimeout. A signal received meanwhile ends the delay and
+// the budget at once.
+func (a *App) stop(hooks []Hook, signals <-chan os.Signal) error {
+	a.health.SetReady(false)
+	a.stopping.Store(true)
+
+	interrupted, interrupt := context.WithCancel(context.Background())
+	defer interrupt()
+	go func() {
+		select {
+		case sig := <-signals:
+			a.logger.Warn("stopping now", "signal", sig.String())
+			interrupt()
+		case <-interrupted.Done():
+		}
+	}()
+
+	if a.preStopDelay > 0 {
+		a.logger.Info("waiting for load balancers to stop sending traffic", "delay", a.preStopDelay)
+		timer := time.NewTimer(a.preStopDelay)
+		select {
+		case <-timer.C:
+		case <-interrupted.Done():
+			timer.Stop()
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(interrupted, a.shutdownTimeout)
+	defer cancel()
+	errs := a.drain(ctx, hooks)
+
+	for i := len(hooks) - 1; i >= 0; i-- {
+		h := hooks[i]
+		if h.OnStop == nil {
+			continue
+		}
+		if err := h.OnStop(ctx); err != nil {
+			a.logger.Error("stop failed", "component", h.Name, "err", err)
+			errs = append(errs, fmt.Errorf("stop %s: %w", h.Name, err))
+			continue
+		}
+		a.logger.Debug("stopped", "component", h.Name)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		a.running.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		// Stops that used up the budget return from Run right after
+		select {
+		case <-done:
+		case <-time.After(runGrace):
+			errs = append(errs, fmt.Errorf("components still running after the shutdown budget: %w", ctx.Err()))
+		}
+	}
+
+	a.health.SetUnhealthy()
+	return errors.Join(errs...)
+}
+
+// drain runs every OnDrain at once until ctx is done
+func (a *App) drain(ctx context.Context, hooks []Hook) []error {
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []error
+	)
+	for _, h := range hooks {
+		if h.OnDrain == nil {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			start := time.Now()
+			if err := h.OnDrain(ctx); err != nil {
+				a.logger.Error("drain failed", "component", h.Name, "err", err)
+				mu.Lock()
+				errs = append(errs, fmt.Errorf("drain %s: %w", h.Name, err))
+				mu.Unlock()
+				return
+			}
+			a.logger.Info("drained", "component", h.Name, "took", time.Since(start))
+		}()
+	}
+	wg.Wait()
+	return errs
+}
@@ -0,0 +1,89 @@
+package app
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"slices"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"kit-fiber-example/health"
+)
+
+func TestStop(t *testing.T) {
+	// block waits for the end of the budget
+	block := func(ctx context.Context) error {
+		<-ctx.Done()
+		return nil
+	}
+
+	tests := []struct {
+		name         string
+		preStopDelay time.Duration
+		timeout      time.Duration
+		drain        func(context.Context) error
+		signal       bool
+		maxElapsed   time.Duration
+		// stopBudgetLeft is whether the stops still have budget
+		stopBudgetLeft bool
+	}{
+		{"quick drain leaves the budget to the stops", 0, time.Hour, func(context.Context) error { return nil }, false, time.Second, true},
+		{"drain and stops share the deadline", 0, 100 * time.Millisecond, block, false, time.Second, false},
+		{"pre-stop delay isn't part of the budget", 50 * time.Millisecond, time.Hour, func(context.Context) error { return nil }, false, time.Second, true},
+		{"signal ends the pre-stop delay and the budget", time.Hour, time.Hour, block, true, time.Second, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := New(slog.New(slog.NewTextHandler(io.Discard, nil)), &health.Health{}, tt.preStopDelay, tt.timeout)
+
+			var (
+				mu      sync.Mutex
+				stopped []string
+				left    []bool
+			)
+			onStop := func(name string) func(context.Context) error {
+				return func(ctx context.Context) error {
+					mu.Lock()
+					defer mu.Unlock()
+					stopped = append(stopped, name)
+					left = append(left, ctx.Err() == nil)
+					return nil
+				}
+			}
+			hooks := []Hook{
+				{Name: "first", OnStop: onStop("first")},
+				{Name: "second", OnStop: onStop("second"), OnDrain: tt.drain},
+			}
+
+			signals := make(chan os.Signal, 1)
+			if tt.signal {
+				signals <- syscall.SIGTERM
+			}
+			start := time.Now()
+			if err := a.stop(hooks, signals); err != nil {
+				t.Fatal(err)
+			}
+			if elapsed := time.Since(start); elapsed > tt.maxElapsed {
+				t.Errorf("stop took %v", elapsed)
+			}
+			if elapsed := time.Since(start); elapsed < tt.preStopDelay && !tt.signal {
+				t.Errorf("stop took %v, less than the pre-stop delay", elapsed)
+			}
+			if !slices.Equal(stopped, []string{"second", "first"}) {
+				t.Errorf("stopped %v, want second then first", stopped)
+			}
+			for i, l := range left {
+				if l != tt.stopBudgetLeft {
+					t.Errorf("%s had budget left = %t, want %t", stopped[i], l, tt.stopBudgetLeft)
+				}
+			}
+			if a.health.IsReady() || a.health.IsHealthy() {
+				t.Error("still ready or healthy after stop")
+			}
+		})
+	}
+}
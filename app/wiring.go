@@ -0,0 +1,188 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"kit-fiber-example/admin"
+	"kit-fiber-example/cassette"
+	"kit-fiber-example/config"
+	"kit-fiber-example/health"
+	"kit-fiber-example/logging"
+	"kit-fiber-example/metrics"
+	"kit-fiber-example/service"
+	"kit-fiber-example/tracing"
+	"kit-fiber-example/transport"
+)
+
+type options struct {
+	logger  *slog.Logger
+	metrics *metrics.Metrics
+	health  HealthChecker
+	service transport.StringService
+}
+
+// Option replaces a dependency NewFromConfig would build from the config
+type Option func(*options)
+
+// WithLogger also becomes the default logger while the app runs
+func WithLogger(l *slog.Logger) Option {
+	return func(o *options) { o.logger = l }
+}
+
+// WithMetrics avoids registering the collectors twice, metrics.Setup can
+// only be called once per process.
+func WithMetrics(m *metrics.Metrics) Option {
+	return func(o *options) { o.metrics = m }
+}
+
+func WithHealth(h HealthChecker) Option {
+	return func(o *options) { o.health = h }
+}
+
+// WithService replaces the LLM backed string service, e.g. with a fake.
+// No provider, cassette or tools are set up then.
+func WithService(svc transport.StringService) Option {
+	return func(o *options) { o.service = svc }
+}
+
+// NewFromConfig wires the service. Components are started in this order
+// and stopped the other way round: telemetry, cassette, admin server,
+// prompt watcher, job workers, HTTP server. Requests, streams, chat asks
+// and job attempts in flight are drained before anything is stopped.
+//
+// Building has no side effects outside of the process, so that it can
+// check a config: nothing is connected to, listened on or written before
+// Run.
+func NewFromConfig(cfg *config.Config, opts ...Option) (_ *App, err error) {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.logger == nil {
+		if o.logger, err = logging.NewLoggerFromConfig(cfg); err != nil {
+			return nil, err
+		}
+	}
+	if o.metrics == nil {
+		o.metrics = metrics.Setup()
+	}
+	if o.health == nil {
+		o.health = &health.Health{}
+	}
+
+	var preStopDelay time.Duration
+	if cfg.Server.PreStopDelay != "" {
+		if preStopDelay, err = time.ParseDuration(cfg.Server.PreStopDelay); err != nil {
+			return nil, fmt.Errorf("server.preStopDelay: %w", err)
+		}
+	}
+	a := New(o.logger, o.health, preStopDelay, time.Duration(cfg.Server.ShutdownTimeout)*time.Second)
+
+	tp, err := tracing.InitOtel(cfg)
+	if err != nil {
+		return nil, err
+	}
+	// Stopped last, flushing the spans of everything else
+	a.Append(Hook{
+		Name:    "telemetry",
+		OnStart: func(ctx context.Context) error { return tracing.StartExporter(ctx, cfg, tp) },
+		OnStop:  tp.Shutdown,
+	})
+	tracer := tp.Tracer("string-service")
+
+	svc := o.service
+	if svc == nil {
+		providerOptions := []service.ClaudeOption{service.WithMetrics(o.metrics), service.WithTracer(tracer)}
+
+		// Record or replay upstream calls
+		recorder, err := cassette.NewRecorderFromConfig(cfg)
+		if err != nil {
+			return nil, err
+		}
+		if recorder != nil {
+			providerOptions = append(providerOptions, service.WithTransport(recorder))
+			a.Append(Hook{Name: "cassette", OnStop: func(context.Context) error { return recorder.Stop() }})
+		}
+
+		provider, err := service.NewProviderFromConfig(cfg, providerOptions...)
+		if err != nil {
+			return nil, err
+		}
+		stringService := &service.String{Provider: provider}
+		// Tools are only supported by Claude
+		if claudeClient, ok := provider.(*service.ClaudeClient); ok {
+			for _, tool := range []service.Tool{
+				service.NewUppercaseTool(*stringService),
+				service.NewCountTool(*stringService),
+				service.NewDiffTool(*stringService),
+			} {
+				if err := claudeClient.RegisterTool(tool); err != nil {
+					return nil, err
+				}
+			}
+			o.health.Register("claude", claudeClient.Check)
+		}
+		svc = stringService
+	}
+
+	tr, err := transport.NewFiberTransport(cfg, svc, o.health, o.metrics, tracer)
+	if err != nil {
+		return nil, err
+	}
+	o.health.Register("jobs", tr.Jobs.Check)
+	o.health.Register("prompts", tr.Prompts.Check)
+
+	if cfg.Server.AdminAddr != "" {
+		adminServer, err := admin.NewFromConfig(cfg, o.health)
+		if err != nil {
+			return nil, err
+		}
+		a.Append(serverHook("admin server", adminServer, cfg.Server.AdminAddr))
+	}
+	a.Append(
+		Hook{
+			Name: "prompt watcher",
+			OnStart: func(context.Context) error {
+				tr.Prompts.Start()
+				return nil
+			},
+			OnStop: func(context.Context) error {
+				tr.Prompts.Stop()
+				return nil
+			},
+		},
+		Hook{
+			Name:    "job workers",
+			OnStart: func(context.Context) error { return tr.Jobs.Start() },
+			// Unfinished jobs resume on the next start
+			OnStop: tr.Jobs.Stop,
+		},
+		serverHook("http server", transport.InitApp(tr), cfg.Server.Port),
+		Hook{Name: "in-flight work", OnDrain: tr.Tracker.Drain},
+	)
+	return a, nil
+}
+
+// serverHook binds addr on start, so that a port in use fails the start
+// rather than the run, and serves until stopped.
+func serverHook(name string, server *fiber.App, addr string) Hook {
+	var ln net.Listener
+	return Hook{
+		Name: name,
+		OnStart: func(context.Context) (err error) {
+			ln, err = net.Listen("tcp", addr)
+			return err
+		},
+		Run: func() error {
+			slog.Info("listening", "server", name, "addr", ln.Addr().String())
+			return server.Listener(ln)
+		},
+		OnStop: server.ShutdownWithContext,
+	}
+}
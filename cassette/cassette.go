@@ -0,0 +1,99 @@
+// Package cassette records HTTP interactions to a file and replays them, so
+// that upstream calls are deterministic in tests and local runs.
+package cassette
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const redacted = "REDACTED"
+
+// Headers whose values never end up in a cassette.
+var sensitiveHeaders = []string{"X-Api-Key", "Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie"}
+
+type Request struct {
+	Method  string      `yaml:"method" json:"method"`
+	URL     string      `yaml:"url" json:"url"`
+	Headers http.Header `yaml:"headers" json:"headers"`
+	Body    string      `yaml:"body" json:"body"`
+}
+
+type Response struct {
+	Status  int         `yaml:"status" json:"status"`
+	Headers http.Header `yaml:"headers" json:"headers"`
+	Body    string      `yaml:"body" json:"body"`
+}
+
+type Interaction struct {
+	Request  Request  `yaml:"request" json:"request"`
+	Response Response `yaml:"response" json:"response"`
+}
+
+// Cassette is a list of interactions stored as YAML or JSON, chosen by the
+// file extension.
+type Cassette struct {
+	Interactions []Interaction `yaml:"interactions" json:"interactions"`
+}
+
+// Load reads a cassette file.
+func Load(path string) (*Cassette, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var c Cassette
+	if isJSON(path) {
+		err = json.Unmarshal(data, &c)
+	} else {
+		err = yaml.Unmarshal(data, &c)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// Save writes the cassette file, creating its directory.
+func (c *Cassette) Save(path string) error {
+	var (
+		data []byte
+		err  error
+	)
+	if isJSON(path) {
+		data, err = json.MarshalIndent(c, "", "  ")
+	} else {
+		data, err = yaml.Marshal(c)
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+func isJSON(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".json")
+}
+
+// redact returns a copy of h without secrets.
+func redact(h http.Header) http.Header {
+	h = h.Clone()
+	for _, name := range sensitiveHeaders {
+		if h.Get(name) != "" {
+			h.Set(name, redacted)
+		}
+	}
+	return h
+}
+
+var errNoCassette = errors.New("cassette: no cassette path")
@@ -0,0 +1,49 @@
+package cassette
+
+import (
+	"fmt"
+	"strings"
+
+	"kit-fiber-example/config"
+)
+
+// NewRecorderFromConfig builds the recorder, or returns nil when cassettes
+// are disabled.
+func NewRecorderFromConfig(cfg *config.Config) (*Recorder, error) {
+	if cfg.Cassette.Mode == "" {
+		return nil, nil
+	}
+
+	var options []Option
+	if len(cfg.Cassette.Match) > 0 {
+		matchers := make([]Matcher, 0, len(cfg.Cassette.Match))
+		for _, name := range cfg.Cassette.Match {
+			m, err := matcherByName(name)
+			if err != nil {
+				return nil, err
+			}
+			matchers = append(matchers, m)
+		}
+		options = append(options, WithMatchers(matchers...))
+	}
+	return New(Mode(cfg.Cassette.Mode), cfg.Cassette.Path, options...)
+}
+
+func matcherByName(name string) (Matcher, error) {
+	switch name {
+	case "method":
+		return MatchMethod, nil
+	case "url":
+		return MatchURL, nil
+	case "path":
+		return MatchPath, nil
+	case "body":
+		return MatchBody, nil
+	case "json_body":
+		return MatchJSONBody, nil
+	}
+	if header, ok := strings.CutPrefix(name, "header:"); ok && header != "" {
+		return MatchHeader(header), nil
+	}
+	return nil, fmt.Errorf("cassette.match: unknown matcher %q", name)
+}
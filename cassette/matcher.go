@@ -0,0 +1,69 @@
+package cassette
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+)
+
+// Matcher reports whether a recorded request matches the live one. body is
+// the live request body.
+type Matcher func(r *http.Request, body []byte, recorded Request) bool
+
+// MatchMethod compares HTTP methods.
+func MatchMethod(r *http.Request, _ []byte, recorded Request) bool {
+	return r.Method == recorded.Method
+}
+
+// MatchURL compares full URLs.
+func MatchURL(r *http.Request, _ []byte, recorded Request) bool {
+	return r.URL.String() == recorded.URL
+}
+
+// MatchPath compares URL paths only, so cassettes work against any host.
+func MatchPath(r *http.Request, _ []byte, recorded Request) bool {
+	return r.URL.Path == pathOf(recorded.URL)
+}
+
+// MatchBody compares bodies byte by byte.
+func MatchBody(_ *http.Request, body []byte, recorded Request) bool {
+	return bytes.Equal(body, []byte(recorded.Body))
+}
+
+// MatchJSONBody compares bodies as JSON values, ignoring formatting and key order.
+func MatchJSONBody(_ *http.Request, body []byte, recorded Request) bool {
+	var live, rec any
+	if json.Unmarshal(body, &live) != nil || json.Unmarshal([]byte(recorded.Body), &rec) != nil {
+		return bytes.Equal(body, []byte(recorded.Body))
+	}
+	a, _ := json.Marshal(live)
+	b, _ := json.Marshal(rec)
+	return bytes.Equal(a, b)
+}
+
+// MatchHeader compares the value of one header. Redacted headers can't be matched.
+func MatchHeader(name string) Matcher {
+	return func(r *http.Request, _ []byte, recorded Request) bool {
+		return r.Header.Get(name) == recorded.Headers.Get(name)
+	}
+}
+
+// DefaultMatchers match method, path and JSON body.
+var DefaultMatchers = []Matcher{MatchMethod, MatchPath, MatchJSONBody}
+
+func matchAll(matchers []Matcher, r *http.Request, body []byte, recorded Request) bool {
+	for _, m := range matchers {
+		if !m(r, body, recorded) {
+			return false
+		}
+	}
+	return true
+}
+
+func pathOf(rawURL string) string {
+	req, err := http.NewRequest("GET", rawURL, nil)
+	if err != nil {
+		return rawURL
+	}
+	return req.URL.Path
+}
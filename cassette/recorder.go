@@ -0,0 +1,209 @@
+package cassette
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+type Mode string
+
+const (
+	// ModeRecord sends requests upstream and records them
+	ModeRecord Mode = "record"
+	// ModeReplay answers from the cassette and never goes upstream
+	ModeReplay Mode = "replay"
+	// ModePassthrough sends requests upstream without recording
+	ModePassthrough Mode = "passthrough"
+)
+
+// Recorder is an http.RoundTripper that records or replays interactions.
+type Recorder struct {
+	mode     Mode
+	path     string
+	next     http.RoundTripper
+	matchers []Matcher
+
+	mu       sync.Mutex
+	cassette *Cassette
+	used     []bool
+}
+
+type Option func(*Recorder)
+
+// WithMatchers replaces DefaultMatchers.
+func WithMatchers(matchers ...Matcher) Option {
+	return func(r *Recorder) { r.matchers = matchers }
+}
+
+// WithTransport sets the upstream transport, http.DefaultTransport by default.
+func WithTransport(next http.RoundTripper) Option {
+	return func(r *Recorder) { r.next = next }
+}
+
+// New creates a recorder for the cassette at path. In replay mode the
+// cassette must exist; in record mode it's written by Stop.
+func New(mode Mode, path string, options ...Option) (*Recorder, error) {
+	r := &Recorder{
+		mode:     mode,
+		path:     path,
+		next:     http.DefaultTransport,
+		matchers: DefaultMatchers,
+		cassette: &Cassette{},
+	}
+	for _, option := range options {
+		option(r)
+	}
+
+	switch mode {
+	case ModeReplay:
+		if path == "" {
+			return nil, errNoCassette
+		}
+		c, err := Load(path)
+		if err != nil {
+			return nil, fmt.Errorf("cassette: %w", err)
+		}
+		r.cassette = c
+		r.used = make([]bool, len(c.Interactions))
+	case ModeRecord:
+		if path == "" {
+			return nil, errNoCassette
+		}
+	case ModePassthrough:
+	default:
+		return nil, fmt.Errorf("cassette: unknown mode %q", mode)
+	}
+	return r, nil
+}
+
+// Client returns an http.Client using the recorder.
+func (r *Recorder) Client() *http.Client {
+	return &http.Client{Transport: r}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	if r.mode == ModePassthrough {
+		return r.next.RoundTrip(req)
+	}
+
+	var body []byte
+	if req.Body != nil {
+		var err error
+		if body, err = io.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+		req.Body.Close()
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	if r.mode == ModeReplay {
+		return r.replay(req, body)
+	}
+	return r.record(req, body)
+}
+
+func (r *Recorder) replay(req *http.Request, body []byte) (*http.Response, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, in := range r.cassette.Interactions {
+		if r.used[i] || !matchAll(r.matchers, req, body, in.Request) {
+			continue
+		}
+		r.used[i] = true
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", in.Response.Status, http.StatusText(in.Response.Status)),
+			StatusCode:    in.Response.Status,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        in.Response.Headers.Clone(),
+			Body:          io.NopCloser(strings.NewReader(in.Response.Body)),
+			ContentLength: int64(len(in.Response.Body)),
+			Request:       req,
+		}, nil
+	}
+	return nil, &MissError{Method: req.Method, URL: req.URL.String(), Body: string(body), Cassette: r.path}
+}
+
+func (r *Recorder) record(req *http.Request, body []byte) (*http.Response, error) {
+	resp, err := r.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cassette.Interactions = append(r.cassette.Interactions, Interaction{
+		Request: Request{
+			Method:  req.Method,
+			URL:     req.URL.String(),
+			Headers: redact(req.Header),
+			Body:    string(body),
+		},
+		Response: Response{
+			Status:  resp.StatusCode,
+			Headers: redact(resp.Header),
+			Body:    string(respBody),
+		},
+	})
+	return resp, nil
+}
+
+// Unused returns the recorded interactions that weren't replayed.
+func (r *Recorder) Unused() []Interaction {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var unused []Interaction
+	for i, in := range r.cassette.Interactions {
+		if !r.used[i] {
+			unused = append(unused, in)
+		}
+	}
+	return unused
+}
+
+// Stop saves the cassette in record mode. In replay mode it fails when
+// interactions weren't replayed, the cassette is stale then.
+func (r *Recorder) Stop() error {
+	switch r.mode {
+	case ModeRecord:
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		return r.cassette.Save(r.path)
+	case ModeReplay:
+		if unused := r.Unused(); len(unused) > 0 {
+			return fmt.Errorf("cassette %s: %d of %d interactions weren't replayed, first %s %s",
+				r.path, len(unused), len(r.cassette.Interactions), unused[0].Request.Method, unused[0].Request.URL)
+		}
+	}
+	return nil
+}
+
+// MissError is returned in replay mode when no recorded interaction matches.
+type MissError struct {
+	Method   string
+	URL      string
+	Body     string
+	Cassette string
+}
+
+func (e *MissError) Error() string {
+	body := e.Body
+	if len(body) > 200 {
+		body = body[:200] + "..."
+	}
+	return fmt.Sprintf("cassette %s: no recorded interaction matches %s %s with body %s", e.Cassette, e.Method, e.URL, body)
+}
@@ -0,0 +1,100 @@
+package cassette
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRecordReplay(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Write([]byte("echo " + string(body)))
+	}))
+	defer upstream.Close()
+	path := filepath.Join(t.TempDir(), "cassette.yaml")
+
+	post := func(client *http.Client, body string) (string, error) {
+		req, _ := http.NewRequest(http.MethodPost, upstream.URL+"/v1/messages", strings.NewReader(body))
+		req.Header.Set("X-Api-Key", "secret")
+		resp, err := client.Do(req)
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		data, err := io.ReadAll(resp.Body)
+		return string(data), err
+	}
+
+	recorder, err := New(ModeRecord, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, body := range []string{"one", "two"} {
+		if _, err := post(recorder.Client(), body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := recorder.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	c, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := c.Interactions[0].Request.Headers.Get("X-Api-Key"); got != redacted {
+		t.Errorf("recorded api key = %q, want it redacted", got)
+	}
+
+	tests := []struct {
+		name     string
+		bodies   []string
+		wantMiss bool
+		wantStop bool // whether Stop fails
+	}{
+		{"all replayed", []string{"two", "one"}, false, false},
+		{"interaction left", []string{"one"}, false, true},
+		{"miss", []string{"three"}, true, true},
+		{"replayed only once", []string{"one", "one"}, true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			replay, err := New(ModeReplay, path, WithTransport(failingTransport{}))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var missed bool
+			for _, body := range tt.bodies {
+				got, err := post(replay.Client(), body)
+				var miss *MissError
+				if errors.As(err, &miss) {
+					missed = true
+					continue
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got != "echo "+body {
+					t.Errorf("replayed %q, want %q", got, "echo "+body)
+				}
+			}
+			if missed != tt.wantMiss {
+				t.Errorf("missed = %v, want %v", missed, tt.wantMiss)
+			}
+			if err := replay.Stop(); (err != nil) != tt.wantStop {
+				t.Errorf("Stop() = %v, want error %v", err, tt.wantStop)
+			}
+		})
+	}
+}
+
+// failingTransport fails every request, replays must never go upstream
+type failingTransport struct{}
+
+func (failingTransport) RoundTrip(*http.Request) (*http.Response, error) {
+	return nil, errors.New("replay went upstream")
+}
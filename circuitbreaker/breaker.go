@@ -0,0 +1,110 @@
+package circuitbreaker
+
+import (
+	"sync"
+	"time"
+)
+
+type State int
+
+const (
+	Closed State = iota
+	Open
+	HalfOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case Closed:
+		return "closed"
+	case Open:
+		return "open"
+	default:
+		return "half-open"
+	}
+}
+
+// Breaker opens after Threshold consecutive failures. After Timeout it lets
+// up to MaxRequests trial requests through; the first failure among them
+// opens it again, MaxRequests successes close it.
+type Breaker struct {
+	mu sync.Mutex
+
+	threshold   int
+	timeout     time.Duration
+	maxRequests int
+
+	state     State
+	failures  int
+	trials    int
+	successes int
+	openedAt  time.Time
+}
+
+func New(threshold int, timeout time.Duration, maxRequests int) *Breaker {
+	if threshold < 1 {
+		threshold = 1
+	}
+	if maxRequests < 1 {
+		maxRequests = 1
+	}
+	return &Breaker{
+		threshold:   threshold,
+		timeout:     timeout,
+		maxRequests: maxRequests,
+	}
+}
+
+// Allow reports whether a request may be sent. Every allowed request must be
+// followed by Success or Failure.
+func (b *Breaker) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == Open {
+		if time.Since(b.openedAt) < b.timeout {
+			return false
+		}
+		b.state = HalfOpen
+		b.trials = 0
+		b.successes = 0
+	}
+	if b.state == HalfOpen {
+		if b.trials >= b.maxRequests {
+			return false
+		}
+		b.trials++
+	}
+	return true
+}
+
+func (b *Breaker) Success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures = 0
+	if b.state == HalfOpen {
+		b.successes++
+		if b.successes >= b.maxRequests {
+			b.state = Closed
+		}
+	}
+}
+
+func (b *Breaker) Failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	if b.state == HalfOpen || b.failures >= b.threshold {
+		b.state = Open
+		b.openedAt = time.Now()
+		b.failures = 0
+	}
+}
+
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
@@ -0,0 +1,50 @@
+package circuitbreaker
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBreaker(t *testing.T) {
+	tests := []struct {
+		name        string
+		threshold   int
+		timeout     time.Duration
+		maxRequests int
+		// events are run in order: s is a success, f a failure, a an
+		// allowed request and d a denied one
+		events string
+		want   State
+	}{
+		{"starts closed", 3, time.Hour, 1, "a", Closed},
+		{"below the threshold", 3, time.Hour, 1, "ff", Closed},
+		{"opens at the threshold", 3, time.Hour, 1, "fffd", Open},
+		{"successes reset the count", 3, time.Hour, 1, "ffsffa", Closed},
+		{"zero threshold opens at once", 0, time.Hour, 1, "fd", Open},
+		{"half-open after the timeout", 1, 0, 2, "faa", HalfOpen},
+		{"half-open limits the trials", 1, 0, 2, "faad", HalfOpen},
+		{"one trial success isn't enough", 1, 0, 2, "faas", HalfOpen},
+		{"all trial successes close it", 1, 0, 2, "faass", Closed},
+		{"a failed trial reopens it", 1, 0, 2, "faf", Open},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := New(tt.threshold, tt.timeout, tt.maxRequests)
+			for i, event := range tt.events {
+				switch event {
+				case 's':
+					b.Success()
+				case 'f':
+					b.Failure()
+				case 'a', 'd':
+					if got := b.Allow(); got != (event == 'a') {
+						t.Fatalf("event %d: Allow() = %v, want %v", i, got, event == 'a')
+					}
+				}
+			}
+			if got := b.State(); got != tt.want {
+				t.Errorf("state = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
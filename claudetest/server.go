@@ -0,0 +1,339 @@
+// Package claudetest provides a fake Messages API server. It answers from
+// scripted rules, can inject errors, latency and malformed bodies, and
+// records every request for assertions.
+package claudetest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"kit-fiber-example/service"
+)
+
+// Rule scripts the answer to requests whose last user text matches Match.
+// A zero Status means 200. Times limits how often the rule fires, 0 means
+// always.
+type Rule struct {
+	Match      string        `yaml:"match" json:"match"`
+	Answer     string        `yaml:"answer" json:"answer"`
+	StopReason string        `yaml:"stopReason" json:"stopReason"`
+	ToolUse    *ToolUse      `yaml:"toolUse" json:"toolUse"`
+	Status     int           `yaml:"status" json:"status"`
+	Latency    time.Duration `yaml:"latency" json:"latency"`
+	Malformed  bool          `yaml:"malformed" json:"malformed"`
+	Times      int           `yaml:"times" json:"times"`
+
+	match *regexp.Regexp
+	fired int
+}
+
+// ToolUse makes the rule answer with a tool call instead of text.
+type ToolUse struct {
+	Name  string          `yaml:"name" json:"name"`
+	Input json.RawMessage `yaml:"input" json:"input"`
+}
+
+// UnmarshalYAML lets scripts write the input as a YAML mapping.
+func (t *ToolUse) UnmarshalYAML(node *yaml.Node) error {
+	var raw struct {
+		Name  string `yaml:"name"`
+		Input any    `yaml:"input"`
+	}
+	if err := node.Decode(&raw); err != nil {
+		return err
+	}
+	input, err := json.Marshal(raw.Input)
+	if err != nil {
+		return err
+	}
+	t.Name, t.Input = raw.Name, input
+	return nil
+}
+
+// RecordedRequest is a request the server received.
+type RecordedRequest struct {
+	Path    string
+	Header  http.Header
+	Body    []byte
+	Request service.ClaudeRequest
+}
+
+type Server struct {
+	mu       sync.Mutex
+	rules    []*Rule
+	requests []RecordedRequest
+	// Latency is added to every response
+	Latency time.Duration
+	// APIKey, when set, must be sent in x-api-key
+	APIKey string
+}
+
+func NewServer() *Server {
+	return &Server{}
+}
+
+// Start serves s on a random local port. Call Close on the result when done.
+func (s *Server) Start() *httptest.Server {
+	return httptest.NewServer(s)
+}
+
+// Add appends rules, they are matched in the order they were added.
+func (s *Server) Add(rules ...Rule) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, r := range rules {
+		re, err := regexp.Compile(r.Match)
+		if err != nil {
+			return fmt.Errorf("rule %q: %w", r.Match, err)
+		}
+		r.match = re
+		s.rules = append(s.rules, &r)
+	}
+	return nil
+}
+
+// Reply answers questions matching match with answer.
+func (s *Server) Reply(match, answer string) *Server {
+	s.must(Rule{Match: match, Answer: answer})
+	return s
+}
+
+// Fail makes the next n requests fail with status, e.g. 429, 500 or 529.
+func (s *Server) Fail(n, status int) *Server {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Failures take precedence over the rules added before
+	s.rules = append([]*Rule{{match: regexp.MustCompile(""), Status: status, Times: n}}, s.rules...)
+	return s
+}
+
+func (s *Server) must(r Rule) {
+	if err := s.Add(r); err != nil {
+		panic(err)
+	}
+}
+
+// Requests returns the requests received so far.
+func (s *Server) Requests() []RecordedRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]RecordedRequest(nil), s.requests...)
+}
+
+// Reset forgets rules and recorded requests.
+func (s *Server) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rules = nil
+	s.requests = nil
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/_requests") {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(s.Requests())
+		return
+	}
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "invalid_request_error", "method not allowed")
+		return
+	}
+	if s.APIKey != "" && r.Header.Get("x-api-key") != s.APIKey {
+		writeError(w, http.StatusUnauthorized, "authentication_error", "invalid x-api-key")
+		return
+	}
+	if r.Header.Get("anthropic-version") == "" {
+		writeError(w, http.StatusBadRequest, "invalid_request_error", "anthropic-version header is required")
+		return
+	}
+
+	body, _ := io.ReadAll(r.Body)
+	var req service.ClaudeRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request_error", "invalid JSON body")
+		return
+	}
+
+	rule := s.record(r, body, req)
+
+	if s.Latency > 0 {
+		time.Sleep(s.Latency)
+	}
+	if strings.HasSuffix(r.URL.Path, "/count_tokens") {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"input_tokens":%d}`, countTokens(req))
+		return
+	}
+	if req.MaxTokens < 1 {
+		writeError(w, http.StatusBadRequest, "invalid_request_error", "max_tokens: field required")
+		return
+	}
+
+	if rule.Latency > 0 {
+		time.Sleep(rule.Latency)
+	}
+	if rule.Status != 0 && rule.Status != http.StatusOK {
+		writeError(w, rule.Status, errorType(rule.Status), http.StatusText(rule.Status))
+		return
+	}
+	if rule.Malformed {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id": "msg_malformed", "content": [`))
+		return
+	}
+
+	resp := s.response(req, rule)
+	if req.Stream {
+		s.stream(w, resp)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// record stores the request and picks the matching rule.
+func (s *Server) record(r *http.Request, body []byte, req service.ClaudeRequest) Rule {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests = append(s.requests, RecordedRequest{
+		Path:    r.URL.Path,
+		Header:  r.Header.Clone(),
+		Body:    body,
+		Request: req,
+	})
+
+	question := lastUserText(req)
+	for _, rule := range s.rules {
+		if rule.Times > 0 && rule.fired >= rule.Times {
+			continue
+		}
+		if rule.match.MatchString(question) {
+			rule.fired++
+			return *rule
+		}
+	}
+	return Rule{Answer: "You asked: " + question}
+}
+
+func (s *Server) response(req service.ClaudeRequest, rule Rule) service.ClaudeResponse {
+	resp := service.ClaudeResponse{
+		ID:         fmt.Sprintf("msg_fake_%d", time.Now().UnixNano()),
+		Model:      req.Model,
+		Role:       "assistant",
+		StopReason: "end_turn",
+		Usage: service.Usage{
+			InputTokens: countTokens(req),
+		},
+	}
+	if rule.ToolUse != nil {
+		resp.StopReason = "tool_use"
+		resp.Content = []service.Content{{
+			Type:  "tool_use",
+			ID:    fmt.Sprintf("toolu_fake_%d", len(s.Requests())),
+			Name:  rule.ToolUse.Name,
+			Input: rule.ToolUse.Input,
+		}}
+	} else {
+		resp.Content = []service.Content{service.TextContent(rule.Answer)}
+		resp.Usage.OutputTokens = approxTokens(rule.Answer)
+	}
+	if rule.StopReason != "" {
+		resp.StopReason = rule.StopReason
+	}
+	return resp
+}
+
+// stream writes resp as Messages API server-sent events, one delta per word.
+func (s *Server) stream(w http.ResponseWriter, resp service.ClaudeResponse) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	flusher, _ := w.(http.Flusher)
+	send := func(event string, data any) {
+		payload, _ := json.Marshal(data)
+		fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, payload)
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+
+	start := resp
+	start.Content = []service.Content{}
+	start.Usage.OutputTokens = 0
+	send("message_start", map[string]any{"type": "message_start", "message": start})
+	send("content_block_start", map[string]any{"type": "content_block_start", "index": 0, "content_block": map[string]any{"type": "text", "text": ""}})
+	for _, word := range strings.SplitAfter(resp.Text(), " ") {
+		send("content_block_delta", map[string]any{"type": "content_block_delta", "index": 0, "delta": map[string]any{"type": "text_delta", "text": word}})
+	}
+	send("content_block_stop", map[string]any{"type": "content_block_stop", "index": 0})
+	send("message_delta", map[string]any{
+		"type":  "message_delta",
+		"delta": map[string]any{"stop_reason": resp.StopReason, "stop_sequence": nil},
+		"usage": map[string]any{"output_tokens": resp.Usage.OutputTokens},
+	})
+	send("message_stop", map[string]any{"type": "message_stop"})
+}
+
+func writeError(w http.ResponseWriter, status int, typ, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]any{
+		"type":  "error",
+		"error": map[string]string{"type": typ, "message": message},
+	})
+}
+
+func errorType(status int) string {
+	switch status {
+	case http.StatusTooManyRequests:
+		return "rate_limit_error"
+	case service.StatusOverloaded:
+		return "overloaded_error"
+	case http.StatusUnauthorized:
+		return "authentication_error"
+	case http.StatusNotFound:
+		return "not_found_error"
+	}
+	if status >= 500 {
+		return "api_error"
+	}
+	return "invalid_request_error"
+}
+
+func lastUserText(req service.ClaudeRequest) string {
+	for i := len(req.Messages) - 1; i >= 0; i-- {
+		if req.Messages[i].Role != "user" {
+			continue
+		}
+		for _, c := range req.Messages[i].Content {
+			if c.Type == "text" {
+				return c.Text
+			}
+		}
+	}
+	return ""
+}
+
+func countTokens(req service.ClaudeRequest) int {
+	n := approxTokens(req.System)
+	for _, m := range req.Messages {
+		for _, c := range m.Content {
+			n += approxTokens(c.Text) + approxTokens(c.Content)
+		}
+	}
+	return n
+}
+
+func approxTokens(s string) int {
+	return (len(s) + 3) / 4
+}
@@ -0,0 +1,143 @@
+// Package client is a typed HTTP client of the string service. Error
+// responses are returned as *problem.Error, with the code the server sent.
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"kit-fiber-example/codec"
+	"kit-fiber-example/problem"
+	"kit-fiber-example/transport"
+)
+
+// HTTPClient is an interface that models *http.Client.
+type HTTPClient interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+type Client struct {
+	base   *url.URL
+	client HTTPClient
+	codec  codec.Codec
+	header http.Header
+}
+
+type Option func(*Client)
+
+// SetClient sets the underlying HTTP client used for requests.
+// By default, http.DefaultClient is used.
+func SetClient(client HTTPClient) Option {
+	return func(c *Client) { c.client = client }
+}
+
+// SetCodec sets the media type of request and response bodies, one of
+// codec.Default. By default, JSON is used.
+func SetCodec(cd codec.Codec) Option {
+	return func(c *Client) { c.codec = cd }
+}
+
+// SetHeader adds a header to every request, like transport.HeaderTenant
+func SetHeader(key, value string) Option {
+	return func(c *Client) { c.header.Set(key, value) }
+}
+
+// New returns a client of the server at baseURL, e.g. http://localhost:3000
+func New(baseURL string, options ...Option) (*Client, error) {
+	base, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, err
+	}
+	if base.Scheme == "" || base.Host == "" {
+		return nil, fmt.Errorf("server URL %q must be absolute", baseURL)
+	}
+	c := &Client{
+		base:   base,
+		client: http.DefaultClient,
+		codec:  codec.JSON,
+		header: make(http.Header),
+	}
+	for _, option := range options {
+		option(c)
+	}
+	return c, nil
+}
+
+func (c *Client) Uppercase(ctx context.Context, req transport.UppercaseRequest) (transport.UppercaseResponse, error) {
+	var response transport.UppercaseResponse
+	err := c.call(ctx, "/uppercase", req, &response)
+	return response, err
+}
+
+func (c *Client) AskClaude(ctx context.Context, req transport.AskClaudeRequest) (transport.AskClaudeResponse, error) {
+	var response transport.AskClaudeResponse
+	err := c.call(ctx, "/ask", req, &response)
+	return response, err
+}
+
+func (c *Client) CountTokens(ctx context.Context, req transport.AskClaudeRequest) (transport.CountTokensResponse, error) {
+	var response transport.CountTokensResponse
+	err := c.call(ctx, "/ask/count_tokens", req, &response)
+	return response, err
+}
+
+// Batch waits for every item, failed items have an Error in their result
+func (c *Client) Batch(ctx context.Context, req transport.BatchRequest) (transport.BatchResponse, error) {
+	var response transport.BatchResponse
+	err := c.call(ctx, "/batch", req, &response)
+	return response, err
+}
+
+// Health returns an error unless the server is alive
+func (c *Client) Health(ctx context.Context) error {
+	return c.probe(ctx, "/health")
+}
+
+// Ready returns an error unless the server takes traffic
+func (c *Client) Ready(ctx context.Context) error {
+	return c.probe(ctx, "/ready")
+}
+
+func (c *Client) call(ctx context.Context, path string, request, response any) error {
+	req, err := c.newRequest(ctx, http.MethodPost, path)
+	if err != nil {
+		return err
+	}
+	if err := transport.EncodeRequest(c.codec)(ctx, req, request); err != nil {
+		return err
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return transport.DecodeResponse(resp, response)
+}
+
+func (c *Client) probe(ctx context.Context, path string) error {
+	req, err := c.newRequest(ctx, http.MethodGet, path)
+	if err != nil {
+		return err
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return problem.Decode(resp)
+}
+
+func (c *Client) newRequest(ctx context.Context, method, path string) (*http.Request, error) {
+	target := c.base.JoinPath(strings.TrimPrefix(path, "/"))
+	req, err := http.NewRequestWithContext(ctx, method, target.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range c.header {
+		req.Header[key] = values
+	}
+	return req, nil
+}
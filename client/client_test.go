@@ -0,0 +1,153 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"kit-fiber-example/codec"
+	"kit-fiber-example/problem"
+	"kit-fiber-example/transport"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		baseURL string
+		ok      bool
+	}{
+		{"http://localhost:3000", true},
+		{"https://example.com/api/", true},
+		{"localhost:3000", false},
+		{"/api", false},
+		{"http://[::1", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.baseURL, func(t *testing.T) {
+			if _, err := New(tt.baseURL); (err == nil) != tt.ok {
+				t.Errorf("New() error = %v, want ok %t", err, tt.ok)
+			}
+		})
+	}
+}
+
+// uppercaseServer answers /api/uppercase in the media type it was asked in
+func uppercaseServer(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/uppercase" || r.Method != http.MethodPost {
+			http.NotFound(w, r)
+			return
+		}
+		if got := r.Header.Get(transport.HeaderTenant); got != "acme" {
+			t.Errorf("tenant = %q, want acme", got)
+		}
+		cd, err := codec.Default.ForContentType(r.Header.Get("Content-Type"))
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if got := r.Header.Get("Accept"); got != cd.MediaType() {
+			t.Errorf("Accept = %q, want %q", got, cd.MediaType())
+		}
+		body, _ := io.ReadAll(r.Body)
+		var req transport.UppercaseRequest
+		if err := cd.UnmarshalStrict(body, &req); err != nil {
+			t.Error(err)
+			return
+		}
+		if req.S == "fail" {
+			w.Header().Set("Content-Type", problem.ContentType)
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			io.WriteString(w, `{"title":"Validation failed","status":422,"code":"validation_failed","detail":"no"}`)
+			return
+		}
+		data, _ := cd.Marshal(transport.UppercaseResponse{V: "HI"})
+		w.Header().Set("Content-Type", cd.MediaType())
+		w.Write(data)
+	}))
+}
+
+func TestUppercase(t *testing.T) {
+	srv := uppercaseServer(t)
+	defer srv.Close()
+
+	for _, cd := range []codec.Codec{codec.JSON, codec.MessagePack, codec.CBOR, codec.Protobuf} {
+		t.Run(cd.MediaType(), func(t *testing.T) {
+			c, err := New(srv.URL+"/api/", SetCodec(cd), SetHeader(transport.HeaderTenant, "acme"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := c.Uppercase(context.Background(), transport.UppercaseRequest{S: "hi"})
+			if err != nil || resp.V != "HI" {
+				t.Errorf("Uppercase() = %+v, %v", resp, err)
+			}
+
+			_, err = c.Uppercase(context.Background(), transport.UppercaseRequest{S: "fail"})
+			var perr *problem.Error
+			if !errors.As(err, &perr) || perr.Code != problem.CodeValidation || perr.Detail != "no" {
+				t.Errorf("Uppercase() error = %#v, want a validation problem", err)
+			}
+		})
+	}
+}
+
+func TestProbes(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		code   problem.Code
+	}{
+		{"up", http.StatusOK, ""},
+		{"unavailable", http.StatusServiceUnavailable, problem.CodeUnavailable},
+		{"not found", http.StatusNotFound, problem.CodeNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var paths []string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				paths = append(paths, r.Method+" "+r.URL.Path)
+				w.WriteHeader(tt.status)
+			}))
+			defer srv.Close()
+
+			c, err := New(srv.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, probe := range []func(context.Context) error{c.Health, c.Ready} {
+				err := probe(context.Background())
+				if tt.code == "" {
+					if err != nil {
+						t.Errorf("probe = %v", err)
+					}
+					continue
+				}
+				if got := problem.CodeOf(err); got != tt.code {
+					t.Errorf("probe code = %s, want %s", got, tt.code)
+				}
+			}
+			if len(paths) != 2 || paths[0] != "GET /health" || paths[1] != "GET /ready" {
+				t.Errorf("paths = %v", paths)
+			}
+		})
+	}
+}
+
+// failingClient fails every request without sending it
+type failingClient struct{}
+
+func (failingClient) Do(*http.Request) (*http.Response, error) {
+	return nil, errors.New("no network")
+}
+
+func TestSetClient(t *testing.T) {
+	c, err := New("http://localhost:3000", SetClient(failingClient{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.AskClaude(context.Background(), transport.AskClaudeRequest{Question: "hi"}); err == nil || err.Error() != "no network" {
+		t.Errorf("AskClaude() error = %v, want no network", err)
+	}
+}
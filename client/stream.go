@@ -0,0 +1,109 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"kit-fiber-example/problem"
+	"kit-fiber-example/transport"
+)
+
+// ErrStreamEnded is returned when a stream ends without a done or error event
+var ErrStreamEnded = errors.New("stream ended before the answer was complete")
+
+// AskClaudeStream calls onDelta with every piece of the answer as it
+// arrives and returns the full response. Returning an error from onDelta
+// stops the stream.
+func (c *Client) AskClaudeStream(ctx context.Context, req transport.AskClaudeRequest, onDelta func(string) error) (transport.AskClaudeResponse, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	r, err := c.newRequest(ctx, http.MethodPost, "/ask/stream")
+	if err != nil {
+		return transport.AskClaudeResponse{}, err
+	}
+	if err := transport.EncodeRequest(c.codec)(ctx, r, req); err != nil {
+		return transport.AskClaudeResponse{}, err
+	}
+	r.Header.Set("Accept", "text/event-stream")
+
+	resp, err := c.client.Do(r)
+	if err != nil {
+		return transport.AskClaudeResponse{}, err
+	}
+	defer resp.Body.Close()
+	if err := problem.Decode(resp); err != nil {
+		return transport.AskClaudeResponse{}, err
+	}
+
+	var response transport.AskClaudeResponse
+	err = readEvents(resp.Body, func(event string, data []byte) (bool, error) {
+		switch event {
+		case "delta":
+			var delta struct {
+				Text string `json:"text"`
+			}
+			if err := json.Unmarshal(data, &delta); err != nil {
+				return false, err
+			}
+			return false, onDelta(delta.Text)
+		case "done":
+			return true, json.Unmarshal(data, &response)
+		case "error":
+			var p problem.Problem
+			if err := json.Unmarshal(data, &p); err != nil {
+				return false, err
+			}
+			return true, p.Err()
+		}
+		// Unknown events are skipped, the server may add some
+		return false, nil
+	})
+	return response, err
+}
+
+// readEvents calls handle with every server-sent event until it returns
+// true or an error
+func readEvents(body io.Reader, handle func(event string, data []byte) (bool, error)) error {
+	reader := bufio.NewReader(body)
+	var (
+		event string
+		data  []string
+	)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return ErrStreamEnded
+			}
+			return err
+		}
+		line = strings.TrimRight(line, "\r\n")
+
+		if line == "" {
+			if event == "" && len(data) == 0 {
+				continue
+			}
+			last, err := handle(event, []byte(strings.Join(data, "\n")))
+			if err != nil || last {
+				return err
+			}
+			event, data = "", nil
+			continue
+		}
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "event":
+			event = value
+		case "data":
+			data = append(data, value)
+		}
+		// Comments and the id and retry fields aren't used
+	}
+}
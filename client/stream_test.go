@@ -0,0 +1,159 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"kit-fiber-example/problem"
+	"kit-fiber-example/transport"
+)
+
+func TestReadEvents(t *testing.T) {
+	tests := []struct {
+		name   string
+		stream string
+		// events are "event=data", the handler stops at the one named last
+		events []string
+		err    error
+	}{
+		{"one event", "event: last\ndata: {}\n\n", []string{"last={}"}, nil},
+		{"stops at last", "event: a\ndata: 1\n\nevent: last\ndata: 2\n\nevent: b\ndata: 3\n\n", []string{"a=1", "last=2"}, nil},
+		{"multiline data", "event: last\ndata: a\ndata: b\n\n", []string{"last=a\nb"}, nil},
+		{"no space after the colon", "event:last\ndata:x\n\n", []string{"last=x"}, nil},
+		{"CRLF lines", "event: last\r\ndata: x\r\n\r\n", []string{"last=x"}, nil},
+		{"data without an event", "data: x\n\nevent: last\n\n", []string{"=x", "last="}, nil},
+		{"comments and other fields", ": keepalive\n\nid: 1\nretry: 10\nevent: last\ndata: x\n\n", []string{"last=x"}, nil},
+		{"ends before last", "event: a\ndata: 1\n\n", []string{"a=1"}, ErrStreamEnded},
+		{"ends inside an event", "event: last\ndata: 1\n", nil, ErrStreamEnded},
+		{"empty", "", nil, ErrStreamEnded},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var events []string
+			err := readEvents(strings.NewReader(tt.stream), func(event string, data []byte) (bool, error) {
+				events = append(events, event+"="+string(data))
+				return event == "last", nil
+			})
+			if !errors.Is(err, tt.err) {
+				t.Errorf("readEvents() = %v, want %v", err, tt.err)
+			}
+			if !reflect.DeepEqual(events, tt.events) {
+				t.Errorf("events = %q, want %q", events, tt.events)
+			}
+		})
+	}
+
+	stop := errors.New("stop")
+	calls := 0
+	err := readEvents(strings.NewReader("event: a\n\nevent: b\n\n"), func(string, []byte) (bool, error) {
+		calls++
+		return false, stop
+	})
+	if err != stop || calls != 1 {
+		t.Errorf("readEvents() = %v after %d calls, want stop after 1", err, calls)
+	}
+}
+
+func TestAskClaudeStream(t *testing.T) {
+	stop := errors.New("stop")
+	tests := []struct {
+		name    string
+		stream  string
+		onDelta error
+		deltas  []string
+		answer  string
+		code    problem.Code
+		err     error
+	}{
+		{
+			"answer",
+			"event: delta\ndata: {\"text\":\"hel\"}\n\nevent: ping\ndata: {}\n\nevent: delta\ndata: {\"text\":\"lo\"}\n\nevent: done\ndata: {\"answer\":\"hello\"}\n\n",
+			nil, []string{"hel", "lo"}, "hello", "", nil,
+		},
+		{
+			"error event",
+			"event: delta\ndata: {\"text\":\"hel\"}\n\nevent: error\ndata: {\"title\":\"Upstream failed\",\"status\":502,\"code\":\"upstream_failed\"}\n\n",
+			nil, []string{"hel"}, "", problem.CodeUpstream, nil,
+		},
+		{
+			"ends early",
+			"event: delta\ndata: {\"text\":\"hel\"}\n\n",
+			nil, []string{"hel"}, "", "", ErrStreamEnded,
+		},
+		{
+			"onDelta stops",
+			"event: delta\ndata: {\"text\":\"hel\"}\n\nevent: delta\ndata: {\"text\":\"lo\"}\n\n",
+			stop, []string{"hel"}, "", "", stop,
+		},
+		{
+			"malformed delta",
+			"event: delta\ndata: {\n\n",
+			nil, nil, "", "", nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/ask/stream" || r.Header.Get("Accept") != "text/event-stream" {
+					http.NotFound(w, r)
+					return
+				}
+				w.Header().Set("Content-Type", "text/event-stream")
+				io.WriteString(w, tt.stream)
+			}))
+			defer srv.Close()
+
+			c, err := New(srv.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var deltas []string
+			resp, err := c.AskClaudeStream(context.Background(), transport.AskClaudeRequest{Question: "hi"}, func(text string) error {
+				deltas = append(deltas, text)
+				return tt.onDelta
+			})
+			switch {
+			case tt.code != "":
+				if got := problem.CodeOf(err); got != tt.code {
+					t.Errorf("error code = %s (%v), want %s", got, err, tt.code)
+				}
+			case tt.err != nil:
+				if !errors.Is(err, tt.err) {
+					t.Errorf("error = %v, want %v", err, tt.err)
+				}
+			case tt.answer == "":
+				if err == nil {
+					t.Error("no error")
+				}
+			default:
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			if resp.Answer != tt.answer {
+				t.Errorf("answer = %q, want %q", resp.Answer, tt.answer)
+			}
+			if !reflect.DeepEqual(deltas, tt.deltas) {
+				t.Errorf("deltas = %q, want %q", deltas, tt.deltas)
+			}
+		})
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", problem.ContentType)
+		w.WriteHeader(http.StatusTooManyRequests)
+		io.WriteString(w, `{"title":"Rate limited","status":429,"code":"rate_limited"}`)
+	}))
+	defer srv.Close()
+	c, _ := New(srv.URL)
+	_, err := c.AskClaudeStream(context.Background(), transport.AskClaudeRequest{Question: "hi"}, func(string) error { return nil })
+	if got := problem.CodeOf(err); got != problem.CodeRateLimited {
+		t.Errorf("refused stream code = %s, want %s", got, problem.CodeRateLimited)
+	}
+}
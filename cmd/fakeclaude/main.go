@@ -0,0 +1,49 @@
+// Command fakeclaude serves the claudetest fake Messages API, so the service
+// can run against it by pointing claude.baseURL at
+// http://<addr>/v1/messages.
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"kit-fiber-example/claudetest"
+)
+
+// Script is the YAML file given with -script.
+type Script struct {
+	Latency time.Duration     `yaml:"latency"`
+	APIKey  string            `yaml:"apiKey"`
+	Rules   []claudetest.Rule `yaml:"rules"`
+}
+
+func main() {
+	addr := flag.String("addr", ":8090", "listen address")
+	script := flag.String("script", "", "YAML file with scripted rules")
+	flag.Parse()
+
+	server := claudetest.NewServer()
+	if *script != "" {
+		data, err := os.ReadFile(*script)
+		if err != nil {
+			log.Fatal(err)
+		}
+		var s Script
+		if err := yaml.Unmarshal(data, &s); err != nil {
+			log.Fatal(err)
+		}
+		server.Latency = s.Latency
+		server.APIKey = s.APIKey
+		if err := server.Add(s.Rules...); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	log.Printf("fake Messages API listening on %s", *addr)
+	log.Fatal(http.ListenAndServe(*addr, server))
+}
@@ -0,0 +1,51 @@
+// Command openapi writes the OpenAPI document of the HTTP API, or with
+// -check fails when the committed document is out of date:
+//
+//	go run ./cmd/openapi -o openapi.json
+//	go run ./cmd/openapi -check openapi.json
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"kit-fiber-example/transport"
+)
+
+func main() {
+	out := flag.String("o", "", "write the document to this file instead of stdout")
+	check := flag.String("check", "", "compare the document with this file and fail on drift")
+	flag.Parse()
+
+	doc, err := transport.OpenAPI()
+	if err != nil {
+		log.Fatal(err)
+	}
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		log.Fatal(err)
+	}
+	data = append(data, '\n')
+
+	switch {
+	case *check != "":
+		committed, err := os.ReadFile(*check)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if !bytes.Equal(committed, data) {
+			fmt.Fprintf(os.Stderr, "%s is out of date, run: go run ./cmd/openapi -o %s\n", *check, *check)
+			os.Exit(1)
+		}
+	case *out != "":
+		if err := os.WriteFile(*out, data, 0o644); err != nil {
+			log.Fatal(err)
+		}
+	default:
+		os.Stdout.Write(data)
+	}
+}
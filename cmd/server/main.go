@@ -0,0 +1,42 @@
+// Command server runs the string service.
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"log/slog"
+	"os"
+
+	"kit-fiber-example/app"
+	"kit-fiber-example/config"
+)
+
+func main() {
+	var configPaths []string
+	flag.Func("config", "config file, repeat to override values of the ones before (default config.yaml)", func(path string) error {
+		configPaths = append(configPaths, path)
+		return nil
+	})
+	flag.Parse()
+	if len(configPaths) == 0 {
+		configPaths = []string{"config.yaml"}
+	}
+
+	// Load config
+	cfg, err := config.LoadConfig(configPaths...)
+	if err != nil {
+		log.Fatalf("Loading config: %v", err)
+	}
+
+	a, err := app.NewFromConfig(cfg)
+	if err != nil {
+		log.Fatalf("Creating app: %v", err)
+	}
+
+	// Run returns once everything is stopped, exiting can't skip cleanup
+	if err := a.Run(context.Background()); err != nil {
+		slog.Error("app stopped with errors", "err", err)
+		os.Exit(1)
+	}
+}
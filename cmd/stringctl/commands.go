@@ -0,0 +1,288 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+
+	"kit-fiber-example/app"
+	"kit-fiber-example/config"
+	"kit-fiber-example/transport"
+)
+
+func serve(fs *flag.FlagSet, g *globals) func(context.Context, []string) error {
+	configPath := fs.String("config", "config.yaml", "config file")
+	return func(ctx context.Context, _ []string) error {
+		cfg, err := config.LoadConfig(*configPath)
+		if err != nil {
+			return fmt.Errorf("loading config: %w", err)
+		}
+		a, err := app.NewFromConfig(cfg)
+		if err != nil {
+			return fmt.Errorf("creating app: %w", err)
+		}
+		// The app handles the signals itself
+		return a.Run(context.WithoutCancel(ctx))
+	}
+}
+
+func validateConfig(fs *flag.FlagSet, g *globals) func(context.Context, []string) error {
+	configPath := fs.String("config", "config.yaml", "config file")
+	return func(context.Context, []string) error {
+		cfg, err := config.LoadConfigStrict(*configPath)
+		if err != nil {
+			return fmt.Errorf("%s: %w", *configPath, err)
+		}
+		// Building the app parses every section without side effects,
+		// nothing is started, connected to or written
+		if _, err := app.NewFromConfig(cfg, app.WithLogger(slog.New(slog.NewTextHandler(io.Discard, nil)))); err != nil {
+			return fmt.Errorf("%s: %w", *configPath, err)
+		}
+		fmt.Fprintf(g.stdout, "%s is valid\n", *configPath)
+		return nil
+	}
+}
+
+func uppercase(fs *flag.FlagSet, g *globals) func(context.Context, []string) error {
+	g.register(fs)
+	lang := fs.String("lang", "", "BCP-47 tag for language specific case rules")
+	return func(ctx context.Context, args []string) error {
+		text, err := argsOrStdin(args)
+		if err != nil {
+			return err
+		}
+		c, err := g.client()
+		if err != nil {
+			return err
+		}
+		response, err := c.Uppercase(ctx, transport.UppercaseRequest{S: text, Lang: *lang})
+		if err != nil {
+			return err
+		}
+		return g.print(response, nil, [][]string{{response.V}})
+	}
+}
+
+// askFlags are the flags of the commands sending an ask
+type askFlags struct {
+	system    *string
+	model     *string
+	maxTokens *int
+}
+
+func registerAsk(fs *flag.FlagSet) askFlags {
+	return askFlags{
+		system:    fs.String("system", "", "system prompt"),
+		model:     fs.String("model", "", "model, the server's default when empty"),
+		maxTokens: fs.Int("max-tokens", 0, "max tokens of the answer, the server's default when 0"),
+	}
+}
+
+func (f askFlags) request(args []string) (transport.AskClaudeRequest, error) {
+	question, err := argsOrStdin(args)
+	if err != nil {
+		return transport.AskClaudeRequest{}, err
+	}
+	return transport.AskClaudeRequest{
+		Question:  question,
+		System:    *f.system,
+		Model:     *f.model,
+		MaxTokens: *f.maxTokens,
+	}, nil
+}
+
+func ask(fs *flag.FlagSet, g *globals) func(context.Context, []string) error {
+	g.register(fs)
+	flags := registerAsk(fs)
+	stream := fs.Bool("stream", false, "print the answer as it arrives")
+	return func(ctx context.Context, args []string) error {
+		req, err := flags.request(args)
+		if err != nil {
+			return err
+		}
+		c, err := g.client()
+		if err != nil {
+			return err
+		}
+		if !*stream {
+			response, err := c.AskClaude(ctx, req)
+			if err != nil {
+				return err
+			}
+			return g.print(response, nil, askRows(response))
+		}
+
+		// JSON output is one event per line, like the server sends them
+		type event struct {
+			Type     string                       `json:"type"`
+			Text     string                       `json:"text,omitempty"`
+			Response *transport.AskClaudeResponse `json:"response,omitempty"`
+		}
+		enc := json.NewEncoder(g.stdout)
+		response, err := c.AskClaudeStream(ctx, req, func(text string) error {
+			if g.output == formatJSON {
+				return enc.Encode(event{Type: "delta", Text: text})
+			}
+			_, err := fmt.Fprint(g.stdout, text)
+			return err
+		})
+		if g.output != formatJSON {
+			fmt.Fprintln(g.stdout)
+		}
+		if err != nil {
+			return err
+		}
+		if g.output == formatJSON {
+			return enc.Encode(event{Type: "done", Response: &response})
+		}
+		// The answer is on stdout already, the details go to stderr so that
+		// the output can be piped
+		details := *g
+		details.stdout = os.Stderr
+		return details.print(response, nil, askRows(response)[1:])
+	}
+}
+
+func askRows(response transport.AskClaudeResponse) [][]string {
+	rows := [][]string{
+		{"answer", response.Answer},
+		{"model", response.Model},
+		{"stop reason", response.StopReason},
+	}
+	if response.Usage != nil {
+		rows = append(rows,
+			[]string{"input tokens", strconv.Itoa(response.Usage.InputTokens)},
+			[]string{"output tokens", strconv.Itoa(response.Usage.OutputTokens)},
+		)
+	}
+	return rows
+}
+
+func batch(fs *flag.FlagSet, g *globals) func(context.Context, []string) error {
+	g.register(fs)
+	file := fs.String("f", "-", "JSON file with a batch request or an array of items, - for stdin")
+	return func(ctx context.Context, _ []string) error {
+		req, err := readBatch(*file)
+		if err != nil {
+			return err
+		}
+		c, err := g.client()
+		if err != nil {
+			return err
+		}
+		response, err := c.Batch(ctx, req)
+		if err != nil {
+			return err
+		}
+
+		rows := make([][]string, len(response.Results))
+		for i, result := range response.Results {
+			outcome := compact(result.Result)
+			if result.Error != nil {
+				outcome = result.Error.Detail
+			}
+			rows[i] = []string{strconv.Itoa(result.Index), result.ID, strconv.Itoa(result.Status), outcome}
+		}
+		if err := g.print(response, []string{"INDEX", "ID", "STATUS", "RESULT"}, rows); err != nil {
+			return err
+		}
+		if response.Failed > 0 {
+			return fmt.Errorf("%d of %d items failed", response.Failed, len(response.Results))
+		}
+		return nil
+	}
+}
+
+func readBatch(file string) (transport.BatchRequest, error) {
+	var (
+		data []byte
+		err  error
+	)
+	if file == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(file)
+	}
+	if err != nil {
+		return transport.BatchRequest{}, err
+	}
+
+	var req transport.BatchRequest
+	if trimmed := strings.TrimSpace(string(data)); strings.HasPrefix(trimmed, "[") {
+		err = json.Unmarshal(data, &req.Items)
+	} else {
+		err = json.Unmarshal(data, &req)
+	}
+	if err != nil {
+		return transport.BatchRequest{}, fmt.Errorf("%s: %w", file, err)
+	}
+	return req, nil
+}
+
+func health(fs *flag.FlagSet, g *globals) func(context.Context, []string) error {
+	g.register(fs)
+	return func(ctx context.Context, _ []string) error {
+		c, err := g.client()
+		if err != nil {
+			return err
+		}
+		healthErr := c.Health(ctx)
+		readyErr := c.Ready(ctx)
+
+		status := struct {
+			Healthy bool `json:"healthy"`
+			Ready   bool `json:"ready"`
+		}{healthErr == nil, readyErr == nil}
+		rows := [][]string{
+			{"healthy", strconv.FormatBool(status.Healthy)},
+			{"ready", strconv.FormatBool(status.Ready)},
+		}
+		if err := g.print(status, nil, rows); err != nil {
+			return err
+		}
+		return errors.Join(healthErr, readyErr)
+	}
+}
+
+func usage(fs *flag.FlagSet, g *globals) func(context.Context, []string) error {
+	g.register(fs)
+	flags := registerAsk(fs)
+	return func(ctx context.Context, args []string) error {
+		req, err := flags.request(args)
+		if err != nil {
+			return err
+		}
+		c, err := g.client()
+		if err != nil {
+			return err
+		}
+		response, err := c.CountTokens(ctx, req)
+		if err != nil {
+			return err
+		}
+		return g.print(response, nil, [][]string{{"input tokens", strconv.Itoa(response.InputTokens)}})
+	}
+}
+
+// argsOrStdin joins the arguments, or reads stdin when there are none
+func argsOrStdin(args []string) (string, error) {
+	if len(args) > 0 {
+		return strings.Join(args, " "), nil
+	}
+	data, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		return "", err
+	}
+	text := strings.TrimSpace(string(data))
+	if text == "" {
+		return "", errors.New("no text given, pass it as arguments or on stdin")
+	}
+	return text, nil
+}
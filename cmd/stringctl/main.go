@@ -0,0 +1,155 @@
+// Command stringctl runs the string service and talks to it over HTTP.
+//
+//	stringctl <command> [flags] [args]
+//
+// Run stringctl help for the list of commands.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"kit-fiber-example/client"
+	"kit-fiber-example/problem"
+	"kit-fiber-example/transport"
+)
+
+// command registers its flags on fs and returns the function running it
+type command struct {
+	name    string
+	args    string
+	summary string
+	setup   func(fs *flag.FlagSet, g *globals) func(ctx context.Context, args []string) error
+}
+
+var commands = []command{
+	{"serve", "", "Run the service", serve},
+	{"validate-config", "", "Check that the config can be loaded and the service built from it", validateConfig},
+	{"uppercase", "<text>", "Convert text to upper case", uppercase},
+	{"ask", "<question>", "Ask Claude, -stream prints the answer as it arrives", ask},
+	{"batch", "", "Run the items of a batch request file", batch},
+	{"health", "", "Check that the server is alive and ready", health},
+	{"usage", "<question>", "Count the input tokens a question would use", usage},
+}
+
+// globals are the flags shared by the commands calling the server
+type globals struct {
+	server  string
+	output  string
+	timeout time.Duration
+	tenant  string
+	stdout  io.Writer
+}
+
+func (g *globals) register(fs *flag.FlagSet) {
+	server := os.Getenv("STRINGCTL_SERVER")
+	if server == "" {
+		server = "http://localhost:3000"
+	}
+	fs.StringVar(&g.server, "server", server, "server URL, defaults to $STRINGCTL_SERVER")
+	fs.StringVar(&g.output, "o", formatTable, "output format, table or json")
+	fs.DurationVar(&g.timeout, "timeout", 2*time.Minute, "request timeout")
+	fs.StringVar(&g.tenant, "tenant", "", "tenant sent in the "+transport.HeaderTenant+" header")
+}
+
+func (g *globals) client() (*client.Client, error) {
+	var options []client.Option
+	if g.tenant != "" {
+		options = append(options, client.SetHeader(transport.HeaderTenant, g.tenant))
+	}
+	return client.New(g.server, options...)
+}
+
+func main() {
+	os.Exit(run(os.Args[1:]))
+}
+
+func run(args []string) int {
+	if len(args) == 0 || args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		printUsage(os.Stdout)
+		return 0
+	}
+
+	var cmd *command
+	for i := range commands {
+		if commands[i].name == args[0] {
+			cmd = &commands[i]
+		}
+	}
+	if cmd == nil {
+		fmt.Fprintf(os.Stderr, "stringctl: unknown command %q\n\n", args[0])
+		printUsage(os.Stderr)
+		return 2
+	}
+
+	g := &globals{stdout: os.Stdout}
+	fs := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: stringctl %s [flags] %s\n\n%s.\n\nFlags:\n", cmd.name, cmd.args, cmd.summary)
+		fs.PrintDefaults()
+	}
+	runCmd := cmd.setup(fs, g)
+	if err := fs.Parse(args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+	if g.output != "" && g.output != formatTable && g.output != formatJSON {
+		fmt.Fprintf(os.Stderr, "stringctl: unknown output format %q, use table or json\n", g.output)
+		return 2
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	if g.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, g.timeout)
+		defer cancel()
+	}
+
+	if err := runCmd(ctx, fs.Args()); err != nil {
+		printError(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: stringctl <command> [flags] [args]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-16s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Run stringctl <command> -h for the flags of a command.")
+}
+
+// printError shows problems the server sent with their code and request id,
+// which is what operators search the logs for
+func printError(w io.Writer, err error) {
+	var pe *problem.Error
+	if !errors.As(err, &pe) {
+		fmt.Fprintf(w, "stringctl: %v\n", err)
+		return
+	}
+	fmt.Fprintf(w, "stringctl: %s (%s, status %d)\n", pe.Error(), pe.Code, pe.HTTPStatus())
+	if pe.RequestID != "" {
+		fmt.Fprintf(w, "request id: %s\n", pe.RequestID)
+	}
+	if fields, ok := pe.Extensions["fields"].([]any); ok {
+		for _, f := range fields {
+			if field, ok := f.(map[string]any); ok {
+				fmt.Fprintf(w, "  %v: %v\n", field["field"], field["message"])
+			}
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"text/tabwriter"
+)
+
+// Output formats
+const (
+	formatTable = "table"
+	formatJSON  = "json"
+)
+
+// print writes v as indented JSON, or the rows as a table with the header
+func (g *globals) print(v any, header []string, rows [][]string) error {
+	if g.output == formatJSON {
+		enc := json.NewEncoder(g.stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	}
+
+	tw := tabwriter.NewWriter(g.stdout, 0, 0, 2, ' ', 0)
+	if header != nil {
+		writeRow(tw, header)
+	}
+	for _, row := range rows {
+		writeRow(tw, row)
+	}
+	return tw.Flush()
+}
+
+func writeRow(tw *tabwriter.Writer, row []string) {
+	for i, cell := range row {
+		if i > 0 {
+			fmt.Fprint(tw, "\t")
+		}
+		fmt.Fprint(tw, cell)
+	}
+	fmt.Fprintln(tw)
+}
+
+// compact renders a value of any shape on one line for table cells
+func compact(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(data)
+}
@@ -0,0 +1,89 @@
+package codec
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// MessagePack is application/msgpack. Byte strings decode to what JSON
+// has for []byte: base64 strings.
+var MessagePack Codec = bridge{"application/msgpack", msgpackTranscoder{}}
+
+// CBOR is application/cbor, byte strings are handled like in MessagePack
+var CBOR Codec = bridge{"application/cbor", cborTranscoder{}}
+
+type msgpackTranscoder struct{}
+
+func (msgpackTranscoder) fromJSON(data []byte, _ any) ([]byte, error) {
+	v, err := decodeGeneric(data)
+	if err != nil {
+		return nil, err
+	}
+	return msgpack.Marshal(v)
+}
+
+func (msgpackTranscoder) toJSON(data []byte, _ any) ([]byte, error) {
+	var v any
+	if err := msgpack.Unmarshal(data, &v); err != nil {
+		return nil, err
+	}
+	return marshalGeneric(v)
+}
+
+var (
+	cborEncMode, _ = cbor.CoreDetEncOptions().EncMode()
+	cborDecMode, _ = cbor.DecOptions{
+		DefaultMapType: reflect.TypeOf(map[string]any(nil)),
+	}.DecMode()
+)
+
+type cborTranscoder struct{}
+
+func (cborTranscoder) fromJSON(data []byte, _ any) ([]byte, error) {
+	v, err := decodeGeneric(data)
+	if err != nil {
+		return nil, err
+	}
+	return cborEncMode.Marshal(v)
+}
+
+func (cborTranscoder) toJSON(data []byte, _ any) ([]byte, error) {
+	var v any
+	if err := cborDecMode.Unmarshal(data, &v); err != nil {
+		return nil, err
+	}
+	return marshalGeneric(v)
+}
+
+// marshalGeneric writes a decoded binary value as JSON, rejecting map keys
+// JSON can't have
+func marshalGeneric(v any) ([]byte, error) {
+	if err := checkKeys(v); err != nil {
+		return nil, err
+	}
+	return json.Marshal(v)
+}
+
+func checkKeys(v any) error {
+	switch v := v.(type) {
+	case map[string]any:
+		for _, e := range v {
+			if err := checkKeys(e); err != nil {
+				return err
+			}
+		}
+	case []any:
+		for _, e := range v {
+			if err := checkKeys(e); err != nil {
+				return err
+			}
+		}
+	case map[any]any:
+		return fmt.Errorf("map keys must be strings")
+	}
+	return nil
+}
@@ -0,0 +1,179 @@
+// Package codec encodes request and response bodies in the media types the
+// API speaks: JSON, MessagePack, CBOR and protobuf.
+//
+// Every codec shares the JSON data model. Values are encoded as if they were
+// marshalled to JSON first, and bodies decode to the JSON the client could
+// have sent instead, so json tags, custom marshalers and the strict decoding
+// of the validation package behave the same whatever the media type.
+package codec
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"sort"
+	"strconv"
+	"strings"
+
+	"kit-fiber-example/validation"
+)
+
+var (
+	ErrUnsupportedMediaType = errors.New("unsupported media type")
+	ErrNotAcceptable        = errors.New("none of the accepted media types can be produced")
+)
+
+type Codec interface {
+	// MediaType is the canonical media type, used in Content-Type
+	MediaType() string
+	Marshal(v any) ([]byte, error)
+	// Unmarshal ignores unknown fields, for clients reading responses
+	Unmarshal(data []byte, v any) error
+	// UnmarshalStrict rejects unknown fields and values of the wrong type
+	// like validation.DecodeJSON, for servers reading requests
+	UnmarshalStrict(data []byte, v any) error
+}
+
+// transcoder converts between JSON and another encoding. v is the Go value
+// being encoded or decoded, for encodings that need its type.
+type transcoder interface {
+	fromJSON(data []byte, v any) ([]byte, error)
+	toJSON(data []byte, v any) ([]byte, error)
+}
+
+type bridge struct {
+	mediaType string
+	transcoder
+}
+
+func (b bridge) MediaType() string {
+	return b.mediaType
+}
+
+func (b bridge) Marshal(v any) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return b.fromJSON(data, v)
+}
+
+func (b bridge) Unmarshal(data []byte, v any) error {
+	j, err := b.toJSON(data, v)
+	if err != nil {
+		return fmt.Errorf("%w: %v", validation.ErrMalformed, err)
+	}
+	return json.Unmarshal(j, v)
+}
+
+func (b bridge) UnmarshalStrict(data []byte, v any) error {
+	j, err := b.toJSON(data, v)
+	if err != nil {
+		return fmt.Errorf("%w: %v", validation.ErrMalformed, err)
+	}
+	return validation.DecodeJSON(j, v)
+}
+
+// Registry finds codecs by media type. The first registered codec is the
+// default, used without Content-Type and for Accept: */*.
+type Registry struct {
+	codecs []Codec
+	byType map[string]Codec
+}
+
+func NewRegistry() *Registry {
+	return &Registry{byType: make(map[string]Codec)}
+}
+
+// Register adds c under its media type and the given aliases
+func (r *Registry) Register(c Codec, aliases ...string) {
+	r.codecs = append(r.codecs, c)
+	r.byType[c.MediaType()] = c
+	for _, alias := range aliases {
+		r.byType[alias] = c
+	}
+}
+
+// MediaTypes lists the canonical media types in registration order
+func (r *Registry) MediaTypes() []string {
+	types := make([]string, len(r.codecs))
+	for i, c := range r.codecs {
+		types[i] = c.MediaType()
+	}
+	return types
+}
+
+// ForContentType returns the codec of a Content-Type header. An empty
+// header means the default codec.
+func (r *Registry) ForContentType(contentType string) (Codec, error) {
+	if contentType == "" {
+		return r.codecs[0], nil
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedMediaType, contentType)
+	}
+	if c, ok := r.byType[mediaType]; ok {
+		return c, nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnsupportedMediaType, mediaType)
+}
+
+// Negotiate picks the codec for an Accept header, honouring q-values and
+// wildcards. An empty header accepts the default codec.
+func (r *Registry) Negotiate(accept string) (Codec, error) {
+	if strings.TrimSpace(accept) == "" {
+		return r.codecs[0], nil
+	}
+
+	type candidate struct {
+		mediaType string
+		q         float64
+	}
+	var candidates []candidate
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		if q > 0 {
+			candidates = append(candidates, candidate{mediaType, q})
+		}
+	}
+	// Equal q-values keep the client's order
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].q > candidates[j].q })
+
+	for _, cand := range candidates {
+		if c, ok := r.byType[cand.mediaType]; ok {
+			return c, nil
+		}
+		if cand.mediaType == "*/*" {
+			return r.codecs[0], nil
+		}
+		if prefix, ok := strings.CutSuffix(cand.mediaType, "/*"); ok {
+			for _, c := range r.codecs {
+				if strings.HasPrefix(c.MediaType(), prefix+"/") {
+					return c, nil
+				}
+			}
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrNotAcceptable, accept)
+}
+
+// Default has every codec of this package, JSON first
+var Default = func() *Registry {
+	r := NewRegistry()
+	r.Register(JSON)
+	r.Register(MessagePack, "application/x-msgpack", "application/vnd.msgpack")
+	r.Register(CBOR)
+	r.Register(Protobuf, "application/protobuf", "application/vnd.google.protobuf")
+	return r
+}()
@@ -0,0 +1,198 @@
+package codec
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"kit-fiber-example/validation"
+)
+
+type testItem struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+type testMessage struct {
+	Text        string         `json:"text"`
+	Temperature *float64       `json:"temperature,omitempty"`
+	Ratio       float64        `json:"ratio"`
+	Tags        []string       `json:"tags,omitempty"`
+	Items       []testItem     `json:"items,omitempty"`
+	Next        *testItem      `json:"next,omitempty"`
+	Data        []byte         `json:"data,omitempty"`
+	Meta        map[string]any `json:"meta,omitempty"`
+	Value       any            `json:"value,omitempty"`
+	At          time.Time      `json:"at"`
+	Done        bool           `json:"done"`
+}
+
+func TestRoundTrip(t *testing.T) {
+	zero := 0.0
+	messages := []struct {
+		name string
+		msg  testMessage
+	}{
+		{"zero value", testMessage{At: time.Unix(0, 0).UTC()}},
+		{"scalars", testMessage{
+			Text:  "héllo, 世界",
+			Ratio: 0.25,
+			At:    time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC),
+			Done:  true,
+		}},
+		{"optional zero", testMessage{Temperature: &zero, At: time.Unix(0, 0).UTC()}},
+		{"nested", testMessage{
+			Tags:  []string{"a", "b"},
+			Items: []testItem{{"x", 1}, {"y", -2}},
+			Next:  &testItem{"z", 3},
+			Data:  []byte{0, 1, 2, 255},
+			Meta:  map[string]any{"n": 1.5, "s": "v", "list": []any{true, "x"}},
+			Value: "any",
+			At:    time.Unix(0, 0).UTC(),
+		}},
+	}
+	for _, c := range Default.codecs {
+		for _, m := range messages {
+			t.Run(c.MediaType()+"/"+m.name, func(t *testing.T) {
+				data, err := c.Marshal(m.msg)
+				if err != nil {
+					t.Fatal(err)
+				}
+				var got testMessage
+				if err := c.UnmarshalStrict(data, &got); err != nil {
+					t.Fatal(err)
+				}
+				if !reflect.DeepEqual(got, m.msg) {
+					t.Errorf("got %+v, want %+v", got, m.msg)
+				}
+			})
+		}
+	}
+}
+
+func TestUnmarshalStrict(t *testing.T) {
+	type extra struct {
+		Text  string `json:"text"`
+		Extra string `json:"extra"`
+	}
+	type wrongType struct {
+		Text int `json:"text"`
+	}
+	// Protobuf has no unknown members, the schema of the target decides
+	for _, c := range []Codec{JSON, MessagePack, CBOR} {
+		t.Run(c.MediaType(), func(t *testing.T) {
+			for _, v := range []any{extra{"a", "b"}, wrongType{1}} {
+				data, err := c.Marshal(v)
+				if err != nil {
+					t.Fatal(err)
+				}
+				var got testItem
+				if err := c.UnmarshalStrict(data, &got); err == nil {
+					t.Errorf("UnmarshalStrict(%+v) succeeded", v)
+				}
+			}
+
+			data, _ := c.Marshal(extra{"a", "b"})
+			var got struct {
+				Text string `json:"text"`
+			}
+			if err := c.Unmarshal(data, &got); err != nil || got.Text != "a" {
+				t.Errorf("Unmarshal = %+v, %v", got, err)
+			}
+		})
+	}
+
+	for _, c := range Default.codecs {
+		t.Run(c.MediaType()+"/malformed", func(t *testing.T) {
+			var got testItem
+			if err := c.UnmarshalStrict([]byte{0xc1, 0xff, 0x00}, &got); !errors.Is(err, validation.ErrMalformed) {
+				t.Errorf("UnmarshalStrict = %v, want ErrMalformed", err)
+			}
+		})
+	}
+}
+
+func TestForContentType(t *testing.T) {
+	tests := []struct {
+		contentType string
+		want        Codec
+		err         error
+	}{
+		{"", JSON, nil},
+		{"application/json", JSON, nil},
+		{"application/json; charset=utf-8", JSON, nil},
+		{"application/x-msgpack", MessagePack, nil},
+		{"application/cbor", CBOR, nil},
+		{"application/vnd.google.protobuf", Protobuf, nil},
+		{"text/plain", nil, ErrUnsupportedMediaType},
+		{"not a media type;", nil, ErrUnsupportedMediaType},
+	}
+	for _, tt := range tests {
+		t.Run(tt.contentType, func(t *testing.T) {
+			got, err := Default.ForContentType(tt.contentType)
+			if !errors.Is(err, tt.err) || got != tt.want {
+				t.Errorf("ForContentType() = %v, %v, want %v, %v", got, err, tt.want, tt.err)
+			}
+		})
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		accept string
+		want   Codec
+		err    error
+	}{
+		{"", JSON, nil},
+		{"*/*", JSON, nil},
+		{"application/cbor", CBOR, nil},
+		{"text/html, application/msgpack", MessagePack, nil},
+		{"application/json;q=0.5, application/cbor", CBOR, nil},
+		{"application/cbor, application/msgpack", CBOR, nil},
+		{"application/x-protobuf;q=0.9, */*;q=0.1", Protobuf, nil},
+		{"application/*", JSON, nil},
+		{"application/cbor;q=0, application/json", JSON, nil},
+		{"application/json;q=oops", nil, ErrNotAcceptable},
+		{"text/html", nil, ErrNotAcceptable},
+		{"application/cbor;q=0", nil, ErrNotAcceptable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.accept, func(t *testing.T) {
+			got, err := Default.Negotiate(tt.accept)
+			if !errors.Is(err, tt.err) || got != tt.want {
+				t.Errorf("Negotiate() = %v, %v, want %v, %v", got, err, tt.want, tt.err)
+			}
+		})
+	}
+}
+
+func TestProtoSchema(t *testing.T) {
+	s, err := ProtoSchema(testMessage{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"package stringservice.v1;",
+		"message testMessage {",
+		"  optional double temperature = 2;",
+		"  repeated testItem items = 5;",
+		"  google.protobuf.Struct meta = 8;",
+		"  google.protobuf.Timestamp at = 10;",
+	} {
+		if !strings.Contains(s, want) {
+			t.Errorf("schema has no %q:\n%s", want, s)
+		}
+	}
+
+	if _, err := ProtoSchema(struct{ A [][]string }{}); err == nil {
+		t.Error("ProtoSchema of an anonymous struct succeeded")
+	}
+	type nested struct {
+		A [][]string `json:"a"`
+	}
+	if _, err := ProtoSchema(nested{}); err == nil {
+		t.Error("ProtoSchema of nested lists succeeded")
+	}
+}
@@ -0,0 +1,51 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// JSON is application/json
+var JSON Codec = bridge{"application/json", identity{}}
+
+type identity struct{}
+
+func (identity) fromJSON(data []byte, _ any) ([]byte, error) {
+	return data, nil
+}
+
+func (identity) toJSON(data []byte, _ any) ([]byte, error) {
+	return data, nil
+}
+
+// decodeGeneric decodes JSON into maps, slices and scalars. Numbers are
+// int64 when they are integers, so binary encodings keep them compact.
+func decodeGeneric(data []byte) (any, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	return convertNumbers(v), nil
+}
+
+func convertNumbers(v any) any {
+	switch v := v.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+	case map[string]any:
+		for k, e := range v {
+			v[k] = convertNumbers(e)
+		}
+	case []any:
+		for i, e := range v {
+			v[i] = convertNumbers(e)
+		}
+	}
+	return v
+}
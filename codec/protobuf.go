@@ -0,0 +1,337 @@
+package codec
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+
+	// Imported by the generated schema
+	_ "google.golang.org/protobuf/types/known/structpb"
+	_ "google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// ProtoPackage is the protobuf package of the generated messages
+const ProtoPackage = "stringservice.v1"
+
+// Protobuf is application/x-protobuf. Messages are derived from the Go
+// types: one message per struct, named after it, with a field per JSON
+// member numbered in declaration order. Fields must only ever be appended
+// to keep the numbers stable. Any JSON value maps to google.protobuf.Value,
+// maps to google.protobuf.Struct and time.Time to google.protobuf.Timestamp.
+var Protobuf Codec = bridge{"application/x-protobuf", protoTranscoder{}}
+
+var schema = newProtoSchema()
+
+// ProtoSchema renders the .proto file of the messages of the given values
+func ProtoSchema(values ...any) (string, error) {
+	for _, v := range values {
+		if _, err := schema.descriptor(reflect.TypeOf(v)); err != nil {
+			return "", err
+		}
+	}
+	return schema.render(), nil
+}
+
+type protoTranscoder struct{}
+
+func (protoTranscoder) fromJSON(data []byte, v any) ([]byte, error) {
+	desc, err := schema.descriptor(reflect.TypeOf(v))
+	if err != nil {
+		return nil, err
+	}
+	msg := dynamicpb.NewMessage(desc)
+	// Members outside the schema, like problem extensions, are dropped
+	if err := (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(data, msg); err != nil {
+		return nil, err
+	}
+	return proto.Marshal(msg)
+}
+
+func (protoTranscoder) toJSON(data []byte, v any) ([]byte, error) {
+	desc, err := schema.descriptor(reflect.TypeOf(v))
+	if err != nil {
+		return nil, err
+	}
+	msg := dynamicpb.NewMessage(desc)
+	if err := proto.Unmarshal(data, msg); err != nil {
+		return nil, err
+	}
+	return protojson.MarshalOptions{UseProtoNames: true}.Marshal(msg)
+}
+
+var (
+	rawMessageType = reflect.TypeOf(json.RawMessage(nil))
+	timeType       = reflect.TypeOf(time.Time{})
+)
+
+// protoSchema grows one file with the messages of every type seen so far
+type protoSchema struct {
+	mu    sync.Mutex
+	file  *descriptorpb.FileDescriptorProto
+	fd    protoreflect.FileDescriptor
+	names map[reflect.Type]string
+	taken map[string]bool
+}
+
+func newProtoSchema() *protoSchema {
+	return &protoSchema{
+		file: &descriptorpb.FileDescriptorProto{
+			Name:       proto.String("stringservice.proto"),
+			Package:    proto.String(ProtoPackage),
+			Syntax:     proto.String("proto3"),
+			Dependency: []string{"google/protobuf/struct.proto", "google/protobuf/timestamp.proto"},
+		},
+		names: make(map[reflect.Type]string),
+		taken: make(map[string]bool),
+	}
+}
+
+func (s *protoSchema) descriptor(t reflect.Type) (protoreflect.MessageDescriptor, error) {
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct || t == timeType {
+		return nil, fmt.Errorf("protobuf: %v is not a message", t)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if name, ok := s.names[t]; ok && s.fd != nil {
+		if md := s.fd.Messages().ByName(protoreflect.Name(name)); md != nil {
+			return md, nil
+		}
+	}
+
+	// Work on a copy, so that a type that can't be described leaves no trace
+	file := proto.Clone(s.file).(*descriptorpb.FileDescriptorProto)
+	names := make(map[reflect.Type]string, len(s.names))
+	for k, v := range s.names {
+		names[k] = v
+	}
+	taken := make(map[string]bool, len(s.taken))
+	for k, v := range s.taken {
+		taken[k] = v
+	}
+	b := &protoBuilder{file: file, names: names, taken: taken}
+	name, err := b.message(t)
+	if err != nil {
+		return nil, err
+	}
+	fd, err := protodesc.NewFile(file, protoregistry.GlobalFiles)
+	if err != nil {
+		return nil, fmt.Errorf("protobuf: %v: %w", t, err)
+	}
+
+	s.file, s.fd, s.names, s.taken = file, fd, names, taken
+	return fd.Messages().ByName(protoreflect.Name(name)), nil
+}
+
+type protoBuilder struct {
+	file  *descriptorpb.FileDescriptorProto
+	names map[reflect.Type]string
+	taken map[string]bool
+}
+
+// message adds the message of struct t and returns its name
+func (b *protoBuilder) message(t reflect.Type) (string, error) {
+	if name, ok := b.names[t]; ok {
+		return name, nil
+	}
+	name := t.Name()
+	if name == "" {
+		return "", fmt.Errorf("protobuf: anonymous struct %v", t)
+	}
+	if b.taken[name] {
+		// Same name in another package
+		pkg := t.PkgPath()
+		pkg = pkg[strings.LastIndex(pkg, "/")+1:]
+		name = strings.ToUpper(pkg[:1]) + pkg[1:] + name
+	}
+	b.names[t] = name
+	b.taken[name] = true
+
+	// Added before its fields, the struct may refer to itself
+	msg := &descriptorpb.DescriptorProto{Name: proto.String(name)}
+	b.file.MessageType = append(b.file.MessageType, msg)
+	return name, b.addFields(msg, t)
+}
+
+func (b *protoBuilder) addFields(msg *descriptorpb.DescriptorProto, t reflect.Type) error {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" || (!f.IsExported() && !f.Anonymous) {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+
+		// Embedded structs without a name are flattened, like encoding/json does
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				if err := b.addFields(msg, ft); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		if f.Type.Kind() == reflect.Func || f.Type.Kind() == reflect.Chan {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		if !protoreflect.Name(name).IsValid() {
+			return fmt.Errorf("protobuf: %v.%s: %q is not a valid field name", t, f.Name, name)
+		}
+
+		field := &descriptorpb.FieldDescriptorProto{
+			Name:     proto.String(name),
+			JsonName: proto.String(name),
+			Number:   proto.Int32(int32(len(msg.Field) + 1)),
+			Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+		}
+		ft := f.Type
+		if ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+			if scalar(ft) {
+				// proto3 optional keeps the presence of pointers, like temperature 0
+				field.Proto3Optional = proto.Bool(true)
+				field.OneofIndex = proto.Int32(int32(len(msg.OneofDecl)))
+				msg.OneofDecl = append(msg.OneofDecl, &descriptorpb.OneofDescriptorProto{Name: proto.String("_" + name)})
+			}
+		}
+		if (ft.Kind() == reflect.Slice || ft.Kind() == reflect.Array) && ft != rawMessageType && ft.Elem().Kind() != reflect.Uint8 {
+			field.Label = descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum()
+			ft = ft.Elem()
+			for ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+		}
+		if err := b.setType(field, ft); err != nil {
+			return fmt.Errorf("protobuf: %v.%s: %w", t, f.Name, err)
+		}
+		msg.Field = append(msg.Field, field)
+	}
+	return nil
+}
+
+func (b *protoBuilder) setType(field *descriptorpb.FieldDescriptorProto, t reflect.Type) error {
+	message := func(name string) {
+		field.Type = descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum()
+		field.TypeName = proto.String(name)
+	}
+
+	switch {
+	case t == rawMessageType || t.Kind() == reflect.Interface:
+		message(".google.protobuf.Value")
+		return nil
+	case t == timeType:
+		message(".google.protobuf.Timestamp")
+		return nil
+	}
+
+	var typ descriptorpb.FieldDescriptorProto_Type
+	switch t.Kind() {
+	case reflect.Bool:
+		typ = descriptorpb.FieldDescriptorProto_TYPE_BOOL
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32:
+		// int64 would be a string in the JSON mapping
+		typ = descriptorpb.FieldDescriptorProto_TYPE_INT32
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		typ = descriptorpb.FieldDescriptorProto_TYPE_UINT32
+	case reflect.Float32:
+		typ = descriptorpb.FieldDescriptorProto_TYPE_FLOAT
+	case reflect.Float64:
+		typ = descriptorpb.FieldDescriptorProto_TYPE_DOUBLE
+	case reflect.String:
+		typ = descriptorpb.FieldDescriptorProto_TYPE_STRING
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() != reflect.Uint8 || field.GetLabel() == descriptorpb.FieldDescriptorProto_LABEL_REPEATED {
+			return fmt.Errorf("nested lists are not supported")
+		}
+		typ = descriptorpb.FieldDescriptorProto_TYPE_BYTES
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String || field.GetLabel() == descriptorpb.FieldDescriptorProto_LABEL_REPEATED {
+			return fmt.Errorf("only maps with string keys are supported")
+		}
+		message(".google.protobuf.Struct")
+		return nil
+	case reflect.Struct:
+		name, err := b.message(t)
+		if err != nil {
+			return err
+		}
+		message("." + ProtoPackage + "." + name)
+		return nil
+	default:
+		return fmt.Errorf("%v has no protobuf type", t)
+	}
+	field.Type = typ.Enum()
+	return nil
+}
+
+func scalar(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Bool, reflect.String, reflect.Float32, reflect.Float64,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return true
+	}
+	return false
+}
+
+// render writes the schema as a .proto file for clients
+func (s *protoSchema) render() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "syntax = %q;\n\npackage %s;\n\n", s.file.GetSyntax(), s.file.GetPackage())
+	for _, dep := range s.file.Dependency {
+		fmt.Fprintf(&sb, "import %q;\n", dep)
+	}
+	// Sorted, the file grows in the order types are first used
+	messages := slices.Clone(s.file.MessageType)
+	slices.SortFunc(messages, func(a, b *descriptorpb.DescriptorProto) int {
+		return strings.Compare(a.GetName(), b.GetName())
+	})
+	for _, msg := range messages {
+		fmt.Fprintf(&sb, "\nmessage %s {\n", msg.GetName())
+		for _, f := range msg.Field {
+			label := ""
+			switch {
+			case f.GetLabel() == descriptorpb.FieldDescriptorProto_LABEL_REPEATED:
+				label = "repeated "
+			case f.GetProto3Optional():
+				label = "optional "
+			}
+			fmt.Fprintf(&sb, "  %s%s %s = %d;\n", label, protoTypeName(f), f.GetName(), f.GetNumber())
+		}
+		sb.WriteString("}\n")
+	}
+	return sb.String()
+}
+
+func protoTypeName(f *descriptorpb.FieldDescriptorProto) string {
+	if f.GetType() == descriptorpb.FieldDescriptorProto_TYPE_MESSAGE {
+		name := strings.TrimPrefix(f.GetTypeName(), ".")
+		return strings.TrimPrefix(name, ProtoPackage+".")
+	}
+	return strings.ToLower(strings.TrimPrefix(f.GetType().String(), "TYPE_"))
+}
@@ -0,0 +1,140 @@
+package concurrency
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrQueueFull is returned when both the limit and the wait queue are exhausted.
+	ErrQueueFull = errors.New("concurrency limit exceeded: queue is full")
+	// ErrQueueTimeout is returned when a request waited in the queue for too long.
+	ErrQueueTimeout = errors.New("concurrency limit exceeded: queue timeout")
+)
+
+// Bulkhead bounds the number of requests running at the same time. Requests
+// over the limit wait in a bounded FIFO queue; requests that don't fit into
+// the queue or wait longer than the queue timeout are rejected.
+type Bulkhead struct {
+	mu       sync.Mutex
+	limit    Limit
+	inflight int
+	waiters  *list.List // of chan struct{}
+
+	maxQueue     int
+	queueTimeout time.Duration
+}
+
+func NewBulkhead(limit Limit, maxQueue int, queueTimeout time.Duration) *Bulkhead {
+	return &Bulkhead{
+		limit:        limit,
+		waiters:      list.New(),
+		maxQueue:     maxQueue,
+		queueTimeout: queueTimeout,
+	}
+}
+
+// Token is a granted slot. Exactly one of Release or Drop must be called
+// when the request finishes.
+type Token struct {
+	b        *Bulkhead
+	start    time.Time
+	inflight int
+}
+
+// Release returns the slot and feeds the request latency to the limit algorithm.
+func (t *Token) Release() {
+	t.b.release(time.Since(t.start), t.inflight, false)
+}
+
+// Drop returns the slot and reports the request as dropped (e.g. timed out
+// or overloaded upstream), which makes adaptive limits back off.
+func (t *Token) Drop() {
+	t.b.release(time.Since(t.start), t.inflight, true)
+}
+
+// Acquire blocks until a slot is available, the queue timeout expires or ctx is done.
+func (b *Bulkhead) Acquire(ctx context.Context) (*Token, error) {
+	b.mu.Lock()
+	if b.inflight < b.limit.Limit() {
+		b.inflight++
+		t := b.newToken()
+		b.mu.Unlock()
+		return t, nil
+	}
+	if b.waiters.Len() >= b.maxQueue {
+		b.mu.Unlock()
+		return nil, ErrQueueFull
+	}
+	ready := make(chan struct{})
+	elem := b.waiters.PushBack(ready)
+	b.mu.Unlock()
+
+	var timeout <-chan time.Time
+	if b.queueTimeout > 0 {
+		timer := time.NewTimer(b.queueTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	var err error
+	select {
+	case <-ready:
+	case <-timeout:
+		err = ErrQueueTimeout
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	select {
+	case <-ready:
+		// The slot was handed over to us concurrently with the timeout,
+		// so take it anyway instead of leaking it.
+		return b.newToken(), nil
+	default:
+		b.waiters.Remove(elem)
+		return nil, err
+	}
+}
+
+// Inflight returns the number of requests holding a slot.
+func (b *Bulkhead) Inflight() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.inflight
+}
+
+// Queued returns the number of requests waiting for a slot.
+func (b *Bulkhead) Queued() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.waiters.Len()
+}
+
+// Limit returns the current concurrency limit.
+func (b *Bulkhead) Limit() int {
+	return b.limit.Limit()
+}
+
+// newToken must be called with b.mu held.
+func (b *Bulkhead) newToken() *Token {
+	return &Token{b: b, start: time.Now(), inflight: b.inflight}
+}
+
+func (b *Bulkhead) release(rtt time.Duration, inflight int, dropped bool) {
+	limit := b.limit.Update(rtt, inflight, dropped)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.inflight--
+	for b.inflight < limit && b.waiters.Len() > 0 {
+		ready := b.waiters.Remove(b.waiters.Front()).(chan struct{})
+		b.inflight++
+		close(ready)
+	}
+}
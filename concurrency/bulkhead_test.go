@@ -0,0 +1,178 @@
+package concurrency
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// waitQueued waits until n requests are queued in b
+func waitQueued(t *testing.T, b *Bulkhead, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for b.Queued() != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d queued, want %d", b.Queued(), n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestBulkheadQueue(t *testing.T) {
+	b := NewBulkhead(FixedLimit(2), 2, 0)
+	ctx := context.Background()
+
+	var running []*Token
+	for i := 0; i < 2; i++ {
+		token, err := b.Acquire(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		running = append(running, token)
+	}
+
+	// Waiters are served in order, record it
+	var (
+		mu    sync.Mutex
+		order []int
+	)
+	granted := make(chan *Token)
+	for i := 1; i <= 2; i++ {
+		go func() {
+			token, err := b.Acquire(ctx)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			mu.Lock()
+			order = append(order, i)
+			mu.Unlock()
+			granted <- token
+		}()
+		waitQueued(t, b, i)
+	}
+
+	if _, err := b.Acquire(ctx); !errors.Is(err, ErrQueueFull) {
+		t.Errorf("Acquire() with a full queue = %v, want ErrQueueFull", err)
+	}
+	if got := b.Inflight(); got != 2 {
+		t.Errorf("Inflight() = %d, want 2", got)
+	}
+
+	// Every release hands its slot over to the next waiter
+	for i, token := range running {
+		token.Release()
+		<-granted
+		if got := b.Queued(); got != 1-i {
+			t.Errorf("after release %d: Queued() = %d, want %d", i, got, 1-i)
+		}
+		if got := b.Inflight(); got != 2 {
+			t.Errorf("after release %d: Inflight() = %d, want 2", i, got)
+		}
+	}
+	mu.Lock()
+	if len(order) != 2 || order[0] != 1 || order[1] != 2 {
+		t.Errorf("granted in order %v, want [1 2]", order)
+	}
+	mu.Unlock()
+}
+
+func TestBulkheadWaitEnds(t *testing.T) {
+	tests := []struct {
+		name         string
+		queueTimeout time.Duration
+		ctxTimeout   time.Duration
+		err          error
+	}{
+		{"queue timeout", 20 * time.Millisecond, time.Minute, ErrQueueTimeout},
+		{"caller gone", time.Minute, 20 * time.Millisecond, context.DeadlineExceeded},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewBulkhead(FixedLimit(1), 1, tt.queueTimeout)
+			holder, err := b.Acquire(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), tt.ctxTimeout)
+			defer cancel()
+			start := time.Now()
+			if _, err := b.Acquire(ctx); !errors.Is(err, tt.err) {
+				t.Errorf("Acquire() = %v, want %v", err, tt.err)
+			}
+			if waited := time.Since(start); waited < 20*time.Millisecond || waited > 10*time.Second {
+				t.Errorf("waited %v", waited)
+			}
+			// The waiter left the queue, the holder's slot isn't handed to it
+			if got := b.Queued(); got != 0 {
+				t.Errorf("Queued() = %d, want 0", got)
+			}
+			holder.Release()
+			if got := b.Inflight(); got != 0 {
+				t.Errorf("Inflight() = %d, want 0", got)
+			}
+		})
+	}
+}
+
+// recordingLimit is a fixed limit that records the samples it gets
+type recordingLimit struct {
+	FixedLimit
+	mu      sync.Mutex
+	samples []bool
+}
+
+func (l *recordingLimit) Update(_ time.Duration, _ int, dropped bool) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.samples = append(l.samples, dropped)
+	return int(l.FixedLimit)
+}
+
+func TestBulkheadFeedsLimit(t *testing.T) {
+	limit := &recordingLimit{FixedLimit: 2}
+	b := NewBulkhead(limit, 0, 0)
+	for _, drop := range []bool{false, true} {
+		token, err := b.Acquire(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if drop {
+			token.Drop()
+		} else {
+			token.Release()
+		}
+	}
+	if len(limit.samples) != 2 || limit.samples[0] || !limit.samples[1] {
+		t.Errorf("samples = %v, want [false true]", limit.samples)
+	}
+}
+
+func TestBulkheadShrinkingLimit(t *testing.T) {
+	// A limit shrinking on drops doesn't hand the slot of a dropped request over
+	b := NewBulkhead(NewAIMDLimit(2, 1, 2, 0.5, 0), 1, 0)
+	first, _ := b.Acquire(context.Background())
+	second, _ := b.Acquire(context.Background())
+
+	granted := make(chan error)
+	go func() {
+		token, err := b.Acquire(context.Background())
+		if err == nil {
+			token.Release()
+		}
+		granted <- err
+	}()
+	waitQueued(t, b, 1)
+
+	first.Drop()
+	if got, want := b.Queued(), 1; got != want {
+		t.Errorf("after drop: Queued() = %d, want %d", got, want)
+	}
+	second.Release()
+	if err := <-granted; err != nil {
+		t.Fatal(err)
+	}
+}
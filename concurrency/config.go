@@ -0,0 +1,57 @@
+package concurrency
+
+import (
+	"fmt"
+	"time"
+
+	"kit-fiber-example/config"
+)
+
+// Default bulkhead used for endpoints that have no config entry.
+const (
+	defaultLimit     = 100
+	defaultQueueSize = 100
+)
+
+// NewBulkheadFromConfig builds the bulkhead for the named endpoint.
+func NewBulkheadFromConfig(cfg *config.Config, name string) (*Bulkhead, error) {
+	bc, ok := cfg.Concurrency[name]
+	if !ok {
+		return NewBulkhead(FixedLimit(defaultLimit), defaultQueueSize, 0), nil
+	}
+
+	limit := bc.Limit
+	if limit <= 0 {
+		limit = defaultLimit
+	}
+
+	queueTimeout, err := parseDuration(bc.QueueTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("concurrency.%s.queueTimeout: %w", name, err)
+	}
+
+	var l Limit
+	switch bc.Adaptive.Algorithm {
+	case "":
+		l = FixedLimit(limit)
+	case "aimd":
+		threshold, err := parseDuration(bc.Adaptive.LatencyThreshold)
+		if err != nil {
+			return nil, fmt.Errorf("concurrency.%s.adaptive.latencyThreshold: %w", name, err)
+		}
+		l = NewAIMDLimit(limit, bc.Adaptive.MinLimit, bc.Adaptive.MaxLimit, bc.Adaptive.BackoffRatio, threshold)
+	case "gradient":
+		l = NewGradientLimit(limit, bc.Adaptive.MinLimit, bc.Adaptive.MaxLimit, bc.Adaptive.Smoothing)
+	default:
+		return nil, fmt.Errorf("concurrency.%s.adaptive.algorithm: unknown algorithm %q", name, bc.Adaptive.Algorithm)
+	}
+
+	return NewBulkhead(l, bc.QueueSize, queueTimeout), nil
+}
+
+func parseDuration(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+	return time.ParseDuration(s)
+}
@@ -0,0 +1,161 @@
+package concurrency
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// Limit is an algorithm that computes the concurrency limit of a Bulkhead
+// from the samples it observes.
+type Limit interface {
+	// Limit returns the current limit.
+	Limit() int
+	// Update records a sample and returns the new limit. rtt is the latency
+	// of the finished request, inflight the number of requests in flight when
+	// it started and dropped reports whether it was shed or timed out upstream.
+	Update(rtt time.Duration, inflight int, dropped bool) int
+}
+
+// FixedLimit is a Limit that never changes.
+type FixedLimit int
+
+// Limit implements Limit.
+func (l FixedLimit) Limit() int {
+	return int(l)
+}
+
+// Update implements Limit.
+func (l FixedLimit) Update(time.Duration, int, bool) int {
+	return int(l)
+}
+
+// AIMDLimit grows the limit by one while requests finish below the latency
+// threshold and shrinks it multiplicatively on drops or slow requests.
+type AIMDLimit struct {
+	mu sync.Mutex
+
+	limit        int
+	minLimit     int
+	maxLimit     int
+	backoffRatio float64
+	threshold    time.Duration
+}
+
+func NewAIMDLimit(initial, minLimit, maxLimit int, backoffRatio float64, threshold time.Duration) *AIMDLimit {
+	if backoffRatio <= 0 || backoffRatio >= 1 {
+		backoffRatio = 0.9
+	}
+	return &AIMDLimit{
+		limit:        clamp(initial, minLimit, maxLimit),
+		minLimit:     minLimit,
+		maxLimit:     maxLimit,
+		backoffRatio: backoffRatio,
+		threshold:    threshold,
+	}
+}
+
+// Limit implements Limit.
+func (l *AIMDLimit) Limit() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.limit
+}
+
+// Update implements Limit.
+func (l *AIMDLimit) Update(rtt time.Duration, inflight int, dropped bool) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	switch {
+	case dropped || (l.threshold > 0 && rtt > l.threshold):
+		l.limit = int(float64(l.limit) * l.backoffRatio)
+	case inflight*2 >= l.limit:
+		// Only grow when the limit is actually being used
+		l.limit++
+	}
+	l.limit = clamp(l.limit, l.minLimit, l.maxLimit)
+	return l.limit
+}
+
+// GradientLimit adjusts the limit by the ratio between the best observed
+// latency and the current one, in the style of Netflix concurrency-limits.
+// While latency stays close to the minimum the limit grows by a queue
+// allowance of sqrt(limit); once queueing shows up in latency it shrinks.
+type GradientLimit struct {
+	mu sync.Mutex
+
+	limit     float64
+	minLimit  int
+	maxLimit  int
+	smoothing float64
+	minRTT    time.Duration
+	// minRTT is periodically reset so that the limit can recover after the
+	// upstream became permanently slower.
+	probeEvery int
+	samples    int
+}
+
+func NewGradientLimit(initial, minLimit, maxLimit int, smoothing float64) *GradientLimit {
+	if smoothing <= 0 || smoothing > 1 {
+		smoothing = 0.2
+	}
+	return &GradientLimit{
+		limit:      float64(clamp(initial, minLimit, maxLimit)),
+		minLimit:   minLimit,
+		maxLimit:   maxLimit,
+		smoothing:  smoothing,
+		probeEvery: 1000,
+	}
+}
+
+// Limit implements Limit.
+func (l *GradientLimit) Limit() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return int(l.limit)
+}
+
+// Update implements Limit.
+func (l *GradientLimit) Update(rtt time.Duration, inflight int, dropped bool) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.samples++
+	if l.samples%l.probeEvery == 0 {
+		l.minRTT = 0
+	}
+	if rtt > 0 && (l.minRTT == 0 || rtt < l.minRTT) {
+		l.minRTT = rtt
+	}
+
+	// Don't grow the limit when it isn't being used
+	if !dropped && float64(inflight*2) < l.limit {
+		return int(l.limit)
+	}
+
+	gradient := 0.5
+	if !dropped && rtt > 0 {
+		gradient = math.Max(0.5, math.Min(1, float64(l.minRTT)/float64(rtt)))
+	}
+	target := l.limit*gradient + math.Sqrt(l.limit)
+	l.limit = l.limit*(1-l.smoothing) + target*l.smoothing
+	l.limit = math.Max(l.limit, float64(clamp(l.minLimit, l.minLimit, l.maxLimit)))
+	if l.maxLimit > 0 {
+		l.limit = math.Min(l.limit, float64(l.maxLimit))
+	}
+	return int(l.limit)
+}
+
+func clamp(v, lo, hi int) int {
+	if lo < 1 {
+		lo = 1
+	}
+	if v < lo {
+		return lo
+	}
+	if hi > 0 && v > hi {
+		return hi
+	}
+	return v
+}
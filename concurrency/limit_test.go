@@ -0,0 +1,135 @@
+package concurrency
+
+import (
+	"testing"
+	"time"
+)
+
+// sample is one Update call and the limit it should return
+type sample struct {
+	rtt      time.Duration
+	inflight int
+	dropped  bool
+	want     int
+}
+
+const ms = time.Millisecond
+
+func TestFixedLimit(t *testing.T) {
+	l := FixedLimit(4)
+	for _, s := range []sample{{ms, 4, false, 4}, {time.Hour, 1, true, 4}} {
+		if got := l.Update(s.rtt, s.inflight, s.dropped); got != s.want || l.Limit() != s.want {
+			t.Errorf("Update(%v, %d, %t) = %d, want %d", s.rtt, s.inflight, s.dropped, got, s.want)
+		}
+	}
+}
+
+func TestAIMDLimit(t *testing.T) {
+	tests := []struct {
+		name    string
+		limit   *AIMDLimit
+		initial int
+		samples []sample
+	}{
+		{"initial clamped to max", NewAIMDLimit(50, 2, 12, 0.5, 100*ms), 12, nil},
+		{"initial clamped to min", NewAIMDLimit(0, 2, 12, 0.5, 100*ms), 2, nil},
+		{"grows by one while used", NewAIMDLimit(10, 2, 12, 0.5, 100*ms), 10, []sample{
+			{10 * ms, 5, false, 11},
+			{10 * ms, 6, false, 12},
+			{10 * ms, 6, false, 12},
+		}},
+		{"doesn't grow unused", NewAIMDLimit(10, 2, 12, 0.5, 100*ms), 10, []sample{
+			{10 * ms, 4, false, 10},
+			{10 * ms, 1, false, 10},
+		}},
+		{"backs off on slow requests", NewAIMDLimit(10, 2, 12, 0.5, 100*ms), 10, []sample{
+			{101 * ms, 1, false, 5},
+			{100 * ms, 3, false, 6},
+		}},
+		{"backs off on drops down to min", NewAIMDLimit(10, 2, 12, 0.5, 100*ms), 10, []sample{
+			{ms, 1, true, 5},
+			{ms, 1, true, 2},
+			{ms, 1, true, 2},
+		}},
+		{"no threshold ignores latency", NewAIMDLimit(10, 2, 12, 0.5, 0), 10, []sample{
+			{time.Minute, 5, false, 11},
+		}},
+		{"invalid ratio defaults to 0.9", NewAIMDLimit(10, 2, 12, 1.5, 0), 10, []sample{
+			{ms, 1, true, 9},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.limit.Limit(); got != tt.initial {
+				t.Fatalf("initial limit = %d, want %d", got, tt.initial)
+			}
+			for i, s := range tt.samples {
+				if got := tt.limit.Update(s.rtt, s.inflight, s.dropped); got != s.want {
+					t.Errorf("sample %d: Update(%v, %d, %t) = %d, want %d", i, s.rtt, s.inflight, s.dropped, got, s.want)
+				}
+			}
+		})
+	}
+}
+
+func TestGradientLimit(t *testing.T) {
+	probing := NewGradientLimit(10, 1, 100, 1)
+	probing.probeEvery = 3
+
+	tests := []struct {
+		name    string
+		limit   *GradientLimit
+		samples []sample
+	}{
+		{"grows at the best latency, shrinks with queueing", NewGradientLimit(10, 6, 20, 1), []sample{
+			// 10*1 + sqrt(10)
+			{100 * ms, 5, false, 13},
+			// gradient 100/200: 13.16*0.5 + sqrt(13.16)
+			{200 * ms, 7, false, 10},
+			// unused, unchanged
+			{100 * ms, 1, false, 10},
+		}},
+		{"drops back off down to min", NewGradientLimit(10, 6, 20, 1), []sample{
+			// 10*0.5 + sqrt(10), whatever the number in flight
+			{0, 0, true, 8},
+			{0, 0, true, 6},
+			{0, 0, true, 6},
+			{0, 0, true, 6},
+		}},
+		{"grows up to max", NewGradientLimit(6, 6, 20, 1), []sample{
+			{100 * ms, 10, false, 8},
+			{100 * ms, 10, false, 11},
+			{100 * ms, 10, false, 14},
+			{100 * ms, 10, false, 18},
+			{100 * ms, 10, false, 20},
+		}},
+		{"gradient is at least one half", NewGradientLimit(10, 1, 100, 1), []sample{
+			{100 * ms, 5, false, 13},
+			{300 * ms, 7, false, 10},
+			{300 * ms, 7, false, 8},
+		}},
+		{"smoothing", NewGradientLimit(16, 1, 100, 0.5), []sample{
+			// 16*0.5 + (16 + 4)*0.5
+			{100 * ms, 8, false, 18},
+			{100 * ms, 9, false, 20},
+		}},
+		{"min latency is probed again", probing, []sample{
+			{100 * ms, 5, false, 13},
+			{300 * ms, 7, false, 10},
+			// the third sample resets the minimum to 300ms, gradient 1
+			{300 * ms, 7, false, 13},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i, s := range tt.samples {
+				if got := tt.limit.Update(s.rtt, s.inflight, s.dropped); got != s.want {
+					t.Errorf("sample %d: Update(%v, %d, %t) = %d, want %d", i, s.rtt, s.inflight, s.dropped, got, s.want)
+				}
+				if got := tt.limit.Limit(); got != s.want {
+					t.Errorf("sample %d: Limit() = %d, want %d", i, got, s.want)
+				}
+			}
+		})
+	}
+}
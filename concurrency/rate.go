@@ -0,0 +1,48 @@
+package concurrency
+
+import (
+	"sync"
+	"time"
+)
+
+// TokenBucket allows bursts of up to n events and refills at n per interval.
+type TokenBucket struct {
+	mu sync.Mutex
+
+	tokens   float64
+	capacity float64
+	perToken time.Duration
+	last     time.Time
+}
+
+// NewTokenBucket returns a full bucket. A nil bucket, returned when n or
+// interval is not positive, allows everything.
+func NewTokenBucket(n int, interval time.Duration) *TokenBucket {
+	if n <= 0 || interval <= 0 {
+		return nil
+	}
+	return &TokenBucket{
+		tokens:   float64(n),
+		capacity: float64(n),
+		perToken: interval / time.Duration(n),
+		last:     time.Now(),
+	}
+}
+
+// Allow takes a token if there is one.
+func (b *TokenBucket) Allow() bool {
+	if b == nil {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	b.tokens = min(b.capacity, b.tokens+float64(now.Sub(b.last))/float64(b.perToken))
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
@@ -0,0 +1,38 @@
+package concurrency
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTokenBucket(t *testing.T) {
+	tests := []struct {
+		name     string
+		n        int
+		interval time.Duration
+		// events are run in order: a is an allowed event, d a denied one
+		// and r lets the time of one token pass
+		events string
+	}{
+		{"nil bucket allows everything", 0, time.Hour, "aaaaa"},
+		{"no interval allows everything", 3, 0, "aaaaa"},
+		{"burst up to n", 3, time.Hour, "aaad"},
+		{"denied events take no token", 1, time.Hour, "addrad"},
+		{"refills one token at a time", 2, time.Hour, "aadradrad"},
+		{"refills up to n", 2, time.Hour, "aadrrrraad"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewTokenBucket(tt.n, tt.interval)
+			for i, e := range tt.events {
+				if e == 'r' {
+					b.last = b.last.Add(-b.perToken)
+					continue
+				}
+				if got := b.Allow(); got != (e == 'a') {
+					t.Fatalf("event %d: Allow() = %t, want %t", i, got, e == 'a')
+				}
+			}
+		})
+	}
+}
@@ -1,6 +1,9 @@
 package config
 
 import (
+	"bytes"
+	"errors"
+	"io"
 	"os"
 
 	"gopkg.in/yaml.v3"
@@ -8,40 +11,243 @@ import (
 
 type Config struct {
 	Server struct {
-		Port            string `yaml:"port"`
-		ShutdownTimeout int    `yaml:"shutdownTimeout"`
+		Port string `yaml:"port"`
+		// ShutdownTimeout is in seconds, the budget of the shutdown after
+		// the pre-stop delay. In-flight work finishes and the components
+		// stop within it.
+		ShutdownTimeout int `yaml:"shutdownTimeout"`
+		// PreStopDelay is how long the server keeps serving after readiness
+		// turned failing on shutdown, e.g. "5s"
+		PreStopDelay string `yaml:"preStopDelay"`
+		// BodyLimit is the max request body size in bytes, fiber defaults to 4MB
+		BodyLimit int `yaml:"bodyLimit"`
+		// UploadBodyLimit is the max body size of /ask/upload in bytes,
+		// defaults to 64MB
+		UploadBodyLimit int `yaml:"uploadBodyLimit"`
+		// AdminAddr is where metrics, pprof and the other operational
+		// endpoints are served, empty disables them
+		AdminAddr string `yaml:"adminAddr"`
+		// AdminToken is the bearer token the admin server requires. It's
+		// needed unless AdminAddr is a loopback address.
+		AdminToken string `yaml:"adminToken" secret:"true"`
 	} `yaml:"server"`
 	RateLimit struct {
 		Requests int    `yaml:"requests"`
 		Duration string `yaml:"duration"`
 	} `yaml:"rateLimit"`
+	Log struct {
+		Level  string `yaml:"level"`  // debug | info | warn | error
+		Format string `yaml:"format"` // text | json
+	} `yaml:"log"`
 	CircuitBreaker struct {
 		Threshold   int    `yaml:"threshold"`
 		Timeout     string `yaml:"timeout"`
 		MaxRequests int    `yaml:"maxRequests"`
 	} `yaml:"circuitBreaker"`
-	Telemetry struct {
+	Idempotency struct {
+		Enabled bool   `yaml:"enabled"`
+		Store   string `yaml:"store"` // memory | file
+		Dir     string `yaml:"dir"`
+		Window  string `yaml:"window"`
+	} `yaml:"idempotency"`
+	Jobs struct {
+		Dir          string `yaml:"dir"`
+		Workers      int    `yaml:"workers"`
+		QueueSize    int    `yaml:"queueSize"`
+		MaxAttempts  int    `yaml:"maxAttempts"`
+		RetryBackoff string `yaml:"retryBackoff"`
+		Timeout      string `yaml:"timeout"`
+		// Retention is how long finished jobs are kept, empty keeps them
+		Retention string `yaml:"retention"`
+		Webhook   struct {
+			// Callbacks are only delivered when a secret is set
+			Secret      string `yaml:"secret" secret:"true"`
+			Timeout     string `yaml:"timeout"`
+			MaxAttempts int    `yaml:"maxAttempts"`
+			// AllowedHosts restricts callbacks to these hosts, "*.example.com"
+			// matches subdomains. Empty allows any public host.
+			AllowedHosts []string `yaml:"allowedHosts"`
+			// AllowPrivateNetworks lets callbacks reach loopback and private
+			// addresses, never enable it in production
+			AllowPrivateNetworks bool `yaml:"allowPrivateNetworks"`
+		} `yaml:"webhook"`
+	} `yaml:"jobs"`
+	Batch struct {
+		MaxItems    int `yaml:"maxItems"`
+		Parallelism int `yaml:"parallelism"`
+	} `yaml:"batch"`
+	WebSocket struct {
+		// Tokens authenticate chat connections, sent as a bearer token or
+		// the access_token query parameter. Empty disables chat.
+		Tokens []string `yaml:"tokens" secret:"true"`
+		// Origins allowed to connect from browsers. Empty allows pages of
+		// the same host only, "*" allows all.
+		Origins         []string `yaml:"origins"`
+		MaxMessageBytes int      `yaml:"maxMessageBytes"`
+		// MaxInflight is the number of asks a connection may run at once
+		MaxInflight int `yaml:"maxInflight"`
+		// SendBuffer is the number of outgoing messages queued per connection,
+		// asks wait for slow clients once it's full
+		SendBuffer   int    `yaml:"sendBuffer"`
+		WriteTimeout string `yaml:"writeTimeout"`
+		PingInterval string `yaml:"pingInterval"`
+		IdleTimeout  string `yaml:"idleTimeout"`
+		// RateLimit bounds the asks of a connection
+		RateLimit struct {
+			Requests int    `yaml:"requests"`
+			Duration string `yaml:"duration"`
+		} `yaml:"rateLimit"`
+	} `yaml:"websocket"`
+	Prompts struct {
+		Dir            string `yaml:"dir"`
+		ReloadInterval string `yaml:"reloadInterval"`
+	} `yaml:"prompts"`
+	// Concurrency holds a bulkhead per endpoint name (e.g. "uppercase", "ask")
+	Concurrency map[string]Bulkhead `yaml:"concurrency"`
+	Telemetry   struct {
 		ServiceName   string  `yaml:"serviceName"`
 		CollectorAddr string  `yaml:"collectorAddr"`
 		SamplingRatio float64 `yaml:"samplingRatio"`
 	} `yaml:"telemetry"`
+	LLM struct {
+		// Provider is anthropic, openai or fake. Routing, tools and
+		// extraction are only available with anthropic.
+		Provider string `yaml:"provider"`
+		OpenAI   struct {
+			BaseURL string `yaml:"baseURL"`
+			APIKey  string `yaml:"apiKey" secret:"true"`
+			Model   string `yaml:"model"`
+			Timeout int    `yaml:"timeout"`
+		} `yaml:"openai"`
+		Fake struct {
+			Latency   string         `yaml:"latency"`
+			Responses []FakeResponse `yaml:"responses"`
+			Default   string         `yaml:"default"`
+		} `yaml:"fake"`
+	} `yaml:"llm"`
+	Cassette struct {
+		Mode string `yaml:"mode"` // record | replay | passthrough, empty disables
+		Path string `yaml:"path"` // .yaml or .json
+		// Match lists the request parts compared on replay:
+		// method, url, path, body, json_body or header:<name>
+		Match []string `yaml:"match"`
+	} `yaml:"cassette"`
 	Claude struct {
-		APIKey     string `yaml:"apiKey"`
+		APIKey     string `yaml:"apiKey" secret:"true"`
 		BaseURL    string `yaml:"baseURL"`
+		Version    string `yaml:"version"`
 		Model      string `yaml:"model"`
 		Timeout    int    `yaml:"timeout"`
 		MaxRetries int    `yaml:"maxRetries"`
+		// Defaults are applied to requests that don't set the parameter
+		Defaults struct {
+			System      string   `yaml:"system"`
+			MaxTokens   int      `yaml:"maxTokens"`
+			Temperature *float64 `yaml:"temperature"`
+			TopP        *float64 `yaml:"topP"`
+			TopK        *int     `yaml:"topK"`
+		} `yaml:"defaults"`
+		// Models lists the models callers may ask for, with their limits.
+		// When empty, only Model is allowed.
+		Models map[string]ModelLimits `yaml:"models"`
+		Tools  struct {
+			MaxIterations int    `yaml:"maxIterations"`
+			Timeout       string `yaml:"timeout"`
+		} `yaml:"tools"`
+		Routing struct {
+			// Rules are tried in order, the first match picks the model
+			Rules []RoutingRule `yaml:"rules"`
+			// Fallbacks are tried in order when the chosen model is overloaded,
+			// failing or its circuit is open
+			Fallbacks []string `yaml:"fallbacks"`
+		} `yaml:"routing"`
+		Extract struct {
+			MaxAttempts int `yaml:"maxAttempts"`
+		} `yaml:"extract"`
+		// ContextGuard checks asks against the model context window before
+		// they are sent
+		ContextGuard struct {
+			Action    string `yaml:"action"`    // reject | truncate, empty disables
+			Estimator string `yaml:"estimator"` // approx | api
+		} `yaml:"contextGuard"`
+		Attachments struct {
+			MaxFiles         int `yaml:"maxFiles"`
+			MaxImageBytes    int `yaml:"maxImageBytes"`
+			MaxDocumentBytes int `yaml:"maxDocumentBytes"`
+			MaxImagePixels   int `yaml:"maxImagePixels"`
+			MaxPages         int `yaml:"maxPages"`
+		} `yaml:"attachments"`
 	} `yaml:"claude"`
 }
 
-func LoadConfig(path string) (*Config, error) {
+// FakeResponse answers questions matching the Match regexp
+type FakeResponse struct {
+	Match  string `yaml:"match"`
+	Answer string `yaml:"answer"`
+}
+
+// RoutingRule matches when all of its non-empty conditions match
+type RoutingRule struct {
+	Name           string `yaml:"name"`
+	Tenant         string `yaml:"tenant"`
+	Template       string `yaml:"template"`
+	MinPromptChars int    `yaml:"minPromptChars"`
+	MaxPromptChars int    `yaml:"maxPromptChars"`
+	Model          string `yaml:"model"`
+}
+
+type ModelLimits struct {
+	ContextWindow    int     `yaml:"contextWindow"`
+	MaxOutputTokens  int     `yaml:"maxOutputTokens"`
+	MaxTemperature   float64 `yaml:"maxTemperature"`
+	MaxTopK          int     `yaml:"maxTopK"`
+	MaxStopSequences int     `yaml:"maxStopSequences"`
+}
+
+type Bulkhead struct {
+	Limit        int    `yaml:"limit"`
+	QueueSize    int    `yaml:"queueSize"`
+	QueueTimeout string `yaml:"queueTimeout"`
+	Adaptive     struct {
+		// Algorithm is "aimd" or "gradient", empty means a fixed limit
+		Algorithm        string  `yaml:"algorithm"`
+		MinLimit         int     `yaml:"minLimit"`
+		MaxLimit         int     `yaml:"maxLimit"`
+		BackoffRatio     float64 `yaml:"backoffRatio"`
+		LatencyThreshold string  `yaml:"latencyThreshold"`
+		Smoothing        float64 `yaml:"smoothing"`
+	} `yaml:"adaptive"`
+}
+
+// LoadConfig reads the config files in order, each one overriding the
+// values set by the ones before it.
+func LoadConfig(paths ...string) (*Config, error) {
+	var config Config
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if err := yaml.Unmarshal(data, &config); err != nil {
+			return nil, err
+		}
+	}
+
+	return &config, nil
+}
+
+// LoadConfigStrict is LoadConfig, but keys that aren't part of Config are
+// errors rather than ignored, which catches typos.
+func LoadConfigStrict(path string) (*Config, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, err
 	}
 
 	var config Config
-	if err := yaml.Unmarshal(data, &config); err != nil {
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&config); err != nil && !errors.Is(err, io.EOF) {
 		return nil, err
 	}
 
@@ -0,0 +1,48 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadConfig(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, data string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	base := write("base.yaml", "server:\n  port: \":3000\"\n  adminAddr: \"127.0.0.1:8081\"\nwebsocket:\n  tokens: [a, b]\n")
+	override := write("override.yaml", "server:\n  adminAddr: \":8081\"\n  adminToken: secret\nwebsocket:\n  tokens: [c]\n")
+
+	tests := []struct {
+		name   string
+		paths  []string
+		port   string
+		admin  string
+		token  string
+		tokens int
+	}{
+		{"one file", []string{base}, ":3000", "127.0.0.1:8081", "", 2},
+		{"override keeps the other values", []string{base, override}, ":3000", ":8081", "secret", 1},
+		{"override first", []string{override, base}, ":3000", "127.0.0.1:8081", "secret", 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := LoadConfig(tt.paths...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.Server.Port != tt.port || cfg.Server.AdminAddr != tt.admin || cfg.Server.AdminToken != tt.token || len(cfg.WebSocket.Tokens) != tt.tokens {
+				t.Errorf("got port %q admin %q token %q tokens %v", cfg.Server.Port, cfg.Server.AdminAddr, cfg.Server.AdminToken, cfg.WebSocket.Tokens)
+			}
+		})
+	}
+
+	if _, err := LoadConfig(base, filepath.Join(dir, "missing.yaml")); err == nil {
+		t.Error("LoadConfig of a missing file succeeded")
+	}
+}
@@ -0,0 +1,70 @@
+package config
+
+import (
+	"reflect"
+
+	"gopkg.in/yaml.v3"
+)
+
+// RedactedValue replaces set values that are marked secret
+const RedactedValue = "[redacted]"
+
+// Redacted returns a copy of c with the values of fields tagged
+// secret:"true" replaced, safe to show to operators.
+func (c *Config) Redacted() (*Config, error) {
+	// A round trip through YAML is a deep copy
+	data, err := yaml.Marshal(c)
+	if err != nil {
+		return nil, err
+	}
+	var redacted Config
+	if err := yaml.Unmarshal(data, &redacted); err != nil {
+		return nil, err
+	}
+	redact(reflect.ValueOf(&redacted).Elem())
+	return &redacted, nil
+}
+
+func redact(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Pointer:
+		if !v.IsNil() {
+			redact(v.Elem())
+		}
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			if t.Field(i).Tag.Get("secret") == "true" {
+				redactValue(v.Field(i))
+				continue
+			}
+			redact(v.Field(i))
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			redact(v.Index(i))
+		}
+	case reflect.Map:
+		// Map values can't be set in place
+		iter := v.MapRange()
+		for iter.Next() {
+			e := reflect.New(iter.Value().Type()).Elem()
+			e.Set(iter.Value())
+			redact(e)
+			v.SetMapIndex(iter.Key(), e)
+		}
+	}
+}
+
+func redactValue(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.String:
+		if v.String() != "" {
+			v.SetString(RedactedValue)
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			redactValue(v.Index(i))
+		}
+	}
+}
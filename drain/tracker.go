@@ -0,0 +1,196 @@
+// Package drain tracks the work in flight, so that shutdown can wait for it
+// to finish and cancel what's left once the budget runs out.
+package drain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"kit-fiber-example/metrics"
+)
+
+// Kinds of tracked work
+const (
+	KindRequest = "request"
+	KindStream  = "stream"
+	KindChat    = "chat"
+	KindJob     = "job"
+)
+
+var (
+	// ErrDraining is returned for work started once the drain began
+	ErrDraining = errors.New("server is shutting down, retry on another instance")
+	// ErrAborted is the cause of contexts canceled when the drain timed out
+	ErrAborted = errors.New("server shut down before the work finished")
+)
+
+// abortGrace is how long aborted work gets to unwind
+const abortGrace = time.Second
+
+// progressInterval is how often the remaining work is logged while draining
+const progressInterval = time.Second
+
+// Tracker counts the work in flight by kind. A nil Tracker tracks nothing.
+type Tracker struct {
+	mu       sync.Mutex
+	inflight map[string]int
+	draining chan struct{}
+	idle     chan struct{} // closed once draining with nothing in flight
+
+	ctx   context.Context
+	abort context.CancelCauseFunc
+
+	inflightGauge metrics.Gauge
+	aborted       metrics.Counter
+}
+
+func NewTracker(m *metrics.Metrics) *Tracker {
+	ctx, abort := context.WithCancelCause(context.Background())
+	return &Tracker{
+		inflight:      make(map[string]int),
+		draining:      make(chan struct{}),
+		idle:          make(chan struct{}),
+		ctx:           ctx,
+		abort:         abort,
+		inflightGauge: m.InflightWork,
+		aborted:       m.AbortedWork,
+	}
+}
+
+// Track registers work of the given kind until done is called. The
+// returned context is canceled with ErrAborted if the drain times out.
+func (t *Tracker) Track(ctx context.Context, kind string) (_ context.Context, done func(), err error) {
+	if t == nil {
+		return ctx, func() {}, nil
+	}
+
+	t.mu.Lock()
+	select {
+	case <-t.draining:
+		t.mu.Unlock()
+		return ctx, nil, ErrDraining
+	default:
+	}
+	t.inflight[kind]++
+	t.mu.Unlock()
+	t.inflightGauge.With("kind", kind).Add(1)
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	stop := context.AfterFunc(t.ctx, func() { cancel(context.Cause(t.ctx)) })
+
+	var once sync.Once
+	return ctx, func() {
+		once.Do(func() {
+			stop()
+			cancel(nil)
+			t.inflightGauge.With("kind", kind).Add(-1)
+
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.inflight[kind]--
+			t.checkIdle()
+		})
+	}, nil
+}
+
+// Draining is closed when the drain begins, for long lived work like chat
+// connections that should wind down.
+func (t *Tracker) Draining() <-chan struct{} {
+	if t == nil {
+		return nil
+	}
+	return t.draining
+}
+
+// Drain rejects new work and waits for the work in flight. When ctx is done
+// first, the remaining work is canceled with ErrAborted and the error says
+// what was aborted.
+func (t *Tracker) Drain(ctx context.Context) error {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	select {
+	case <-t.draining:
+	default:
+		close(t.draining)
+	}
+	t.checkIdle()
+	t.mu.Unlock()
+
+	ticker := time.NewTicker(progressInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-t.idle:
+			return nil
+		case <-ticker.C:
+			slog.Info("draining", "inflight", t.snapshot())
+		case <-ctx.Done():
+			remaining := t.snapshot()
+			slog.Warn("drain timed out, aborting", "inflight", remaining)
+			for kind, n := range remaining {
+				t.aborted.With("kind", kind).Add(float64(n))
+			}
+			t.abort(ErrAborted)
+
+			// Give the aborted work a moment to report the abort
+			select {
+			case <-t.idle:
+			case <-time.After(abortGrace):
+			}
+			return fmt.Errorf("%w: %s", ErrAborted, format(remaining))
+		}
+	}
+}
+
+// checkIdle must be called with mu held
+func (t *Tracker) checkIdle() {
+	select {
+	case <-t.draining:
+	default:
+		return
+	}
+	for _, n := range t.inflight {
+		if n > 0 {
+			return
+		}
+	}
+	select {
+	case <-t.idle:
+	default:
+		close(t.idle)
+	}
+}
+
+func (t *Tracker) snapshot() map[string]int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	counts := make(map[string]int, len(t.inflight))
+	for kind, n := range t.inflight {
+		if n > 0 {
+			counts[kind] = n
+		}
+	}
+	return counts
+}
+
+// format lists counts like "2 request, 1 stream"
+func format(counts map[string]int) string {
+	kinds := make([]string, 0, len(counts))
+	for kind := range counts {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	parts := make([]string, len(kinds))
+	for i, kind := range kinds {
+		parts[i] = fmt.Sprintf("%d %s", counts[kind], kind)
+	}
+	return strings.Join(parts, ", ")
+}
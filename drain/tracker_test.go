@@ -0,0 +1,114 @@
+package drain
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"kit-fiber-example/metrics"
+)
+
+var testMetrics = metrics.Setup()
+
+func TestTracker(t *testing.T) {
+	tests := []struct {
+		name string
+		// work is tracked before the drain: a finishes right away, w
+		// finishes once the drain began and s is stuck until aborted
+		work    string
+		timeout time.Duration
+		err     string
+	}{
+		{"nothing in flight", "", time.Second, ""},
+		{"finished work", "aa", time.Second, ""},
+		{"waits for the work", "aww", time.Second, ""},
+		{"aborts stuck work", "wss", 50 * time.Millisecond, "server shut down before the work finished: 2 request"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr := NewTracker(testMetrics)
+			var waiting, stuck []func()
+			var stuckCtxs []context.Context
+			for _, w := range tt.work {
+				ctx, done, err := tr.Track(context.Background(), KindRequest)
+				if err != nil {
+					t.Fatal(err)
+				}
+				switch w {
+				case 'a':
+					done()
+					done() // twice is fine
+				case 'w':
+					waiting = append(waiting, done)
+				case 's':
+					stuck = append(stuck, done)
+					stuckCtxs = append(stuckCtxs, ctx)
+				}
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), tt.timeout)
+			defer cancel()
+			result := make(chan error, 1)
+			go func() { result <- tr.Drain(ctx) }()
+
+			<-tr.Draining()
+			if _, _, err := tr.Track(context.Background(), KindJob); !errors.Is(err, ErrDraining) {
+				t.Errorf("Track while draining = %v, want ErrDraining", err)
+			}
+			for _, done := range waiting {
+				done()
+			}
+			// Aborted work unwinds once its context is canceled
+			for i, ctx := range stuckCtxs {
+				go func() {
+					<-ctx.Done()
+					if cause := context.Cause(ctx); !errors.Is(cause, ErrAborted) {
+						t.Errorf("cause = %v, want ErrAborted", cause)
+					}
+					stuck[i]()
+				}()
+			}
+
+			err := <-result
+			switch {
+			case tt.err == "" && err != nil:
+				t.Errorf("Drain = %v", err)
+			case tt.err != "" && (err == nil || err.Error() != tt.err):
+				t.Errorf("Drain = %v, want %s", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestNilTracker(t *testing.T) {
+	var tr *Tracker
+	ctx := context.Background()
+	got, done, err := tr.Track(ctx, KindStream)
+	if err != nil || got != ctx {
+		t.Fatalf("Track = %v, %v", got, err)
+	}
+	done()
+	if tr.Draining() != nil {
+		t.Error("Draining isn't nil")
+	}
+	if err := tr.Drain(ctx); err != nil {
+		t.Errorf("Drain = %v", err)
+	}
+}
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		counts map[string]int
+		want   string
+	}{
+		{nil, ""},
+		{map[string]int{KindJob: 1}, "1 job"},
+		{map[string]int{KindStream: 1, KindChat: 3, KindRequest: 2}, "3 chat, 2 request, 1 stream"},
+	}
+	for _, tt := range tests {
+		if got := format(tt.counts); got != tt.want {
+			t.Errorf("format(%v) = %q, want %q", tt.counts, got, tt.want)
+		}
+	}
+}
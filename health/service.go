@@ -1,13 +1,36 @@
 package health
 
-import "sync/atomic"
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// defaultCheckTimeout bounds each check of a report
+const defaultCheckTimeout = 5 * time.Second
 
 // Service health status
 type Health struct {
-	status int32 // atomic
+	// CheckTimeout bounds each check of a report, 5s by default
+	CheckTimeout time.Duration
+
+	status  int32 // atomic
+	ready   atomic.Bool
+	started atomic.Int64
+
+	mu     sync.Mutex
+	checks map[string]Check
 }
 
+// Check reports the state of a dependency. The detail is shown in the
+// report, an error marks the service as degraded.
+type Check func(ctx context.Context) (detail any, err error)
+
 func (h *Health) SetHealthy() {
+	h.started.CompareAndSwap(0, time.Now().UnixNano())
 	atomic.StoreInt32(&h.status, 1)
 }
 
@@ -18,3 +41,122 @@ func (h *Health) SetUnhealthy() {
 func (h *Health) IsHealthy() bool {
 	return atomic.LoadInt32(&h.status) == 1
 }
+
+// SetReady reports whether the service takes traffic. It's not ready until
+// everything started, and no longer once it starts draining.
+func (h *Health) SetReady(ready bool) {
+	h.ready.Store(ready)
+}
+
+func (h *Health) IsReady() bool {
+	return h.ready.Load()
+}
+
+// Register adds a check to the report, replacing the one with the same name
+func (h *Health) Register(name string, check Check) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.checks == nil {
+		h.checks = make(map[string]Check)
+	}
+	h.checks[name] = check
+}
+
+// Report statuses
+const (
+	StatusOK        = "ok"
+	StatusDegraded  = "degraded"
+	StatusUnhealthy = "unhealthy"
+)
+
+type Report struct {
+	Status  string                 `json:"status"`
+	Ready   bool                   `json:"ready"`
+	Started time.Time              `json:"started,omitempty"`
+	Uptime  string                 `json:"uptime,omitempty"`
+	Checks  map[string]CheckResult `json:"checks,omitempty"`
+}
+
+type CheckResult struct {
+	Status   string `json:"status"`
+	Detail   any    `json:"detail,omitempty"`
+	Error    string `json:"error,omitempty"`
+	Duration string `json:"duration"`
+}
+
+// Report runs every check at once, so that a slow dependency only costs
+// its own timeout
+func (h *Health) Report(ctx context.Context) Report {
+	h.mu.Lock()
+	names := make([]string, 0, len(h.checks))
+	for name := range h.checks {
+		names = append(names, name)
+	}
+	checks := make(map[string]Check, len(h.checks))
+	for name, check := range h.checks {
+		checks[name] = check
+	}
+	h.mu.Unlock()
+	sort.Strings(names)
+
+	report := Report{Status: StatusOK, Ready: h.IsReady(), Checks: make(map[string]CheckResult, len(names))}
+	if started := h.started.Load(); started != 0 {
+		report.Started = time.Unix(0, started).UTC()
+		report.Uptime = time.Since(report.Started).Round(time.Second).String()
+	}
+	timeout := h.CheckTimeout
+	if timeout <= 0 {
+		timeout = defaultCheckTimeout
+	}
+	results := make([]CheckResult, len(names))
+	var wg sync.WaitGroup
+	for i, name := range names {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = runCheck(ctx, checks[name], timeout)
+		}()
+	}
+	wg.Wait()
+	for i, name := range names {
+		if results[i].Status != StatusOK {
+			report.Status = StatusDegraded
+		}
+		report.Checks[name] = results[i]
+	}
+	if !h.IsHealthy() {
+		report.Status = StatusUnhealthy
+	}
+	return report
+}
+
+// runCheck gives up on checks that ignore the cancellation of their
+// context, they finish in the background.
+func runCheck(ctx context.Context, check Check, timeout time.Duration) CheckResult {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	type outcome struct {
+		detail any
+		err    error
+	}
+	done := make(chan outcome, 1)
+	start := time.Now()
+	go func() {
+		detail, err := check(ctx)
+		done <- outcome{detail, err}
+	}()
+
+	var o outcome
+	select {
+	case o = <-done:
+	case <-ctx.Done():
+		o.err = fmt.Errorf("no answer: %w", ctx.Err())
+	}
+	result := CheckResult{Status: StatusOK, Detail: o.detail, Duration: time.Since(start).String()}
+	if o.err != nil {
+		result.Status = StatusDegraded
+		result.Error = o.err.Error()
+	}
+	return result
+}
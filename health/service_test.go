@@ -0,0 +1,65 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestReport(t *testing.T) {
+	ok := func(context.Context) (any, error) { return "fine", nil }
+	failing := func(context.Context) (any, error) { return nil, errors.New("down") }
+	// slow honours its context, stuck doesn't
+	slow := func(ctx context.Context) (any, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	stuck := func(context.Context) (any, error) {
+		time.Sleep(time.Second)
+		return "late", nil
+	}
+
+	tests := []struct {
+		name    string
+		healthy bool
+		checks  map[string]Check
+		status  string
+		results map[string]string
+	}{
+		{"no checks", true, nil, StatusOK, map[string]string{}},
+		{"all ok", true, map[string]Check{"a": ok, "b": ok}, StatusOK, map[string]string{"a": StatusOK, "b": StatusOK}},
+		{"one failing", true, map[string]Check{"a": ok, "b": failing}, StatusDegraded, map[string]string{"a": StatusOK, "b": StatusDegraded}},
+		{"timed out", true, map[string]Check{"a": ok, "slow": slow, "stuck": stuck}, StatusDegraded, map[string]string{"a": StatusOK, "slow": StatusDegraded, "stuck": StatusDegraded}},
+		{"unhealthy", false, map[string]Check{"a": ok}, StatusUnhealthy, map[string]string{"a": StatusOK}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &Health{CheckTimeout: 50 * time.Millisecond}
+			if tt.healthy {
+				h.SetHealthy()
+			}
+			for name, check := range tt.checks {
+				h.Register(name, check)
+			}
+
+			start := time.Now()
+			report := h.Report(context.Background())
+			// The checks run at once, each under its own timeout
+			if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+				t.Errorf("Report took %v", elapsed)
+			}
+			if report.Status != tt.status {
+				t.Errorf("status = %s, want %s", report.Status, tt.status)
+			}
+			if len(report.Checks) != len(tt.results) {
+				t.Errorf("checks = %v, want %v", report.Checks, tt.results)
+			}
+			for name, want := range tt.results {
+				if got := report.Checks[name]; got.Status != want {
+					t.Errorf("%s = %+v, want %s", name, got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,31 @@
+package idempotency
+
+import (
+	"fmt"
+	"time"
+
+	"kit-fiber-example/config"
+)
+
+const defaultWindow = 24 * time.Hour
+
+// NewStoreFromConfig builds the configured Store and returns the record window.
+func NewStoreFromConfig(cfg *config.Config) (Store, time.Duration, error) {
+	window := defaultWindow
+	if cfg.Idempotency.Window != "" {
+		d, err := time.ParseDuration(cfg.Idempotency.Window)
+		if err != nil {
+			return nil, 0, fmt.Errorf("idempotency.window: %w", err)
+		}
+		window = d
+	}
+
+	switch cfg.Idempotency.Store {
+	case "", "memory":
+		return NewMemoryStore(), window, nil
+	case "file":
+		return NewFileStore(cfg.Idempotency.Dir), window, nil
+	default:
+		return nil, 0, fmt.Errorf("idempotency.store: unknown store %q", cfg.Idempotency.Store)
+	}
+}
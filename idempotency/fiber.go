@@ -0,0 +1,104 @@
+package idempotency
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"kit-fiber-example/problem"
+)
+
+const (
+	HeaderKey      = "Idempotency-Key"
+	HeaderReplayed = "Idempotent-Replayed"
+
+	maxKeyLength = 255
+
+	// reservationLease bounds how long a key stays in flight, so that a
+	// crash doesn't block retries for the whole window
+	reservationLease = 5 * time.Minute
+)
+
+// New returns a Fiber handler that makes POST requests carrying an
+// Idempotency-Key header safe to retry. The first request with a key is
+// executed and its response stored for window; a replay with the same body
+// gets the stored response back. Reusing a key with a different body is
+// rejected with 409, a duplicate arriving while the first one is still
+// running gets 425 Too Early. The reservation of a request that failed,
+// panicked or outlived reservationLease is released for the client to retry.
+func New(store Store, window time.Duration) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		key := c.Get(HeaderKey)
+		if key == "" || c.Method() != fiber.MethodPost {
+			return c.Next()
+		}
+		if len(key) > maxKeyLength {
+			return problem.New(problem.CodeBadRequest, "Idempotency-Key is too long")
+		}
+
+		fingerprint := fingerprint(c)
+		existing, err := store.Reserve(c.UserContext(), key, Record{
+			Fingerprint: fingerprint,
+			InFlight:    true,
+			ExpiresAt:   time.Now().Add(min(window, reservationLease)),
+		})
+		if err != nil {
+			return err
+		}
+
+		if existing != nil {
+			switch {
+			case existing.Fingerprint != fingerprint:
+				return problem.New(problem.CodeConflict, "Idempotency-Key was already used with a different request")
+			case existing.InFlight:
+				return problem.New(problem.CodeTooEarly, "A request with this Idempotency-Key is still in progress")
+			}
+			c.Set(HeaderReplayed, "true")
+			c.Set(fiber.HeaderContentType, existing.ContentType)
+			return c.Status(existing.Status).Send(existing.Body)
+		}
+
+		completed := false
+		defer func() {
+			// Errors are rendered later by the app error handler, so there is
+			// nothing to store. Let the client retry instead.
+			if !completed {
+				store.Release(context.WithoutCancel(c.UserContext()), key)
+			}
+		}()
+
+		if err := c.Next(); err != nil {
+			return err
+		}
+
+		status := c.Response().StatusCode()
+		if status >= fiber.StatusInternalServerError {
+			return nil
+		}
+		err = store.Complete(c.UserContext(), key, Record{
+			Fingerprint: fingerprint,
+			Status:      status,
+			ContentType: string(c.Response().Header.ContentType()),
+			Body:        append([]byte(nil), c.Response().Body()...),
+			ExpiresAt:   time.Now().Add(window),
+		})
+		completed = err == nil
+		return err
+	}
+}
+
+func fingerprint(c *fiber.Ctx) string {
+	h := sha256.New()
+	h.Write([]byte(c.Method()))
+	h.Write([]byte{0})
+	h.Write([]byte(c.Path()))
+	h.Write([]byte{0})
+	// The stored body is in the media type of the first request
+	h.Write([]byte(c.Get(fiber.HeaderAccept)))
+	h.Write([]byte{0})
+	h.Write(c.Body())
+	return hex.EncodeToString(h.Sum(nil))
+}
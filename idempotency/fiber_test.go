@@ -0,0 +1,124 @@
+package idempotency
+
+import (
+	"errors"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"kit-fiber-example/problem"
+)
+
+// testApp runs New in front of a handler answering with the request body.
+// The bodies "error" and "crash" fail with an error and a 500 response,
+// "block" waits for release.
+func testApp(release <-chan struct{}, entered chan<- struct{}, calls *atomic.Int32) *fiber.App {
+	app := fiber.New(fiber.Config{ErrorHandler: func(c *fiber.Ctx, err error) error {
+		var pe *problem.Error
+		if errors.As(err, &pe) {
+			return c.Status(pe.HTTPStatus()).SendString(string(pe.Code))
+		}
+		return c.Status(fiber.StatusInternalServerError).SendString(err.Error())
+	}})
+	app.Use(New(NewMemoryStore(), time.Hour))
+	app.All("/echo", func(c *fiber.Ctx) error {
+		n := calls.Add(1)
+		switch string(c.Body()) {
+		case "error":
+			return errors.New("handler failed")
+		case "crash":
+			return c.Status(fiber.StatusBadGateway).SendString("upstream down")
+		case "block":
+			entered <- struct{}{}
+			<-release
+		}
+		c.Set(fiber.HeaderContentType, "text/plain")
+		return c.Status(fiber.StatusCreated).SendString(string(c.Body()) + " #" + string(rune('0'+n)))
+	})
+	return app
+}
+
+type testResponse struct {
+	status   int
+	body     string
+	replayed bool
+}
+
+// send returns a failed request as status 0, so that it can be called from
+// any goroutine
+func send(app *fiber.App, method, key, body string) testResponse {
+	req := httptest.NewRequest(method, "/echo", strings.NewReader(body))
+	if key != "" {
+		req.Header.Set(HeaderKey, key)
+	}
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		return testResponse{body: err.Error()}
+	}
+	data, _ := io.ReadAll(resp.Body)
+	return testResponse{resp.StatusCode, string(data), resp.Header.Get(HeaderReplayed) == "true"}
+}
+
+func TestNew(t *testing.T) {
+	var calls atomic.Int32
+	app := testApp(nil, nil, &calls)
+
+	steps := []struct {
+		name   string
+		method string
+		key    string
+		body   string
+		want   testResponse
+		calls  int32
+	}{
+		{"first request runs", "POST", "k1", "a", testResponse{201, "a #1", false}, 1},
+		{"retry is replayed", "POST", "k1", "a", testResponse{201, "a #1", true}, 1},
+		{"other body conflicts", "POST", "k1", "b", testResponse{409, string(problem.CodeConflict), false}, 1},
+		{"other key runs", "POST", "k2", "a", testResponse{201, "a #2", false}, 2},
+		{"no key runs", "POST", "", "a", testResponse{201, "a #3", false}, 3},
+		{"GET isn't idempotent-keyed", "GET", "k1", "a", testResponse{201, "a #4", false}, 4},
+		{"error releases the key", "POST", "k3", "error", testResponse{500, "handler failed", false}, 5},
+		{"retry after an error runs", "POST", "k3", "error", testResponse{500, "handler failed", false}, 6},
+		{"5xx response releases the key", "POST", "k4", "crash", testResponse{502, "upstream down", false}, 7},
+		{"retry after a 5xx runs", "POST", "k4", "crash", testResponse{502, "upstream down", false}, 8},
+		{"key too long", "POST", strings.Repeat("k", maxKeyLength+1), "a", testResponse{400, string(problem.CodeBadRequest), false}, 8},
+	}
+	for _, step := range steps {
+		got := send(app, step.method, step.key, step.body)
+		if got != step.want {
+			t.Errorf("%s: got %+v, want %+v", step.name, got, step.want)
+		}
+		if n := calls.Load(); n != step.calls {
+			t.Errorf("%s: handler ran %d times, want %d", step.name, n, step.calls)
+		}
+	}
+}
+
+func TestNewInFlight(t *testing.T) {
+	var calls atomic.Int32
+	release, entered := make(chan struct{}), make(chan struct{})
+	app := testApp(release, entered, &calls)
+
+	first := make(chan testResponse)
+	go func() { first <- send(app, "POST", "k", "block") }()
+	<-entered
+
+	if got := send(app, "POST", "k", "block"); got.status != fiber.StatusTooEarly {
+		t.Errorf("duplicate in flight = %+v, want 425", got)
+	}
+	close(release)
+	if got := <-first; got.status != fiber.StatusCreated || got.replayed {
+		t.Errorf("first = %+v, want 201", got)
+	}
+	if got := send(app, "POST", "k", "block"); got.status != fiber.StatusCreated || !got.replayed {
+		t.Errorf("retry after completion = %+v, want a replayed 201", got)
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("handler ran %d times, want 1", n)
+	}
+}
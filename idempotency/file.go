@@ -0,0 +1,160 @@
+package idempotency
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// FileStore keeps one JSON file per key in a directory, so records survive
+// restarts. Reserve is atomic within a single process only.
+type FileStore struct {
+	mu  sync.Mutex
+	dir string
+	// Expired records are swept by the first write, which catches those of
+	// an earlier run, and then every sweepEvery writes
+	sweepEvery int
+	writes     int
+}
+
+// NewFileStore doesn't touch the disk, dir is made by the first write.
+func NewFileStore(dir string) *FileStore {
+	return &FileStore{dir: dir, sweepEvery: 1000}
+}
+
+// Sweep deletes expired records.
+func (s *FileStore) Sweep() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.sweep(time.Now())
+}
+
+// sweep must be called with s.mu held.
+func (s *FileStore) sweep(now time.Time) error {
+	paths, err := filepath.Glob(filepath.Join(s.dir, "*.json"))
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, p := range paths {
+		data, err := os.ReadFile(p)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		var rec Record
+		if json.Unmarshal(data, &rec) != nil || rec.expired(now) {
+			if err := os.Remove(p); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Reserve implements Store.
+func (s *FileStore) Reserve(_ context.Context, key string, rec Record) (*Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, err := s.read(key)
+	switch {
+	case err == nil && !existing.expired(time.Now()):
+		return existing, nil
+	case err != nil && !errors.Is(err, ErrNotFound):
+		return nil, err
+	}
+	return nil, s.write(key, rec)
+}
+
+// Complete implements Store.
+func (s *FileStore) Complete(_ context.Context, key string, rec Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.write(key, rec)
+}
+
+// Release implements Store.
+func (s *FileStore) Release(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err := os.Remove(s.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// Keys are hashed so that arbitrary client input can't escape the directory.
+func (s *FileStore) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(s.dir, hex.EncodeToString(sum[:])+".json")
+}
+
+func (s *FileStore) read(key string) (*Record, error) {
+	data, err := os.ReadFile(s.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var rec Record
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return nil, err
+	}
+	return &rec, nil
+}
+
+// write replaces the file atomically, so a crash never leaves a torn record.
+// It must be called with s.mu held.
+func (s *FileStore) write(key string, rec Record) error {
+	s.writes++
+	if (s.writes-1)%s.sweepEvery == 0 {
+		// A failed sweep only leaves garbage behind, the write goes on
+		if err := s.sweep(time.Now()); err != nil {
+			slog.Warn("sweeping expired idempotency records", "dir", s.dir, "err", err)
+		}
+	}
+
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(s.dir, "tmp-*")
+	if errors.Is(err, fs.ErrNotExist) {
+		if err := os.MkdirAll(s.dir, 0o755); err != nil {
+			return err
+		}
+		tmp, err = os.CreateTemp(s.dir, "tmp-*")
+	}
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path(key))
+}
@@ -0,0 +1,111 @@
+package idempotency
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFileStore(t *testing.T) {
+	ctx := context.Background()
+	dir := filepath.Join(t.TempDir(), "idempotency")
+	s := NewFileStore(dir)
+	if _, err := os.Stat(dir); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("NewFileStore made %s: %v", dir, err)
+	}
+
+	future := time.Now().Add(time.Hour)
+	steps := []struct {
+		name string
+		do   func() (*Record, error)
+		want *Record
+	}{
+		{"reserve makes the directory", func() (*Record, error) {
+			return s.Reserve(ctx, "k", Record{Fingerprint: "f", InFlight: true, ExpiresAt: future})
+		}, nil},
+		{"reserve again finds the record", func() (*Record, error) {
+			return s.Reserve(ctx, "k", Record{Fingerprint: "g", InFlight: true, ExpiresAt: future})
+		}, &Record{Fingerprint: "f", InFlight: true}},
+		{"complete replaces it", func() (*Record, error) {
+			return nil, s.Complete(ctx, "k", Record{Fingerprint: "f", Status: 200, ExpiresAt: future})
+		}, nil},
+		{"reserve finds the response", func() (*Record, error) {
+			return s.Reserve(ctx, "k", Record{Fingerprint: "f", InFlight: true, ExpiresAt: future})
+		}, &Record{Fingerprint: "f", Status: 200}},
+		{"release deletes it", func() (*Record, error) {
+			return nil, s.Release(ctx, "k")
+		}, nil},
+		{"release of nothing", func() (*Record, error) {
+			return nil, s.Release(ctx, "k")
+		}, nil},
+		{"reserve after release", func() (*Record, error) {
+			return s.Reserve(ctx, "k", Record{Fingerprint: "g", InFlight: true, ExpiresAt: future})
+		}, nil},
+	}
+	for _, step := range steps {
+		got, err := step.do()
+		if err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		switch {
+		case step.want == nil && got != nil:
+			t.Errorf("%s: got %+v, want none", step.name, got)
+		case step.want != nil && (got == nil || got.Fingerprint != step.want.Fingerprint ||
+			got.InFlight != step.want.InFlight || got.Status != step.want.Status):
+			t.Errorf("%s: got %+v, want %+v", step.name, got, step.want)
+		}
+	}
+}
+
+func TestFileStoreSweep(t *testing.T) {
+	s := NewFileStore(t.TempDir())
+	s.sweepEvery = 3
+	plant := func(key string, expiresAt time.Time) {
+		data, _ := json.Marshal(Record{Fingerprint: key, ExpiresAt: expiresAt})
+		if err := os.WriteFile(s.path(key), data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	exists := func(key string) bool {
+		_, err := os.Stat(s.path(key))
+		return err == nil
+	}
+	past, future := time.Now().Add(-time.Hour), time.Now().Add(time.Hour)
+
+	// Records of an earlier run are swept by the first write
+	plant("earlier-expired", past)
+	plant("earlier-live", future)
+	steps := []struct {
+		plant string
+		write string
+		// gone and kept are checked after the write
+		gone, kept []string
+	}{
+		{"", "w1", []string{"earlier-expired"}, []string{"earlier-live", "w1"}},
+		{"expired-1", "w2", nil, []string{"expired-1"}},
+		{"", "w3", nil, []string{"expired-1"}},
+		{"", "w4", []string{"expired-1"}, []string{"earlier-live", "w1", "w2", "w3", "w4"}},
+	}
+	for _, step := range steps {
+		if step.plant != "" {
+			plant(step.plant, past)
+		}
+		if _, err := s.Reserve(context.Background(), step.write, Record{InFlight: true, ExpiresAt: future}); err != nil {
+			t.Fatal(err)
+		}
+		for _, key := range step.gone {
+			if exists(key) {
+				t.Errorf("after %s: %s wasn't swept", step.write, key)
+			}
+		}
+		for _, key := range step.kept {
+			if !exists(key) {
+				t.Errorf("after %s: %s is gone", step.write, key)
+			}
+		}
+	}
+}
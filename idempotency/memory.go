@@ -0,0 +1,69 @@
+package idempotency
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps records in process memory. Records are lost on restart
+// and aren't shared between replicas.
+type MemoryStore struct {
+	mu      sync.Mutex
+	records map[string]Record
+	// Expired records are swept every sweepEvery writes
+	sweepEvery int
+	writes     int
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		records:    make(map[string]Record),
+		sweepEvery: 1000,
+	}
+}
+
+// Reserve implements Store.
+func (s *MemoryStore) Reserve(_ context.Context, key string, rec Record) (*Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if existing, ok := s.records[key]; ok && !existing.expired(now) {
+		return &existing, nil
+	}
+	s.put(key, rec, now)
+	return nil, nil
+}
+
+// Complete implements Store.
+func (s *MemoryStore) Complete(_ context.Context, key string, rec Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.put(key, rec, time.Now())
+	return nil
+}
+
+// Release implements Store.
+func (s *MemoryStore) Release(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.records, key)
+	return nil
+}
+
+// put must be called with s.mu held.
+func (s *MemoryStore) put(key string, rec Record, now time.Time) {
+	s.records[key] = rec
+	s.writes++
+	if s.writes%s.sweepEvery != 0 {
+		return
+	}
+	for k, r := range s.records {
+		if r.expired(now) {
+			delete(s.records, k)
+		}
+	}
+}
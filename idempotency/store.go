@@ -0,0 +1,37 @@
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrNotFound is returned when there is no record for the key.
+var ErrNotFound = errors.New("idempotency record not found")
+
+// Record is what gets stored per Idempotency-Key.
+type Record struct {
+	// Fingerprint identifies the request that created the record
+	Fingerprint string    `json:"fingerprint"`
+	InFlight    bool      `json:"inFlight"`
+	Status      int       `json:"status,omitempty"`
+	ContentType string    `json:"contentType,omitempty"`
+	Body        []byte    `json:"body,omitempty"`
+	ExpiresAt   time.Time `json:"expiresAt"`
+}
+
+func (r *Record) expired(now time.Time) bool {
+	return !r.ExpiresAt.IsZero() && now.After(r.ExpiresAt)
+}
+
+// Store keeps idempotency records. Implementations must be safe for
+// concurrent use.
+type Store interface {
+	// Reserve atomically stores rec under key unless a live record already
+	// exists, in which case the existing record is returned and nothing is stored.
+	Reserve(ctx context.Context, key string, rec Record) (existing *Record, err error)
+	// Complete replaces the record under key with the finished response.
+	Complete(ctx context.Context, key string, rec Record) error
+	// Release deletes the record so that the request may be retried.
+	Release(ctx context.Context, key string) error
+}
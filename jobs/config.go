@@ -0,0 +1,59 @@
+package jobs
+
+import (
+	"fmt"
+	"time"
+
+	"kit-fiber-example/config"
+)
+
+// NewQueueFromConfig builds a queue backed by a FileStore. The tracker may be nil.
+func NewQueueFromConfig(cfg *config.Config, process Processor, tracker Tracker) (*Queue, error) {
+	store := NewFileStore(cfg.Jobs.Dir)
+
+	backoff, err := parseDuration(cfg.Jobs.RetryBackoff)
+	if err != nil {
+		return nil, fmt.Errorf("jobs.retryBackoff: %w", err)
+	}
+	timeout, err := parseDuration(cfg.Jobs.Timeout)
+	if err != nil {
+		return nil, fmt.Errorf("jobs.timeout: %w", err)
+	}
+	retention, err := parseDuration(cfg.Jobs.Retention)
+	if err != nil {
+		return nil, fmt.Errorf("jobs.retention: %w", err)
+	}
+
+	opts := Options{
+		Workers:      cfg.Jobs.Workers,
+		QueueSize:    cfg.Jobs.QueueSize,
+		MaxAttempts:  cfg.Jobs.MaxAttempts,
+		RetryBackoff: backoff,
+		Timeout:      timeout,
+		Tracker:      tracker,
+		Retention:    retention,
+	}
+	if cfg.Jobs.Webhook.Secret != "" {
+		webhookTimeout, err := parseDuration(cfg.Jobs.Webhook.Timeout)
+		if err != nil {
+			return nil, fmt.Errorf("jobs.webhook.timeout: %w", err)
+		}
+		var webhookOptions []WebhookOption
+		if len(cfg.Jobs.Webhook.AllowedHosts) > 0 {
+			webhookOptions = append(webhookOptions, AllowHosts(cfg.Jobs.Webhook.AllowedHosts...))
+		}
+		if cfg.Jobs.Webhook.AllowPrivateNetworks {
+			webhookOptions = append(webhookOptions, AllowPrivateNetworks())
+		}
+		opts.Webhook = NewWebhook(cfg.Jobs.Webhook.Secret, webhookTimeout, cfg.Jobs.Webhook.MaxAttempts, webhookOptions...)
+	}
+
+	return NewQueue(store, process, opts), nil
+}
+
+func parseDuration(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+	return time.ParseDuration(s)
+}
@@ -0,0 +1,78 @@
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+type Status string
+
+const (
+	StatusQueued    Status = "queued"
+	StatusRunning   Status = "running"
+	StatusSucceeded Status = "succeeded"
+	StatusFailed    Status = "failed" // failed attempt, will be retried
+	StatusDead      Status = "dead"   // out of attempts
+)
+
+var (
+	ErrNotFound  = errors.New("job not found")
+	ErrQueueFull = errors.New("job queue is full")
+	ErrStopped   = errors.New("job queue is stopped")
+)
+
+// Job is a unit of asynchronous work. Request and Result are opaque to the
+// queue, they are interpreted by the Processor.
+type Job struct {
+	ID          string          `json:"id"`
+	Kind        string          `json:"kind"`
+	Status      Status          `json:"status"`
+	Request     json.RawMessage `json:"request"`
+	Result      json.RawMessage `json:"result,omitempty"`
+	Error       string          `json:"error,omitempty"`
+	Attempts    int             `json:"attempts"`
+	CallbackURL string          `json:"callbackUrl,omitempty"`
+	// CallbackError is the last webhook delivery failure, if any
+	CallbackError string    `json:"callbackError,omitempty"`
+	CreatedAt     time.Time `json:"createdAt"`
+	UpdatedAt     time.Time `json:"updatedAt"`
+}
+
+// Done reports whether the job reached a final state.
+func (j *Job) Done() bool {
+	return j.Status == StatusSucceeded || j.Status == StatusDead
+}
+
+// Processor executes a job and returns its result. Errors are retried
+// unless they are marked with Permanent.
+type Processor func(ctx context.Context, job *Job) (json.RawMessage, error)
+
+// Permanent marks a processor error that another attempt can't fix, like an
+// invalid request. The job is dead at once instead of being retried.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return permanentError{err}
+}
+
+type permanentError struct{ err error }
+
+func (e permanentError) Error() string { return e.err.Error() }
+func (e permanentError) Unwrap() error { return e.err }
+
+// IsPermanent reports whether err was marked with Permanent
+func IsPermanent(err error) bool {
+	var pe permanentError
+	return errors.As(err, &pe)
+}
+
+func newID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,334 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+)
+
+type Options struct {
+	Workers     int
+	QueueSize   int
+	MaxAttempts int
+	// RetryBackoff is multiplied by the attempt number
+	RetryBackoff time.Duration
+	// Timeout bounds a single attempt
+	Timeout time.Duration
+	// Webhook is optional, jobs without a callback URL never use it
+	Webhook *Webhook
+	// Tracker is optional, it lets shutdown wait for running attempts
+	Tracker Tracker
+	// Retention is how long finished jobs are kept, zero keeps them forever
+	Retention time.Duration
+}
+
+// Tracker registers running attempts. Attempts it refuses stay queued for
+// the next start, attempts whose context it cancels aren't counted.
+type Tracker interface {
+	Track(ctx context.Context, kind string) (context.Context, func(), error)
+}
+
+// trackerKind is the kind of work attempts are tracked as
+const trackerKind = "job"
+
+// sweepEvery is how often finished jobs past their retention are deleted
+const sweepEvery = time.Hour
+
+// Queue runs jobs on a pool of workers. Every state change is persisted
+// before it becomes visible, and unfinished jobs are picked up again by Start.
+type Queue struct {
+	store   Store
+	process Processor
+	opts    Options
+
+	pending chan string
+	ctx     context.Context
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+}
+
+func NewQueue(store Store, process Processor, opts Options) *Queue {
+	if opts.Workers < 1 {
+		opts.Workers = 1
+	}
+	if opts.QueueSize < 1 {
+		opts.QueueSize = 100
+	}
+	if opts.MaxAttempts < 1 {
+		opts.MaxAttempts = 1
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Queue{
+		store:   store,
+		process: process,
+		opts:    opts,
+		pending: make(chan string, opts.QueueSize),
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+}
+
+// Start requeues unfinished jobs from the store and starts the workers.
+func (q *Queue) Start() error {
+	stored, err := q.store.List(q.ctx)
+	if err != nil {
+		return err
+	}
+	for _, job := range stored {
+		if job.Done() {
+			continue
+		}
+		// A running job was interrupted by the restart, try it again
+		job.Status = StatusQueued
+		if err := q.store.Save(q.ctx, job); err != nil {
+			return err
+		}
+		q.schedule(job.ID, 0)
+	}
+
+	for i := 0; i < q.opts.Workers; i++ {
+		q.wg.Add(1)
+		go q.work()
+	}
+	if q.opts.Retention > 0 {
+		q.wg.Add(1)
+		go q.sweepLoop()
+	}
+	return nil
+}
+
+// Stop stops the workers and waits for running attempts until ctx is done.
+// Interrupted jobs stay queued in the store.
+func (q *Queue) Stop(ctx context.Context) error {
+	q.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		q.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Enqueue stores a new job and schedules it.
+func (q *Queue) Enqueue(ctx context.Context, kind string, request json.RawMessage, callbackURL string) (*Job, error) {
+	if q.ctx.Err() != nil {
+		return nil, ErrStopped
+	}
+	if len(q.pending) == cap(q.pending) {
+		return nil, ErrQueueFull
+	}
+
+	now := time.Now()
+	job := &Job{
+		ID:          newID(),
+		Kind:        kind,
+		Status:      StatusQueued,
+		Request:     request,
+		CallbackURL: callbackURL,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if err := q.store.Save(ctx, job); err != nil {
+		return nil, err
+	}
+
+	select {
+	case q.pending <- job.ID:
+	default:
+		// Lost the race for the last slot, wait for one in the background
+		q.schedule(job.ID, q.opts.RetryBackoff)
+	}
+	return job, nil
+}
+
+// Check reports the queue depth for the health report, a stopped or full
+// queue is failing.
+func (q *Queue) Check(context.Context) (any, error) {
+	detail := map[string]int{
+		"pending":  len(q.pending),
+		"capacity": cap(q.pending),
+		"workers":  q.opts.Workers,
+	}
+	switch {
+	case q.ctx.Err() != nil:
+		return detail, ErrStopped
+	case len(q.pending) == cap(q.pending):
+		return detail, ErrQueueFull
+	}
+	return detail, nil
+}
+
+// Get returns the current state of a job.
+func (q *Queue) Get(ctx context.Context, id string) (*Job, error) {
+	return q.store.Get(ctx, id)
+}
+
+func (q *Queue) schedule(id string, delay time.Duration) {
+	q.wg.Add(1)
+	go func() {
+		defer q.wg.Done()
+		select {
+		case <-time.After(delay):
+		case <-q.ctx.Done():
+			return
+		}
+		select {
+		case q.pending <- id:
+		case <-q.ctx.Done():
+		}
+	}()
+}
+
+func (q *Queue) work() {
+	defer q.wg.Done()
+	for {
+		select {
+		case id := <-q.pending:
+			if err := q.run(id); err != nil {
+				log.Printf("job %s: %v", id, err)
+			}
+		case <-q.ctx.Done():
+			return
+		}
+	}
+}
+
+func (q *Queue) run(id string) error {
+	ctx := q.ctx
+	if q.opts.Tracker != nil {
+		tracked, done, err := q.opts.Tracker.Track(ctx, trackerKind)
+		if err != nil {
+			// Shutting down, the job is still queued in the store
+			return nil
+		}
+		defer done()
+		ctx = tracked
+	}
+
+	job, err := q.store.Get(q.ctx, id)
+	if err != nil {
+		return err
+	}
+	if job.Status != StatusQueued && job.Status != StatusFailed {
+		return nil
+	}
+
+	job.Status = StatusRunning
+	job.Attempts++
+	job.UpdatedAt = time.Now()
+	if err := q.store.Save(q.ctx, job); err != nil {
+		return err
+	}
+
+	attemptCtx := ctx
+	if q.opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		attemptCtx, cancel = context.WithTimeout(ctx, q.opts.Timeout)
+		defer cancel()
+	}
+	result, err := q.process(attemptCtx, job)
+
+	if ctx.Err() != nil {
+		// Shutting down: leave the job for the next start and don't count
+		// the interrupted attempt
+		job.Status = StatusQueued
+		job.Attempts--
+		return q.store.Save(context.Background(), job)
+	}
+
+	job.UpdatedAt = time.Now()
+	retry := false
+	switch {
+	case err == nil:
+		job.Status = StatusSucceeded
+		job.Result = result
+		job.Error = ""
+	case job.Attempts < q.opts.MaxAttempts && !IsPermanent(err):
+		job.Status = StatusFailed
+		job.Error = err.Error()
+		retry = true
+	default:
+		job.Status = StatusDead
+		job.Error = err.Error()
+	}
+	if err := q.store.Save(q.ctx, job); err != nil {
+		return err
+	}
+	if retry {
+		q.schedule(job.ID, time.Duration(job.Attempts)*q.opts.RetryBackoff)
+	}
+
+	if job.Done() && job.CallbackURL != "" && q.opts.Webhook != nil {
+		q.deliver(job)
+	}
+	return nil
+}
+
+func (q *Queue) sweepLoop() {
+	defer q.wg.Done()
+	ticker := time.NewTicker(sweepEvery)
+	defer ticker.Stop()
+	for {
+		if _, err := q.sweep(time.Now()); err != nil {
+			log.Printf("jobs: sweeping finished jobs: %v", err)
+		}
+		select {
+		case <-ticker.C:
+		case <-q.ctx.Done():
+			return
+		}
+	}
+}
+
+// sweep deletes the jobs that finished more than Retention before now and
+// returns how many it deleted
+func (q *Queue) sweep(now time.Time) (int, error) {
+	stored, err := q.store.List(q.ctx)
+	if err != nil {
+		return 0, err
+	}
+	deleted := 0
+	for _, job := range stored {
+		if !job.Done() || now.Sub(job.UpdatedAt) < q.opts.Retention {
+			continue
+		}
+		if err := q.store.Delete(q.ctx, job.ID); err != nil {
+			return deleted, err
+		}
+		deleted++
+	}
+	return deleted, nil
+}
+
+// deliver posts the job to its callback in the background, so that the
+// retries of a failing callback don't hold a worker
+func (q *Queue) deliver(job *Job) {
+	q.wg.Add(1)
+	go func() {
+		defer q.wg.Done()
+		if err := q.opts.Webhook.Deliver(q.ctx, job); err != nil {
+			job.CallbackError = err.Error()
+			if err := q.store.Save(context.Background(), job); err != nil {
+				log.Printf("job %s: saving callback error: %v", job.ID, err)
+			}
+		}
+	}()
+}
+
+// CheckCallbackURL returns an error wrapping ErrCallbackNotAllowed for
+// callback URLs the webhook won't deliver to
+func (q *Queue) CheckCallbackURL(ctx context.Context, url string) error {
+	if url == "" || q.opts.Webhook == nil {
+		return nil
+	}
+	return q.opts.Webhook.Check(ctx, url)
+}
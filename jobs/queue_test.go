@@ -0,0 +1,186 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// waitDone polls the store until the job is finished
+func waitDone(t *testing.T, s Store, id string) *Job {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		job, err := s.Get(context.Background(), id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if job.Done() {
+			return job
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("job %s didn't finish", id)
+	return nil
+}
+
+func TestQueueAttempts(t *testing.T) {
+	fail := errors.New("upstream failed")
+	tests := []struct {
+		name string
+		// results are returned by the attempts in order, the last one repeats
+		results  []error
+		status   Status
+		attempts int
+	}{
+		{"succeeds", []error{nil}, StatusSucceeded, 1},
+		{"retried until it succeeds", []error{fail, fail, nil}, StatusSucceeded, 3},
+		{"dead after max attempts", []error{fail}, StatusDead, 3},
+		{"permanent error isn't retried", []error{Permanent(fail)}, StatusDead, 1},
+		{"permanent after a retry", []error{fail, Permanent(fail)}, StatusDead, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			calls := 0
+			process := func(context.Context, *Job) (json.RawMessage, error) {
+				mu.Lock()
+				defer mu.Unlock()
+				err := tt.results[min(calls, len(tt.results)-1)]
+				calls++
+				if err != nil {
+					return nil, err
+				}
+				return json.RawMessage(`"ok"`), nil
+			}
+			store := NewFileStore(t.TempDir())
+			q := NewQueue(store, process, Options{MaxAttempts: 3, RetryBackoff: time.Millisecond})
+			if err := q.Start(); err != nil {
+				t.Fatal(err)
+			}
+			defer q.Stop(context.Background())
+
+			job, err := q.Enqueue(context.Background(), "test", json.RawMessage(`{}`), "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			job = waitDone(t, store, job.ID)
+			if job.Status != tt.status || job.Attempts != tt.attempts {
+				t.Errorf("job = %s after %d attempts, want %s after %d", job.Status, job.Attempts, tt.status, tt.attempts)
+			}
+			if (job.Error != "") != (tt.status == StatusDead) {
+				t.Errorf("error = %q", job.Error)
+			}
+		})
+	}
+}
+
+func TestQueueRestart(t *testing.T) {
+	store := NewFileStore(t.TempDir())
+	ctx := context.Background()
+	stored := []*Job{
+		{ID: "queued", Status: StatusQueued},
+		{ID: "running", Status: StatusRunning, Attempts: 1},
+		{ID: "failed", Status: StatusFailed, Attempts: 1},
+		{ID: "done", Status: StatusSucceeded, Attempts: 1, Result: json.RawMessage(`"old"`)},
+	}
+	for _, job := range stored {
+		if err := store.Save(ctx, job); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	process := func(context.Context, *Job) (json.RawMessage, error) { return json.RawMessage(`"new"`), nil }
+	q := NewQueue(store, process, Options{MaxAttempts: 3})
+	if err := q.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer q.Stop(ctx)
+
+	for _, want := range []struct {
+		id       string
+		attempts int
+		result   string
+	}{
+		{"queued", 1, `"new"`},
+		{"running", 2, `"new"`},
+		{"failed", 2, `"new"`},
+		{"done", 1, `"old"`},
+	} {
+		job := waitDone(t, store, want.id)
+		if job.Status != StatusSucceeded || job.Attempts != want.attempts || string(job.Result) != want.result {
+			t.Errorf("%s = %s after %d attempts with %s, want succeeded after %d with %s",
+				want.id, job.Status, job.Attempts, job.Result, want.attempts, want.result)
+		}
+	}
+}
+
+func TestQueueStopKeepsInterruptedJobs(t *testing.T) {
+	store := NewFileStore(t.TempDir())
+	started := make(chan struct{})
+	process := func(ctx context.Context, _ *Job) (json.RawMessage, error) {
+		close(started)
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	q := NewQueue(store, process, Options{MaxAttempts: 1})
+	if err := q.Start(); err != nil {
+		t.Fatal(err)
+	}
+	job, err := q.Enqueue(context.Background(), "test", json.RawMessage(`{}`), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-started
+	if err := q.Stop(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// The interrupted attempt isn't counted, the job runs again on start
+	job, err = store.Get(context.Background(), job.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.Status != StatusQueued || job.Attempts != 0 {
+		t.Errorf("job = %s after %d attempts, want queued after 0", job.Status, job.Attempts)
+	}
+	if _, err := q.Enqueue(context.Background(), "test", nil, ""); !errors.Is(err, ErrStopped) {
+		t.Errorf("Enqueue() after Stop = %v, want ErrStopped", err)
+	}
+}
+
+func TestQueueSweep(t *testing.T) {
+	store := NewFileStore(t.TempDir())
+	ctx := context.Background()
+	now := time.Now()
+	jobs := []struct {
+		job  *Job
+		kept bool
+	}{
+		{&Job{ID: "old-succeeded", Status: StatusSucceeded, UpdatedAt: now.Add(-2 * time.Hour)}, false},
+		{&Job{ID: "old-dead", Status: StatusDead, UpdatedAt: now.Add(-2 * time.Hour)}, false},
+		{&Job{ID: "recent", Status: StatusSucceeded, UpdatedAt: now.Add(-time.Minute)}, true},
+		{&Job{ID: "old-queued", Status: StatusQueued, UpdatedAt: now.Add(-2 * time.Hour)}, true},
+		{&Job{ID: "old-failed", Status: StatusFailed, UpdatedAt: now.Add(-2 * time.Hour)}, true},
+	}
+	for _, j := range jobs {
+		if err := store.Save(ctx, j.job); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	q := NewQueue(store, nil, Options{Retention: time.Hour})
+	deleted, err := q.sweep(now)
+	if err != nil || deleted != 2 {
+		t.Errorf("sweep() = %d, %v, want 2 deleted", deleted, err)
+	}
+	for _, j := range jobs {
+		_, err := store.Get(ctx, j.job.ID)
+		if kept := err == nil; kept != j.kept {
+			t.Errorf("%s kept = %t, want %t (%v)", j.job.ID, kept, j.kept, err)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileStore(t *testing.T) {
+	ctx := context.Background()
+	dir := filepath.Join(t.TempDir(), "jobs")
+	s := NewFileStore(dir)
+	if _, err := os.Stat(dir); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("NewFileStore made %s: %v", dir, err)
+	}
+
+	// Reads of the missing directory find nothing
+	if jobs, err := s.List(ctx); err != nil || len(jobs) != 0 {
+		t.Errorf("List() = %v, %v, want no jobs", jobs, err)
+	}
+	if err := s.Save(ctx, &Job{ID: "a", Status: StatusQueued}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Save(ctx, &Job{ID: "a", Status: StatusSucceeded}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		id     string
+		status Status
+		err    error
+	}{
+		{"a", StatusSucceeded, nil},
+		{"b", "", ErrNotFound},
+		{"", "", ErrNotFound},
+		{"../a", "", ErrNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.id, func(t *testing.T) {
+			job, err := s.Get(ctx, tt.id)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("Get() error = %v, want %v", err, tt.err)
+			}
+			if err == nil && job.Status != tt.status {
+				t.Errorf("Get().Status = %s, want %s", job.Status, tt.status)
+			}
+		})
+	}
+	if jobs, err := s.List(ctx); err != nil || len(jobs) != 1 {
+		t.Errorf("List() = %v, %v, want one job", jobs, err)
+	}
+}
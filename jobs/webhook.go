@@ -0,0 +1,220 @@
+package jobs
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	HeaderSignature = "X-Webhook-Signature"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+
+	maxRedirects = 5
+)
+
+// ErrCallbackNotAllowed is returned for callback URLs the server must not
+// call, like its own admin port or other hosts of the private network.
+var ErrCallbackNotAllowed = errors.New("callback URL is not allowed")
+
+// Webhook delivers finished jobs to their callback URL. The body is signed
+// with HMAC-SHA256 over "<timestamp>.<body>", so receivers can verify the
+// sender and reject replays.
+//
+// Callback URLs come from clients. Only public addresses are called unless
+// AllowPrivateNetworks is given, which is checked on every connection, so
+// redirects and DNS answers changing after the check can't get around it.
+type Webhook struct {
+	client       *http.Client
+	secret       []byte
+	attempts     int
+	allowedHosts []string
+	allowPrivate bool
+}
+
+type WebhookOption func(*Webhook)
+
+// AllowHosts restricts callbacks to the given hosts. "*.example.com"
+// matches the subdomains of example.com.
+func AllowHosts(hosts ...string) WebhookOption {
+	return func(w *Webhook) { w.allowedHosts = hosts }
+}
+
+// AllowPrivateNetworks lets callbacks reach loopback, link-local and
+// private addresses, for development setups only.
+func AllowPrivateNetworks() WebhookOption {
+	return func(w *Webhook) { w.allowPrivate = true }
+}
+
+func NewWebhook(secret string, timeout time.Duration, attempts int, options ...WebhookOption) *Webhook {
+	if attempts < 1 {
+		attempts = 1
+	}
+	w := &Webhook{
+		secret:   []byte(secret),
+		attempts: attempts,
+	}
+	for _, option := range options {
+		option(w)
+	}
+
+	dialer := &net.Dialer{Timeout: timeout, Control: w.control}
+	w.client = &http.Client{
+		Timeout: timeout,
+		// No proxy: the address checks must see the callback host itself
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: timeout,
+			MaxIdleConns:        10,
+			IdleConnTimeout:     time.Minute,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxRedirects {
+				return fmt.Errorf("stopped after %d redirects", maxRedirects)
+			}
+			return w.checkHost(req.URL)
+		},
+	}
+	return w
+}
+
+// Check rejects callback URLs that can't be delivered to, so that clients
+// learn about it when creating the job.
+func (w *Webhook) Check(ctx context.Context, rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("%w: scheme must be http or https", ErrCallbackNotAllowed)
+	}
+	if err := w.checkHost(u); err != nil {
+		return err
+	}
+	if w.allowPrivate {
+		return nil
+	}
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", u.Hostname())
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrCallbackNotAllowed, err)
+	}
+	for _, addr := range addrs {
+		if addr = addr.Unmap(); !isPublic(addr) {
+			return fmt.Errorf("%w: %s resolves to %s", ErrCallbackNotAllowed, u.Hostname(), addr)
+		}
+	}
+	return nil
+}
+
+func (w *Webhook) checkHost(u *url.URL) error {
+	if len(w.allowedHosts) == 0 {
+		return nil
+	}
+	host := strings.ToLower(u.Hostname())
+	allowed := slices.ContainsFunc(w.allowedHosts, func(pattern string) bool {
+		pattern = strings.ToLower(pattern)
+		if suffix, ok := strings.CutPrefix(pattern, "*"); ok {
+			return strings.HasSuffix(host, suffix)
+		}
+		return host == pattern
+	})
+	if !allowed {
+		return fmt.Errorf("%w: %s is not an allowed host", ErrCallbackNotAllowed, host)
+	}
+	return nil
+}
+
+// control runs before every connection, with the address DNS resolved to
+func (w *Webhook) control(_, address string, _ syscall.RawConn) error {
+	if w.allowPrivate {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return err
+	}
+	if !isPublic(addr) {
+		return fmt.Errorf("%w: %s is not a public address", ErrCallbackNotAllowed, addr)
+	}
+	return nil
+}
+
+// sharedAddressSpace is the carrier-grade NAT range of RFC 6598
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+func isPublic(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsGlobalUnicast() &&
+		!addr.IsPrivate() &&
+		!sharedAddressSpace.Contains(addr)
+}
+
+// Sign returns the signature header value for body sent at timestamp.
+func (w *Webhook) Sign(timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, w.secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Deliver posts the job to its callback URL, retrying with a linear backoff.
+func (w *Webhook) Deliver(ctx context.Context, job *Job) error {
+	body, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+
+	for attempt := 1; ; attempt++ {
+		err = w.post(ctx, job.CallbackURL, body)
+		if err == nil || attempt >= w.attempts || errors.Is(err, ErrCallbackNotAllowed) {
+			return err
+		}
+		select {
+		case <-time.After(time.Duration(attempt) * time.Second):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (w *Webhook) post(ctx context.Context, url string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderTimestamp, timestamp)
+	req.Header.Set(HeaderSignature, w.Sign(timestamp, body))
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
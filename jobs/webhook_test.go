@@ -0,0 +1,133 @@
+package jobs
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+)
+
+func TestSign(t *testing.T) {
+	w := NewWebhook("secret", time.Second, 1)
+	body := []byte(`{"id":"1"}`)
+
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write([]byte("1700000000." + string(body)))
+	want := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	if got := w.Sign("1700000000", body); got != want {
+		t.Errorf("Sign() = %s, want %s", got, want)
+	}
+	if w.Sign("1700000001", body) == want {
+		t.Error("signature doesn't cover the timestamp")
+	}
+	if NewWebhook("other", time.Second, 1).Sign("1700000000", body) == want {
+		t.Error("signature doesn't depend on the secret")
+	}
+}
+
+func TestDeliver(t *testing.T) {
+	var (
+		gotBody   []byte
+		gotHeader http.Header
+	)
+	status := http.StatusOK
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotBody, _ = io.ReadAll(r.Body)
+		gotHeader = r.Header.Clone()
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+	job := &Job{ID: "1", Status: StatusSucceeded, CallbackURL: srv.URL}
+
+	// The test server is on loopback, which is only reachable when allowed
+	private := NewWebhook("secret", time.Second, 1, AllowPrivateNetworks())
+	if err := private.Deliver(context.Background(), job); err != nil {
+		t.Fatal(err)
+	}
+	timestamp := gotHeader.Get(HeaderTimestamp)
+	if got, want := gotHeader.Get(HeaderSignature), private.Sign(timestamp, gotBody); got != want {
+		t.Errorf("signature = %s, want %s", got, want)
+	}
+
+	status = http.StatusInternalServerError
+	if err := private.Deliver(context.Background(), job); err == nil {
+		t.Error("Deliver() to a failing receiver succeeded")
+	}
+
+	public := NewWebhook("secret", time.Second, 3)
+	if err := public.Deliver(context.Background(), job); !errors.Is(err, ErrCallbackNotAllowed) {
+		t.Errorf("Deliver() to loopback = %v, want ErrCallbackNotAllowed", err)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name    string
+		options []WebhookOption
+		url     string
+		ok      bool
+	}{
+		{"public address", nil, "https://8.8.8.8/hook", true},
+		{"ftp", nil, "ftp://8.8.8.8/hook", false},
+		{"loopback", nil, "http://127.0.0.1:8081/metrics", false},
+		{"IPv6 loopback", nil, "http://[::1]/hook", false},
+		{"IPv4-mapped loopback", nil, "http://[::ffff:127.0.0.1]/hook", false},
+		{"private network", nil, "http://10.0.0.5/hook", false},
+		{"link-local metadata", nil, "http://169.254.169.254/latest", false},
+		{"shared address space", nil, "http://100.64.1.1/hook", false},
+		{"unspecified", nil, "http://0.0.0.0/hook", false},
+		{"private allowed", []WebhookOption{AllowPrivateNetworks()}, "http://127.0.0.1/hook", true},
+		{"allowed host", []WebhookOption{AllowHosts("8.8.8.8")}, "https://8.8.8.8/hook", true},
+		{"host not allowed", []WebhookOption{AllowHosts("hooks.example.com")}, "https://8.8.8.8/hook", false},
+		{"subdomain pattern", []WebhookOption{AllowHosts("*.example.com"), AllowPrivateNetworks()}, "https://A.Example.com/hook", true},
+		{"pattern isn't the domain", []WebhookOption{AllowHosts("*.example.com"), AllowPrivateNetworks()}, "https://evilexample.org/hook", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := NewWebhook("secret", time.Second, 1, tt.options...)
+			err := w.Check(context.Background(), tt.url)
+			if (err == nil) != tt.ok {
+				t.Errorf("Check() = %v, want ok %t", err, tt.ok)
+			}
+			if err != nil && !errors.Is(err, ErrCallbackNotAllowed) {
+				t.Errorf("Check() = %v, want ErrCallbackNotAllowed", err)
+			}
+		})
+	}
+}
+
+func TestIsPublic(t *testing.T) {
+	tests := []struct {
+		addr   string
+		public bool
+	}{
+		{"8.8.8.8", true},
+		{"2606:4700:4700::1111", true},
+		{"127.0.0.1", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false},
+		{"100.64.0.1", false},
+		{"100.128.0.1", true},
+		{"::1", false},
+		{"fe80::1", false},
+		{"fc00::1", false},
+		{"::ffff:10.0.0.1", false},
+		{"224.0.0.1", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.addr, func(t *testing.T) {
+			if got := isPublic(netip.MustParseAddr(tt.addr)); got != tt.public {
+				t.Errorf("isPublic() = %t, want %t", got, tt.public)
+			}
+		})
+	}
+}
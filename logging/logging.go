@@ -0,0 +1,57 @@
+// Package logging sets up the process logger. Its level can be changed
+// while the service runs, e.g. from the admin server.
+package logging
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+
+	"kit-fiber-example/config"
+)
+
+var level = new(slog.LevelVar)
+
+// NewLoggerFromConfig makes a logger with the configured format. Its level
+// is shared by every logger of this package and can be changed with SetLevel.
+func NewLoggerFromConfig(cfg *config.Config) (*slog.Logger, error) {
+	l, err := ParseLevel(cfg.Log.Level)
+	if err != nil {
+		return nil, fmt.Errorf("log.level: %w", err)
+	}
+	level.Set(l)
+
+	opts := &slog.HandlerOptions{Level: level}
+	var handler slog.Handler
+	switch cfg.Log.Format {
+	case "", "text":
+		handler = slog.NewTextHandler(os.Stderr, opts)
+	case "json":
+		handler = slog.NewJSONHandler(os.Stderr, opts)
+	default:
+		return nil, fmt.Errorf("log.format: unknown format %q", cfg.Log.Format)
+	}
+	return slog.New(handler), nil
+}
+
+// Level is the current level of the default logger
+func Level() slog.Level {
+	return level.Level()
+}
+
+func SetLevel(l slog.Level) {
+	level.Set(l)
+}
+
+// ParseLevel parses debug, info, warn or error, optionally with an offset
+// like debug-4. Empty means info.
+func ParseLevel(s string) (slog.Level, error) {
+	var l slog.Level
+	if s == "" {
+		return l, nil
+	}
+	if err := l.UnmarshalText([]byte(s)); err != nil {
+		return l, err
+	}
+	return l, nil
+}
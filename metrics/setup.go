@@ -71,6 +71,25 @@ type Metrics struct {
 	RequestCount   Counter
 	RequestLatency Histogram
 	ErrorCount     Counter
+
+	InflightRequests Gauge
+	ConcurrencyLimit Gauge
+	LoadShedCount    Counter
+
+	PromptRequests Counter
+	PromptTokens   Counter
+
+	UpstreamRequests Counter
+	UpstreamLatency  Histogram
+
+	InputTokens       Histogram
+	ContextGuardCount Counter
+
+	ChatConnections Gauge
+	ChatMessages    Counter
+
+	InflightWork Gauge
+	AbortedWork  Counter
 }
 
 func Setup() *Metrics {
@@ -95,5 +114,98 @@ func Setup() *Metrics {
 			Name:      "error_count",
 			Help:      "Number of errors occurred.",
 		}, []string{"method"}),
+
+		InflightRequests: NewGaugeFrom(prometheus.GaugeOpts{
+			Namespace: "api",
+			Subsystem: "string_service",
+			Name:      "inflight_requests",
+			Help:      "Number of requests holding a concurrency slot.",
+		}, []string{"endpoint"}),
+
+		ConcurrencyLimit: NewGaugeFrom(prometheus.GaugeOpts{
+			Namespace: "api",
+			Subsystem: "string_service",
+			Name:      "concurrency_limit",
+			Help:      "Current concurrency limit of the endpoint bulkhead.",
+		}, []string{"endpoint"}),
+
+		LoadShedCount: NewCounterFrom(prometheus.CounterOpts{
+			Namespace: "api",
+			Subsystem: "string_service",
+			Name:      "load_shed_total",
+			Help:      "Number of requests rejected by the concurrency limiter.",
+		}, []string{"endpoint", "reason"}),
+
+		PromptRequests: NewCounterFrom(prometheus.CounterOpts{
+			Namespace: "api",
+			Subsystem: "string_service",
+			Name:      "prompt_requests_total",
+			Help:      "Number of prompt template invocations.",
+		}, []string{"template", "version", "error"}),
+
+		PromptTokens: NewCounterFrom(prometheus.CounterOpts{
+			Namespace: "api",
+			Subsystem: "string_service",
+			Name:      "prompt_tokens_total",
+			Help:      "Number of tokens used by prompt template invocations.",
+		}, []string{"template", "version", "direction"}),
+
+		UpstreamRequests: NewCounterFrom(prometheus.CounterOpts{
+			Namespace: "api",
+			Subsystem: "claude",
+			Name:      "requests_total",
+			Help:      "Number of model API calls by model and outcome.",
+		}, []string{"model", "outcome"}),
+
+		UpstreamLatency: NewHistogramFrom(prometheus.HistogramOpts{
+			Namespace: "api",
+			Subsystem: "claude",
+			Name:      "request_latency_seconds",
+			Help:      "Model API call duration in seconds.",
+			Buckets:   []float64{0.5, 1, 2.5, 5, 10, 20, 40, 80},
+		}, []string{"model"}),
+
+		InputTokens: NewHistogramFrom(prometheus.HistogramOpts{
+			Namespace: "api",
+			Subsystem: "claude",
+			Name:      "input_tokens",
+			Help:      "Input tokens per ask, as estimated before sending and as reported by the API.",
+			Buckets:   prometheus.ExponentialBuckets(16, 4, 9),
+		}, []string{"model", "source"}),
+
+		ContextGuardCount: NewCounterFrom(prometheus.CounterOpts{
+			Namespace: "api",
+			Subsystem: "claude",
+			Name:      "context_guard_total",
+			Help:      "Number of asks rejected or truncated for exceeding the context window.",
+		}, []string{"model", "action"}),
+
+		ChatConnections: NewGaugeFrom(prometheus.GaugeOpts{
+			Namespace: "api",
+			Subsystem: "chat",
+			Name:      "connections",
+			Help:      "Number of open chat WebSocket connections.",
+		}, []string{}),
+
+		ChatMessages: NewCounterFrom(prometheus.CounterOpts{
+			Namespace: "api",
+			Subsystem: "chat",
+			Name:      "messages_total",
+			Help:      "Number of chat messages by direction and type.",
+		}, []string{"direction", "type"}),
+
+		InflightWork: NewGaugeFrom(prometheus.GaugeOpts{
+			Namespace: "api",
+			Subsystem: "drain",
+			Name:      "inflight",
+			Help:      "Number of requests, streams, chat asks and jobs in flight.",
+		}, []string{"kind"}),
+
+		AbortedWork: NewCounterFrom(prometheus.CounterOpts{
+			Namespace: "api",
+			Subsystem: "drain",
+			Name:      "aborted_total",
+			Help:      "Number of requests, streams, chat asks and jobs canceled because the shutdown budget ran out.",
+		}, []string{"kind"}),
 	}
 }
@@ -0,0 +1,58 @@
+package middlewares
+
+import (
+	"context"
+	"errors"
+
+	"kit-fiber-example/concurrency"
+	"kit-fiber-example/metrics"
+)
+
+// ErrOverloaded is returned when the concurrency limiter sheds a request.
+var ErrOverloaded = errors.New("service overloaded")
+
+func concurrencyMiddleware[Req any, Res any](name string, b *concurrency.Bulkhead, m *metrics.Metrics) Middleware[Req, Res] {
+	inflight := m.InflightRequests.With("endpoint", name)
+	limit := m.ConcurrencyLimit.With("endpoint", name)
+	shed := m.LoadShedCount.With("endpoint", name)
+	limit.Set(float64(b.Limit()))
+
+	return func(next Endpoint[Req, Res]) Endpoint[Req, Res] {
+		return func(ctx context.Context, request Req) (_ Res, err error) {
+			token, err := b.Acquire(ctx)
+			if err != nil {
+				var zero Res
+				switch {
+				case errors.Is(err, concurrency.ErrQueueFull):
+					shed.With("reason", "queue_full").Add(1)
+				case errors.Is(err, concurrency.ErrQueueTimeout):
+					shed.With("reason", "queue_timeout").Add(1)
+				default:
+					// The caller went away while waiting
+					shed.With("reason", "canceled").Add(1)
+					return zero, err
+				}
+				return zero, errors.Join(ErrOverloaded, err)
+			}
+			inflight.Add(1)
+			// Deferred, so that a panicking endpoint gives its slot back too
+			defer func() {
+				inflight.Add(-1)
+				if errors.Is(err, context.DeadlineExceeded) {
+					token.Drop()
+				} else {
+					token.Release()
+				}
+				limit.Set(float64(b.Limit()))
+			}()
+
+			return next(ctx, request)
+		}
+	}
+}
+
+// WithConcurrencyLimit runs the endpoint behind the bulkhead b. Each endpoint
+// should get its own bulkhead so that slow endpoints can't starve the others.
+func WithConcurrencyLimit[Req any, Res any](name string, b *concurrency.Bulkhead, m *metrics.Metrics, endpoint Endpoint[Req, Res]) Endpoint[Req, Res] {
+	return concurrencyMiddleware[Req, Res](name, b, m)(endpoint)
+}
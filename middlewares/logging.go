@@ -2,29 +2,34 @@ package middlewares
 
 import (
 	"context"
-	"fmt"
+	"log/slog"
 	"time"
 )
 
-func LoggingMiddleware[Req any, Res any](next Endpoint[Req, Res]) Endpoint[Req, Res] {
-	return func(ctx context.Context, request Req) (Res, error) {
-		start := time.Now()
-		result, err := next(ctx, request)
-		duration := time.Since(start)
+func loggingMiddleware[Req any, Res any](name string) Middleware[Req, Res] {
+	return func(next Endpoint[Req, Res]) Endpoint[Req, Res] {
+		return func(ctx context.Context, request Req) (Res, error) {
+			start := time.Now()
+			result, err := next(ctx, request)
+			duration := time.Since(start)
 
-		// Log the request
-		fmt.Printf(
-			"method=%s path=%s duration=%s err=%v\n",
-			ctx.Value("method").(string),
-			ctx.Value("path").(string),
-			duration,
-			err,
-		)
+			// Log the request
+			slog.InfoContext(ctx, "endpoint called",
+				"method", name,
+				"duration", duration,
+				"err", err,
+			)
 
-		return result, err
+			return result, err
+		}
 	}
 }
 
+// WithLogging logs every call of the endpoint as method name
+func WithLogging[Req any, Res any](name string, endpoint Endpoint[Req, Res]) Endpoint[Req, Res] {
+	return loggingMiddleware[Req, Res](name)(endpoint)
+}
+
 /*func anotherLoggingMiddleware[Req any, Res any](logger *log.Logger) Middleware[Req, Res] {
 	return func(next Endpoint[Req, Res]) Endpoint[Req, Res] {
 		return func(ctx context.Context, request Req) (Res, error) {
@@ -0,0 +1,45 @@
+package middlewares
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"testing"
+)
+
+func TestWithLogging(t *testing.T) {
+	var buf bytes.Buffer
+	defer slog.SetDefault(slog.Default())
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&buf, nil)))
+
+	tests := []struct {
+		method string
+		err    error
+	}{
+		{"uppercase", nil},
+		{"diff", errors.New("fail")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method, func(t *testing.T) {
+			buf.Reset()
+			endpoint := WithLogging(tt.method, func(context.Context, string) (string, error) { return "", tt.err })
+			endpoint(context.Background(), "")
+
+			var line struct {
+				Method string `json:"method"`
+				Err    string `json:"err"`
+			}
+			if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+				t.Fatalf("log line %q: %v", buf.String(), err)
+			}
+			if line.Method != tt.method {
+				t.Errorf("method = %q, want %q", line.Method, tt.method)
+			}
+			if (line.Err != "") != (tt.err != nil) {
+				t.Errorf("err = %q, want %v", line.Err, tt.err)
+			}
+		})
+	}
+}
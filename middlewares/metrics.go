@@ -7,23 +7,30 @@ import (
 	"kit-fiber-example/metrics"
 )
 
-func metricsMiddleware[Req any, Res any](m *metrics.Metrics) Middleware[Req, Res] {
+func metricsMiddleware[Req any, Res any](name string, m *metrics.Metrics) Middleware[Req, Res] {
+	latency := m.RequestLatency.With("method", name)
+	count := m.RequestCount.With("method", name)
+	errs := m.ErrorCount.With("method", name)
+
 	return func(next Endpoint[Req, Res]) Endpoint[Req, Res] {
 		return func(ctx context.Context, request Req) (Res, error) {
 			defer func(begin time.Time) {
-				m.RequestLatency.Observe(time.Since(begin).Seconds())
-				m.RequestCount.Add(1)
+				latency.Observe(time.Since(begin).Seconds())
+				count.Add(1)
 			}(time.Now())
 
 			result, err := next(ctx, request)
 			if err != nil {
-				m.ErrorCount.Add(1)
+				errs.Add(1)
 			}
 			return result, err
 		}
 	}
 }
 
-func WithMetrics[Req any, Res any](m *metrics.Metrics, endpoint Endpoint[Req, Res]) Endpoint[Req, Res] {
-	return metricsMiddleware[Req, Res](m)(endpoint)
+// WithMetrics counts the calls, errors and latency of the endpoint under
+// the method label name. Endpoints are called outside of requests too
+// (batches, jobs), so the name can't come from the request.
+func WithMetrics[Req any, Res any](name string, m *metrics.Metrics, endpoint Endpoint[Req, Res]) Endpoint[Req, Res] {
+	return metricsMiddleware[Req, Res](name, m)(endpoint)
 }
@@ -0,0 +1,91 @@
+package middlewares
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"kit-fiber-example/metrics"
+)
+
+// series records the adds and observations of a metric by label values
+type series map[string]float64
+
+type fakeCounter struct {
+	s      series
+	labels []string
+}
+
+func (c fakeCounter) With(lv ...string) metrics.Counter {
+	return fakeCounter{c.s, append(append([]string(nil), c.labels...), lv...)}
+}
+func (c fakeCounter) Add(d float64) { c.s[strings.Join(c.labels, ",")] += d }
+
+type fakeHistogram struct {
+	s      series
+	labels []string
+}
+
+func (h fakeHistogram) With(lv ...string) metrics.Histogram {
+	return fakeHistogram{h.s, append(append([]string(nil), h.labels...), lv...)}
+}
+func (h fakeHistogram) Observe(float64) { h.s[strings.Join(h.labels, ",")]++ }
+
+func TestWithMetrics(t *testing.T) {
+	count, errs, latency := series{}, series{}, series{}
+	m := &metrics.Metrics{
+		RequestCount:   fakeCounter{s: count},
+		ErrorCount:     fakeCounter{s: errs},
+		RequestLatency: fakeHistogram{s: latency},
+	}
+	fail := errors.New("fail")
+	endpoint := func(_ context.Context, req string) (string, error) {
+		if req == "fail" {
+			return "", fail
+		}
+		return req, nil
+	}
+	endpoints := map[string]Endpoint[string, string]{
+		"lowercase": WithMetrics("lowercase", m, endpoint),
+		"title":     WithMetrics("title", m, endpoint),
+	}
+
+	calls := []struct {
+		method string
+		req    string
+		err    error
+	}{
+		{"lowercase", "a", nil},
+		{"lowercase", "fail", fail},
+		{"title", "b", nil},
+		{"lowercase", "c", nil},
+	}
+	for _, c := range calls {
+		if _, err := endpoints[c.method](context.Background(), c.req); err != c.err {
+			t.Errorf("%s(%q) error = %v, want %v", c.method, c.req, err, c.err)
+		}
+	}
+
+	tests := []struct {
+		name string
+		s    series
+		want series
+	}{
+		{"requests", count, series{"method,lowercase": 3, "method,title": 1}},
+		{"errors", errs, series{"method,lowercase": 1}},
+		{"latency", latency, series{"method,lowercase": 3, "method,title": 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if len(tt.s) != len(tt.want) {
+				t.Errorf("series = %v, want %v", tt.s, tt.want)
+			}
+			for labels, v := range tt.want {
+				if tt.s[labels] != v {
+					t.Errorf("%s = %v, want %v", labels, tt.s[labels], v)
+				}
+			}
+		})
+	}
+}
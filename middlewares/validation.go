@@ -0,0 +1,30 @@
+package middlewares
+
+import (
+	"context"
+	"reflect"
+
+	"kit-fiber-example/validation"
+)
+
+func validationMiddleware[Req any, Res any]() Middleware[Req, Res] {
+	// Broken tags are programming errors, they stop the service at startup
+	if err := validation.Compile(reflect.TypeFor[Req]()); err != nil {
+		panic(err)
+	}
+	return func(next Endpoint[Req, Res]) Endpoint[Req, Res] {
+		return func(ctx context.Context, request Req) (Res, error) {
+			if err := validation.Struct(request); err != nil {
+				var zero Res
+				return zero, err
+			}
+			return next(ctx, request)
+		}
+	}
+}
+
+// WithValidation rejects requests that break the rules of their type, see
+// package validation, before they reach the endpoint.
+func WithValidation[Req any, Res any](endpoint Endpoint[Req, Res]) Endpoint[Req, Res] {
+	return validationMiddleware[Req, Res]()(endpoint)
+}
@@ -0,0 +1,238 @@
+package openapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	rawMessageType = reflect.TypeOf(json.RawMessage(nil))
+	timeType       = reflect.TypeOf(time.Time{})
+)
+
+// Reflector derives schemas from Go types the way encoding/json marshals
+// them. Named structs become components, so each is described once.
+//
+// Struct fields are documented with tags next to the json tag:
+//
+//	doc:"The string to convert"  description
+//	example:"hello"             example, parsed as JSON when valid
+//	enum:"NFC,NFD"              allowed values
+//
+// Rules in validate tags are described as well: required, min and max
+// become lengths, item counts or bounds, and enum lists the allowed values.
+// A field is required when its rules say so, otherwise unless it has
+// omitempty or is a pointer.
+type Reflector struct {
+	Schemas map[string]*Schema
+
+	names map[reflect.Type]string
+}
+
+func NewReflector() *Reflector {
+	return &Reflector{
+		Schemas: make(map[string]*Schema),
+		names:   make(map[reflect.Type]string),
+	}
+}
+
+// Schema returns the schema of v's type. Nil returns nil.
+func (r *Reflector) Schema(v any) *Schema {
+	if v == nil {
+		return nil
+	}
+	return r.schema(reflect.TypeOf(v))
+}
+
+func (r *Reflector) schema(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch t {
+	case rawMessageType:
+		// Any JSON value
+		return &Schema{}
+	case timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", ContentEncoding: "base64"}
+		}
+		return &Schema{Type: "array", Items: r.schema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: r.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return r.structSchema(t)
+		}
+		return Ref(r.component(t))
+	}
+	// Interfaces and anything else can hold any value
+	return &Schema{}
+}
+
+// component registers the named struct t and returns its component name
+func (r *Reflector) component(t reflect.Type) string {
+	if name, ok := r.names[t]; ok {
+		return name
+	}
+
+	name := t.Name()
+	if _, taken := r.Schemas[name]; taken {
+		// Same name in another package
+		pkg := t.PkgPath()
+		pkg = pkg[strings.LastIndex(pkg, "/")+1:]
+		name = strings.ToUpper(pkg[:1]) + pkg[1:] + name
+	}
+	r.names[t] = name
+	// Reserve the name first, the struct may refer to itself
+	r.Schemas[name] = &Schema{}
+	*r.Schemas[name] = *r.structSchema(t)
+	return name
+}
+
+func (r *Reflector) structSchema(t reflect.Type) *Schema {
+	s := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	r.addFields(s, t)
+	return s
+}
+
+func (r *Reflector) addFields(s *Schema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" || (!f.IsExported() && !f.Anonymous) {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+
+		// Embedded structs without a name are flattened, like encoding/json does
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				r.addFields(s, ft)
+				continue
+			}
+		}
+		if f.Type.Kind() == reflect.Func || f.Type.Kind() == reflect.Chan {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		field := r.schema(f.Type)
+		rules := f.Tag.Get("validate")
+		if doc, ok := f.Tag.Lookup("doc"); ok || f.Tag.Get("example") != "" || f.Tag.Get("enum") != "" || rules != "" {
+			// 3.1 allows siblings of $ref, so refs can be documented too
+			copied := *field
+			field = &copied
+			field.Description = doc
+			if example := f.Tag.Get("example"); example != "" {
+				field.Examples = []any{parseExample(example)}
+			}
+			if enum := f.Tag.Get("enum"); enum != "" {
+				for _, v := range strings.Split(enum, ",") {
+					field.Enum = append(field.Enum, v)
+				}
+			}
+			applyRules(field, rules)
+		}
+		s.Properties[name] = field
+
+		required := !strings.Contains(opts, "omitempty") && f.Type.Kind() != reflect.Pointer
+		if rules != "" {
+			required = hasRule(rules, "required")
+		}
+		if required {
+			s.Required = append(s.Required, name)
+		}
+	}
+}
+
+// applyRules describes the rules of a validate tag in s
+func applyRules(s *Schema, rules string) {
+	for _, rule := range strings.Split(rules, ",") {
+		name, arg, _ := strings.Cut(rule, "=")
+		switch name {
+		case "enum":
+			for _, v := range strings.Split(arg, "|") {
+				s.Enum = append(s.Enum, v)
+			}
+		case "url":
+			s.Format = "uri"
+		case "min", "max":
+			n, err := strconv.ParseFloat(arg, 64)
+			if err != nil {
+				continue
+			}
+			setLimit(s, name, n)
+		}
+	}
+}
+
+func setLimit(s *Schema, rule string, n float64) {
+	i := int(n)
+	switch {
+	case s.Type == "string":
+		if rule == "min" {
+			s.MinLength = &i
+		} else {
+			s.MaxLength = &i
+		}
+	case s.Type == "array":
+		if rule == "min" {
+			s.MinItems = &i
+		} else {
+			s.MaxItems = &i
+		}
+	case s.Type == "object":
+		if rule == "min" {
+			s.MinProperties = &i
+		} else {
+			s.MaxProperties = &i
+		}
+	case s.Type == "integer" || s.Type == "number":
+		if rule == "min" {
+			s.Minimum = &n
+		} else {
+			s.Maximum = &n
+		}
+	}
+}
+
+func hasRule(rules, name string) bool {
+	for _, rule := range strings.Split(rules, ",") {
+		if rule == name {
+			return true
+		}
+	}
+	return false
+}
+
+func parseExample(example string) any {
+	var v any
+	if err := json.Unmarshal([]byte(example), &v); err == nil {
+		return v
+	}
+	return example
+}
@@ -0,0 +1,151 @@
+package problem
+
+import (
+	"net/http"
+
+	"google.golang.org/grpc/codes"
+)
+
+// Code is the machine readable kind of a problem
+type Code string
+
+const (
+	CodeBadRequest           Code = "bad_request"
+	CodeValidation           Code = "validation_failed"
+	CodeUnauthorized         Code = "unauthorized"
+	CodeForbidden            Code = "forbidden"
+	CodeNotFound             Code = "not_found"
+	CodeMethodNotAllowed     Code = "method_not_allowed"
+	CodeConflict             Code = "conflict"
+	CodeTooLarge             Code = "too_large"
+	CodeNotAcceptable        Code = "not_acceptable"
+	CodeUnsupportedMediaType Code = "unsupported_media_type"
+	CodeExtractionFailed     Code = "extraction_failed"
+	CodeTooEarly             Code = "too_early"
+	CodeUpgradeRequired      Code = "upgrade_required"
+	CodeRateLimited          Code = "rate_limited"
+	CodeCanceled             Code = "canceled"
+	CodeInternal             Code = "internal"
+	CodeNotImplemented       Code = "not_implemented"
+	CodeUpstream             Code = "upstream_failed"
+	CodeUnavailable          Code = "unavailable"
+	CodeOverloaded           Code = "overloaded"
+	CodeTimeout              Code = "timeout"
+)
+
+// StatusClientClosedRequest is the non-standard status of canceled calls
+const StatusClientClosedRequest = 499
+
+type codeSpec struct {
+	status int
+	grpc   codes.Code
+	title  string
+}
+
+var specs = map[Code]codeSpec{
+	CodeBadRequest:           {http.StatusBadRequest, codes.InvalidArgument, "Bad request"},
+	CodeValidation:           {http.StatusUnprocessableEntity, codes.InvalidArgument, "Validation failed"},
+	CodeUnauthorized:         {http.StatusUnauthorized, codes.Unauthenticated, "Unauthorized"},
+	CodeForbidden:            {http.StatusForbidden, codes.PermissionDenied, "Forbidden"},
+	CodeNotFound:             {http.StatusNotFound, codes.NotFound, "Not found"},
+	CodeMethodNotAllowed:     {http.StatusMethodNotAllowed, codes.Unimplemented, "Method not allowed"},
+	CodeConflict:             {http.StatusConflict, codes.Aborted, "Conflict"},
+	CodeTooLarge:             {http.StatusRequestEntityTooLarge, codes.OutOfRange, "Request too large"},
+	CodeNotAcceptable:        {http.StatusNotAcceptable, codes.InvalidArgument, "Not acceptable"},
+	CodeUnsupportedMediaType: {http.StatusUnsupportedMediaType, codes.InvalidArgument, "Unsupported media type"},
+	CodeExtractionFailed:     {http.StatusUnprocessableEntity, codes.FailedPrecondition, "Extraction failed"},
+	CodeTooEarly:             {http.StatusTooEarly, codes.Unavailable, "Request still in progress"},
+	CodeUpgradeRequired:      {http.StatusUpgradeRequired, codes.FailedPrecondition, "Upgrade required"},
+	CodeRateLimited:          {http.StatusTooManyRequests, codes.ResourceExhausted, "Rate limited"},
+	CodeCanceled:             {StatusClientClosedRequest, codes.Canceled, "Request canceled"},
+	CodeInternal:             {http.StatusInternalServerError, codes.Internal, "Internal error"},
+	CodeNotImplemented:       {http.StatusNotImplemented, codes.Unimplemented, "Not implemented"},
+	CodeUpstream:             {http.StatusBadGateway, codes.Unavailable, "Upstream failed"},
+	CodeUnavailable:          {http.StatusServiceUnavailable, codes.Unavailable, "Service unavailable"},
+	CodeOverloaded:           {http.StatusServiceUnavailable, codes.ResourceExhausted, "Overloaded"},
+	CodeTimeout:              {http.StatusGatewayTimeout, codes.DeadlineExceeded, "Timed out"},
+}
+
+// byStatus picks the code reported for a bare HTTP status
+var byStatus = map[int]Code{
+	http.StatusBadRequest:            CodeBadRequest,
+	http.StatusUnauthorized:          CodeUnauthorized,
+	http.StatusForbidden:             CodeForbidden,
+	http.StatusNotFound:              CodeNotFound,
+	http.StatusMethodNotAllowed:      CodeMethodNotAllowed,
+	http.StatusNotAcceptable:         CodeNotAcceptable,
+	http.StatusConflict:              CodeConflict,
+	http.StatusRequestEntityTooLarge: CodeTooLarge,
+	http.StatusUnsupportedMediaType:  CodeUnsupportedMediaType,
+	http.StatusUnprocessableEntity:   CodeValidation,
+	http.StatusTooEarly:              CodeTooEarly,
+	http.StatusUpgradeRequired:       CodeUpgradeRequired,
+	http.StatusTooManyRequests:       CodeRateLimited,
+	StatusClientClosedRequest:        CodeCanceled,
+	http.StatusInternalServerError:   CodeInternal,
+	http.StatusNotImplemented:        CodeNotImplemented,
+	http.StatusBadGateway:            CodeUpstream,
+	http.StatusServiceUnavailable:    CodeUnavailable,
+	http.StatusGatewayTimeout:        CodeTimeout,
+}
+
+// byGRPC picks the code reported for a bare gRPC status
+var byGRPC = map[codes.Code]Code{
+	codes.InvalidArgument:    CodeBadRequest,
+	codes.Unauthenticated:    CodeUnauthorized,
+	codes.PermissionDenied:   CodeForbidden,
+	codes.NotFound:           CodeNotFound,
+	codes.AlreadyExists:      CodeConflict,
+	codes.Aborted:            CodeConflict,
+	codes.OutOfRange:         CodeTooLarge,
+	codes.FailedPrecondition: CodeBadRequest,
+	codes.ResourceExhausted:  CodeRateLimited,
+	codes.Canceled:           CodeCanceled,
+	codes.Unimplemented:      CodeNotImplemented,
+	codes.Unavailable:        CodeUnavailable,
+	codes.DeadlineExceeded:   CodeTimeout,
+}
+
+// HTTPStatus is the status of responses with this code. Unknown codes are
+// internal errors.
+func (c Code) HTTPStatus() int {
+	if spec, ok := specs[c]; ok {
+		return spec.status
+	}
+	return http.StatusInternalServerError
+}
+
+// GRPCCode is the gRPC status code of errors with this code
+func (c Code) GRPCCode() codes.Code {
+	if spec, ok := specs[c]; ok {
+		return spec.grpc
+	}
+	return codes.Unknown
+}
+
+func (c Code) Title() string {
+	if spec, ok := specs[c]; ok {
+		return spec.title
+	}
+	return statusText(c.HTTPStatus())
+}
+
+// CodeFromStatus returns the code of an HTTP status, falling back to the
+// class of the status for ones without their own code.
+func CodeFromStatus(status int) Code {
+	if code, ok := byStatus[status]; ok {
+		return code
+	}
+	if status >= 400 && status < 500 {
+		return CodeBadRequest
+	}
+	return CodeInternal
+}
+
+// CodeFromGRPC returns the code of a gRPC status code
+func CodeFromGRPC(c codes.Code) Code {
+	if code, ok := byGRPC[c]; ok {
+		return code
+	}
+	return CodeInternal
+}
@@ -0,0 +1,51 @@
+package problem
+
+import (
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// maxBody caps how much of an error response is read
+const maxBody = 64 << 10
+
+// Decode returns the error of a non-2xx response. Problem documents are
+// decoded as they are, other bodies become the detail of an Error with the
+// code of the status. It returns nil for successful responses.
+func Decode(resp *http.Response) error {
+	if resp.StatusCode < 300 {
+		return nil
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxBody))
+	if err != nil {
+		return Wrap(CodeFromStatus(resp.StatusCode), err)
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType == ContentType || mediaType == "application/json" {
+		var p Problem
+		if json.Unmarshal(body, &p) == nil && (p.Code != "" || p.Title != "") {
+			if p.Status == 0 {
+				p.Status = resp.StatusCode
+			}
+			e := p.Err()
+			if e.RequestID == "" {
+				e.RequestID = resp.Header.Get("X-Request-ID")
+			}
+			return e
+		}
+	}
+
+	detail := strings.TrimSpace(string(body))
+	if detail == "" {
+		detail = statusText(resp.StatusCode)
+	}
+	return &Error{
+		Code:      CodeFromStatus(resp.StatusCode),
+		Status:    resp.StatusCode,
+		Detail:    detail,
+		RequestID: resp.Header.Get("X-Request-ID"),
+	}
+}
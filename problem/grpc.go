@@ -0,0 +1,93 @@
+package problem
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/status"
+)
+
+// Domain names the API in the ErrorInfo of gRPC statuses
+const Domain = "kit-fiber-example"
+
+// Metadata keys of the ErrorInfo besides the extensions
+const (
+	metaHTTPStatus = "http_status"
+	metaRequestID  = "request_id"
+	metaTraceID    = "trace_id"
+)
+
+// GRPCStatus lets status.FromError and gRPC servers report e. The problem
+// code is the ErrorInfo reason, extensions are JSON encoded metadata.
+func (e *Error) GRPCStatus() *status.Status {
+	s := status.New(e.Code.GRPCCode(), e.Error())
+
+	info := &errdetails.ErrorInfo{
+		Reason:   string(e.Code),
+		Domain:   Domain,
+		Metadata: make(map[string]string, len(e.Extensions)+3),
+	}
+	for k, v := range e.Extensions {
+		if data, err := json.Marshal(v); err == nil {
+			info.Metadata[k] = string(data)
+		}
+	}
+	if e.Status != 0 {
+		info.Metadata[metaHTTPStatus] = strconv.Itoa(e.Status)
+	}
+	if e.RequestID != "" {
+		info.Metadata[metaRequestID] = e.RequestID
+	}
+	if e.TraceID != "" {
+		info.Metadata[metaTraceID] = e.TraceID
+	}
+
+	if detailed, err := s.WithDetails(info); err == nil {
+		return detailed
+	}
+	return s
+}
+
+// FromGRPC turns an error returned by a gRPC call into an Error. Statuses
+// without our ErrorInfo get the code matching their gRPC code.
+func FromGRPC(err error) *Error {
+	if err == nil {
+		return nil
+	}
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+	s, ok := status.FromError(err)
+	if !ok {
+		return Wrap(CodeInternal, err)
+	}
+
+	e = &Error{Code: CodeFromGRPC(s.Code()), Detail: s.Message(), Err: err}
+	for _, detail := range s.Details() {
+		info, ok := detail.(*errdetails.ErrorInfo)
+		if !ok || info.Domain != Domain {
+			continue
+		}
+		e.Code = Code(info.Reason)
+		for k, v := range info.Metadata {
+			switch k {
+			case metaHTTPStatus:
+				e.Status, _ = strconv.Atoi(v)
+			case metaRequestID:
+				e.RequestID = v
+			case metaTraceID:
+				e.TraceID = v
+			default:
+				var value any
+				if json.Unmarshal([]byte(v), &value) != nil {
+					value = v
+				}
+				e.With(k, value)
+			}
+		}
+	}
+	return e
+}
@@ -0,0 +1,72 @@
+package problem
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestGRPCStatus(t *testing.T) {
+	e := New(CodeRateLimited, "slow down").With("retry_after", 2.0).With("scope", "tenant")
+	e.Status, e.RequestID, e.TraceID = 429, "req-1", "trace-1"
+
+	s, ok := status.FromError(e)
+	if !ok {
+		t.Fatal("status.FromError found no status")
+	}
+	if wrapped, _ := status.FromError(fmt.Errorf("calling: %w", e)); wrapped.Code() != codes.ResourceExhausted {
+		t.Errorf("wrapped Code() = %v, want %v", wrapped.Code(), codes.ResourceExhausted)
+	}
+	if s.Code() != codes.ResourceExhausted {
+		t.Errorf("Code() = %v, want %v", s.Code(), codes.ResourceExhausted)
+	}
+	if s.Message() != "slow down" {
+		t.Errorf("Message() = %q, want %q", s.Message(), "slow down")
+	}
+
+	// What a client gets back, without the original error
+	got := FromGRPC(s.Err())
+	got.Err = nil
+	if !reflect.DeepEqual(got, e) {
+		t.Errorf("FromGRPC() = %+v, want %+v", got, e)
+	}
+}
+
+func TestFromGRPC(t *testing.T) {
+	plain := errors.New("boom")
+	tests := []struct {
+		name   string
+		err    error
+		code   Code
+		detail string
+	}{
+		{"nil", nil, "", ""},
+		{"Error", New(CodeNotFound, "gone"), CodeNotFound, "gone"},
+		{"bare status", status.Error(codes.DeadlineExceeded, "too slow"), CodeTimeout, "too slow"},
+		{"bare status without a code", status.Error(codes.DataLoss, "lost"), CodeInternal, "lost"},
+		{"context error", status.FromContextError(context.Canceled).Err(), CodeCanceled, "context canceled"},
+		{"not a status", plain, CodeInternal, "boom"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := FromGRPC(tt.err)
+			if tt.err == nil {
+				if got != nil {
+					t.Errorf("FromGRPC(nil) = %+v", got)
+				}
+				return
+			}
+			if got.Code != tt.code || got.Detail != tt.detail {
+				t.Errorf("FromGRPC() = %s %q, want %s %q", got.Code, got.Detail, tt.code, tt.detail)
+			}
+			if !errors.Is(got, tt.err) {
+				t.Errorf("FromGRPC() doesn't wrap %v", tt.err)
+			}
+		})
+	}
+}
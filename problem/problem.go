@@ -0,0 +1,206 @@
+// Package problem implements RFC 9457 problem details, the single error
+// format of the API. Every error carries a Code that maps to an HTTP status
+// and a gRPC status, so servers and clients agree on what went wrong
+// whatever the transport.
+package problem
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+)
+
+// ContentType is the media type of problem documents
+const ContentType = "application/problem+json"
+
+// TypeBase prefixes the code to form the type URI of a problem. It's a
+// relative reference, resolved against the URL of the API.
+const TypeBase = "/problems/"
+
+// Problem is a problem details document. Extensions are written as top
+// level members next to the standard ones.
+type Problem struct {
+	Type      string `json:"type" example:"/problems/validation_failed"`
+	Title     string `json:"title" example:"Validation failed"`
+	Status    int    `json:"status" example:"422"`
+	Detail    string `json:"detail,omitempty"`
+	Instance  string `json:"instance,omitempty" doc:"The path of the failed request"`
+	Code      Code   `json:"code" doc:"Machine readable kind of the problem" example:"validation_failed"`
+	RequestID string `json:"request_id,omitempty" doc:"Also sent in the X-Request-ID header"`
+	TraceID   string `json:"trace_id,omitempty"`
+
+	Extensions map[string]any `json:"-"`
+}
+
+// problemFields has the members of Problem without its methods
+type problemFields Problem
+
+var standardMembers = []string{"type", "title", "status", "detail", "instance", "code", "request_id", "trace_id"}
+
+func (p Problem) MarshalJSON() ([]byte, error) {
+	data, err := json.Marshal(problemFields(p))
+	if err != nil || len(p.Extensions) == 0 {
+		return data, err
+	}
+
+	keys := make([]string, 0, len(p.Extensions))
+	for k := range p.Extensions {
+		// Standard members can't be overwritten by extensions
+		if !slices.Contains(standardMembers, k) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	buf := bytes.NewBuffer(data[:len(data)-1])
+	for _, k := range keys {
+		name, _ := json.Marshal(k)
+		value, err := json.Marshal(p.Extensions[k])
+		if err != nil {
+			return nil, err
+		}
+		buf.WriteByte(',')
+		buf.Write(name)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+func (p *Problem) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, (*problemFields)(p)); err != nil {
+		return err
+	}
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(data, &members); err != nil {
+		return err
+	}
+	for _, k := range standardMembers {
+		delete(members, k)
+	}
+	p.Extensions = nil
+	for k, v := range members {
+		var value any
+		if err := json.Unmarshal(v, &value); err != nil {
+			return err
+		}
+		if p.Extensions == nil {
+			p.Extensions = make(map[string]any, len(members))
+		}
+		p.Extensions[k] = value
+	}
+	return nil
+}
+
+// Error is an error with a problem code. Servers return it from endpoints,
+// clients get it back from Decode and FromGRPC.
+type Error struct {
+	Code Code
+	// Status overrides the HTTP status of Code when set
+	Status     int
+	Detail     string
+	Extensions map[string]any
+	// RequestID and TraceID identify the failed call, they're set on
+	// errors decoded by clients
+	RequestID string
+	TraceID   string
+
+	Err error
+}
+
+// New returns an error with the given code and detail
+func New(code Code, detail string) *Error {
+	return &Error{Code: code, Detail: detail}
+}
+
+// Errorf formats the detail like fmt.Errorf, %w wraps the cause
+func Errorf(code Code, format string, args ...any) *Error {
+	err := fmt.Errorf(format, args...)
+	return &Error{Code: code, Detail: err.Error(), Err: errors.Unwrap(err)}
+}
+
+// Wrap returns an error with the given code and err as its cause and detail
+func Wrap(code Code, err error) *Error {
+	return &Error{Code: code, Detail: err.Error(), Err: err}
+}
+
+func (e *Error) Error() string {
+	if e.Detail != "" {
+		return e.Detail
+	}
+	return e.Code.Title()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// With sets an extension member and returns e
+func (e *Error) With(key string, value any) *Error {
+	if e.Extensions == nil {
+		e.Extensions = make(map[string]any)
+	}
+	e.Extensions[key] = value
+	return e
+}
+
+// HTTPStatus is Status when set, otherwise the status of Code
+func (e *Error) HTTPStatus() int {
+	if e.Status != 0 {
+		return e.Status
+	}
+	return e.Code.HTTPStatus()
+}
+
+// Problem returns the problem document of e
+func (e *Error) Problem() *Problem {
+	return &Problem{
+		Type:       TypeBase + string(e.Code),
+		Title:      e.Code.Title(),
+		Status:     e.HTTPStatus(),
+		Detail:     e.Detail,
+		Code:       e.Code,
+		RequestID:  e.RequestID,
+		TraceID:    e.TraceID,
+		Extensions: e.Extensions,
+	}
+}
+
+// Err returns the error described by p
+func (p *Problem) Err() *Error {
+	code := p.Code
+	if code == "" {
+		code = CodeFromStatus(p.Status)
+	}
+	return &Error{
+		Code:       code,
+		Status:     p.Status,
+		Detail:     p.Detail,
+		Extensions: p.Extensions,
+		RequestID:  p.RequestID,
+		TraceID:    p.TraceID,
+	}
+}
+
+// CodeOf returns the code of the first Error in err's chain, or
+// CodeInternal when there is none
+func CodeOf(err error) Code {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Code
+	}
+	return CodeInternal
+}
+
+// statusText is used as detail when a response has no problem document
+func statusText(status int) string {
+	if text := http.StatusText(status); text != "" {
+		return text
+	}
+	return fmt.Sprintf("HTTP status %d", status)
+}
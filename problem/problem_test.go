@@ -0,0 +1,295 @@
+package problem
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+)
+
+func TestCodes(t *testing.T) {
+	for code, spec := range specs {
+		t.Run(string(code), func(t *testing.T) {
+			if got := code.HTTPStatus(); got != spec.status {
+				t.Errorf("HTTPStatus() = %d, want %d", got, spec.status)
+			}
+			if got := code.GRPCCode(); got != spec.grpc {
+				t.Errorf("GRPCCode() = %v, want %v", got, spec.grpc)
+			}
+			if code.Title() == "" {
+				t.Error("no title")
+			}
+			// Codes sharing a status map back to one with the same status
+			if got := CodeFromStatus(spec.status); got.HTTPStatus() != spec.status {
+				t.Errorf("CodeFromStatus(%d) = %s with status %d", spec.status, got, got.HTTPStatus())
+			}
+			// gRPC codes are coarser, but each one sent maps back to a code
+			if got := CodeFromGRPC(spec.grpc); got == CodeInternal && spec.grpc != codes.Internal {
+				t.Errorf("CodeFromGRPC(%v) has no code", spec.grpc)
+			}
+		})
+	}
+	for status, code := range byStatus {
+		if _, ok := specs[code]; !ok {
+			t.Errorf("byStatus[%d] = %s has no spec", status, code)
+		}
+	}
+	for c, code := range byGRPC {
+		if _, ok := specs[code]; !ok {
+			t.Errorf("byGRPC[%v] = %s has no spec", c, code)
+		}
+	}
+}
+
+func TestCodeFallbacks(t *testing.T) {
+	tests := []struct {
+		name string
+		got  any
+		want any
+	}{
+		{"unknown code status", Code("nope").HTTPStatus(), 500},
+		{"unknown code gRPC code", Code("nope").GRPCCode(), codes.Unknown},
+		{"unknown code title", Code("nope").Title(), "Internal Server Error"},
+		{"other 4xx status", CodeFromStatus(http.StatusTeapot), CodeBadRequest},
+		{"other 5xx status", CodeFromStatus(http.StatusLoopDetected), CodeInternal},
+		{"non-error status", CodeFromStatus(http.StatusOK), CodeInternal},
+		{"failed precondition", CodeFromGRPC(codes.FailedPrecondition), CodeBadRequest},
+		{"already exists", CodeFromGRPC(codes.AlreadyExists), CodeConflict},
+		{"gRPC code without a code", CodeFromGRPC(codes.DataLoss), CodeInternal},
+		{"gRPC OK", CodeFromGRPC(codes.OK), CodeInternal},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.got != tt.want {
+				t.Errorf("got %v, want %v", tt.got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProblemJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		p    Problem
+		json string
+	}{
+		{
+			"no extensions",
+			Problem{Type: "/problems/not_found", Title: "Not found", Status: 404, Code: CodeNotFound},
+			`{"type":"/problems/not_found","title":"Not found","status":404,"code":"not_found"}`,
+		},
+		{
+			"extensions sorted after the standard members",
+			Problem{
+				Type: "/problems/rate_limited", Title: "Rate limited", Status: 429, Code: CodeRateLimited,
+				Extensions: map[string]any{"retry_after": 3.0, "limit": "tenant"},
+			},
+			`{"type":"/problems/rate_limited","title":"Rate limited","status":429,"code":"rate_limited","limit":"tenant","retry_after":3}`,
+		},
+		{
+			"nested extension",
+			Problem{
+				Type: "/problems/validation_failed", Title: "Validation failed", Status: 422, Code: CodeValidation,
+				Extensions: map[string]any{"errors": []any{map[string]any{"field": "name"}}},
+			},
+			`{"type":"/problems/validation_failed","title":"Validation failed","status":422,"code":"validation_failed","errors":[{"field":"name"}]}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := json.Marshal(tt.p)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != tt.json {
+				t.Errorf("Marshal = %s, want %s", data, tt.json)
+			}
+
+			var got Problem
+			if err := json.Unmarshal(data, &got); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.p) {
+				t.Errorf("Unmarshal = %+v, want %+v", got, tt.p)
+			}
+		})
+	}
+}
+
+func TestProblemJSONStandardMembers(t *testing.T) {
+	p := Problem{
+		Type: "/problems/internal", Title: "Internal error", Status: 500, Code: CodeInternal,
+		Extensions: map[string]any{"status": 200, "code": "ok"},
+	}
+	data, err := json.Marshal(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"type":"/problems/internal","title":"Internal error","status":500,"code":"internal"}`
+	if string(data) != want {
+		t.Errorf("Marshal = %s, want %s", data, want)
+	}
+
+	if _, err := json.Marshal(Problem{Extensions: map[string]any{"f": func() {}}}); err == nil {
+		t.Error("Marshal of an unencodable extension succeeded")
+	}
+}
+
+func TestError(t *testing.T) {
+	cause := errors.New("disk full")
+	tests := []struct {
+		name   string
+		err    *Error
+		msg    string
+		status int
+		cause  error
+	}{
+		{"New", New(CodeConflict, "job exists"), "job exists", 409, nil},
+		{"no detail", &Error{Code: CodeTimeout}, "Timed out", 504, nil},
+		{"status override", &Error{Code: CodeUpstream, Status: 529}, "Upstream failed", 529, nil},
+		{"Wrap", Wrap(CodeUnavailable, cause), "disk full", 503, cause},
+		{"Errorf with %w", Errorf(CodeInternal, "saving: %w", cause), "saving: disk full", 500, cause},
+		{"Errorf without %w", Errorf(CodeBadRequest, "bad %s", "mode"), "bad mode", 400, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.err.Error(); got != tt.msg {
+				t.Errorf("Error() = %q, want %q", got, tt.msg)
+			}
+			if got := tt.err.HTTPStatus(); got != tt.status {
+				t.Errorf("HTTPStatus() = %d, want %d", got, tt.status)
+			}
+			if got := tt.err.Unwrap(); got != tt.cause {
+				t.Errorf("Unwrap() = %v, want %v", got, tt.cause)
+			}
+		})
+	}
+}
+
+func TestErrorProblem(t *testing.T) {
+	e := New(CodeRateLimited, "slow down").With("retry_after", 2.0)
+	e.RequestID, e.TraceID = "req-1", "trace-1"
+
+	p := e.Problem()
+	want := &Problem{
+		Type:       "/problems/rate_limited",
+		Title:      "Rate limited",
+		Status:     429,
+		Detail:     "slow down",
+		Code:       CodeRateLimited,
+		RequestID:  "req-1",
+		TraceID:    "trace-1",
+		Extensions: map[string]any{"retry_after": 2.0},
+	}
+	if !reflect.DeepEqual(p, want) {
+		t.Fatalf("Problem() = %+v, want %+v", p, want)
+	}
+	if got := p.Err(); !reflect.DeepEqual(got, &Error{
+		Code: CodeRateLimited, Status: 429, Detail: "slow down",
+		Extensions: e.Extensions, RequestID: "req-1", TraceID: "trace-1",
+	}) {
+		t.Errorf("Err() = %+v", got)
+	}
+
+	// Problems without a code get the one of their status
+	if got := (&Problem{Status: 404}).Err().Code; got != CodeNotFound {
+		t.Errorf("Err().Code = %s, want %s", got, CodeNotFound)
+	}
+}
+
+func TestCodeOf(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want Code
+	}{
+		{"Error", New(CodeNotFound, "gone"), CodeNotFound},
+		{"wrapped Error", fmt.Errorf("loading: %w", New(CodeTimeout, "")), CodeTimeout},
+		{"plain error", errors.New("boom"), CodeInternal},
+		{"nil", nil, CodeInternal},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CodeOf(tt.err); got != tt.want {
+				t.Errorf("CodeOf() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      int
+		contentType string
+		body        string
+		want        *Error
+	}{
+		{"success", 200, "application/json", `{}`, nil},
+		{
+			"problem document",
+			429, ContentType,
+			`{"type":"/problems/rate_limited","title":"Rate limited","status":429,"detail":"slow down","code":"rate_limited","retry_after":2}`,
+			&Error{Code: CodeRateLimited, Status: 429, Detail: "slow down", RequestID: "req-1", Extensions: map[string]any{"retry_after": 2.0}},
+		},
+		{
+			"problem without a status",
+			404, "application/json; charset=utf-8",
+			`{"title":"Not found","code":"not_found","request_id":"req-2"}`,
+			&Error{Code: CodeNotFound, Status: 404, RequestID: "req-2"},
+		},
+		{
+			"JSON that isn't a problem",
+			400, "application/json",
+			`{"error":"bad"}`,
+			&Error{Code: CodeBadRequest, Status: 400, Detail: `{"error":"bad"}`, RequestID: "req-1"},
+		},
+		{
+			"text body",
+			502, "text/plain",
+			"upstream died\n",
+			&Error{Code: CodeUpstream, Status: 502, Detail: "upstream died", RequestID: "req-1"},
+		},
+		{
+			"empty body",
+			503, "",
+			"",
+			&Error{Code: CodeUnavailable, Status: 503, Detail: "Service Unavailable", RequestID: "req-1"},
+		},
+		{
+			"unknown status",
+			599, "",
+			"",
+			&Error{Code: CodeInternal, Status: 599, Detail: "HTTP status 599", RequestID: "req-1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &http.Response{
+				StatusCode: tt.status,
+				Header:     http.Header{"Content-Type": {tt.contentType}, "X-Request-Id": {"req-1"}},
+				Body:       io.NopCloser(strings.NewReader(tt.body)),
+			}
+			err := Decode(resp)
+			if tt.want == nil {
+				if err != nil {
+					t.Fatalf("Decode() = %v, want nil", err)
+				}
+				return
+			}
+			var got *Error
+			if !errors.As(err, &got) {
+				t.Fatalf("Decode() = %v, want an *Error", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Decode() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,22 @@
+package prompts
+
+import (
+	"fmt"
+	"time"
+
+	"kit-fiber-example/config"
+)
+
+// NewRegistryFromConfig loads the configured templates directory.
+func NewRegistryFromConfig(cfg *config.Config) (*Registry, error) {
+	var interval time.Duration
+	if cfg.Prompts.ReloadInterval != "" {
+		d, err := time.ParseDuration(cfg.Prompts.ReloadInterval)
+		if err != nil {
+			return nil, fmt.Errorf("prompts.reloadInterval: %w", err)
+		}
+		interval = d
+	}
+
+	return NewRegistry(cfg.Prompts.Dir, interval)
+}
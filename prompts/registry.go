@@ -0,0 +1,208 @@
+package prompts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+var ErrNotFound = errors.New("prompt template not found")
+
+// Registry holds the templates loaded from a directory of *.yaml files.
+// It polls the directory and reloads it when a file changes.
+type Registry struct {
+	dir      string
+	interval time.Duration
+
+	mu        sync.RWMutex
+	templates map[string]*Template
+	stamp     string
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewRegistry loads all templates from dir. An empty dir gives an empty
+// registry. The directory is polled every interval once started.
+func NewRegistry(dir string, interval time.Duration) (*Registry, error) {
+	r := &Registry{dir: dir, interval: interval}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Get returns the template by name.
+func (r *Registry) Get(name string) (*Template, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	t, ok := r.templates[name]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return t, nil
+}
+
+// List returns all templates sorted by name.
+func (r *Registry) List() []*Template {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	list := make([]*Template, 0, len(r.templates))
+	for _, t := range r.templates {
+		list = append(list, t)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// Check reports the loaded templates for the health report
+func (r *Registry) Check(context.Context) (any, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return map[string]any{"dir": r.dir, "templates": len(r.templates)}, nil
+}
+
+// Reload reads the directory again. On error the previous templates stay in use.
+func (r *Registry) Reload() error {
+	paths, err := r.files()
+	if err != nil {
+		return err
+	}
+
+	templates := make(map[string]*Template, len(paths))
+	for _, p := range paths {
+		t, err := load(p)
+		if err != nil {
+			return fmt.Errorf("%s: %w", p, err)
+		}
+		if _, ok := templates[t.Name]; ok {
+			return fmt.Errorf("%s: duplicate template %q", p, t.Name)
+		}
+		templates[t.Name] = t
+	}
+
+	stamp, err := r.stampOf(paths)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	r.templates = templates
+	r.stamp = stamp
+	r.mu.Unlock()
+	return nil
+}
+
+// Start polls the directory and reloads it on changes.
+func (r *Registry) Start() {
+	if r.interval <= 0 || r.dir == "" {
+		return
+	}
+	r.stop = make(chan struct{})
+	r.done = make(chan struct{})
+
+	go func() {
+		defer close(r.done)
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := r.reloadIfChanged(); err != nil {
+					log.Printf("prompts: reload failed: %v", err)
+				}
+			case <-r.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops polling.
+func (r *Registry) Stop() {
+	if r.stop == nil {
+		return
+	}
+	close(r.stop)
+	<-r.done
+}
+
+func (r *Registry) reloadIfChanged() error {
+	paths, err := r.files()
+	if err != nil {
+		return err
+	}
+	stamp, err := r.stampOf(paths)
+	if err != nil {
+		return err
+	}
+
+	r.mu.RLock()
+	changed := stamp != r.stamp
+	r.mu.RUnlock()
+	if !changed {
+		return nil
+	}
+
+	if err := r.Reload(); err != nil {
+		return err
+	}
+	log.Printf("prompts: reloaded %d templates from %s", len(r.List()), r.dir)
+	return nil
+}
+
+func (r *Registry) files() ([]string, error) {
+	if r.dir == "" {
+		return nil, nil
+	}
+	yml, err := filepath.Glob(filepath.Join(r.dir, "*.yml"))
+	if err != nil {
+		return nil, err
+	}
+	yamls, err := filepath.Glob(filepath.Join(r.dir, "*.yaml"))
+	if err != nil {
+		return nil, err
+	}
+	paths := append(yml, yamls...)
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// stampOf summarizes names, sizes and modification times of the files.
+func (r *Registry) stampOf(paths []string) (string, error) {
+	stamp := ""
+	for _, p := range paths {
+		info, err := os.Stat(p)
+		if err != nil {
+			return "", err
+		}
+		stamp += fmt.Sprintf("%s:%d:%d;", p, info.Size(), info.ModTime().UnixNano())
+	}
+	return stamp, nil
+}
+
+func load(path string) (*Template, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var t Template
+	if err := yaml.Unmarshal(data, &t); err != nil {
+		return nil, err
+	}
+	if err := t.compile(); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
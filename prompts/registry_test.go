@@ -0,0 +1,135 @@
+package prompts
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeTemplate(t *testing.T, dir, file, body string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, file), []byte(body), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNewRegistry(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		names []string
+		ok    bool
+	}{
+		{"empty", nil, []string{}, true},
+		{"yml and yaml", map[string]string{
+			"b.yml":  "name: b\nprompt: B",
+			"a.yaml": "name: a\nprompt: A",
+			"c.txt":  "not a template",
+		}, []string{"a", "b"}, true},
+		{"duplicate", map[string]string{
+			"a.yaml":  "name: a\nprompt: A",
+			"a2.yaml": "name: a\nprompt: A2",
+		}, nil, false},
+		{"invalid yaml", map[string]string{"a.yaml": "name: [a"}, nil, false},
+		{"invalid template", map[string]string{"a.yaml": "name: a\nprompt: '{{.x'"}, nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for file, body := range tt.files {
+				writeTemplate(t, dir, file, body)
+			}
+
+			r, err := NewRegistry(dir, 0)
+			if (err == nil) != tt.ok {
+				t.Fatalf("NewRegistry() error = %v, want ok %t", err, tt.ok)
+			}
+			if !tt.ok {
+				return
+			}
+			names := []string{}
+			for _, tmpl := range r.List() {
+				names = append(names, tmpl.Name)
+			}
+			if len(names) != len(tt.names) {
+				t.Fatalf("List() = %v, want %v", names, tt.names)
+			}
+			for i := range names {
+				if names[i] != tt.names[i] {
+					t.Fatalf("List() = %v, want %v", names, tt.names)
+				}
+			}
+		})
+	}
+}
+
+func TestRegistryReload(t *testing.T) {
+	dir := t.TempDir()
+	writeTemplate(t, dir, "a.yaml", "name: a\nversion: '1'\nprompt: A")
+	r, err := NewRegistry(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Each step changes the directory and reloads it
+	steps := []struct {
+		name    string
+		change  func()
+		ok      bool
+		version string
+		b       bool
+	}{
+		{"unchanged", func() {}, true, "1", false},
+		{"edited", func() { writeTemplate(t, dir, "a.yaml", "name: a\nversion: '2'\nprompt: A") }, true, "2", false},
+		{"added", func() { writeTemplate(t, dir, "b.yaml", "name: b\nprompt: B") }, true, "2", true},
+		{"broken keeps the previous templates", func() { writeTemplate(t, dir, "a.yaml", "name: a\nprompt: '{{'") }, false, "2", true},
+		{"removed", func() {
+			writeTemplate(t, dir, "a.yaml", "name: a\nversion: '3'\nprompt: A")
+			os.Remove(filepath.Join(dir, "b.yaml"))
+		}, true, "3", false},
+	}
+	for _, step := range steps {
+		step.change()
+		if err := r.Reload(); (err == nil) != step.ok {
+			t.Fatalf("%s: Reload() error = %v, want ok %t", step.name, err, step.ok)
+		}
+		a, err := r.Get("a")
+		if err != nil || a.Version != step.version {
+			t.Errorf("%s: Get(a) = %+v, %v, want version %s", step.name, a, err, step.version)
+		}
+		if _, err := r.Get("b"); (err == nil) != step.b {
+			t.Errorf("%s: Get(b) error = %v, want found %t", step.name, err, step.b)
+		}
+	}
+	if _, err := r.Get("c"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get(c) error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestRegistryHotReload(t *testing.T) {
+	dir := t.TempDir()
+	writeTemplate(t, dir, "a.yaml", "name: a\nprompt: A")
+	r, err := NewRegistry(dir, 5*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Start()
+	defer r.Stop()
+
+	writeTemplate(t, dir, "b.yaml", "name: b\nprompt: B")
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if _, err := r.Get("b"); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("new template wasn't loaded")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if _, err := r.Get("a"); err != nil {
+		t.Errorf("Get(a) error = %v", err)
+	}
+}
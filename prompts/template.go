@@ -0,0 +1,193 @@
+package prompts
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// Variable describes one template input.
+type Variable struct {
+	// Type is one of string, integer, number, boolean, array
+	Type        string   `yaml:"type" json:"type"`
+	Description string   `yaml:"description" json:"description,omitempty"`
+	Required    bool     `yaml:"required" json:"required,omitempty"`
+	Default     any      `yaml:"default" json:"default,omitempty"`
+	Enum        []string `yaml:"enum" json:"enum,omitempty"`
+	MaxLength   int      `yaml:"maxLength" json:"maxLength,omitempty"`
+}
+
+// Template is a named, versioned prompt loaded from a YAML file.
+type Template struct {
+	Name        string              `yaml:"name" json:"name"`
+	Version     string              `yaml:"version" json:"version"`
+	Description string              `yaml:"description" json:"description,omitempty"`
+	Variables   map[string]Variable `yaml:"variables" json:"variables"`
+	System      string              `yaml:"system" json:"-"`
+	Prompt      string              `yaml:"prompt" json:"-"`
+	// Model overrides, zero values keep the server defaults
+	Model       string   `yaml:"model" json:"model,omitempty"`
+	MaxTokens   int      `yaml:"maxTokens" json:"maxTokens,omitempty"`
+	Temperature *float64 `yaml:"temperature" json:"temperature,omitempty"`
+
+	system *template.Template
+	prompt *template.Template
+}
+
+// Rendered is a template applied to its variables.
+type Rendered struct {
+	System      string
+	Prompt      string
+	Model       string
+	MaxTokens   int
+	Temperature *float64
+}
+
+func (t *Template) compile() error {
+	if t.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if strings.TrimSpace(t.Prompt) == "" {
+		return fmt.Errorf("prompt is required")
+	}
+	for name, v := range t.Variables {
+		switch v.Type {
+		case "string", "integer", "number", "boolean", "array":
+		default:
+			return fmt.Errorf("variable %s: unknown type %q", name, v.Type)
+		}
+	}
+
+	var err error
+	// Referencing an undeclared variable is an error, not an empty string
+	if t.system, err = template.New("system").Option("missingkey=error").Parse(t.System); err != nil {
+		return err
+	}
+	if t.prompt, err = template.New("prompt").Option("missingkey=error").Parse(t.Prompt); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Render validates vars against the variables schema and executes the template.
+func (t *Template) Render(vars map[string]any) (Rendered, error) {
+	values, err := t.bind(vars)
+	if err != nil {
+		return Rendered{}, err
+	}
+
+	var system, prompt bytes.Buffer
+	if err := t.system.Execute(&system, values); err != nil {
+		return Rendered{}, &RenderError{Err: err}
+	}
+	if err := t.prompt.Execute(&prompt, values); err != nil {
+		return Rendered{}, &RenderError{Err: err}
+	}
+
+	return Rendered{
+		System:      system.String(),
+		Prompt:      prompt.String(),
+		Model:       t.Model,
+		MaxTokens:   t.MaxTokens,
+		Temperature: t.Temperature,
+	}, nil
+}
+
+// VariableError reports variables that don't match the schema.
+type VariableError struct {
+	Problems []string
+}
+
+func (e *VariableError) Error() string {
+	return "invalid variables: " + strings.Join(e.Problems, "; ")
+}
+
+// RenderError reports a template that failed to execute on valid variables,
+// e.g. an index out of range of an array variable.
+type RenderError struct {
+	Err error
+}
+
+func (e *RenderError) Error() string {
+	return "render template: " + e.Err.Error()
+}
+
+func (e *RenderError) Unwrap() error { return e.Err }
+
+func (t *Template) bind(vars map[string]any) (map[string]any, error) {
+	var problems []string
+	for name := range vars {
+		if _, ok := t.Variables[name]; !ok {
+			problems = append(problems, fmt.Sprintf("%s: unknown variable", name))
+		}
+	}
+
+	values := make(map[string]any, len(t.Variables))
+	for name, v := range t.Variables {
+		value, ok := vars[name]
+		if !ok || value == nil {
+			if v.Required {
+				problems = append(problems, fmt.Sprintf("%s: is required", name))
+			}
+			values[name] = v.Default
+			continue
+		}
+		if problem := v.check(value); problem != "" {
+			problems = append(problems, fmt.Sprintf("%s: %s", name, problem))
+			continue
+		}
+		values[name] = value
+	}
+
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return nil, &VariableError{Problems: problems}
+	}
+	return values, nil
+}
+
+// check validates a value decoded from JSON.
+func (v Variable) check(value any) string {
+	switch v.Type {
+	case "string":
+		s, ok := value.(string)
+		if !ok {
+			return "must be a string"
+		}
+		if v.MaxLength > 0 && len([]rune(s)) > v.MaxLength {
+			return fmt.Sprintf("must be at most %d characters", v.MaxLength)
+		}
+		if len(v.Enum) > 0 && !contains(v.Enum, s) {
+			return fmt.Sprintf("must be one of %s", strings.Join(v.Enum, ", "))
+		}
+	case "integer":
+		n, ok := value.(float64)
+		if !ok || n != float64(int64(n)) {
+			return "must be an integer"
+		}
+	case "number":
+		if _, ok := value.(float64); !ok {
+			return "must be a number"
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return "must be a boolean"
+		}
+	case "array":
+		if _, ok := value.([]any); !ok {
+			return "must be an array"
+		}
+	}
+	return ""
+}
+
+func contains(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
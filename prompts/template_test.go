@@ -0,0 +1,136 @@
+package prompts
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func newTestTemplate(t *testing.T) *Template {
+	t.Helper()
+	tmpl := &Template{
+		Name: "test",
+		Variables: map[string]Variable{
+			"text":  {Type: "string", Required: true, MaxLength: 5},
+			"tone":  {Type: "string", Enum: []string{"neutral", "formal"}, Default: "neutral"},
+			"count": {Type: "integer", Default: 3},
+			"ratio": {Type: "number"},
+			"loud":  {Type: "boolean"},
+			"items": {Type: "array"},
+		},
+		System: "Be {{.tone}}.",
+		Prompt: "{{.text}} x{{.count}}{{if .loud}}!{{end}}{{with .items}} first {{index . 1}}{{end}}",
+	}
+	if err := tmpl.compile(); err != nil {
+		t.Fatal(err)
+	}
+	return tmpl
+}
+
+func TestTemplateRender(t *testing.T) {
+	tests := []struct {
+		name     string
+		vars     map[string]any
+		system   string
+		prompt   string
+		problems []string
+		render   bool
+	}{
+		{
+			name:   "defaults",
+			vars:   map[string]any{"text": "hi"},
+			system: "Be neutral.", prompt: "hi x3",
+		},
+		{
+			name:   "all set",
+			vars:   map[string]any{"text": "hé", "tone": "formal", "count": float64(2), "ratio": 0.5, "loud": true, "items": []any{"a", "b"}},
+			system: "Be formal.", prompt: "hé x2! first b",
+		},
+		{
+			name:   "null is unset",
+			vars:   map[string]any{"text": "hi", "count": nil},
+			system: "Be neutral.", prompt: "hi x3",
+		},
+		{
+			name:   "max length counts runes",
+			vars:   map[string]any{"text": "ééééé"},
+			system: "Be neutral.", prompt: "ééééé x3",
+		},
+		{
+			name:     "missing",
+			vars:     map[string]any{},
+			problems: []string{"text: is required"},
+		},
+		{
+			name:     "unknown",
+			vars:     map[string]any{"text": "hi", "mood": "sad"},
+			problems: []string{"mood: unknown variable"},
+		},
+		{
+			name: "wrong types",
+			vars: map[string]any{"text": 1.0, "count": 1.5, "ratio": "1", "loud": "yes", "items": "a"},
+			problems: []string{
+				"count: must be an integer",
+				"items: must be an array",
+				"loud: must be a boolean",
+				"ratio: must be a number",
+				"text: must be a string",
+			},
+		},
+		{
+			name:     "too long and not in enum",
+			vars:     map[string]any{"text": "éééééé", "tone": "rude"},
+			problems: []string{"text: must be at most 5 characters", "tone: must be one of neutral, formal"},
+		},
+		{
+			name:   "fails to execute",
+			vars:   map[string]any{"text": "hi", "items": []any{"a"}},
+			render: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := newTestTemplate(t).Render(tt.vars)
+
+			var (
+				vars   *VariableError
+				render *RenderError
+			)
+			switch {
+			case tt.problems != nil:
+				if !errors.As(err, &vars) || !reflect.DeepEqual(vars.Problems, tt.problems) {
+					t.Errorf("Render() error = %v, want problems %q", err, tt.problems)
+				}
+			case tt.render:
+				if !errors.As(err, &render) {
+					t.Errorf("Render() error = %v, want a render error", err)
+				}
+			case err != nil:
+				t.Errorf("Render() error = %v", err)
+			case got.System != tt.system || got.Prompt != tt.prompt:
+				t.Errorf("Render() = %q, %q, want %q, %q", got.System, got.Prompt, tt.system, tt.prompt)
+			}
+		})
+	}
+}
+
+func TestTemplateCompile(t *testing.T) {
+	tests := []struct {
+		name string
+		tmpl Template
+		ok   bool
+	}{
+		{"valid", Template{Name: "a", Prompt: "{{.x}}", Variables: map[string]Variable{"x": {Type: "string"}}}, true},
+		{"no name", Template{Prompt: "p"}, false},
+		{"blank prompt", Template{Name: "a", Prompt: " \n"}, false},
+		{"unknown type", Template{Name: "a", Prompt: "p", Variables: map[string]Variable{"x": {Type: "date"}}}, false},
+		{"bad syntax", Template{Name: "a", Prompt: "{{.x"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.tmpl.compile(); (err == nil) != tt.ok {
+				t.Errorf("compile() error = %v, want ok %t", err, tt.ok)
+			}
+		})
+	}
+}
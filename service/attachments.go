@@ -0,0 +1,206 @@
+package service
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"kit-fiber-example/config"
+)
+
+// Source is where an image or document block gets its data from.
+type Source struct {
+	Type      string `json:"type"` // base64 | url | text
+	MediaType string `json:"media_type,omitempty"`
+	Data      string `json:"data,omitempty"`
+	URL       string `json:"url,omitempty"`
+}
+
+// Attachment is a file sent along with a question. Either Data or URL is set.
+type Attachment struct {
+	Name      string
+	MediaType string
+	Data      []byte
+	URL       string
+}
+
+func (a Attachment) isImage() bool {
+	return strings.HasPrefix(a.MediaType, "image/")
+}
+
+// block converts the attachment to an image or document content block.
+func (a Attachment) block() Content {
+	typ := "document"
+	if a.isImage() {
+		typ = "image"
+	}
+	block := Content{Type: typ}
+	if typ == "document" {
+		block.Title = a.Name
+	}
+
+	switch {
+	case a.URL != "":
+		block.Source = &Source{Type: "url", URL: a.URL}
+	case a.MediaType == "text/plain":
+		block.Source = &Source{Type: "text", MediaType: a.MediaType, Data: string(a.Data)}
+	default:
+		block.Source = &Source{Type: "base64", MediaType: a.MediaType, Data: base64.StdEncoding.EncodeToString(a.Data)}
+	}
+	return block
+}
+
+var imageTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/gif":  true,
+	"image/webp": true,
+}
+
+// AttachmentPolicy validates attachments before they are sent upstream.
+type AttachmentPolicy struct {
+	MaxFiles         int
+	MaxImageBytes    int
+	MaxDocumentBytes int
+	MaxImagePixels   int // max width and height
+	MaxPages         int
+}
+
+func NewAttachmentPolicy(cfg *config.Config) AttachmentPolicy {
+	p := AttachmentPolicy{
+		MaxFiles:         cfg.Claude.Attachments.MaxFiles,
+		MaxImageBytes:    cfg.Claude.Attachments.MaxImageBytes,
+		MaxDocumentBytes: cfg.Claude.Attachments.MaxDocumentBytes,
+		MaxImagePixels:   cfg.Claude.Attachments.MaxImagePixels,
+		MaxPages:         cfg.Claude.Attachments.MaxPages,
+	}
+	// Messages API limits
+	if p.MaxFiles <= 0 {
+		p.MaxFiles = 20
+	}
+	if p.MaxImageBytes <= 0 {
+		p.MaxImageBytes = 5 << 20
+	}
+	if p.MaxDocumentBytes <= 0 {
+		p.MaxDocumentBytes = 32 << 20
+	}
+	if p.MaxImagePixels <= 0 {
+		p.MaxImagePixels = 8000
+	}
+	if p.MaxPages <= 0 {
+		p.MaxPages = 100
+	}
+	return p
+}
+
+// Validate checks the attachments and fills in their sniffed media types.
+// The declared media type of inline data is never trusted.
+func (p AttachmentPolicy) Validate(attachments []Attachment) ([]Attachment, error) {
+	if len(attachments) > p.MaxFiles {
+		return nil, invalidf("at most %d attachments are allowed", p.MaxFiles)
+	}
+
+	valid := make([]Attachment, 0, len(attachments))
+	for _, a := range attachments {
+		var err error
+		if a.URL != "" {
+			a, err = p.validateURL(a)
+		} else {
+			a, err = p.validateData(a)
+		}
+		if err != nil {
+			return nil, err
+		}
+		valid = append(valid, a)
+	}
+	return valid, nil
+}
+
+func (p AttachmentPolicy) validateURL(a Attachment) (Attachment, error) {
+	u, err := url.Parse(a.URL)
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		return a, invalidf("%s: attachment url must be an absolute https url", a.displayName())
+	}
+	if !imageTypes[a.MediaType] && a.MediaType != "application/pdf" {
+		return a, invalidf("%s: media type %q is not supported for urls", a.displayName(), a.MediaType)
+	}
+	return a, nil
+}
+
+func (p AttachmentPolicy) validateData(a Attachment) (Attachment, error) {
+	if len(a.Data) == 0 {
+		return a, invalidf("%s: attachment is empty", a.displayName())
+	}
+
+	mediaType, _, _ := strings.Cut(http.DetectContentType(a.Data), ";")
+	a.MediaType = mediaType
+
+	switch {
+	case imageTypes[mediaType]:
+		if len(a.Data) > p.MaxImageBytes {
+			return a, tooLargef("%s: images must be at most %d bytes", a.displayName(), p.MaxImageBytes)
+		}
+		// webp isn't decodable by the standard library, its size is checked upstream
+		if mediaType != "image/webp" {
+			cfg, _, err := image.DecodeConfig(bytes.NewReader(a.Data))
+			if err != nil {
+				return a, invalidf("%s: image is corrupt: %v", a.displayName(), err)
+			}
+			if cfg.Width > p.MaxImagePixels || cfg.Height > p.MaxImagePixels {
+				return a, invalidf("%s: images must be at most %dx%d pixels", a.displayName(), p.MaxImagePixels, p.MaxImagePixels)
+			}
+		}
+	case mediaType == "application/pdf":
+		if len(a.Data) > p.MaxDocumentBytes {
+			return a, tooLargef("%s: documents must be at most %d bytes", a.displayName(), p.MaxDocumentBytes)
+		}
+		if pages := countPDFPages(a.Data); pages > p.MaxPages {
+			return a, invalidf("%s: documents must have at most %d pages, got %d", a.displayName(), p.MaxPages, pages)
+		}
+	case mediaType == "text/plain":
+		if len(a.Data) > p.MaxDocumentBytes {
+			return a, tooLargef("%s: documents must be at most %d bytes", a.displayName(), p.MaxDocumentBytes)
+		}
+		if !utf8.Valid(a.Data) {
+			return a, invalidf("%s: text documents must be UTF-8", a.displayName())
+		}
+	default:
+		return a, ServiceError{
+			Code:    http.StatusUnsupportedMediaType,
+			Message: fmt.Sprintf("%s: media type %q is not supported", a.displayName(), mediaType),
+		}
+	}
+	return a, nil
+}
+
+func (a Attachment) displayName() string {
+	if a.Name != "" {
+		return a.Name
+	}
+	return "attachment"
+}
+
+// pdfPage matches page objects but not the /Pages tree nodes.
+var pdfPage = regexp.MustCompile(`/Type\s*/Page[^s]`)
+
+// countPDFPages is a cheap estimate that doesn't parse the document.
+// Compressed object streams hide pages from it, so it can only undercount.
+func countPDFPages(data []byte) int {
+	return len(pdfPage.FindAllIndex(data, -1))
+}
+
+func tooLargef(format string, args ...any) error {
+	return ServiceError{
+		Code:    http.StatusRequestEntityTooLarge,
+		Message: fmt.Sprintf(format, args...),
+	}
+}
@@ -4,22 +4,53 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"fmt"
+	"io"
 	"net/http"
+	"sort"
+	"strings"
 	"time"
 
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+
 	"kit-fiber-example/config"
+	"kit-fiber-example/metrics"
+)
+
+const (
+	defaultAPIVersion        = "2023-06-01"
+	defaultToolTimeout       = 10 * time.Second
+	defaultMaxToolIterations = 5
+
+	// StatusOverloaded is what the Messages API answers when it's overloaded
+	StatusOverloaded = 529
 )
 
 // Claude API structures
 type ClaudeRequest struct {
-	Model     string    `json:"model"`
-	Messages  []Message `json:"messages"`
-	MaxTokens int       `json:"max_tokens,omitempty"`
+	Model         string           `json:"model"`
+	Messages      []Message        `json:"messages"`
+	MaxTokens     int              `json:"max_tokens"`
+	System        string           `json:"system,omitempty"`
+	Temperature   *float64         `json:"temperature,omitempty"`
+	TopP          *float64         `json:"top_p,omitempty"`
+	TopK          *int             `json:"top_k,omitempty"`
+	StopSequences []string         `json:"stop_sequences,omitempty"`
+	Metadata      *Metadata        `json:"metadata,omitempty"`
+	Tools         []ToolDefinition `json:"tools,omitempty"`
+	ToolChoice    *ToolChoice      `json:"tool_choice,omitempty"`
+	Stream        bool             `json:"stream,omitempty"`
 }
 
 type Message struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
+	Role    string    `json:"role"`
+	Content []Content `json:"content"`
+}
+
+type Metadata struct {
+	UserID string `json:"user_id,omitempty"`
 }
 
 type ClaudeResponse struct {
@@ -34,9 +65,39 @@ type ClaudeResponse struct {
 	StopSequence string    `json:"stop_sequence"`
 }
 
+// Text joins all text content blocks of the response.
+func (r *ClaudeResponse) Text() string {
+	var sb strings.Builder
+	for _, c := range r.Content {
+		if c.Type == "text" {
+			sb.WriteString(c.Text)
+		}
+	}
+	return sb.String()
+}
+
+// Content is a content block. Type tells which of the fields are set.
 type Content struct {
 	Type string `json:"type"`
-	Text string `json:"text"`
+	Text string `json:"text,omitempty"`
+
+	// tool_use
+	ID    string          `json:"id,omitempty"`
+	Name  string          `json:"name,omitempty"`
+	Input json.RawMessage `json:"input,omitempty"`
+
+	// tool_result
+	ToolUseID string `json:"tool_use_id,omitempty"`
+	Content   string `json:"content,omitempty"`
+	IsError   bool   `json:"is_error,omitempty"`
+
+	// image and document
+	Source *Source `json:"source,omitempty"`
+	Title  string  `json:"title,omitempty"`
+}
+
+func TextContent(text string) Content {
+	return Content{Type: "text", Text: text}
 }
 
 type Usage struct {
@@ -44,61 +105,495 @@ type Usage struct {
 	OutputTokens int `json:"output_tokens"`
 }
 
+// ClaudeError is the error body returned by the Messages API
+type ClaudeError struct {
+	Type  string `json:"type"`
+	Error struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// AskRequest is a single-turn question with optional sampling parameters.
+// Zero values are replaced by the server-side defaults.
+type AskRequest struct {
+	Question      string
+	System        string
+	Model         string
+	MaxTokens     int
+	Temperature   *float64
+	TopP          *float64
+	TopK          *int
+	StopSequences []string
+	UserID        string
+	// Tenant and Template are only used for model routing
+	Tenant   string
+	Template string
+	// Attachments are sent before the question
+	Attachments []Attachment
+}
+
+type AskResponse struct {
+	Answer       string
+	Model        string
+	StopReason   string
+	StopSequence string
+	Usage        Usage
+}
+
 type ClaudeClient struct {
 	httpClient *http.Client
 	apiKey     string
 	baseURL    string
+	version    string
 	model      string
+	defaults   AskRequest
+	models     map[string]config.ModelLimits
+	policy     AttachmentPolicy
+
+	tools             map[string]Tool
+	toolTimeout       time.Duration
+	maxToolIterations int
+
+	extractMaxAttempts int
+
+	router *Router
+	clientOptions
 }
 
-func NewClaudeClient(cfg *config.Config) *ClaudeClient {
-	return &ClaudeClient{
+// clientOptions are shared by the clients of every model API
+type clientOptions struct {
+	transport http.RoundTripper
+	metrics   *metrics.Metrics
+	tracer    trace.Tracer
+}
+
+// ClaudeOption configures a model API client, the OpenAI client takes the
+// same options.
+type ClaudeOption func(*clientOptions)
+
+// WithMetrics records every model API call by served model.
+func WithMetrics(m *metrics.Metrics) ClaudeOption {
+	return func(o *clientOptions) { o.metrics = m }
+}
+
+// WithTransport sends model API calls through rt, e.g. a cassette recorder.
+func WithTransport(rt http.RoundTripper) ClaudeOption {
+	return func(o *clientOptions) { o.transport = rt }
+}
+
+// WithTracer starts the spans of the client, e.g. tool calls, with tracer.
+func WithTracer(tracer trace.Tracer) ClaudeOption {
+	return func(o *clientOptions) { o.tracer = tracer }
+}
+
+func newClientOptions(options []ClaudeOption) clientOptions {
+	o := clientOptions{tracer: noop.NewTracerProvider().Tracer("")}
+	for _, option := range options {
+		option(&o)
+	}
+	return o
+}
+
+func NewClaudeClient(cfg *config.Config, options ...ClaudeOption) (*ClaudeClient, error) {
+	version := cfg.Claude.Version
+	if version == "" {
+		version = defaultAPIVersion
+	}
+	toolTimeout := defaultToolTimeout
+	if cfg.Claude.Tools.Timeout != "" {
+		d, err := time.ParseDuration(cfg.Claude.Tools.Timeout)
+		if err != nil {
+			return nil, fmt.Errorf("claude.tools.timeout: %w", err)
+		}
+		toolTimeout = d
+	}
+	maxToolIterations := cfg.Claude.Tools.MaxIterations
+	if maxToolIterations <= 0 {
+		maxToolIterations = defaultMaxToolIterations
+	}
+	extractMaxAttempts := cfg.Claude.Extract.MaxAttempts
+	if extractMaxAttempts <= 0 {
+		extractMaxAttempts = defaultExtractMaxAttempts
+	}
+	opts := newClientOptions(options)
+	c := &ClaudeClient{
 		httpClient: &http.Client{
-			Timeout: time.Duration(cfg.Claude.Timeout) * time.Second,
+			Transport: opts.transport,
+			Timeout:   time.Duration(cfg.Claude.Timeout) * time.Second,
 		},
 		apiKey:  cfg.Claude.APIKey,
 		baseURL: cfg.Claude.BaseURL,
+		version: version,
 		model:   cfg.Claude.Model,
+		defaults: AskRequest{
+			System:      cfg.Claude.Defaults.System,
+			MaxTokens:   cfg.Claude.Defaults.MaxTokens,
+			Temperature: cfg.Claude.Defaults.Temperature,
+			TopP:        cfg.Claude.Defaults.TopP,
+			TopK:        cfg.Claude.Defaults.TopK,
+		},
+		models: cfg.Claude.Models,
+		policy: NewAttachmentPolicy(cfg),
+
+		tools:             make(map[string]Tool),
+		toolTimeout:       toolTimeout,
+		maxToolIterations: maxToolIterations,
+
+		extractMaxAttempts: extractMaxAttempts,
+
+		router:        NewRouter(cfg),
+		clientOptions: opts,
 	}
+	return c, nil
 }
 
-func (c *ClaudeClient) Ask(ctx context.Context, question string) (string, error) {
+// Check reports the circuit breakers of the models for the health report
+func (c *ClaudeClient) Check(ctx context.Context) (any, error) {
+	return c.router.Check(ctx)
+}
+
+// NewRequest applies the defaults to ask, validates it against the model
+// limits and builds the Messages API request.
+func (c *ClaudeClient) NewRequest(ask AskRequest) (ClaudeRequest, error) {
+	attachments, err := c.policy.Validate(ask.Attachments)
+	if err != nil {
+		return ClaudeRequest{}, err
+	}
+	content := make([]Content, 0, len(attachments)+1)
+	for _, a := range attachments {
+		content = append(content, a.block())
+	}
+	content = append(content, TextContent(ask.Question))
+
 	request := ClaudeRequest{
-		Model: c.model,
-		Messages: []Message{
-			{
-				Role:    "user",
-				Content: question,
-			},
-		},
+		Model:         firstNonEmpty(ask.Model, c.model),
+		Messages:      []Message{{Role: "user", Content: content}},
+		MaxTokens:     ask.MaxTokens,
+		System:        firstNonEmpty(ask.System, c.defaults.System),
+		Temperature:   ask.Temperature,
+		TopP:          ask.TopP,
+		TopK:          ask.TopK,
+		StopSequences: ask.StopSequences,
+	}
+	if request.MaxTokens == 0 {
+		request.MaxTokens = c.defaults.MaxTokens
+	}
+	if request.Temperature == nil {
+		request.Temperature = c.defaults.Temperature
+	}
+	if request.TopP == nil {
+		request.TopP = c.defaults.TopP
+	}
+	if request.TopK == nil {
+		request.TopK = c.defaults.TopK
 	}
+	if ask.UserID != "" {
+		request.Metadata = &Metadata{UserID: ask.UserID}
+	}
+	for _, t := range c.tools {
+		request.Tools = append(request.Tools, t.definition())
+	}
+	// Keep the request stable, map order is random
+	sort.Slice(request.Tools, func(i, j int) bool { return request.Tools[i].Name < request.Tools[j].Name })
+
+	return request, c.validate(request)
+}
 
-	postBody, err := json.Marshal(request)
+func (c *ClaudeClient) validate(r ClaudeRequest) error {
+	question := r.Messages[0].Content[len(r.Messages[0].Content)-1]
+	if strings.TrimSpace(question.Text) == "" {
+		return invalidf("question is required")
+	}
+
+	limits, ok := c.models[r.Model]
+	if !ok {
+		if len(c.models) > 0 || r.Model != c.model {
+			return invalidf("model %q is not allowed", r.Model)
+		}
+	}
+	return checkLimits(r, limits)
+}
+
+// checkLimits validates the sampling parameters of r against the limits of
+// its model.
+func checkLimits(r ClaudeRequest, limits config.ModelLimits) error {
+	if r.MaxTokens < 1 {
+		return invalidf("max_tokens must be positive")
+	}
+	if limits.MaxOutputTokens > 0 && r.MaxTokens > limits.MaxOutputTokens {
+		return invalidf("max_tokens must be at most %d for %s", limits.MaxOutputTokens, r.Model)
+	}
+	if r.Temperature != nil {
+		maxTemperature := limits.MaxTemperature
+		if maxTemperature == 0 {
+			maxTemperature = 1
+		}
+		if *r.Temperature < 0 || *r.Temperature > maxTemperature {
+			return invalidf("temperature must be between 0 and %g", maxTemperature)
+		}
+	}
+	if r.TopP != nil && (*r.TopP < 0 || *r.TopP > 1) {
+		return invalidf("top_p must be between 0 and 1")
+	}
+	if r.TopK != nil {
+		if *r.TopK < 1 {
+			return invalidf("top_k must be positive")
+		}
+		if limits.MaxTopK > 0 && *r.TopK > limits.MaxTopK {
+			return invalidf("top_k must be at most %d", limits.MaxTopK)
+		}
+	}
+	if limits.MaxStopSequences > 0 && len(r.StopSequences) > limits.MaxStopSequences {
+		return invalidf("at most %d stop_sequences are allowed", limits.MaxStopSequences)
+	}
+	return nil
+}
+
+// route picks the model of ask and records the decision on the current span.
+func (c *ClaudeClient) route(ctx context.Context, ask AskRequest) AskRequest {
+	model, reason := c.router.Route(ask)
+	trace.SpanFromContext(ctx).SetAttributes(
+		attribute.String("routing.model", model),
+		attribute.String("routing.reason", reason),
+	)
+	ask.Model = model
+	return ask
+}
+
+// Complete implements LLMProvider. Registered tools are run until Claude
+// gives a final answer.
+func (c *ClaudeClient) Complete(ctx context.Context, ask AskRequest) (AskResponse, error) {
+	request, err := c.NewRequest(c.route(ctx, ask))
+	if err != nil {
+		return AskResponse{}, err
+	}
+
+	response, err := c.converse(ctx, request)
+	if err != nil {
+		return AskResponse{}, err
+	}
+
+	return AskResponse{
+		Answer:       response.Text(),
+		Model:        response.Model,
+		StopReason:   response.StopReason,
+		StopSequence: response.StopSequence,
+		Usage:        response.Usage,
+	}, nil
+}
+
+// converse sends the request and keeps running the tools Claude asks for,
+// feeding their results back, until Claude stops for another reason.
+// The returned response carries the usage summed over all rounds.
+func (c *ClaudeClient) converse(ctx context.Context, request ClaudeRequest) (*ClaudeResponse, error) {
+	var usage Usage
+	for i := 0; ; i++ {
+		response, err := c.Do(ctx, request)
+		if err != nil {
+			return nil, err
+		}
+		usage.InputTokens += response.Usage.InputTokens
+		usage.OutputTokens += response.Usage.OutputTokens
+		response.Usage = usage
+
+		if response.StopReason != "tool_use" {
+			return response, nil
+		}
+		if i >= c.maxToolIterations {
+			return nil, ServiceError{
+				Code:    http.StatusBadGateway,
+				Message: fmt.Sprintf("claude: tool use did not finish after %d iterations", c.maxToolIterations),
+			}
+		}
+
+		var results []Content
+		for _, block := range response.Content {
+			if block.Type == "tool_use" {
+				results = append(results, c.runTool(ctx, block))
+			}
+		}
+		request.Messages = append(request.Messages,
+			Message{Role: "assistant", Content: response.Content},
+			Message{Role: "user", Content: results},
+		)
+	}
+}
+
+// Do sends a prepared request to the Messages API. When the model is
+// unavailable, the request is retried with the fallback models in order.
+// The model that served the request is reported in the response.
+func (c *ClaudeClient) Do(ctx context.Context, request ClaudeRequest) (*ClaudeResponse, error) {
+	var response *ClaudeResponse
+	err := c.withFallback(ctx, request, func(model string) error {
+		request.Model = model
+		var err error
+		response, err = c.send(ctx, request)
+		return err
+	})
+	return response, err
+}
+
+// withFallback calls fn with the model of request and then with the
+// fallback models until one of them isn't unavailable, skipping models whose
+// circuit is open or whose limits the request exceeds.
+func (c *ClaudeClient) withFallback(ctx context.Context, request ClaudeRequest, fn func(model string) error) error {
+	span := trace.SpanFromContext(ctx)
+
+	var lastErr error
+	for i, model := range c.router.Chain(request.Model) {
+		if i > 0 {
+			fallback := request
+			fallback.Model = model
+			if err := checkLimits(fallback, c.models[model]); err != nil {
+				span.AddEvent("routing.skip", trace.WithAttributes(
+					attribute.String("model", model),
+					attribute.String("reason", err.Error()),
+				))
+				continue
+			}
+		}
+		breaker := c.router.Breaker(model)
+		if !breaker.Allow() {
+			span.AddEvent("routing.skip", trace.WithAttributes(
+				attribute.String("model", model),
+				attribute.String("reason", "circuit open"),
+			))
+			lastErr = ServiceError{
+				Code:    http.StatusServiceUnavailable,
+				Message: fmt.Sprintf("claude: circuit for %s is open", model),
+			}
+			continue
+		}
+		if i > 0 {
+			span.AddEvent("routing.fallback", trace.WithAttributes(
+				attribute.String("model", model),
+				attribute.String("error", lastErr.Error()),
+			))
+		}
+
+		err := fn(model)
+		if err == nil {
+			breaker.Success()
+			span.SetAttributes(attribute.String("routing.served_model", model))
+			return nil
+		}
+		if !shouldFallback(ctx, err) {
+			// The request itself is wrong, another model won't help
+			breaker.Success()
+			return err
+		}
+		breaker.Failure()
+		lastErr = err
+	}
+	return lastErr
+}
+
+// observe records a model API call, use it deferred.
+func (o clientOptions) observe(model string, begin time.Time, err error) {
+	if o.metrics == nil {
+		return
+	}
+	outcome := "success"
+	if err != nil {
+		outcome = "error"
+	}
+	o.metrics.UpstreamRequests.With("model", model, "outcome", outcome).Add(1)
+	o.metrics.UpstreamLatency.With("model", model).Observe(time.Since(begin).Seconds())
+}
+
+func (c *ClaudeClient) newHTTPRequest(ctx context.Context, url string, body any) (*http.Request, error) {
+	postBody, err := json.Marshal(body)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	req, err := http.NewRequestWithContext(ctx, "POST", c.baseURL, bytes.NewBuffer(postBody))
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(postBody))
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	req.Header.Set("x-api-key", c.apiKey)
+	req.Header.Set("anthropic-version", c.version)
+	return req, nil
+}
+
+// send makes a single Messages API call.
+func (c *ClaudeClient) send(ctx context.Context, request ClaudeRequest) (response *ClaudeResponse, err error) {
+	defer func(begin time.Time) { c.observe(request.Model, begin, err) }(time.Now())
+
+	req, err := c.newHTTPRequest(ctx, c.baseURL, request)
+	if err != nil {
+		return nil, err
+	}
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return "", nil
+		return nil, upstreamError(resp)
+	}
+
+	response = &ClaudeResponse{}
+	if err := json.NewDecoder(resp.Body).Decode(response); err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+// UpstreamError is a non-200 answer of the Messages API. It unwraps to the
+// ServiceError that should be reported to our callers.
+type UpstreamError struct {
+	StatusCode int
+	Type       string
+	Message    string
+}
+
+func (e *UpstreamError) Error() string {
+	return "claude: " + e.Message
+}
+
+func (e *UpstreamError) Unwrap() error {
+	// Client errors are ours to report, anything else means the upstream failed
+	code := http.StatusBadGateway
+	switch e.StatusCode {
+	case http.StatusBadRequest, http.StatusRequestEntityTooLarge, http.StatusTooManyRequests:
+		code = e.StatusCode
 	}
+	return ServiceError{Code: code, Message: e.Error()}
+}
 
-	var response ClaudeResponse
-	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
-		return "", err
+func upstreamError(resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+
+	ue := &UpstreamError{
+		StatusCode: resp.StatusCode,
+		Message:    http.StatusText(resp.StatusCode),
+	}
+	var e ClaudeError
+	if json.Unmarshal(body, &e) == nil && e.Error.Message != "" {
+		ue.Type = e.Error.Type
+		ue.Message = e.Error.Message
 	}
+	return ue
+}
 
-	return response.Content[0].Text, nil
+func invalidf(format string, args ...any) error {
+	return ServiceError{
+		Code:    http.StatusBadRequest,
+		Message: fmt.Sprintf(format, args...),
+	}
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
 }
@@ -0,0 +1,156 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// streamEvent covers the fields of all Messages API stream events we use.
+type streamEvent struct {
+	Type    string          `json:"type"`
+	Message *ClaudeResponse `json:"message"`
+	Delta   struct {
+		Type         string `json:"type"`
+		Text         string `json:"text"`
+		StopReason   string `json:"stop_reason"`
+		StopSequence string `json:"stop_sequence"`
+	} `json:"delta"`
+	Usage *Usage `json:"usage"`
+}
+
+// committedError marks a stream that failed after deltas were delivered,
+// it must not be retried with a fallback model.
+type committedError struct{ err error }
+
+func (e committedError) Error() string { return e.err.Error() }
+func (e committedError) Unwrap() error { return e.err }
+
+// Stream implements LLMProvider. Tools are not offered to streamed
+// requests, so the answer is always plain text.
+func (c *ClaudeClient) Stream(ctx context.Context, ask AskRequest, onDelta func(string) error) (AskResponse, error) {
+	request, err := c.NewRequest(c.route(ctx, ask))
+	if err != nil {
+		return AskResponse{}, err
+	}
+	request.Tools = nil
+	request.Stream = true
+
+	var response AskResponse
+	err = c.withFallback(ctx, request, func(model string) error {
+		request.Model = model
+		var err error
+		response, err = c.sendStream(ctx, request, onDelta)
+		return err
+	})
+	var ce committedError
+	if errors.As(err, &ce) {
+		err = ce.err
+	}
+	return response, err
+}
+
+func (c *ClaudeClient) sendStream(ctx context.Context, request ClaudeRequest, onDelta func(string) error) (response AskResponse, err error) {
+	defer func(begin time.Time) { c.observe(request.Model, begin, err) }(time.Now())
+
+	req, err := c.newHTTPRequest(ctx, c.baseURL, request)
+	if err != nil {
+		return AskResponse{}, err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return AskResponse{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return AskResponse{}, upstreamError(resp)
+	}
+
+	var answer strings.Builder
+	started := false
+	err = readSSE(resp.Body, func(event, data string) error {
+		if event == "error" {
+			var e ClaudeError
+			json.Unmarshal([]byte(data), &e)
+			return &UpstreamError{StatusCode: StatusOverloaded, Type: e.Error.Type, Message: e.Error.Message}
+		}
+
+		var ev streamEvent
+		if err := json.Unmarshal([]byte(data), &ev); err != nil {
+			return err
+		}
+		switch ev.Type {
+		case "message_start":
+			if ev.Message != nil {
+				response.Model = ev.Message.Model
+				response.Usage.InputTokens = ev.Message.Usage.InputTokens
+			}
+		case "content_block_delta":
+			if ev.Delta.Type != "text_delta" {
+				return nil
+			}
+			started = true
+			answer.WriteString(ev.Delta.Text)
+			return onDelta(ev.Delta.Text)
+		case "message_delta":
+			response.StopReason = ev.Delta.StopReason
+			response.StopSequence = ev.Delta.StopSequence
+			if ev.Usage != nil {
+				response.Usage.OutputTokens = ev.Usage.OutputTokens
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		if started {
+			return AskResponse{}, committedError{err}
+		}
+		return AskResponse{}, err
+	}
+
+	response.Answer = answer.String()
+	return response, nil
+}
+
+// CountTokens implements LLMProvider with the count_tokens API.
+func (c *ClaudeClient) CountTokens(ctx context.Context, ask AskRequest) (int, error) {
+	request, err := c.NewRequest(c.route(ctx, ask))
+	if err != nil {
+		return 0, err
+	}
+
+	body := struct {
+		Model    string           `json:"model"`
+		Messages []Message        `json:"messages"`
+		System   string           `json:"system,omitempty"`
+		Tools    []ToolDefinition `json:"tools,omitempty"`
+	}{request.Model, request.Messages, request.System, request.Tools}
+
+	req, err := c.newHTTPRequest(ctx, strings.TrimSuffix(c.baseURL, "/")+"/count_tokens", body)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, upstreamError(resp)
+	}
+
+	var count struct {
+		InputTokens int `json:"input_tokens"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&count); err != nil {
+		return 0, err
+	}
+	return count.InputTokens, nil
+}
@@ -0,0 +1,290 @@
+package service_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"kit-fiber-example/claudetest"
+	"kit-fiber-example/config"
+	"kit-fiber-example/service"
+)
+
+// newTestClient returns a client calling a fresh fake Messages API server
+func newTestClient(t *testing.T, configure func(cfg *config.Config), options ...service.ClaudeOption) (*service.ClaudeClient, *claudetest.Server) {
+	t.Helper()
+	fake := claudetest.NewServer()
+	srv := fake.Start()
+	t.Cleanup(srv.Close)
+
+	cfg := &config.Config{}
+	cfg.Claude.BaseURL = srv.URL
+	cfg.Claude.APIKey = "test-key"
+	cfg.Claude.Model = "claude-primary"
+	cfg.Claude.Defaults.MaxTokens = 1024
+	cfg.Claude.Timeout = 5
+	cfg.CircuitBreaker.Threshold = 5
+	cfg.CircuitBreaker.Timeout = "1m"
+	if configure != nil {
+		configure(cfg)
+	}
+	c, err := service.NewClaudeClient(cfg, options...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c, fake
+}
+
+func withFallbacks(models ...string) func(cfg *config.Config) {
+	return func(cfg *config.Config) { cfg.Claude.Routing.Fallbacks = models }
+}
+
+// models returns the model of every recorded request
+func models(fake *claudetest.Server) []string {
+	var asked []string
+	for _, r := range fake.Requests() {
+		asked = append(asked, r.Request.Model)
+	}
+	return asked
+}
+
+func TestComplete(t *testing.T) {
+	tests := []struct {
+		name       string
+		rules      []claudetest.Rule
+		fail       int
+		failStatus int
+		wantAnswer string
+		wantModel  string
+		wantAsked  []string
+		wantStatus int // of the ServiceError, 0 when the ask succeeds
+	}{
+		{
+			name:       "answer",
+			rules:      []claudetest.Rule{{Match: "hello", Answer: "Hi there"}},
+			wantAnswer: "Hi there",
+			wantModel:  "claude-primary",
+			wantAsked:  []string{"claude-primary"},
+		},
+		{
+			name:       "overloaded primary falls back",
+			rules:      []claudetest.Rule{{Match: "hello", Answer: "Hi there"}},
+			fail:       1,
+			failStatus: service.StatusOverloaded,
+			wantAnswer: "Hi there",
+			wantModel:  "claude-fallback",
+			wantAsked:  []string{"claude-primary", "claude-fallback"},
+		},
+		{
+			name:       "all models overloaded",
+			fail:       2,
+			failStatus: service.StatusOverloaded,
+			wantAsked:  []string{"claude-primary", "claude-fallback"},
+			wantStatus: 502,
+		},
+		{
+			name:       "rate limits don't fall back",
+			fail:       1,
+			failStatus: 429,
+			wantAsked:  []string{"claude-primary"},
+			wantStatus: 429,
+		},
+		{
+			name:      "malformed body",
+			rules:     []claudetest.Rule{{Match: "hello", Malformed: true}},
+			wantAsked: []string{"claude-primary"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, fake := newTestClient(t, withFallbacks("claude-fallback"))
+			if err := fake.Add(tt.rules...); err != nil {
+				t.Fatal(err)
+			}
+			if tt.fail > 0 {
+				fake.Fail(tt.fail, tt.failStatus)
+			}
+
+			response, err := c.Complete(context.Background(), service.AskRequest{Question: "hello"})
+			if got := models(fake); !slices.Equal(got, tt.wantAsked) {
+				t.Errorf("asked %v, want %v", got, tt.wantAsked)
+			}
+			if tt.wantAnswer == "" {
+				if err == nil {
+					t.Fatalf("got %+v, want an error", response)
+				}
+				var se service.ServiceError
+				if tt.wantStatus != 0 && (!errors.As(err, &se) || se.Code != tt.wantStatus) {
+					t.Errorf("err = %v, want status %d", err, tt.wantStatus)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if response.Answer != tt.wantAnswer || response.Model != tt.wantModel {
+				t.Errorf("got %q from %s, want %q from %s", response.Answer, response.Model, tt.wantAnswer, tt.wantModel)
+			}
+		})
+	}
+}
+
+func TestCompleteRequest(t *testing.T) {
+	c, fake := newTestClient(t, func(cfg *config.Config) {
+		cfg.Claude.Defaults.System = "Be brief."
+	})
+
+	temperature := 0.5
+	_, err := c.Complete(context.Background(), service.AskRequest{
+		Question:      "hello",
+		Temperature:   &temperature,
+		StopSequences: []string{"END"},
+		UserID:        "user-1",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	requests := fake.Requests()
+	if len(requests) != 1 {
+		t.Fatalf("sent %d requests, want 1", len(requests))
+	}
+	r := requests[0]
+	if got := r.Header.Get("x-api-key"); got != "test-key" {
+		t.Errorf("x-api-key = %q", got)
+	}
+	if got := r.Header.Get("anthropic-version"); got == "" {
+		t.Error("anthropic-version is missing")
+	}
+	req := r.Request
+	if req.Model != "claude-primary" || req.MaxTokens != 1024 || req.System != "Be brief." {
+		t.Errorf("model, max_tokens, system = %q, %d, %q, want the defaults", req.Model, req.MaxTokens, req.System)
+	}
+	if req.Temperature == nil || *req.Temperature != 0.5 {
+		t.Errorf("temperature = %v, want 0.5", req.Temperature)
+	}
+	if !slices.Equal(req.StopSequences, []string{"END"}) {
+		t.Errorf("stop_sequences = %v", req.StopSequences)
+	}
+	if req.Metadata == nil || req.Metadata.UserID != "user-1" {
+		t.Errorf("metadata = %+v, want the user id", req.Metadata)
+	}
+	if req.Stream {
+		t.Error("stream is set on a complete")
+	}
+}
+
+func TestCompleteTools(t *testing.T) {
+	spans := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans))
+	c, fake := newTestClient(t, nil, service.WithTracer(tp.Tracer("test")))
+	if err := c.RegisterTool(service.NewUppercaseTool(service.String{})); err != nil {
+		t.Fatal(err)
+	}
+	fake.Add(
+		claudetest.Rule{Match: "shout", ToolUse: &claudetest.ToolUse{Name: "uppercase", Input: json.RawMessage(`{"string":"shout"}`)}, Times: 1},
+		claudetest.Rule{Match: "shout", Answer: "SHOUT"},
+	)
+
+	response, err := c.Complete(context.Background(), service.AskRequest{Question: "shout"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.Answer != "SHOUT" {
+		t.Errorf("answer = %q, want SHOUT", response.Answer)
+	}
+
+	requests := fake.Requests()
+	if len(requests) != 2 {
+		t.Fatalf("sent %d requests, want 2", len(requests))
+	}
+	if tools := requests[0].Request.Tools; len(tools) != 1 || tools[0].Name != "uppercase" {
+		t.Errorf("tools = %+v, want the uppercase tool", tools)
+	}
+	// The tool result goes back with the conversation so far
+	messages := requests[1].Request.Messages
+	result := messages[len(messages)-1].Content[0]
+	if result.Type != "tool_result" || result.Content != "SHOUT" || result.IsError {
+		t.Errorf("last block = %+v, want the tool result", result)
+	}
+	// The tool call is traced by the injected tracer
+	var names []string
+	for _, span := range spans.Ended() {
+		names = append(names, span.Name())
+	}
+	if !slices.Equal(names, []string{"tool uppercase"}) {
+		t.Errorf("spans = %v, want the tool span", names)
+	}
+}
+
+func TestStream(t *testing.T) {
+	tests := []struct {
+		name       string
+		fail       int
+		wantModel  string
+		wantAsked  []string
+		wantDeltas int
+	}{
+		{"stream", 0, "claude-primary", []string{"claude-primary"}, 4},
+		{"overloaded primary falls back", 1, "claude-fallback", []string{"claude-primary", "claude-fallback"}, 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, fake := newTestClient(t, withFallbacks("claude-fallback"))
+			fake.Reply("hello", "one two three four")
+			if tt.fail > 0 {
+				fake.Fail(tt.fail, service.StatusOverloaded)
+			}
+
+			var deltas []string
+			response, err := c.Stream(context.Background(), service.AskRequest{Question: "hello"}, func(text string) error {
+				deltas = append(deltas, text)
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(deltas) != tt.wantDeltas {
+				t.Errorf("got %d deltas, want %d", len(deltas), tt.wantDeltas)
+			}
+			if joined := strings.Join(deltas, ""); joined != response.Answer || joined != "one two three four" {
+				t.Errorf("deltas %q and answer %q differ", joined, response.Answer)
+			}
+			if response.Model != tt.wantModel || response.StopReason != "end_turn" {
+				t.Errorf("model, stop reason = %q, %q", response.Model, response.StopReason)
+			}
+			if got := models(fake); !slices.Equal(got, tt.wantAsked) {
+				t.Errorf("asked %v, want %v", got, tt.wantAsked)
+			}
+			for _, r := range fake.Requests() {
+				if !r.Request.Stream || len(r.Request.Tools) > 0 {
+					t.Errorf("stream, tools = %v, %v, want a stream without tools", r.Request.Stream, r.Request.Tools)
+				}
+			}
+		})
+	}
+}
+
+func TestStreamStops(t *testing.T) {
+	c, fake := newTestClient(t, nil)
+	fake.Reply("hello", "one two three four")
+
+	stop := errors.New("stop")
+	var deltas int
+	_, err := c.Stream(context.Background(), service.AskRequest{Question: "hello"}, func(string) error {
+		deltas++
+		return stop
+	})
+	if !errors.Is(err, stop) {
+		t.Errorf("err = %v, want the error of onDelta", err)
+	}
+	if deltas != 1 {
+		t.Errorf("got %d deltas, want 1", deltas)
+	}
+}
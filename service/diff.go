@@ -0,0 +1,306 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/rivo/uniseg"
+)
+
+// Diff granularities
+const (
+	DiffUnified = "unified" // lines, with a unified diff text
+	DiffWords   = "word"
+	DiffChars   = "char" // grapheme clusters
+)
+
+// Diffs with more edits than this are rejected, the cost of the diff grows
+// with the square of the number of edits.
+const maxDiffEdits = 2000
+
+// Unified diff context lines around a change
+const diffContext = 3
+
+// DiffOp is a run of text that is equal in both strings, or only in one of them
+type DiffOp struct {
+	Op   string // equal | insert | delete
+	Text string
+}
+
+type DiffResult struct {
+	Ops     []DiffOp
+	Unified string // only for DiffUnified
+}
+
+// Diff returns the edits that turn a into b.
+func (String) Diff(a, b, mode string) (DiffResult, error) {
+	var split func(string) []string
+	switch mode {
+	case DiffUnified, "":
+		mode, split = DiffUnified, splitLines
+	case DiffWords:
+		split = splitWords
+	case DiffChars:
+		split = splitGraphemes
+	default:
+		return DiffResult{}, ServiceError{
+			Code:    http.StatusBadRequest,
+			Message: fmt.Sprintf("unknown diff mode %q, want unified, word or char", mode),
+		}
+	}
+
+	ops, ok := diffTokens(split(a), split(b))
+	if !ok {
+		return DiffResult{}, ServiceError{
+			Code:    http.StatusRequestEntityTooLarge,
+			Message: fmt.Sprintf("strings differ in more than %d places", maxDiffEdits),
+		}
+	}
+
+	result := DiffResult{Ops: mergeOps(ops)}
+	if mode == DiffUnified {
+		result.Unified = unified(ops)
+	}
+	return result, nil
+}
+
+func splitLines(s string) []string {
+	return strings.SplitAfter(s, "\n")
+}
+
+// splitWords splits s into words and the whitespace between them, so that
+// joining the tokens gives s back.
+func splitWords(s string) []string {
+	var tokens []string
+	start, space := 0, false
+	for i, r := range s {
+		if i > start && unicode.IsSpace(r) != space {
+			tokens = append(tokens, s[start:i])
+			start = i
+		}
+		space = unicode.IsSpace(r)
+	}
+	if start < len(s) {
+		tokens = append(tokens, s[start:])
+	}
+	return tokens
+}
+
+func splitGraphemes(s string) []string {
+	var tokens []string
+	g := uniseg.NewGraphemes(s)
+	for g.Next() {
+		tokens = append(tokens, g.Str())
+	}
+	return tokens
+}
+
+// diffTokens is the Myers diff of a and b, with one op per token. It gives
+// up once more than maxDiffEdits edits are needed.
+func diffTokens(a, b []string) ([]DiffOp, bool) {
+	// Common prefix and suffix need no search
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	var ops []DiffOp
+	for _, t := range a[:prefix] {
+		ops = append(ops, DiffOp{"equal", t})
+	}
+	middle, ok := myers(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])
+	if !ok {
+		return nil, false
+	}
+	ops = append(ops, middle...)
+	for _, t := range a[len(a)-suffix:] {
+		ops = append(ops, DiffOp{"equal", t})
+	}
+	return ops, true
+}
+
+func myers(a, b []string) ([]DiffOp, bool) {
+	n, m := len(a), len(b)
+	if n+m == 0 {
+		return nil, true
+	}
+	maxD := min(n+m, maxDiffEdits)
+	offset := maxD + 1
+
+	// trace[d] is the furthest x on the diagonals -d..d before round d, the
+	// only ones the walk back reads. Copying all of v each round would take
+	// 2*maxD ints per round instead of 2*d+1.
+	v := make([]int, 2*offset+1)
+	var trace [][]int
+	found := false
+	for d := 0; d <= maxD && !found; d++ {
+		trace = append(trace, append([]int(nil), v[offset-d:offset+d+1]...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x, y = x+1, y+1
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				found = true
+				break
+			}
+		}
+	}
+	if !found {
+		return nil, false
+	}
+
+	// Walk back from the end to recover the edits
+	var ops []DiffOp
+	x, y := n, m
+	for d := len(trace) - 1; d > 0; d-- {
+		// Diagonal k is at index k+d of the round
+		v := trace[d]
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && v[d+k-1] < v[d+k+1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v[d+prevK]
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			x, y = x-1, y-1
+			ops = append(ops, DiffOp{"equal", a[x]})
+		}
+		if x == prevX {
+			y--
+			ops = append(ops, DiffOp{"insert", b[y]})
+		} else {
+			x--
+			ops = append(ops, DiffOp{"delete", a[x]})
+		}
+	}
+	for x > 0 && y > 0 {
+		x, y = x-1, y-1
+		ops = append(ops, DiffOp{"equal", a[x]})
+	}
+
+	for i, j := 0, len(ops)-1; i < j; i, j = i+1, j-1 {
+		ops[i], ops[j] = ops[j], ops[i]
+	}
+	return ops, true
+}
+
+// mergeOps joins consecutive ops of the same kind
+func mergeOps(ops []DiffOp) []DiffOp {
+	ops = slices.DeleteFunc(slices.Clone(ops), func(op DiffOp) bool { return op.Text == "" })
+	merged := make([]DiffOp, 0, len(ops))
+	for i := 0; i < len(ops); {
+		// Joined once per run, appending token by token is quadratic
+		j := i + 1
+		for j < len(ops) && ops[j].Op == ops[i].Op {
+			j++
+		}
+		var text strings.Builder
+		for _, op := range ops[i:j] {
+			text.WriteString(op.Text)
+		}
+		merged = append(merged, DiffOp{ops[i].Op, text.String()})
+		i = j
+	}
+	return merged
+}
+
+// unified renders line ops as a unified diff without file headers
+func unified(ops []DiffOp) string {
+	// Drop the empty line after a trailing newline
+	lines := make([]DiffOp, 0, len(ops))
+	for _, op := range ops {
+		if op.Text != "" {
+			lines = append(lines, op)
+		}
+	}
+
+	var b strings.Builder
+	for start := 0; start < len(lines); {
+		// Find the next change and the hunk around it
+		first := start
+		for first < len(lines) && lines[first].Op == "equal" {
+			first++
+		}
+		if first == len(lines) {
+			break
+		}
+		from := max(first-diffContext, start)
+		to := first
+		for i := first; i < len(lines); i++ {
+			if lines[i].Op != "equal" {
+				to = i + 1
+			} else if i-to >= 2*diffContext {
+				break
+			}
+		}
+		to = min(to+diffContext, len(lines))
+
+		aStart, bStart := position(lines[:from])
+		var aLen, bLen int
+		for _, op := range lines[from:to] {
+			if op.Op != "insert" {
+				aLen++
+			}
+			if op.Op != "delete" {
+				bLen++
+			}
+		}
+		fmt.Fprintf(&b, "@@ -%s +%s @@\n", hunkRange(aStart, aLen), hunkRange(bStart, bLen))
+		for _, op := range lines[from:to] {
+			prefix := " "
+			switch op.Op {
+			case "insert":
+				prefix = "+"
+			case "delete":
+				prefix = "-"
+			}
+			b.WriteString(prefix + op.Text)
+			if !strings.HasSuffix(op.Text, "\n") {
+				b.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+		start = to
+	}
+	return b.String()
+}
+
+// position returns the 0-based line numbers in a and b after ops
+func position(ops []DiffOp) (a, b int) {
+	for _, op := range ops {
+		if op.Op != "insert" {
+			a++
+		}
+		if op.Op != "delete" {
+			b++
+		}
+	}
+	return a, b
+}
+
+func hunkRange(start, length int) string {
+	if length == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	if length == 1 {
+		return fmt.Sprint(start + 1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, length)
+}
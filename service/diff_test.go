@@ -0,0 +1,159 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// apply rebuilds both sides of a diff from its ops
+func apply(ops []DiffOp) (a, b string) {
+	var sa, sb strings.Builder
+	for _, op := range ops {
+		if op.Op != "insert" {
+			sa.WriteString(op.Text)
+		}
+		if op.Op != "delete" {
+			sb.WriteString(op.Text)
+		}
+	}
+	return sa.String(), sb.String()
+}
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name    string
+		a, b    string
+		mode    string
+		wantOps []DiffOp
+	}{
+		{"both empty", "", "", DiffChars, []DiffOp{}},
+		{"empty a", "", "abc", DiffChars, []DiffOp{{"insert", "abc"}}},
+		{"empty b", "abc", "", DiffChars, []DiffOp{{"delete", "abc"}}},
+		{"identical", "same text", "same text", DiffWords, []DiffOp{{"equal", "same text"}}},
+		{"chars", "kitten", "sitting", DiffChars, []DiffOp{
+			{"delete", "k"}, {"insert", "s"}, {"equal", "itt"}, {"delete", "e"}, {"insert", "i"}, {"equal", "n"}, {"insert", "g"},
+		}},
+		{"words", "the quick fox", "the slow fox", DiffWords, []DiffOp{
+			{"equal", "the "}, {"delete", "quick"}, {"insert", "slow"}, {"equal", " fox"},
+		}},
+		{"graphemes", "👍🏽a", "👍🏿a", DiffChars, []DiffOp{{"delete", "👍🏽"}, {"insert", "👍🏿"}, {"equal", "a"}}},
+		{"lines", "a\nb\nc\n", "a\nc\n", DiffUnified, []DiffOp{{"equal", "a\n"}, {"delete", "b\n"}, {"equal", "c\n"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := String{}.Diff(tt.a, tt.b, tt.mode)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(result.Ops) != len(tt.wantOps) {
+				t.Fatalf("ops = %q, want %q", result.Ops, tt.wantOps)
+			}
+			for i := range result.Ops {
+				if result.Ops[i] != tt.wantOps[i] {
+					t.Fatalf("ops = %q, want %q", result.Ops, tt.wantOps)
+				}
+			}
+			if a, b := apply(result.Ops); a != tt.a || b != tt.b {
+				t.Errorf("ops rebuild %q and %q", a, b)
+			}
+		})
+	}
+}
+
+func TestDiffEditCap(t *testing.T) {
+	tests := []struct {
+		name    string
+		a, b    string
+		wantErr bool
+	}{
+		{"at the cap", strings.Repeat("a", maxDiffEdits/2), strings.Repeat("b", maxDiffEdits/2), false},
+		{"over the cap", strings.Repeat("a", maxDiffEdits/2+1), strings.Repeat("b", maxDiffEdits/2), true},
+		// Shared prefixes and suffixes don't count
+		{"long common parts", strings.Repeat("x", 100000) + "a" + strings.Repeat("y", 100000), strings.Repeat("x", 100000) + "b" + strings.Repeat("y", 100000), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := String{}.Diff(tt.a, tt.b, DiffChars)
+			if tt.wantErr {
+				var se ServiceError
+				if !errors.As(err, &se) || se.Code != 413 {
+					t.Fatalf("err = %v, want a 413", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if a, b := apply(result.Ops); a != tt.a || b != tt.b {
+				t.Error("ops don't rebuild the inputs")
+			}
+		})
+	}
+}
+
+func TestUnified(t *testing.T) {
+	lines := func(changed ...int) string {
+		var b strings.Builder
+		for i := 1; i <= 20; i++ {
+			text := "line"
+			for _, c := range changed {
+				if c == i {
+					text = "changed"
+				}
+			}
+			b.WriteString(text + " " + string(rune('a'+i)) + "\n")
+		}
+		return b.String()
+	}
+
+	tests := []struct {
+		name string
+		a, b string
+		want string
+	}{
+		{"identical", lines(), lines(), ""},
+		{
+			name: "one change",
+			a:    lines(),
+			b:    lines(10),
+			want: "@@ -7,7 +7,7 @@\n line h\n line i\n line j\n-line k\n+changed k\n line l\n line m\n line n\n",
+		},
+		{
+			name: "close changes share a hunk",
+			a:    lines(),
+			b:    lines(5, 11),
+			want: "@@ -2,13 +2,13 @@\n line c\n line d\n line e\n-line f\n+changed f\n line g\n line h\n line i\n line j\n line k\n-line l\n+changed l\n line m\n line n\n line o\n",
+		},
+		{
+			name: "distant changes get their own hunks",
+			a:    lines(),
+			b:    lines(2, 18),
+			want: "@@ -1,5 +1,5 @@\n line b\n-line c\n+changed c\n line d\n line e\n line f\n" +
+				"@@ -15,6 +15,6 @@\n line p\n line q\n line r\n-line s\n+changed s\n line t\n line u\n",
+		},
+		{
+			name: "from empty",
+			a:    "",
+			b:    "one\ntwo\n",
+			want: "@@ -0,0 +1,2 @@\n+one\n+two\n",
+		},
+		{
+			name: "missing newline",
+			a:    "one\ntwo",
+			b:    "one\nthree",
+			want: "@@ -1,2 +1,2 @@\n one\n-two\n\\ No newline at end of file\n+three\n\\ No newline at end of file\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := String{}.Diff(tt.a, tt.b, DiffUnified)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Unified != tt.want {
+				t.Errorf("unified diff:\n%s\nwant:\n%s", result.Unified, tt.want)
+			}
+		})
+	}
+}
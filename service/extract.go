@@ -0,0 +1,163 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"kit-fiber-example/jsonschema"
+)
+
+const (
+	extractToolName           = "extract"
+	defaultExtractMaxAttempts = 3
+)
+
+// ExtractRequest asks Claude to produce JSON matching Schema from Input.
+type ExtractRequest struct {
+	Input  string
+	Schema json.RawMessage
+	// Instructions are added to the prompt, e.g. what to extract
+	Instructions string
+	Model        string
+	MaxTokens    int
+	// MaxAttempts bounds the re-asks after validation failures, it's
+	// capped at the configured claude.extract.maxAttempts
+	MaxAttempts int
+	UserID      string
+}
+
+type ExtractResponse struct {
+	Data     json.RawMessage
+	Model    string
+	Attempts int
+	Usage    Usage
+}
+
+// ExtractError is returned when Claude didn't produce valid JSON within
+// the allowed attempts.
+type ExtractError struct {
+	Attempts int
+	Problems []jsonschema.Error
+}
+
+func (e *ExtractError) Error() string {
+	problems := make([]string, len(e.Problems))
+	for i, p := range e.Problems {
+		problems[i] = p.String()
+	}
+	return fmt.Sprintf("output doesn't match the schema after %d attempts: %s", e.Attempts, strings.Join(problems, "; "))
+}
+
+// Extract forces Claude to call a tool whose input schema is the requested
+// one, so the tool input is the structured answer. Invalid answers are
+// returned to Claude as a failed tool result and it's asked to try again.
+func (c *ClaudeClient) Extract(ctx context.Context, ext ExtractRequest) (ExtractResponse, error) {
+	schema, err := jsonschema.Parse(ext.Schema)
+	if err != nil {
+		return ExtractResponse{}, invalidf("%v", err)
+	}
+
+	// Tool inputs are always objects, so other schemas get wrapped
+	inputSchema := ext.Schema
+	wrapped := !schema.IsObject()
+	if wrapped {
+		inputSchema = json.RawMessage(fmt.Sprintf(`{"type":"object","properties":{"result":%s},"required":["result"]}`, ext.Schema))
+	}
+
+	question := "Extract the data from the text below by calling the " + extractToolName + " tool."
+	if ext.Instructions != "" {
+		question += "\n\n" + ext.Instructions
+	}
+	question += "\n\n<text>\n" + ext.Input + "\n</text>"
+
+	request, err := c.NewRequest(c.route(ctx, AskRequest{
+		Question:  question,
+		Model:     ext.Model,
+		MaxTokens: ext.MaxTokens,
+		UserID:    ext.UserID,
+	}))
+	if err != nil {
+		return ExtractResponse{}, err
+	}
+	request.Tools = []ToolDefinition{{
+		Name:        extractToolName,
+		Description: "Records the extracted data.",
+		InputSchema: inputSchema,
+	}}
+	request.ToolChoice = &ToolChoice{Type: "tool", Name: extractToolName}
+
+	// Callers may ask for fewer attempts, never for more than configured
+	maxAttempts := ext.MaxAttempts
+	if maxAttempts <= 0 || maxAttempts > c.extractMaxAttempts {
+		maxAttempts = c.extractMaxAttempts
+	}
+
+	var (
+		usage    Usage
+		problems []jsonschema.Error
+	)
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		response, err := c.Do(ctx, request)
+		if err != nil {
+			return ExtractResponse{}, err
+		}
+		usage.InputTokens += response.Usage.InputTokens
+		usage.OutputTokens += response.Usage.OutputTokens
+
+		use, ok := findToolUse(response.Content, extractToolName)
+		if !ok {
+			return ExtractResponse{}, ServiceError{
+				Code:    http.StatusBadGateway,
+				Message: "claude: response has no " + extractToolName + " tool call",
+			}
+		}
+
+		data := use.Input
+		if wrapped {
+			var w struct {
+				Result json.RawMessage `json:"result"`
+			}
+			if err := json.Unmarshal(use.Input, &w); err == nil && w.Result != nil {
+				data = w.Result
+			}
+		}
+
+		problems = schema.Validate(data)
+		if len(problems) == 0 {
+			return ExtractResponse{
+				Data:     data,
+				Model:    response.Model,
+				Attempts: attempt,
+				Usage:    usage,
+			}, nil
+		}
+
+		feedback := make([]string, len(problems))
+		for i, p := range problems {
+			feedback[i] = p.String()
+		}
+		request.Messages = append(request.Messages,
+			Message{Role: "assistant", Content: response.Content},
+			Message{Role: "user", Content: []Content{{
+				Type:      "tool_result",
+				ToolUseID: use.ID,
+				IsError:   true,
+				Content:   "The data doesn't match the schema:\n" + strings.Join(feedback, "\n") + "\nCall the tool again with corrected data.",
+			}}},
+		)
+	}
+
+	return ExtractResponse{}, &ExtractError{Attempts: maxAttempts, Problems: problems}
+}
+
+func findToolUse(content []Content, name string) (Content, bool) {
+	for _, block := range content {
+		if block.Type == "tool_use" && block.Name == name {
+			return block, true
+		}
+	}
+	return Content{}, false
+}
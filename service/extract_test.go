@@ -0,0 +1,139 @@
+package service_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"kit-fiber-example/claudetest"
+	"kit-fiber-example/service"
+)
+
+func TestExtract(t *testing.T) {
+	const schema = `{"type":"object","properties":{"name":{"type":"string"}},"required":["name"]}`
+	valid := &claudetest.ToolUse{Name: "extract", Input: json.RawMessage(`{"name":"Ada"}`)}
+	invalid := &claudetest.ToolUse{Name: "extract", Input: json.RawMessage(`{}`)}
+
+	tests := []struct {
+		name         string
+		schema       string
+		maxAttempts  int
+		rules        []claudetest.Rule
+		wantData     string
+		wantAttempts int
+		wantErr      bool
+	}{
+		{
+			name:         "valid at once",
+			schema:       schema,
+			rules:        []claudetest.Rule{{ToolUse: valid}},
+			wantData:     `{"name":"Ada"}`,
+			wantAttempts: 1,
+		},
+		{
+			name:         "valid after a re-ask",
+			schema:       schema,
+			rules:        []claudetest.Rule{{ToolUse: invalid, Times: 1}, {ToolUse: valid}},
+			wantData:     `{"name":"Ada"}`,
+			wantAttempts: 2,
+		},
+		{
+			name:         "never valid",
+			schema:       schema,
+			rules:        []claudetest.Rule{{ToolUse: invalid}},
+			wantAttempts: 3,
+			wantErr:      true,
+		},
+		{
+			name:         "fewer attempts requested",
+			schema:       schema,
+			maxAttempts:  1,
+			rules:        []claudetest.Rule{{ToolUse: invalid}},
+			wantAttempts: 1,
+			wantErr:      true,
+		},
+		{
+			name:         "more attempts than configured",
+			schema:       schema,
+			maxAttempts:  10,
+			rules:        []claudetest.Rule{{ToolUse: invalid}},
+			wantAttempts: 3,
+			wantErr:      true,
+		},
+		{
+			name:         "wrapped schema",
+			schema:       `{"type":"array","items":{"type":"string"}}`,
+			rules:        []claudetest.Rule{{ToolUse: &claudetest.ToolUse{Name: "extract", Input: json.RawMessage(`{"result":["a","b"]}`)}}},
+			wantData:     `["a","b"]`,
+			wantAttempts: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, fake := newTestClient(t, nil)
+			if err := fake.Add(tt.rules...); err != nil {
+				t.Fatal(err)
+			}
+
+			response, err := c.Extract(context.Background(), service.ExtractRequest{
+				Input:       "Ada Lovelace wrote the first program.",
+				Schema:      json.RawMessage(tt.schema),
+				MaxAttempts: tt.maxAttempts,
+			})
+			if got := len(fake.Requests()); got != tt.wantAttempts {
+				t.Errorf("sent %d requests, want %d", got, tt.wantAttempts)
+			}
+			if tt.wantErr {
+				var ee *service.ExtractError
+				if !errors.As(err, &ee) {
+					t.Fatalf("err = %v, want an ExtractError", err)
+				}
+				if ee.Attempts != tt.wantAttempts {
+					t.Errorf("attempts = %d, want %d", ee.Attempts, tt.wantAttempts)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(response.Data) != tt.wantData {
+				t.Errorf("data = %s, want %s", response.Data, tt.wantData)
+			}
+			if response.Attempts != tt.wantAttempts {
+				t.Errorf("attempts = %d, want %d", response.Attempts, tt.wantAttempts)
+			}
+		})
+	}
+}
+
+func TestExtractFeedback(t *testing.T) {
+	c, fake := newTestClient(t, nil)
+	fake.Add(
+		claudetest.Rule{ToolUse: &claudetest.ToolUse{Name: "extract", Input: json.RawMessage(`{}`)}, Times: 1},
+		claudetest.Rule{ToolUse: &claudetest.ToolUse{Name: "extract", Input: json.RawMessage(`{"name":"Ada"}`)}},
+	)
+
+	_, err := c.Extract(context.Background(), service.ExtractRequest{
+		Input:  "Ada Lovelace",
+		Schema: json.RawMessage(`{"type":"object","properties":{"name":{"type":"string"}},"required":["name"]}`),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	requests := fake.Requests()
+	first := requests[0].Request
+	if first.ToolChoice == nil || first.ToolChoice.Name != "extract" {
+		t.Errorf("tool choice = %+v, want the extract tool", first.ToolChoice)
+	}
+	// The re-ask carries the rejected call and why it was rejected
+	retry := requests[1].Request.Messages
+	if len(retry) != 3 {
+		t.Fatalf("re-ask has %d messages, want 3", len(retry))
+	}
+	result := retry[2].Content[0]
+	if result.Type != "tool_result" || !result.IsError || result.ToolUseID != retry[1].Content[0].ID {
+		t.Errorf("re-ask ends with %+v, want a failed result of the rejected call", result)
+	}
+}
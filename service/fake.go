@@ -0,0 +1,107 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"kit-fiber-example/config"
+)
+
+// FakeProvider answers from a script, without calling any model. Answers are
+// deterministic, which makes it useful for local development and tests.
+type FakeProvider struct {
+	latency   time.Duration
+	responses []fakeResponse
+	fallback  string
+}
+
+type fakeResponse struct {
+	match  *regexp.Regexp
+	answer string
+}
+
+func NewFakeProvider(cfg *config.Config) (*FakeProvider, error) {
+	p := &FakeProvider{fallback: cfg.LLM.Fake.Default}
+	if cfg.LLM.Fake.Latency != "" {
+		d, err := time.ParseDuration(cfg.LLM.Fake.Latency)
+		if err != nil {
+			return nil, fmt.Errorf("llm.fake.latency: %w", err)
+		}
+		p.latency = d
+	}
+	for i, r := range cfg.LLM.Fake.Responses {
+		re, err := regexp.Compile(r.Match)
+		if err != nil {
+			return nil, fmt.Errorf("llm.fake.responses[%d].match: %w", i, err)
+		}
+		p.responses = append(p.responses, fakeResponse{re, r.Answer})
+	}
+	return p, nil
+}
+
+func (p *FakeProvider) answer(ask AskRequest) string {
+	for _, r := range p.responses {
+		if r.match.MatchString(ask.Question) {
+			return r.answer
+		}
+	}
+	if p.fallback != "" {
+		return p.fallback
+	}
+	return "You asked: " + ask.Question
+}
+
+func (p *FakeProvider) wait(ctx context.Context) error {
+	if p.latency == 0 {
+		return nil
+	}
+	select {
+	case <-time.After(p.latency):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Complete implements LLMProvider.
+func (p *FakeProvider) Complete(ctx context.Context, ask AskRequest) (AskResponse, error) {
+	if strings.TrimSpace(ask.Question) == "" {
+		return AskResponse{}, invalidf("question is required")
+	}
+	if err := p.wait(ctx); err != nil {
+		return AskResponse{}, err
+	}
+
+	answer := p.answer(ask)
+	return AskResponse{
+		Answer:     answer,
+		Model:      "fake",
+		StopReason: "end_turn",
+		Usage: Usage{
+			InputTokens:  approxTokens(ask.System, ask.Question),
+			OutputTokens: approxTokens(answer),
+		},
+	}, nil
+}
+
+// Stream implements LLMProvider, the answer is streamed word by word.
+func (p *FakeProvider) Stream(ctx context.Context, ask AskRequest, onDelta func(string) error) (AskResponse, error) {
+	response, err := p.Complete(ctx, ask)
+	if err != nil {
+		return AskResponse{}, err
+	}
+	for _, word := range strings.SplitAfter(response.Answer, " ") {
+		if err := onDelta(word); err != nil {
+			return AskResponse{}, err
+		}
+	}
+	return response, nil
+}
+
+// CountTokens implements LLMProvider.
+func (p *FakeProvider) CountTokens(_ context.Context, ask AskRequest) (int, error) {
+	return approxTokens(ask.System, ask.Question), nil
+}
@@ -0,0 +1,100 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"kit-fiber-example/config"
+)
+
+func TestNewFakeProvider(t *testing.T) {
+	tests := []struct {
+		name      string
+		configure func(cfg *config.Config)
+		ok        bool
+	}{
+		{"empty", func(*config.Config) {}, true},
+		{"latency", func(cfg *config.Config) { cfg.LLM.Fake.Latency = "10ms" }, true},
+		{"invalid latency", func(cfg *config.Config) { cfg.LLM.Fake.Latency = "soon" }, false},
+		{"invalid match", func(cfg *config.Config) {
+			cfg.LLM.Fake.Responses = []config.FakeResponse{{Match: "(", Answer: "x"}}
+		}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var cfg config.Config
+			tt.configure(&cfg)
+			if _, err := NewFakeProvider(&cfg); (err == nil) != tt.ok {
+				t.Errorf("NewFakeProvider() error = %v, want ok %t", err, tt.ok)
+			}
+		})
+	}
+}
+
+func TestFakeProvider(t *testing.T) {
+	var cfg config.Config
+	cfg.LLM.Fake.Responses = []config.FakeResponse{
+		{Match: "^hello", Answer: "Hi there"},
+		{Match: "weather", Answer: "Sunny"},
+	}
+	withDefault := cfg
+	withDefault.LLM.Fake.Default = "No idea"
+
+	tests := []struct {
+		name     string
+		cfg      config.Config
+		question string
+		answer   string
+		deltas   []string
+		status   int
+	}{
+		{"first match", cfg, "hello, how's the weather?", "Hi there", []string{"Hi ", "there"}, 0},
+		{"later match", cfg, "how's the weather?", "Sunny", []string{"Sunny"}, 0},
+		{"echo", cfg, "what?", "You asked: what?", []string{"You ", "asked: ", "what?"}, 0},
+		{"default", withDefault, "what?", "No idea", []string{"No ", "idea"}, 0},
+		{"no question", cfg, "  ", "", nil, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := NewFakeProvider(&tt.cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var deltas []string
+			resp, err := p.Stream(context.Background(), AskRequest{Question: tt.question}, func(d string) error {
+				deltas = append(deltas, d)
+				return nil
+			})
+			if got := statusOf(err); got != tt.status {
+				t.Fatalf("Stream() error = %v, want status %d", err, tt.status)
+			}
+			if resp.Answer != tt.answer {
+				t.Errorf("answer = %q, want %q", resp.Answer, tt.answer)
+			}
+			if !reflect.DeepEqual(deltas, tt.deltas) {
+				t.Errorf("deltas = %q, want %q", deltas, tt.deltas)
+			}
+			if tt.status == 0 && (resp.Model != "fake" || resp.StopReason != "end_turn" || resp.Usage.InputTokens == 0) {
+				t.Errorf("response = %+v", resp)
+			}
+		})
+	}
+}
+
+func TestFakeProviderLatency(t *testing.T) {
+	var cfg config.Config
+	cfg.LLM.Fake.Latency = "1h"
+	p, err := NewFakeProvider(&cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := p.Complete(ctx, AskRequest{Question: "hi"}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Complete() error = %v, want the deadline", err)
+	}
+}
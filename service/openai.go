@@ -0,0 +1,265 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"kit-fiber-example/config"
+)
+
+// OpenAIClient talks to any server implementing the OpenAI chat completions
+// API, e.g. local model servers like Ollama, vLLM or llama.cpp.
+type OpenAIClient struct {
+	httpClient *http.Client
+	baseURL    string
+	apiKey     string
+	model      string
+	maxTokens  int
+	policy     AttachmentPolicy
+	clientOptions
+}
+
+func NewOpenAIClient(cfg *config.Config, options ...ClaudeOption) *OpenAIClient {
+	opts := newClientOptions(options)
+	return &OpenAIClient{
+		httpClient: &http.Client{
+			Transport: opts.transport,
+			Timeout:   time.Duration(cfg.LLM.OpenAI.Timeout) * time.Second,
+		},
+		baseURL:       strings.TrimSuffix(cfg.LLM.OpenAI.BaseURL, "/"),
+		apiKey:        cfg.LLM.OpenAI.APIKey,
+		model:         cfg.LLM.OpenAI.Model,
+		maxTokens:     cfg.Claude.Defaults.MaxTokens,
+		policy:        NewAttachmentPolicy(cfg),
+		clientOptions: opts,
+	}
+}
+
+type openAIMessage struct {
+	Role    string `json:"role"`
+	Content any    `json:"content"` // string or []openAIPart
+}
+
+type openAIPart struct {
+	Type     string `json:"type"`
+	Text     string `json:"text,omitempty"`
+	ImageURL *struct {
+		URL string `json:"url"`
+	} `json:"image_url,omitempty"`
+}
+
+type openAIRequest struct {
+	Model       string          `json:"model"`
+	Messages    []openAIMessage `json:"messages"`
+	MaxTokens   int             `json:"max_tokens,omitempty"`
+	Temperature *float64        `json:"temperature,omitempty"`
+	TopP        *float64        `json:"top_p,omitempty"`
+	Stop        []string        `json:"stop,omitempty"`
+	User        string          `json:"user,omitempty"`
+	Stream      bool            `json:"stream,omitempty"`
+}
+
+type openAIResponse struct {
+	Model   string `json:"model"`
+	Choices []struct {
+		Message struct {
+			Content string `json:"content"`
+		} `json:"message"`
+		Delta struct {
+			Content string `json:"content"`
+		} `json:"delta"`
+		FinishReason string `json:"finish_reason"`
+	} `json:"choices"`
+	Usage *struct {
+		PromptTokens     int `json:"prompt_tokens"`
+		CompletionTokens int `json:"completion_tokens"`
+	} `json:"usage"`
+}
+
+// stopReasons maps OpenAI finish reasons to Messages API stop reasons, so
+// that callers see the same values whatever the provider.
+var stopReasons = map[string]string{
+	"stop":   "end_turn",
+	"length": "max_tokens",
+}
+
+func (c *OpenAIClient) newRequest(ask AskRequest) (openAIRequest, error) {
+	if strings.TrimSpace(ask.Question) == "" {
+		return openAIRequest{}, invalidf("question is required")
+	}
+	// Sniffs the media type of inline data, uploads don't declare one
+	attachments, err := c.policy.Validate(ask.Attachments)
+	if err != nil {
+		return openAIRequest{}, err
+	}
+
+	var messages []openAIMessage
+	if ask.System != "" {
+		messages = append(messages, openAIMessage{Role: "system", Content: ask.System})
+	}
+	if len(attachments) == 0 {
+		messages = append(messages, openAIMessage{Role: "user", Content: ask.Question})
+	} else {
+		var parts []openAIPart
+		for _, a := range attachments {
+			if !a.isImage() {
+				return openAIRequest{}, unavailable("%s: documents are not supported by the openai provider", a.displayName())
+			}
+			part := openAIPart{Type: "image_url", ImageURL: &struct {
+				URL string `json:"url"`
+			}{URL: a.URL}}
+			if a.URL == "" {
+				part.ImageURL.URL = "data:" + a.MediaType + ";base64," + base64.StdEncoding.EncodeToString(a.Data)
+			}
+			parts = append(parts, part)
+		}
+		parts = append(parts, openAIPart{Type: "text", Text: ask.Question})
+		messages = append(messages, openAIMessage{Role: "user", Content: parts})
+	}
+
+	maxTokens := ask.MaxTokens
+	if maxTokens == 0 {
+		maxTokens = c.maxTokens
+	}
+	return openAIRequest{
+		Model:       firstNonEmpty(ask.Model, c.model),
+		Messages:    messages,
+		MaxTokens:   maxTokens,
+		Temperature: ask.Temperature,
+		TopP:        ask.TopP,
+		Stop:        ask.StopSequences,
+		User:        ask.UserID,
+	}, nil
+}
+
+func (c *OpenAIClient) post(ctx context.Context, request openAIRequest) (_ *http.Response, err error) {
+	defer func(begin time.Time) { c.observe(request.Model, begin, err) }(time.Now())
+
+	body, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", c.baseURL+"/chat/completions", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4<<10))
+		return nil, &UpstreamError{
+			StatusCode: resp.StatusCode,
+			Message:    fmt.Sprintf("%s: %s", http.StatusText(resp.StatusCode), bytes.TrimSpace(msg)),
+		}
+	}
+	return resp, nil
+}
+
+// Complete implements LLMProvider.
+func (c *OpenAIClient) Complete(ctx context.Context, ask AskRequest) (AskResponse, error) {
+	request, err := c.newRequest(ask)
+	if err != nil {
+		return AskResponse{}, err
+	}
+
+	resp, err := c.post(ctx, request)
+	if err != nil {
+		return AskResponse{}, err
+	}
+	defer resp.Body.Close()
+
+	var response openAIResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return AskResponse{}, err
+	}
+	if len(response.Choices) == 0 {
+		return AskResponse{}, &UpstreamError{StatusCode: http.StatusBadGateway, Message: "response has no choices"}
+	}
+
+	result := AskResponse{
+		Answer:     response.Choices[0].Message.Content,
+		Model:      response.Model,
+		StopReason: firstNonEmpty(stopReasons[response.Choices[0].FinishReason], response.Choices[0].FinishReason),
+	}
+	if response.Usage != nil {
+		result.Usage = Usage{
+			InputTokens:  response.Usage.PromptTokens,
+			OutputTokens: response.Usage.CompletionTokens,
+		}
+	}
+	return result, nil
+}
+
+// Stream implements LLMProvider.
+func (c *OpenAIClient) Stream(ctx context.Context, ask AskRequest, onDelta func(string) error) (AskResponse, error) {
+	request, err := c.newRequest(ask)
+	if err != nil {
+		return AskResponse{}, err
+	}
+	request.Stream = true
+
+	resp, err := c.post(ctx, request)
+	if err != nil {
+		return AskResponse{}, err
+	}
+	defer resp.Body.Close()
+
+	var (
+		result AskResponse
+		answer strings.Builder
+	)
+	err = readSSE(resp.Body, func(_, data string) error {
+		if data == "[DONE]" {
+			return nil
+		}
+		var chunk openAIResponse
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return err
+		}
+		result.Model = chunk.Model
+		if chunk.Usage != nil {
+			result.Usage = Usage{
+				InputTokens:  chunk.Usage.PromptTokens,
+				OutputTokens: chunk.Usage.CompletionTokens,
+			}
+		}
+		if len(chunk.Choices) == 0 {
+			return nil
+		}
+		if reason := chunk.Choices[0].FinishReason; reason != "" {
+			result.StopReason = firstNonEmpty(stopReasons[reason], reason)
+		}
+		if delta := chunk.Choices[0].Delta.Content; delta != "" {
+			answer.WriteString(delta)
+			return onDelta(delta)
+		}
+		return nil
+	})
+	if err != nil {
+		return AskResponse{}, err
+	}
+
+	result.Answer = answer.String()
+	return result, nil
+}
+
+// CountTokens implements LLMProvider. There is no standard counting
+// endpoint, so the count is estimated.
+func (c *OpenAIClient) CountTokens(_ context.Context, ask AskRequest) (int, error) {
+	return approxTokens(ask.System, ask.Question), nil
+}
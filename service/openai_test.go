@@ -0,0 +1,254 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"image"
+	"image/png"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"kit-fiber-example/config"
+	"kit-fiber-example/metrics"
+)
+
+// openAIServer is a fake chat completions API, it keeps the last request
+type openAIServer struct {
+	mu      sync.Mutex
+	request openAIRequest
+	header  http.Header
+}
+
+func (s *openAIServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req openAIRequest
+	if r.URL.Path != "/v1/chat/completions" || json.NewDecoder(r.Body).Decode(&req) != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	s.mu.Lock()
+	s.request, s.header = req, r.Header.Clone()
+	s.mu.Unlock()
+
+	question := ""
+	if q, ok := req.Messages[len(req.Messages)-1].Content.(string); ok {
+		question = q
+	}
+	if question == "fail" {
+		http.Error(w, "model crashed", http.StatusInternalServerError)
+		return
+	}
+	if req.Stream {
+		w.Header().Set("Content-Type", "text/event-stream")
+		io.WriteString(w, `data: {"model":"local","choices":[{"delta":{"content":"hel"}}]}`+"\n\n")
+		io.WriteString(w, `data: {"model":"local","choices":[{"delta":{"content":"lo"},"finish_reason":"length"}]}`+"\n\n")
+		io.WriteString(w, `data: {"model":"local","choices":[],"usage":{"prompt_tokens":3,"completion_tokens":2}}`+"\n\n")
+		io.WriteString(w, "data: [DONE]\n\n")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	io.WriteString(w, `{"model":"local","choices":[{"message":{"content":"hello"},"finish_reason":"stop"}],"usage":{"prompt_tokens":3,"completion_tokens":1}}`)
+}
+
+func (s *openAIServer) last() (openAIRequest, http.Header) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.request, s.header
+}
+
+func newTestOpenAIClient(t *testing.T, options ...ClaudeOption) (*OpenAIClient, *openAIServer) {
+	t.Helper()
+	fake := &openAIServer{}
+	srv := httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+
+	cfg := &config.Config{}
+	cfg.LLM.OpenAI.BaseURL = srv.URL + "/v1/"
+	cfg.LLM.OpenAI.APIKey = "test-key"
+	cfg.LLM.OpenAI.Model = "llama"
+	cfg.LLM.OpenAI.Timeout = 5
+	cfg.Claude.Defaults.MaxTokens = 256
+	cfg.Claude.Attachments.MaxImagePixels = 64
+	return NewOpenAIClient(cfg, options...), fake
+}
+
+func testPNG(t *testing.T, size int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, size, size))); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// statusOf returns the HTTP status of a service or upstream error
+func statusOf(err error) int {
+	var serr ServiceError
+	if errors.As(err, &serr) {
+		return serr.Code
+	}
+	var uerr *UpstreamError
+	if errors.As(err, &uerr) {
+		return uerr.StatusCode
+	}
+	return 0
+}
+
+func TestOpenAIComplete(t *testing.T) {
+	c, fake := newTestOpenAIClient(t)
+	resp, err := c.Complete(context.Background(), AskRequest{Question: "hi", System: "be brief"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := AskResponse{Answer: "hello", Model: "local", StopReason: "end_turn", Usage: Usage{InputTokens: 3, OutputTokens: 1}}
+	if resp != want {
+		t.Errorf("Complete() = %+v, want %+v", resp, want)
+	}
+
+	req, header := fake.last()
+	if got := header.Get("Authorization"); got != "Bearer test-key" {
+		t.Errorf("Authorization = %q", got)
+	}
+	if req.Model != "llama" || req.MaxTokens != 256 || len(req.Messages) != 2 || req.Messages[0].Role != "system" {
+		t.Errorf("request = %+v", req)
+	}
+}
+
+func TestOpenAIAttachments(t *testing.T) {
+	small := testPNG(t, 8)
+	tests := []struct {
+		name        string
+		attachments []Attachment
+		status      int
+		url         string
+	}{
+		{"upload without a media type", []Attachment{{Name: "a.png", Data: small}}, 0, "data:image/png;base64,"},
+		{"wrong declared media type", []Attachment{{Name: "a.png", MediaType: "application/pdf", Data: small}}, 0, "data:image/png;base64,"},
+		{"image url", []Attachment{{URL: "https://example.com/a.png", MediaType: "image/png"}}, 0, "https://example.com/a.png"},
+		{"too many pixels", []Attachment{{Name: "big.png", Data: testPNG(t, 65)}}, http.StatusBadRequest, ""},
+		{"document", []Attachment{{Name: "a.txt", Data: []byte("plain text")}}, http.StatusNotImplemented, ""},
+		{"plain http url", []Attachment{{URL: "http://example.com/a.png", MediaType: "image/png"}}, http.StatusBadRequest, ""},
+		{"unsupported data", []Attachment{{Name: "a.bin", Data: []byte{0, 1, 2, 3}}}, http.StatusUnsupportedMediaType, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, fake := newTestOpenAIClient(t)
+			_, err := c.Complete(context.Background(), AskRequest{Question: "what is this?", Attachments: tt.attachments})
+			if got := statusOf(err); got != tt.status {
+				t.Fatalf("Complete() error = %v with status %d, want %d", err, got, tt.status)
+			}
+			if tt.status != 0 {
+				return
+			}
+
+			req, _ := fake.last()
+			parts, _ := json.Marshal(req.Messages[0].Content)
+			var got []openAIPart
+			if err := json.Unmarshal(parts, &got); err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != 2 || got[0].ImageURL == nil || !strings.HasPrefix(got[0].ImageURL.URL, tt.url) || got[1].Text != "what is this?" {
+				t.Errorf("content = %s", parts)
+			}
+		})
+	}
+}
+
+func TestOpenAIErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		question string
+		status   int
+	}{
+		{"no question", " ", http.StatusBadRequest},
+		{"upstream error", "fail", http.StatusBadGateway},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := newTestOpenAIClient(t)
+			if _, err := c.Complete(context.Background(), AskRequest{Question: tt.question}); statusOf(err) != tt.status {
+				t.Errorf("Complete() error = %v, want status %d", err, tt.status)
+			}
+		})
+	}
+}
+
+func TestOpenAIStream(t *testing.T) {
+	c, fake := newTestOpenAIClient(t)
+	var deltas []string
+	resp, err := c.Stream(context.Background(), AskRequest{Question: "hi"}, func(d string) error {
+		deltas = append(deltas, d)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := AskResponse{Answer: "hello", Model: "local", StopReason: "max_tokens", Usage: Usage{InputTokens: 3, OutputTokens: 2}}
+	if resp != want {
+		t.Errorf("Stream() = %+v, want %+v", resp, want)
+	}
+	if !reflect.DeepEqual(deltas, []string{"hel", "lo"}) {
+		t.Errorf("deltas = %q", deltas)
+	}
+	if req, _ := fake.last(); !req.Stream {
+		t.Error("request isn't a stream")
+	}
+
+	stop := errors.New("stop")
+	if _, err := c.Stream(context.Background(), AskRequest{Question: "hi"}, func(string) error { return stop }); err != stop {
+		t.Errorf("Stream() error = %v, want stop", err)
+	}
+}
+
+// countingTransport counts the requests it passes on
+type countingTransport struct{ n int }
+
+func (t *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.n++
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+type testCounter map[string]float64
+
+func (c testCounter) With(lv ...string) metrics.Counter {
+	return labeledCounter{c, strings.Join(lv, ",")}
+}
+func (c testCounter) Add(d float64) { c[""] += d }
+
+type labeledCounter struct {
+	c      testCounter
+	labels string
+}
+
+func (c labeledCounter) With(lv ...string) metrics.Counter {
+	return labeledCounter{c.c, c.labels + "," + strings.Join(lv, ",")}
+}
+func (c labeledCounter) Add(d float64) { c.c[c.labels] += d }
+
+type testHistogram struct{}
+
+func (testHistogram) With(...string) metrics.Histogram { return testHistogram{} }
+func (testHistogram) Observe(float64)                  {}
+
+func TestOpenAIOptions(t *testing.T) {
+	rt := &countingTransport{}
+	requests := testCounter{}
+	m := &metrics.Metrics{UpstreamRequests: requests, UpstreamLatency: testHistogram{}}
+	c, _ := newTestOpenAIClient(t, WithTransport(rt), WithMetrics(m))
+
+	c.Complete(context.Background(), AskRequest{Question: "hi"})
+	c.Complete(context.Background(), AskRequest{Question: "fail"})
+	if rt.n != 2 {
+		t.Errorf("transport saw %d requests, want 2", rt.n)
+	}
+	want := testCounter{"model,llama,outcome,success": 1, "model,llama,outcome,error": 1}
+	if !reflect.DeepEqual(requests, want) {
+		t.Errorf("upstream requests = %v, want %v", requests, want)
+	}
+}
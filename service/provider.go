@@ -0,0 +1,97 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"kit-fiber-example/config"
+)
+
+// LLMProvider is a language model backend. AskRequest and AskResponse are
+// provider-neutral, every implementation maps them to its own wire format.
+type LLMProvider interface {
+	// Complete answers the question in one piece.
+	Complete(ctx context.Context, req AskRequest) (AskResponse, error)
+	// Stream calls onDelta with every chunk of the answer as it arrives and
+	// returns the complete response at the end. An error from onDelta
+	// aborts the stream.
+	Stream(ctx context.Context, req AskRequest, onDelta func(string) error) (AskResponse, error)
+	// CountTokens returns the number of input tokens req would use.
+	CountTokens(ctx context.Context, req AskRequest) (int, error)
+}
+
+// Extractor is implemented by providers that support structured extraction.
+type Extractor interface {
+	Extract(ctx context.Context, req ExtractRequest) (ExtractResponse, error)
+}
+
+// NewProviderFromConfig returns the provider selected by llm.provider.
+func NewProviderFromConfig(cfg *config.Config, options ...ClaudeOption) (LLMProvider, error) {
+	switch cfg.LLM.Provider {
+	case "", "anthropic":
+		return NewClaudeClient(cfg, options...)
+	case "openai":
+		return NewOpenAIClient(cfg, options...), nil
+	case "fake":
+		return NewFakeProvider(cfg)
+	default:
+		return nil, fmt.Errorf("llm.provider: unknown provider %q", cfg.LLM.Provider)
+	}
+}
+
+// approxTokens is a rough estimate for providers without a token counting
+// API: about four bytes of English text per token.
+func approxTokens(texts ...string) int {
+	n := 0
+	for _, t := range texts {
+		n += (len(t) + 3) / 4
+		// Non-ASCII text is denser in tokens
+		n += (len(t) - utf8.RuneCountInString(t)) / 4
+	}
+	return n
+}
+
+// readSSE calls fn for every server-sent event in r.
+func readSSE(r io.Reader, fn func(event, data string) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64<<10), 1<<20)
+
+	var event string
+	var data []string
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			if len(data) > 0 {
+				if err := fn(event, strings.Join(data, "\n")); err != nil {
+					return err
+				}
+			}
+			event, data = "", nil
+		case strings.HasPrefix(line, "event:"):
+			event = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			data = append(data, strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if len(data) > 0 {
+		return fn(event, strings.Join(data, "\n"))
+	}
+	return nil
+}
+
+// unavailable is returned for features a provider doesn't have.
+func unavailable(format string, args ...any) error {
+	return ServiceError{
+		Code:    http.StatusNotImplemented,
+		Message: fmt.Sprintf(format, args...),
+	}
+}
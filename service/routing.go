@@ -0,0 +1,142 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"kit-fiber-example/cassette"
+	"kit-fiber-example/circuitbreaker"
+	"kit-fiber-example/config"
+)
+
+// Router picks the model of a request and the models to fall back to.
+type Router struct {
+	model     string
+	rules     []config.RoutingRule
+	fallbacks []string
+
+	newBreaker func() *circuitbreaker.Breaker
+	mu         sync.Mutex
+	breakers   map[string]*circuitbreaker.Breaker
+}
+
+func NewRouter(cfg *config.Config) *Router {
+	timeout, err := time.ParseDuration(cfg.CircuitBreaker.Timeout)
+	if err != nil {
+		timeout = time.Minute
+	}
+	return &Router{
+		model:     cfg.Claude.Model,
+		rules:     cfg.Claude.Routing.Rules,
+		fallbacks: cfg.Claude.Routing.Fallbacks,
+		newBreaker: func() *circuitbreaker.Breaker {
+			return circuitbreaker.New(cfg.CircuitBreaker.Threshold, timeout, cfg.CircuitBreaker.MaxRequests)
+		},
+		breakers: make(map[string]*circuitbreaker.Breaker),
+	}
+}
+
+// Route returns the model for ask and why it was chosen. An explicit model
+// wins, then the first matching rule, then the default model.
+func (r *Router) Route(ask AskRequest) (model, reason string) {
+	if ask.Model != "" {
+		return ask.Model, "explicit"
+	}
+	for _, rule := range r.rules {
+		if matches(rule, ask) {
+			return rule.Model, "rule:" + rule.Name
+		}
+	}
+	return r.model, "default"
+}
+
+func matches(rule config.RoutingRule, ask AskRequest) bool {
+	if rule.Tenant != "" && rule.Tenant != ask.Tenant {
+		return false
+	}
+	if rule.Template != "" && rule.Template != ask.Template {
+		return false
+	}
+	n := len([]rune(ask.Question))
+	if rule.MinPromptChars > 0 && n < rule.MinPromptChars {
+		return false
+	}
+	if rule.MaxPromptChars > 0 && n > rule.MaxPromptChars {
+		return false
+	}
+	return true
+}
+
+// Chain returns primary followed by the fallback models.
+func (r *Router) Chain(primary string) []string {
+	chain := []string{primary}
+	for _, m := range r.fallbacks {
+		if m != primary {
+			chain = append(chain, m)
+		}
+	}
+	return chain
+}
+
+func (r *Router) Breaker(model string) *circuitbreaker.Breaker {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	b, ok := r.breakers[model]
+	if !ok {
+		b = r.newBreaker()
+		r.breakers[model] = b
+	}
+	return b
+}
+
+// Check reports the circuit of every model called so far, open circuits
+// are failing.
+func (r *Router) Check(context.Context) (any, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	states := make(map[string]string, len(r.breakers))
+	var open []string
+	for model, b := range r.breakers {
+		state := b.State()
+		states[model] = state.String()
+		if state == circuitbreaker.Open {
+			open = append(open, model)
+		}
+	}
+	if len(open) > 0 {
+		sort.Strings(open)
+		return states, fmt.Errorf("circuit open for %s", strings.Join(open, ", "))
+	}
+	return states, nil
+}
+
+// shouldFallback reports whether err means the model is unavailable rather
+// than the request being wrong. Once the caller gave up or ran out of time,
+// another model won't help either.
+func shouldFallback(ctx context.Context, err error) bool {
+	var (
+		ce committedError
+		me *cassette.MissError
+	)
+	// A request missing from the cassette is missing for every model
+	if ctx.Err() != nil || errors.As(err, &ce) || errors.As(err, &me) {
+		return false
+	}
+	var ue *UpstreamError
+	if errors.As(err, &ue) {
+		return ue.StatusCode == StatusOverloaded || ue.StatusCode >= http.StatusInternalServerError
+	}
+	// All models are served by the same host, so only a model that is too
+	// slow is worth replacing, not one that can't be reached
+	var ne net.Error
+	return errors.As(err, &ne) && ne.Timeout()
+}
@@ -0,0 +1,241 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"kit-fiber-example/cassette"
+	"kit-fiber-example/config"
+)
+
+func TestRoute(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Claude.Model = "default"
+	cfg.Claude.Routing.Rules = []config.RoutingRule{
+		{Name: "acme", Tenant: "acme", Model: "tenant-model"},
+		{Name: "summaries", Template: "summarize", Model: "template-model"},
+		{Name: "short", MaxPromptChars: 10, Model: "short-model"},
+		{Name: "long", MinPromptChars: 100, Model: "long-model"},
+	}
+	router := NewRouter(cfg)
+
+	tests := []struct {
+		name       string
+		ask        AskRequest
+		wantModel  string
+		wantReason string
+	}{
+		{"explicit model wins", AskRequest{Model: "mine", Tenant: "acme"}, "mine", "explicit"},
+		{"tenant rule", AskRequest{Tenant: "acme", Question: "hello there, how are you"}, "tenant-model", "rule:acme"},
+		{"template rule", AskRequest{Template: "summarize", Question: "hello there, how are you"}, "template-model", "rule:summaries"},
+		{"first matching rule", AskRequest{Tenant: "acme", Template: "summarize"}, "tenant-model", "rule:acme"},
+		{"short prompt", AskRequest{Question: "hi"}, "short-model", "rule:short"},
+		{"prompt length counts runes", AskRequest{Question: "ééééééééé"}, "short-model", "rule:short"},
+		{"long prompt", AskRequest{Question: string(make([]byte, 100))}, "long-model", "rule:long"},
+		{"no rule matches", AskRequest{Question: "hello there, how are you"}, "default", "default"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			model, reason := router.Route(tt.ask)
+			if model != tt.wantModel || reason != tt.wantReason {
+				t.Errorf("Route() = %q, %q, want %q, %q", model, reason, tt.wantModel, tt.wantReason)
+			}
+		})
+	}
+}
+
+func TestChain(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Claude.Routing.Fallbacks = []string{"b", "c"}
+	router := NewRouter(cfg)
+
+	tests := []struct {
+		primary string
+		want    []string
+	}{
+		{"a", []string{"a", "b", "c"}},
+		{"b", []string{"b", "c"}},
+		{"c", []string{"c", "b"}},
+	}
+	for _, tt := range tests {
+		if got := router.Chain(tt.primary); !slices.Equal(got, tt.want) {
+			t.Errorf("Chain(%q) = %v, want %v", tt.primary, got, tt.want)
+		}
+	}
+}
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestShouldFallback(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	expired, cancel := context.WithDeadline(context.Background(), time.Now())
+	defer cancel()
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		err  error
+		want bool
+	}{
+		{"overloaded", context.Background(), &UpstreamError{StatusCode: StatusOverloaded}, true},
+		{"server error", context.Background(), &UpstreamError{StatusCode: http.StatusInternalServerError}, true},
+		{"bad request", context.Background(), &UpstreamError{StatusCode: http.StatusBadRequest}, false},
+		{"rate limited", context.Background(), &UpstreamError{StatusCode: http.StatusTooManyRequests}, false},
+		{"transport timeout", context.Background(), fmt.Errorf("post: %w", timeoutError{}), true},
+		{"unreachable", context.Background(), &net.OpError{Op: "dial", Err: errors.New("connection refused")}, false},
+		{"malformed body", context.Background(), &json.SyntaxError{}, false},
+		{"cassette miss", context.Background(), &url.Error{Op: "Post", Err: &cassette.MissError{}}, false},
+		{"committed stream", context.Background(), committedError{&UpstreamError{StatusCode: StatusOverloaded}}, false},
+		{"caller canceled", canceled, timeoutError{}, false},
+		{"caller deadline", expired, &UpstreamError{StatusCode: StatusOverloaded}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := shouldFallback(tt.ctx, tt.err); got != tt.want {
+				t.Errorf("shouldFallback() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// modelServer answers with status for the models in failing and records
+// the models asked for
+type modelServer struct {
+	failing map[string]int
+	delay   time.Duration
+
+	mu     sync.Mutex
+	models []string
+}
+
+func (s *modelServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req ClaudeRequest
+	json.NewDecoder(r.Body).Decode(&req)
+	s.mu.Lock()
+	s.models = append(s.models, req.Model)
+	s.mu.Unlock()
+
+	select {
+	case <-time.After(s.delay):
+	case <-r.Context().Done():
+		return
+	}
+	if status, ok := s.failing[req.Model]; ok {
+		w.WriteHeader(status)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ClaudeResponse{
+		Model:      req.Model,
+		Content:    []Content{TextContent("ok")},
+		StopReason: "end_turn",
+	})
+}
+
+func (s *modelServer) asked() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.models
+}
+
+func TestFallback(t *testing.T) {
+	tests := []struct {
+		name      string
+		maxTokens int
+		failing   map[string]int
+		delay     time.Duration
+		timeout   time.Duration
+		wantAsked []string
+		wantModel string
+		wantErr   bool
+	}{
+		{
+			name:      "primary serves",
+			maxTokens: 4096,
+			wantAsked: []string{"primary"},
+			wantModel: "primary",
+		},
+		{
+			name:      "overloaded primary",
+			maxTokens: 1024,
+			failing:   map[string]int{"primary": StatusOverloaded},
+			wantAsked: []string{"primary", "small"},
+			wantModel: "small",
+		},
+		{
+			name:      "fallback limits are checked",
+			maxTokens: 4096,
+			failing:   map[string]int{"primary": StatusOverloaded},
+			wantAsked: []string{"primary", "large"},
+			wantModel: "large",
+		},
+		{
+			name:      "request errors don't fall back",
+			maxTokens: 1024,
+			failing:   map[string]int{"primary": http.StatusBadRequest},
+			wantAsked: []string{"primary"},
+			wantErr:   true,
+		},
+		{
+			name:      "caller deadline doesn't fall back",
+			maxTokens: 1024,
+			delay:     time.Second,
+			timeout:   50 * time.Millisecond,
+			wantAsked: []string{"primary"},
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			upstream := &modelServer{failing: tt.failing, delay: tt.delay}
+			srv := httptest.NewServer(upstream)
+			defer srv.Close()
+
+			cfg := &config.Config{}
+			cfg.Claude.BaseURL = srv.URL
+			cfg.Claude.Model = "primary"
+			cfg.Claude.Models = map[string]config.ModelLimits{
+				"primary": {MaxOutputTokens: 8192},
+				"small":   {MaxOutputTokens: 2048},
+				"large":   {MaxOutputTokens: 8192},
+			}
+			cfg.Claude.Routing.Fallbacks = []string{"small", "large"}
+			c, err := NewClaudeClient(cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ctx := context.Background()
+			if tt.timeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tt.timeout)
+				defer cancel()
+			}
+			response, err := c.Complete(ctx, AskRequest{Question: "hello", MaxTokens: tt.maxTokens})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && response.Model != tt.wantModel {
+				t.Errorf("served by %q, want %q", response.Model, tt.wantModel)
+			}
+			if asked := upstream.asked(); !slices.Equal(asked, tt.wantAsked) {
+				t.Errorf("asked %v, want %v", asked, tt.wantAsked)
+			}
+		})
+	}
+}
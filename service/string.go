@@ -2,7 +2,8 @@ package service
 
 import (
 	"context"
-	"strings"
+
+	"golang.org/x/text/cases"
 )
 
 // Custom error types
@@ -17,15 +18,35 @@ func (e ServiceError) Error() string {
 
 // stringService is a concrete implementation of StringService
 type String struct {
-	ClaudeClient *ClaudeClient
+	Provider LLMProvider
+}
+
+// Uppercase maps s to upper case with the rules of lang, a BCP-47 tag.
+// Without a tag the language-neutral Unicode mapping is used.
+func (String) Uppercase(s, lang string) (string, error) {
+	tag, err := parseLanguage(lang)
+	if err != nil {
+		return "", err
+	}
+	return cases.Upper(tag).String(s), nil
+}
+
+func (s *String) AskClaude(ctx context.Context, req AskRequest) (AskResponse, error) {
+	return s.Provider.Complete(ctx, req)
+}
+
+func (s *String) AskClaudeStream(ctx context.Context, req AskRequest, onDelta func(string) error) (AskResponse, error) {
+	return s.Provider.Stream(ctx, req, onDelta)
 }
 
-func (String) Uppercase(s string) (string, error) {
-	return strings.ToUpper(s), nil
+func (s *String) CountTokens(ctx context.Context, req AskRequest) (int, error) {
+	return s.Provider.CountTokens(ctx, req)
 }
 
-func (s *String) AskClaude(ctx context.Context, question string) (string, error) {
-	// todo
-	response, err = s.ClaudeClient.Ask(ctx, question)
-	return "", nil
+func (s *String) Extract(ctx context.Context, req ExtractRequest) (ExtractResponse, error) {
+	extractor, ok := s.Provider.(Extractor)
+	if !ok {
+		return ExtractResponse{}, unavailable("extraction is not supported by the configured provider")
+	}
+	return extractor.Extract(ctx, req)
 }
@@ -0,0 +1,189 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode"
+
+	"github.com/rivo/uniseg"
+	"golang.org/x/text/cases"
+	"golang.org/x/text/language"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Counts are the sizes of a string in different units
+type Counts struct {
+	Bytes     int
+	Runes     int
+	Words     int
+	Graphemes int
+}
+
+func (String) Lowercase(s, lang string) (string, error) {
+	tag, err := parseLanguage(lang)
+	if err != nil {
+		return "", err
+	}
+	return cases.Lower(tag).String(s), nil
+}
+
+func (String) Title(s, lang string) (string, error) {
+	tag, err := parseLanguage(lang)
+	if err != nil {
+		return "", err
+	}
+	return cases.Title(tag).String(s), nil
+}
+
+// parseLanguage parses a BCP-47 tag for case mapping, e.g. "tr" maps i to
+// İ and "el" drops accents in upper case. An empty tag is language-neutral.
+func parseLanguage(lang string) (language.Tag, error) {
+	if lang == "" {
+		return language.Und, nil
+	}
+	tag, err := language.Parse(lang)
+	if err != nil {
+		return language.Und, ServiceError{
+			Code:    http.StatusBadRequest,
+			Message: fmt.Sprintf("invalid language tag %q", lang),
+		}
+	}
+	return tag, nil
+}
+
+func (String) Count(s string) (Counts, error) {
+	return Counts{
+		Bytes:     len(s),
+		Runes:     len([]rune(s)),
+		Words:     len(strings.Fields(s)),
+		Graphemes: uniseg.GraphemeClusterCount(s),
+	}, nil
+}
+
+// Reverse reverses s by grapheme clusters, so combining marks and emoji
+// sequences stay intact.
+func (String) Reverse(s string) (string, error) {
+	var clusters []string
+	g := uniseg.NewGraphemes(s)
+	for g.Next() {
+		clusters = append(clusters, g.Str())
+	}
+
+	var b strings.Builder
+	b.Grow(len(s))
+	for i := len(clusters) - 1; i >= 0; i-- {
+		b.WriteString(clusters[i])
+	}
+	return b.String(), nil
+}
+
+// Normalize converts s to the Unicode normalization form NFC, NFD, NFKC or NFKD.
+func (String) Normalize(s, form string) (string, error) {
+	switch strings.ToUpper(form) {
+	case "NFC", "":
+		return norm.NFC.String(s), nil
+	case "NFD":
+		return norm.NFD.String(s), nil
+	case "NFKC":
+		return norm.NFKC.String(s), nil
+	case "NFKD":
+		return norm.NFKD.String(s), nil
+	}
+	return "", ServiceError{
+		Code:    http.StatusBadRequest,
+		Message: fmt.Sprintf("unknown normalization form %q, want NFC, NFD, NFKC or NFKD", form),
+	}
+}
+
+// Trim removes leading and trailing whitespace, with collapse every inner
+// run of whitespace becomes a single space.
+func (String) Trim(s string, collapse bool) (string, error) {
+	if collapse {
+		return strings.Join(strings.Fields(s), " "), nil
+	}
+	return strings.TrimSpace(s), nil
+}
+
+// Slugify makes a lowercase ASCII slug of s for use in URLs.
+func (svc String) Slugify(s string) (string, error) {
+	ascii, err := svc.Transliterate(s)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(ascii) {
+		if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(r)
+			dash = false
+		} else {
+			dash = true
+		}
+	}
+	return b.String(), nil
+}
+
+// Transliterate replaces non-ASCII letters by their closest ASCII spelling.
+// Characters without one are dropped.
+func (String) Transliterate(s string) (string, error) {
+	var b strings.Builder
+	b.Grow(len(s))
+	// Letters are looked up composed, so that й and ї keep their spelling
+	for _, r := range norm.NFC.String(s) {
+		if ascii, ok := transliteration(r); ok {
+			b.WriteString(ascii)
+			continue
+		}
+		// Other letters lose their accents: decompose and drop the marks
+		for _, d := range norm.NFKD.String(string(r)) {
+			if ascii, ok := transliteration(d); ok {
+				b.WriteString(ascii)
+			} else if unicode.IsSpace(d) {
+				b.WriteByte(' ')
+			}
+		}
+	}
+	return b.String(), nil
+}
+
+func transliteration(r rune) (string, bool) {
+	if r < unicode.MaxASCII {
+		return string(r), true
+	}
+	ascii, ok := transliterations[r]
+	return ascii, ok
+}
+
+// transliterations covers letters that don't decompose into ASCII or
+// whose decomposition would be misspelled
+var transliterations = map[rune]string{
+	'ß': "ss", 'æ': "ae", 'Æ': "AE", 'ø': "o", 'Ø': "O", 'œ': "oe", 'Œ': "OE",
+	'ł': "l", 'Ł': "L", 'đ': "d", 'Đ': "D", 'þ': "th", 'Þ': "Th", 'ð': "d", 'Ð': "D",
+	'ı': "i", '‘': "'", '’': "'", '“': "\"", '”': "\"", '–': "-", '—': "-", '…': "...",
+
+	// Cyrillic
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d", 'е': "e", 'ё': "e", 'ж': "zh",
+	'з': "z", 'и': "i", 'й': "y", 'к': "k", 'л': "l", 'м': "m", 'н': "n", 'о': "o",
+	'п': "p", 'р': "r", 'с': "s", 'т': "t", 'у': "u", 'ф': "f", 'х': "kh", 'ц': "ts",
+	'ч': "ch", 'ш': "sh", 'щ': "shch", 'ъ': "", 'ы': "y", 'ь': "", 'э': "e", 'ю': "yu",
+	'я': "ya", 'і': "i", 'ї': "yi", 'є': "ye", 'ґ': "g",
+	'А': "A", 'Б': "B", 'В': "V", 'Г': "G", 'Д': "D", 'Е': "E", 'Ё': "E", 'Ж': "Zh",
+	'З': "Z", 'И': "I", 'Й': "Y", 'К': "K", 'Л': "L", 'М': "M", 'Н': "N", 'О': "O",
+	'П': "P", 'Р': "R", 'С': "S", 'Т': "T", 'У': "U", 'Ф': "F", 'Х': "Kh", 'Ц': "Ts",
+	'Ч': "Ch", 'Ш': "Sh", 'Щ': "Shch", 'Ъ': "", 'Ы': "Y", 'Ь': "", 'Э': "E", 'Ю': "Yu",
+	'Я': "Ya", 'І': "I", 'Ї': "Yi", 'Є': "Ye", 'Ґ': "G",
+
+	// Greek
+	'α': "a", 'β': "v", 'γ': "g", 'δ': "d", 'ε': "e", 'ζ': "z", 'η': "i", 'θ': "th",
+	'ι': "i", 'κ': "k", 'λ': "l", 'μ': "m", 'ν': "n", 'ξ': "x", 'ο': "o", 'π': "p",
+	'ρ': "r", 'σ': "s", 'ς': "s", 'τ': "t", 'υ': "y", 'φ': "f", 'χ': "ch", 'ψ': "ps",
+	'ω': "o",
+	'Α': "A", 'Β': "V", 'Γ': "G", 'Δ': "D", 'Ε': "E", 'Ζ': "Z", 'Η': "I", 'Θ': "Th",
+	'Ι': "I", 'Κ': "K", 'Λ': "L", 'Μ': "M", 'Ν': "N", 'Ξ': "X", 'Ο': "O", 'Π': "P",
+	'Ρ': "R", 'Σ': "S", 'Τ': "T", 'Υ': "Y", 'Φ': "F", 'Χ': "Ch", 'Ψ': "Ps", 'Ω': "O",
+}
@@ -0,0 +1,59 @@
+package service
+
+import "testing"
+
+func TestTransliterate(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"ascii", "Hello, world!", "Hello, world!"},
+		{"accents", "Crème brûlée à la façon", "Creme brulee a la facon"},
+		{"german", "Straße über Äpfel", "Strasse uber Apfel"},
+		{"german umlaut", "ä", "a"},
+		{"sharp s", "ß", "ss"},
+		{"russian", "Привет, мир", "Privet, mir"},
+		{"short i", "Андрій й Й", "Andriy y Y"},
+		{"ukrainian yi", "Її ї", "Yiyi yi"},
+		{"decomposed short i", "й", "y"},
+		{"hard and soft signs", "объезд мышь", "obezd mysh"},
+		{"greek with accents", "Αθήνα", "Athina"},
+		{"ligatures", "ﬁne Œuvre", "fine OEuvre"},
+		{"non-ascii spaces", "a b c", "a b c"},
+		{"unknown characters", "日本 ok 🙂", " ok "},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := String{}.Transliterate(tt.in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("Transliterate(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSlugify(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"Hello, World!", "hello-world"},
+		{"  leading and trailing  ", "leading-and-trailing"},
+		{"Ärger mit der Straße", "arger-mit-der-strasse"},
+		{"Йошкар-Ола", "yoshkar-ola"},
+		{"---", ""},
+	}
+	for _, tt := range tests {
+		got, err := String{}.Slugify(tt.in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("Slugify(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
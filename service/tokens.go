@@ -0,0 +1,139 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"kit-fiber-example/config"
+)
+
+// Rough token costs of things approxTokens can't see
+const (
+	messageOverheadTokens = 10
+	attachmentTokens      = 1600
+)
+
+// TokenEstimator predicts the input tokens of an ask before it's sent.
+type TokenEstimator interface {
+	EstimateTokens(ctx context.Context, ask AskRequest) (int, error)
+}
+
+// ApproxEstimator estimates tokens locally from text length.
+type ApproxEstimator struct{}
+
+func (ApproxEstimator) EstimateTokens(_ context.Context, ask AskRequest) (int, error) {
+	return approxTokens(ask.System, ask.Question) + len(ask.Attachments)*attachmentTokens + messageOverheadTokens, nil
+}
+
+// TokenCounter is anything with a token counting API, e.g. an LLMProvider.
+type TokenCounter interface {
+	CountTokens(ctx context.Context, ask AskRequest) (int, error)
+}
+
+// CountingEstimator asks a token counting API and falls back to the local
+// estimate when the call fails.
+type CountingEstimator struct {
+	Counter  TokenCounter
+	Fallback TokenEstimator
+}
+
+func (e CountingEstimator) EstimateTokens(ctx context.Context, ask AskRequest) (int, error) {
+	n, err := e.Counter.CountTokens(ctx, ask)
+	if err == nil {
+		return n, nil
+	}
+	if e.Fallback == nil {
+		return 0, err
+	}
+	return e.Fallback.EstimateTokens(ctx, ask)
+}
+
+// ContextGuard rejects or truncates asks that don't fit into the context
+// window of their model, leaving room for max_tokens of output.
+type ContextGuard struct {
+	estimator TokenEstimator
+	router    *Router
+	models    map[string]config.ModelLimits
+	system    string
+	maxTokens int
+	truncate  bool
+}
+
+// NewContextGuardFromConfig returns nil when the guard is disabled.
+func NewContextGuardFromConfig(cfg *config.Config, counter TokenCounter) (*ContextGuard, error) {
+	g := &ContextGuard{
+		router:    NewRouter(cfg),
+		models:    cfg.Claude.Models,
+		system:    cfg.Claude.Defaults.System,
+		maxTokens: cfg.Claude.Defaults.MaxTokens,
+	}
+
+	switch cfg.Claude.ContextGuard.Action {
+	case "":
+		return nil, nil
+	case "reject":
+	case "truncate":
+		g.truncate = true
+	default:
+		return nil, fmt.Errorf("claude.contextGuard.action: unknown action %q", cfg.Claude.ContextGuard.Action)
+	}
+
+	switch cfg.Claude.ContextGuard.Estimator {
+	case "", "approx":
+		g.estimator = ApproxEstimator{}
+	case "api":
+		g.estimator = CountingEstimator{Counter: counter, Fallback: ApproxEstimator{}}
+	default:
+		return nil, fmt.Errorf("claude.contextGuard.estimator: unknown estimator %q", cfg.Claude.ContextGuard.Estimator)
+	}
+	return g, nil
+}
+
+// Model returns the model ask would be sent to.
+func (g *ContextGuard) Model(ask AskRequest) string {
+	model, _ := g.router.Route(ask)
+	return model
+}
+
+// Fit returns ask, truncated if allowed, together with its estimated input
+// tokens. Asks for models without a known context window pass unchecked.
+func (g *ContextGuard) Fit(ctx context.Context, ask AskRequest) (AskRequest, int, error) {
+	if ask.System == "" {
+		ask.System = g.system
+	}
+	estimate, err := g.estimator.EstimateTokens(ctx, ask)
+	if err != nil {
+		return ask, 0, err
+	}
+
+	model := g.Model(ask)
+	window := g.models[model].ContextWindow
+	if window <= 0 {
+		return ask, estimate, nil
+	}
+	maxTokens := ask.MaxTokens
+	if maxTokens == 0 {
+		maxTokens = g.maxTokens
+	}
+
+	budget := window - maxTokens
+	if estimate <= budget {
+		return ask, estimate, nil
+	}
+	if !g.truncate {
+		return ask, estimate, tooLargef("request needs about %d input tokens, %s allows %d with max_tokens %d",
+			estimate, model, budget, maxTokens)
+	}
+
+	// Only the question is cut, the system prompt and attachments are kept
+	questionTokens := approxTokens(ask.Question)
+	keep := questionTokens - (estimate - budget)
+	if keep <= 0 {
+		return ask, estimate, tooLargef("request without the question already needs about %d input tokens, %s allows %d with max_tokens %d",
+			estimate-questionTokens, model, budget, maxTokens)
+	}
+	runes := []rune(ask.Question)
+	ask.Question = strings.TrimSpace(string(runes[:len(runes)*keep/questionTokens]))
+	return ask, estimate - questionTokens + approxTokens(ask.Question), nil
+}
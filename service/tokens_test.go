@@ -0,0 +1,184 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"kit-fiber-example/config"
+)
+
+func newTestGuard(t *testing.T, action string) *ContextGuard {
+	t.Helper()
+	cfg := &config.Config{}
+	cfg.Claude.Model = "small"
+	cfg.Claude.Defaults.MaxTokens = 500
+	cfg.Claude.Models = map[string]config.ModelLimits{
+		"small": {ContextWindow: 1000},
+		"large": {ContextWindow: 100000},
+	}
+	cfg.Claude.ContextGuard.Action = action
+	g, err := NewContextGuardFromConfig(cfg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return g
+}
+
+func TestApproxTokens(t *testing.T) {
+	tests := []struct {
+		text string
+		want int
+	}{
+		{"", 0},
+		{"a", 1},
+		{"abcd", 1},
+		{"abcde", 2},
+		// two bytes and one rune each: (8+3)/4 + 4/4
+		{"éééé", 3},
+		// three bytes and one rune each: (6+3)/4 + 4/4
+		{"世界", 3},
+		// four bytes and one rune: (4+3)/4 + 3/4
+		{"🙂", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			if got := approxTokens(tt.text); got != tt.want {
+				t.Errorf("approxTokens() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestContextGuardFit(t *testing.T) {
+	// The small model allows 1000-500 = 500 input tokens, a bare question
+	// costs (bytes+3)/4 plus 10 of overhead
+	tests := []struct {
+		name   string
+		action string
+		ask    AskRequest
+		// estimate of the ask as it's returned
+		estimate  int
+		truncated bool
+		status    int
+	}{
+		{"fits", "reject", AskRequest{Question: strings.Repeat("a", 400)}, 110, false, 0},
+		{"exactly the budget", "reject", AskRequest{Question: strings.Repeat("a", 1960)}, 500, false, 0},
+		{"one token over", "reject", AskRequest{Question: strings.Repeat("a", 1961)}, 501, false, http.StatusRequestEntityTooLarge},
+		{"unknown model passes", "reject", AskRequest{Question: strings.Repeat("a", 8000), Model: "other"}, 2010, false, 0},
+		{"larger model", "reject", AskRequest{Question: strings.Repeat("a", 8000), Model: "large"}, 2010, false, 0},
+		{"max_tokens leaves more room", "reject", AskRequest{Question: strings.Repeat("a", 2400), MaxTokens: 300}, 610, false, 0},
+		{"max_tokens leaves less room", "reject", AskRequest{Question: strings.Repeat("a", 1960), MaxTokens: 501}, 500, false, http.StatusRequestEntityTooLarge},
+		{"truncates one token over", "truncate", AskRequest{Question: strings.Repeat("a", 1961)}, 500, true, 0},
+		{"truncates far over", "truncate", AskRequest{Question: strings.Repeat("word ", 4000)}, 500, true, 0},
+		{"truncates two byte runes", "truncate", AskRequest{Question: strings.Repeat("é", 3000)}, 500, true, 0},
+		{"truncates three byte runes", "truncate", AskRequest{Question: strings.Repeat("世界", 1000)}, 500, true, 0},
+		{"truncates mixed text", "truncate", AskRequest{Question: strings.Repeat("a世🙂 ", 500)}, 500, true, 0},
+		{"keeps the system prompt", "truncate", AskRequest{System: strings.Repeat("s", 1600), Question: strings.Repeat("q", 1000)}, 500, true, 0},
+		{"rejects when only the question could be cut", "truncate", AskRequest{
+			Question:    "short",
+			Attachments: []Attachment{{Name: "a.png"}},
+		}, 1612, false, http.StatusRequestEntityTooLarge},
+		{"fitting ask isn't truncated", "truncate", AskRequest{Question: "hi"}, 11, false, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newTestGuard(t, tt.action)
+			got, estimate, err := g.Fit(context.Background(), tt.ask)
+
+			var se ServiceError
+			switch {
+			case tt.status == 0 && err != nil:
+				t.Fatalf("Fit() error = %v", err)
+			case tt.status != 0 && (!errors.As(err, &se) || se.Code != tt.status):
+				t.Fatalf("Fit() error = %v, want status %d", err, tt.status)
+			}
+			if tt.status != 0 {
+				return
+			}
+
+			if truncated := got.Question != tt.ask.Question; truncated != tt.truncated {
+				t.Errorf("truncated = %t, want %t", truncated, tt.truncated)
+			}
+			if !tt.truncated && estimate != tt.estimate {
+				t.Errorf("estimate = %d, want %d", estimate, tt.estimate)
+			}
+			if !tt.truncated {
+				return
+			}
+			// Truncating fills the budget as far as possible without going over
+			if estimate > tt.estimate || estimate < tt.estimate-3 {
+				t.Errorf("estimate = %d, want at most %d and close to it", estimate, tt.estimate)
+			}
+			if actual, _ := (ApproxEstimator{}).EstimateTokens(context.Background(), got); actual != estimate {
+				t.Errorf("returned estimate %d, but the truncated ask costs %d", estimate, actual)
+			}
+			if !utf8.ValidString(got.Question) || !strings.HasPrefix(tt.ask.Question, got.Question) {
+				t.Errorf("question %q isn't a prefix of the original", got.Question)
+			}
+			if got.System != tt.ask.System {
+				t.Error("system prompt changed")
+			}
+		})
+	}
+}
+
+func TestNewContextGuardFromConfig(t *testing.T) {
+	tests := []struct {
+		action, estimator string
+		enabled, ok       bool
+	}{
+		{"", "", false, true},
+		{"reject", "", true, true},
+		{"truncate", "api", true, true},
+		{"shout", "", false, false},
+		{"reject", "guess", false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.action+"/"+tt.estimator, func(t *testing.T) {
+			cfg := &config.Config{}
+			cfg.Claude.ContextGuard.Action = tt.action
+			cfg.Claude.ContextGuard.Estimator = tt.estimator
+			g, err := NewContextGuardFromConfig(cfg, nil)
+			if (err == nil) != tt.ok || (g != nil) != tt.enabled {
+				t.Errorf("NewContextGuardFromConfig() = %v, %v, want enabled %t, ok %t", g, err, tt.enabled, tt.ok)
+			}
+		})
+	}
+}
+
+// failingCounter fails every count
+type failingCounter struct{}
+
+func (failingCounter) CountTokens(context.Context, AskRequest) (int, error) {
+	return 0, errors.New("counting failed")
+}
+
+type fixedCounter int
+
+func (c fixedCounter) CountTokens(context.Context, AskRequest) (int, error) { return int(c), nil }
+
+func TestCountingEstimator(t *testing.T) {
+	ask := AskRequest{Question: strings.Repeat("a", 40)}
+	tests := []struct {
+		name      string
+		estimator CountingEstimator
+		want      int
+		ok        bool
+	}{
+		{"counted", CountingEstimator{Counter: fixedCounter(7), Fallback: ApproxEstimator{}}, 7, true},
+		{"falls back", CountingEstimator{Counter: failingCounter{}, Fallback: ApproxEstimator{}}, 20, true},
+		{"no fallback", CountingEstimator{Counter: failingCounter{}}, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.estimator.EstimateTokens(context.Background(), ask)
+			if got != tt.want || (err == nil) != tt.ok {
+				t.Errorf("EstimateTokens() = %d, %v, want %d, ok %t", got, err, tt.want, tt.ok)
+			}
+		})
+	}
+}
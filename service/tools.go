@@ -0,0 +1,206 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+)
+
+// ToolFunc runs a tool with the input Claude produced and returns the
+// result as text.
+type ToolFunc func(ctx context.Context, input json.RawMessage) (string, error)
+
+// Tool is a Go function that Claude may call.
+type Tool struct {
+	Name        string
+	Description string
+	// InputSchema is the JSON Schema of the input object
+	InputSchema json.RawMessage
+	Func        ToolFunc
+	// Timeout overrides the client-wide tool timeout
+	Timeout time.Duration
+}
+
+// ToolDefinition is how a tool is described to the Messages API.
+type ToolDefinition struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	InputSchema json.RawMessage `json:"input_schema"`
+}
+
+// ToolChoice forces or forbids tool use.
+type ToolChoice struct {
+	Type string `json:"type"` // auto | any | tool
+	Name string `json:"name,omitempty"`
+}
+
+func (t Tool) definition() ToolDefinition {
+	return ToolDefinition{
+		Name:        t.Name,
+		Description: t.Description,
+		InputSchema: t.InputSchema,
+	}
+}
+
+// RegisterTool makes a tool available to every ask.
+func (c *ClaudeClient) RegisterTool(t Tool) error {
+	if t.Name == "" || t.Func == nil {
+		return fmt.Errorf("tool must have a name and a func")
+	}
+	if !json.Valid(t.InputSchema) {
+		return fmt.Errorf("tool %s: input schema is not valid JSON", t.Name)
+	}
+	if _, ok := c.tools[t.Name]; ok {
+		return fmt.Errorf("tool %s is already registered", t.Name)
+	}
+	c.tools[t.Name] = t
+	return nil
+}
+
+// runTool executes one tool_use block and returns the matching tool_result.
+// Tool failures are reported back to Claude rather than failing the ask.
+func (c *ClaudeClient) runTool(ctx context.Context, use Content) Content {
+	ctx, span := c.tracer.Start(ctx, "tool "+use.Name)
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("tool.name", use.Name),
+		attribute.String("tool.use_id", use.ID),
+	)
+
+	result := Content{Type: "tool_result", ToolUseID: use.ID}
+
+	tool, ok := c.tools[use.Name]
+	if !ok {
+		result.Content = fmt.Sprintf("unknown tool %q", use.Name)
+		result.IsError = true
+		span.SetStatus(codes.Error, result.Content)
+		return result
+	}
+
+	timeout := tool.Timeout
+	if timeout == 0 {
+		timeout = c.toolTimeout
+	}
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	out, err := tool.Func(ctx, use.Input)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		result.Content = err.Error()
+		result.IsError = true
+		return result
+	}
+	result.Content = out
+	return result
+}
+
+// NewUppercaseTool exposes Uppercase of a string service as a tool.
+func NewUppercaseTool(svc interface {
+	Uppercase(s, lang string) (string, error)
+}) Tool {
+	return Tool{
+		Name:        "uppercase",
+		Description: "Converts a string to upper case.",
+		InputSchema: json.RawMessage(`{
+			"type": "object",
+			"properties": {
+				"string": {"type": "string", "description": "The string to convert"},
+				"lang": {"type": "string", "description": "BCP-47 language tag for language specific rules, e.g. tr"}
+			},
+			"required": ["string"]
+		}`),
+		Func: func(_ context.Context, input json.RawMessage) (string, error) {
+			var in struct {
+				S    string `json:"string"`
+				Lang string `json:"lang"`
+			}
+			if err := json.Unmarshal(input, &in); err != nil {
+				return "", err
+			}
+			return svc.Uppercase(in.S, in.Lang)
+		},
+	}
+}
+
+// NewCountTool exposes Count of a string service as a tool, models are
+// unreliable at counting characters and words themselves.
+func NewCountTool(svc interface {
+	Count(s string) (Counts, error)
+}) Tool {
+	return Tool{
+		Name:        "count",
+		Description: "Counts the bytes, Unicode code points, words and user-perceived characters of a string.",
+		InputSchema: json.RawMessage(`{
+			"type": "object",
+			"properties": {
+				"string": {"type": "string", "description": "The string to count"}
+			},
+			"required": ["string"]
+		}`),
+		Func: func(_ context.Context, input json.RawMessage) (string, error) {
+			var in struct {
+				S string `json:"string"`
+			}
+			if err := json.Unmarshal(input, &in); err != nil {
+				return "", err
+			}
+			counts, err := svc.Count(in.S)
+			if err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("bytes: %d, code points: %d, words: %d, characters: %d",
+				counts.Bytes, counts.Runes, counts.Words, counts.Graphemes), nil
+		},
+	}
+}
+
+// NewDiffTool exposes Diff of a string service as a tool. Line diffs come
+// back as unified diff text, word and character diffs as JSON edits.
+func NewDiffTool(svc interface {
+	Diff(a, b, mode string) (DiffResult, error)
+}) Tool {
+	return Tool{
+		Name:        "diff",
+		Description: "Compares two texts and returns the edits that turn the first into the second.",
+		InputSchema: json.RawMessage(`{
+			"type": "object",
+			"properties": {
+				"a": {"type": "string", "description": "The original text"},
+				"b": {"type": "string", "description": "The changed text"},
+				"mode": {"type": "string", "enum": ["unified", "word", "char"], "description": "Compare lines, words or characters, unified by default"}
+			},
+			"required": ["a", "b"]
+		}`),
+		Func: func(_ context.Context, input json.RawMessage) (string, error) {
+			var in struct {
+				A    string `json:"a"`
+				B    string `json:"b"`
+				Mode string `json:"mode"`
+			}
+			if err := json.Unmarshal(input, &in); err != nil {
+				return "", err
+			}
+			if in.Mode == "" {
+				in.Mode = DiffUnified
+			}
+			result, err := svc.Diff(in.A, in.B, in.Mode)
+			if err != nil {
+				return "", err
+			}
+			if in.Mode == DiffUnified {
+				return result.Unified, nil
+			}
+			out, err := json.Marshal(result.Ops)
+			return string(out), err
+		},
+	}
+}
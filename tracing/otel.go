@@ -14,20 +14,9 @@ import (
 	"kit-fiber-example/config"
 )
 
+// InitOtel builds the tracer provider. It has no exporter yet, nothing is
+// sent before StartExporter.
 func InitOtel(cfg *config.Config) (*sdktrace.TracerProvider, error) {
-	ctx := context.Background()
-
-	conn, err := grpc.NewClient(cfg.Telemetry.CollectorAddr, grpc.WithTransportCredentials(insecure.NewCredentials()))
-	if err != nil {
-		return nil, err
-	}
-
-	//exporter, err := otlptracehttp.New(ctx, otlptracehttp.WithEndpointURL("http://jaeger:4318"))
-	exporter, err := otlptracegrpc.New(ctx, otlptracegrpc.WithGRPCConn(conn))
-	if err != nil {
-		return nil, err
-	}
-
 	r, err := resource.Merge(
 		resource.Default(),
 		resource.NewWithAttributes(
@@ -41,12 +30,28 @@ func InitOtel(cfg *config.Config) (*sdktrace.TracerProvider, error) {
 		return nil, err
 	}
 
-	tp := sdktrace.NewTracerProvider(
+	return sdktrace.NewTracerProvider(
 		sdktrace.WithSampler(sdktrace.TraceIDRatioBased(cfg.Telemetry.SamplingRatio)),
-		sdktrace.WithBatcher(exporter),
 		sdktrace.WithResource(r),
-	)
+	), nil
+}
+
+// StartExporter connects tp to the collector and makes it the global
+// tracer provider
+func StartExporter(ctx context.Context, cfg *config.Config, tp *sdktrace.TracerProvider) error {
+	conn, err := grpc.NewClient(cfg.Telemetry.CollectorAddr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return err
+	}
+
+	//exporter, err := otlptracehttp.New(ctx, otlptracehttp.WithEndpointURL("http://jaeger:4318"))
+	exporter, err := otlptracegrpc.New(ctx, otlptracegrpc.WithGRPCConn(conn))
+	if err != nil {
+		conn.Close()
+		return err
+	}
 
+	tp.RegisterSpanProcessor(sdktrace.NewBatchSpanProcessor(exporter))
 	otel.SetTracerProvider(tp)
-	return tp, nil
+	return nil
 }
@@ -0,0 +1,198 @@
+package transport
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+
+	"github.com/gofiber/fiber/v2"
+
+	"kit-fiber-example/config"
+	"kit-fiber-example/problem"
+	"kit-fiber-example/validation"
+)
+
+const (
+	defaultBatchMaxItems    = 1000
+	defaultBatchParallelism = 8
+
+	mimeNDJSON = "application/x-ndjson"
+)
+
+type batchOptions struct {
+	MaxItems    int
+	Parallelism int
+}
+
+func newBatchOptions(cfg *config.Config) batchOptions {
+	o := batchOptions{
+		MaxItems:    cfg.Batch.MaxItems,
+		Parallelism: cfg.Batch.Parallelism,
+	}
+	if o.MaxItems <= 0 {
+		o.MaxItems = defaultBatchMaxItems
+	}
+	if o.Parallelism <= 0 {
+		o.Parallelism = defaultBatchParallelism
+	}
+	return o
+}
+
+type BatchRequest struct {
+	Items []BatchItem `json:"items" validate:"required"`
+}
+
+// BatchItem is one operation, Input is the body the operation's own
+// endpoint would take.
+type BatchItem struct {
+	ID    string          `json:"id,omitempty" validate:"max=256"`
+	Op    string          `json:"op" validate:"required,enum=uppercase|ask"`
+	Input json.RawMessage `json:"input" validate:"required"`
+}
+
+type BatchItemResult struct {
+	ID     string           `json:"id"`
+	Index  int              `json:"index"`
+	Status int              `json:"status"`
+	Result any              `json:"result,omitempty"`
+	Error  *problem.Problem `json:"error,omitempty"`
+}
+
+type BatchResponse struct {
+	Results   []BatchItemResult `json:"results"`
+	Succeeded int               `json:"succeeded"`
+	Failed    int               `json:"failed"`
+}
+
+// runBatchItem runs one item through the endpoint of its operation. Errors
+// end up in the result as problems, they never fail the batch.
+func (t *fiberTransport) runBatchItem(ctx context.Context, info requestInfo, index int, item BatchItem, tenant string) BatchItemResult {
+	result := BatchItemResult{ID: item.ID, Index: index, Status: fiber.StatusOK}
+	response, err := t.batchOp(ctx, item, tenant)
+	if err != nil {
+		result.Error = info.problem(abortCause(ctx, err))
+		result.Status = result.Error.Status
+		return result
+	}
+	result.Result = response
+	return result
+}
+
+func (t *fiberTransport) batchOp(ctx context.Context, item BatchItem, tenant string) (any, error) {
+	if err := validation.Struct(item); err != nil {
+		return nil, err
+	}
+
+	switch item.Op {
+	case "uppercase":
+		var req UppercaseRequest
+		if err := validation.DecodeJSON(item.Input, &req); err != nil {
+			return nil, err
+		}
+		return t.Uppercase(ctx, req)
+	case "ask":
+		var req AskClaudeRequest
+		if err := validation.DecodeJSON(item.Input, &req); err != nil {
+			return nil, err
+		}
+		req.Tenant = tenant
+		return t.AskClaude(ctx, req)
+	}
+	return nil, problem.Errorf(problem.CodeBadRequest, "unknown op %q", item.Op)
+}
+
+// runBatch runs the items with bounded parallelism and calls emit with every
+// result as soon as it's ready. emit is never called concurrently.
+func (t *fiberTransport) runBatch(ctx context.Context, info requestInfo, items []BatchItem, tenant string, emit func(BatchItemResult)) {
+	var (
+		wg  sync.WaitGroup
+		mu  sync.Mutex
+		sem = make(chan struct{}, t.Batch.Parallelism)
+	)
+	for i, item := range items {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			// Items that never started still get a result
+			p := info.problem(abortCause(ctx, ctx.Err()))
+			mu.Lock()
+			emit(BatchItemResult{ID: item.ID, Index: i, Status: p.Status, Error: p})
+			mu.Unlock()
+			continue
+		}
+		wg.Add(1)
+		go func(i int, item BatchItem) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			result := t.runBatchItem(ctx, info, i, item, tenant)
+			mu.Lock()
+			emit(result)
+			mu.Unlock()
+		}(i, item)
+	}
+	wg.Wait()
+}
+
+// HandleBatch runs many uppercase and ask operations in one call. Results
+// come back in request order, or as NDJSON lines in completion order when
+// the client accepts application/x-ndjson or passes ?stream=true.
+func (t *fiberTransport) HandleBatch(c *fiber.Ctx) error {
+	var req BatchRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+	if len(req.Items) == 0 {
+		return validation.Errors{{Field: "items", Code: validation.CodeRequired, Message: "is required"}}
+	}
+	if len(req.Items) > t.Batch.MaxItems {
+		return problem.Errorf(problem.CodeTooLarge, "at most %d items are allowed", t.Batch.MaxItems)
+	}
+	tenant := c.Get(HeaderTenant)
+	info := newRequestInfo(c)
+
+	if c.QueryBool("stream") || strings.Contains(c.Get(fiber.HeaderAccept), mimeNDJSON) {
+		ctx, done, err := t.trackStream(c)
+		if err != nil {
+			return err
+		}
+		c.Set(fiber.HeaderContentType, mimeNDJSON)
+
+		c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+			defer done()
+			ctx, cancel := context.WithCancel(ctx)
+			defer cancel()
+
+			// Aborted items are still reported, only a gone client stops the writes
+			gone := false
+			enc := json.NewEncoder(w)
+			t.runBatch(ctx, info, req.Items, tenant, func(result BatchItemResult) {
+				if gone {
+					return
+				}
+				if enc.Encode(result) != nil || w.Flush() != nil {
+					// The client went away
+					gone = true
+					cancel()
+				}
+			})
+		})
+		return nil
+	}
+
+	if _, err := negotiate(c); err != nil {
+		return err
+	}
+	response := BatchResponse{Results: make([]BatchItemResult, len(req.Items))}
+	t.runBatch(c.UserContext(), info, req.Items, tenant, func(result BatchItemResult) {
+		response.Results[result.Index] = result
+		if result.Error != nil {
+			response.Failed++
+		} else {
+			response.Succeeded++
+		}
+	})
+	return respond(c, response)
+}
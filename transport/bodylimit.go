@@ -0,0 +1,47 @@
+package transport
+
+import (
+	"io"
+
+	"github.com/gofiber/fiber/v2"
+
+	"kit-fiber-example/problem"
+)
+
+// defaultUploadBodyLimit leaves room for a few attachments on /ask/upload
+const defaultUploadBodyLimit = 64 << 20
+
+// limitBody reads the body of a streamed request up to the limit of its
+// route, routes missing from limits get defaultLimit. The server streams
+// every body larger than the default limit, so that only the routes
+// allowing more ever buffer more.
+func limitBody(defaultLimit int, limits map[string]int) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		req := c.Request()
+		if !req.IsBodyStream() {
+			return c.Next()
+		}
+		limit, ok := limits[c.Path()]
+		if !ok {
+			limit = defaultLimit
+		}
+
+		// The rest of the body stays unread, the connection can't be reused
+		if req.Header.ContentLength() > limit {
+			c.Context().SetConnectionClose()
+			return problem.Errorf(problem.CodeTooLarge, "the body must be at most %d bytes", limit)
+		}
+		// Chunked bodies have no length, they are read one byte past the limit
+		body, err := io.ReadAll(io.LimitReader(c.Context().RequestBodyStream(), int64(limit)+1))
+		if err != nil {
+			c.Context().SetConnectionClose()
+			return problem.Errorf(problem.CodeBadRequest, "reading the body: %w", err)
+		}
+		if len(body) > limit {
+			c.Context().SetConnectionClose()
+			return problem.Errorf(problem.CodeTooLarge, "the body must be at most %d bytes", limit)
+		}
+		req.SetBody(body)
+		return c.Next()
+	}
+}
@@ -0,0 +1,77 @@
+package transport
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestLimitBody(t *testing.T) {
+	app := fiber.New(fiber.Config{
+		ErrorHandler:                 errorHandler,
+		BodyLimit:                    1024,
+		StreamRequestBody:            true,
+		DisablePreParseMultipartForm: true,
+	})
+	app.Use(limitBody(1024, map[string]int{"/upload": 8192}))
+	app.Post("/text", func(c *fiber.Ctx) error { return c.Send(c.Body()) })
+	app.Post("/upload", func(c *fiber.Ctx) error {
+		form, err := c.MultipartForm()
+		if err != nil {
+			return err
+		}
+		return c.SendString(formValue(form.Value, "question"))
+	})
+
+	upload := func(size int) (string, []byte) {
+		var buf bytes.Buffer
+		w := multipart.NewWriter(&buf)
+		w.WriteField("question", "what is it?")
+		fw, _ := w.CreateFormFile("files", "a.txt")
+		fw.Write(bytes.Repeat([]byte("a"), size))
+		w.Close()
+		return w.FormDataContentType(), buf.Bytes()
+	}
+	smallType, smallUpload := upload(4000)
+	largeType, largeUpload := upload(9000)
+
+	tests := []struct {
+		name        string
+		path        string
+		contentType string
+		body        []byte
+		chunked     bool
+		status      int
+	}{
+		{"small body", "/text", "text/plain", bytes.Repeat([]byte("a"), 1000), false, 200},
+		{"body over the default limit", "/text", "text/plain", bytes.Repeat([]byte("a"), 2000), false, 413},
+		{"chunked body over the default limit", "/text", "text/plain", bytes.Repeat([]byte("a"), 2000), true, 413},
+		{"upload under the route limit", "/upload", smallType, smallUpload, false, 200},
+		{"upload over the route limit", "/upload", largeType, largeUpload, false, 413},
+		{"upload on another route", "/text", smallType, smallUpload, false, 413},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", tt.path, bytes.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			if tt.chunked {
+				req.ContentLength = -1
+				req.TransferEncoding = []string{"chunked"}
+			}
+			resp, err := app.Test(req, -1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tt.status {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.status)
+			}
+			if tt.status == 413 && !strings.Contains(resp.Header.Get("Content-Type"), "problem+json") {
+				t.Errorf("content type = %q, want a problem", resp.Header.Get("Content-Type"))
+			}
+		})
+	}
+}
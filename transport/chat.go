@@ -0,0 +1,481 @@
+package transport
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gofiber/contrib/websocket"
+	"github.com/gofiber/fiber/v2"
+
+	"kit-fiber-example/concurrency"
+	"kit-fiber-example/config"
+	"kit-fiber-example/drain"
+	"kit-fiber-example/metrics"
+	"kit-fiber-example/problem"
+	"kit-fiber-example/validation"
+)
+
+const (
+	defaultChatMaxMessageBytes = 1 << 20
+	defaultChatMaxInflight     = 4
+	defaultChatSendBuffer      = 64
+	defaultChatWriteTimeout    = 10 * time.Second
+	defaultChatPingInterval    = 30 * time.Second
+	defaultChatIdleTimeout     = 5 * time.Minute
+)
+
+type chatOptions struct {
+	Tokens          []string
+	Origins         []string
+	MaxMessageBytes int
+	MaxInflight     int
+	SendBuffer      int
+	WriteTimeout    time.Duration
+	PingInterval    time.Duration
+	IdleTimeout     time.Duration
+	RateRequests    int
+	RateInterval    time.Duration
+}
+
+func newChatOptions(cfg *config.Config) (chatOptions, error) {
+	ws := cfg.WebSocket
+	o := chatOptions{
+		Tokens:          ws.Tokens,
+		Origins:         ws.Origins,
+		MaxMessageBytes: ws.MaxMessageBytes,
+		MaxInflight:     ws.MaxInflight,
+		SendBuffer:      ws.SendBuffer,
+		RateRequests:    ws.RateLimit.Requests,
+	}
+	durations := []struct {
+		name  string
+		value string
+		dst   *time.Duration
+		def   time.Duration
+	}{
+		{"writeTimeout", ws.WriteTimeout, &o.WriteTimeout, defaultChatWriteTimeout},
+		{"pingInterval", ws.PingInterval, &o.PingInterval, defaultChatPingInterval},
+		{"idleTimeout", ws.IdleTimeout, &o.IdleTimeout, defaultChatIdleTimeout},
+		{"rateLimit.duration", ws.RateLimit.Duration, &o.RateInterval, 0},
+	}
+	for _, d := range durations {
+		*d.dst = d.def
+		if d.value == "" {
+			continue
+		}
+		v, err := time.ParseDuration(d.value)
+		if err != nil {
+			return chatOptions{}, fmt.Errorf("websocket.%s: %w", d.name, err)
+		}
+		if v > 0 {
+			*d.dst = v
+		}
+	}
+
+	if o.MaxMessageBytes <= 0 {
+		o.MaxMessageBytes = defaultChatMaxMessageBytes
+	}
+	if o.MaxInflight <= 0 {
+		o.MaxInflight = defaultChatMaxInflight
+	}
+	if o.SendBuffer <= 0 {
+		o.SendBuffer = defaultChatSendBuffer
+	}
+	return o, nil
+}
+
+// Chat message types
+const (
+	ChatAsk    = "ask"
+	ChatCancel = "cancel"
+	ChatPing   = "ping"
+
+	ChatDelta = "delta"
+	ChatDone  = "done"
+	ChatError = "error"
+	ChatPong  = "pong"
+)
+
+// ChatClientMessage is sent by chat clients as a text frame. Everything sent
+// back about an ask carries its id, and cancel stops the ask with that id.
+type ChatClientMessage struct {
+	Type string            `json:"type" validate:"required,enum=ask|cancel|ping"`
+	ID   string            `json:"id,omitempty" validate:"max=256"`
+	Ask  *AskClaudeRequest `json:"ask,omitempty"`
+}
+
+// Validate requires an id for asks and cancels, and a question for asks
+func (m ChatClientMessage) Validate() error {
+	var errs validation.Errors
+	if m.ID == "" && m.Type != ChatPing {
+		errs = append(errs, validation.FieldError{Field: "id", Code: validation.CodeRequired, Message: "is required"})
+	}
+	if m.Ask == nil && m.Type == ChatAsk {
+		errs = append(errs, validation.FieldError{Field: "ask", Code: validation.CodeRequired, Message: "is required"})
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// ChatServerMessage is sent to chat clients. An ask gets any number of
+// delta messages followed by exactly one done or error message.
+type ChatServerMessage struct {
+	Type     string             `json:"type" doc:"delta, done, error or pong"`
+	ID       string             `json:"id,omitempty"`
+	Text     string             `json:"text,omitempty" doc:"The next piece of the answer, in delta messages"`
+	Response *AskClaudeResponse `json:"response,omitempty" doc:"The full answer, in done messages"`
+	Problem  *problem.Problem   `json:"problem,omitempty" doc:"Why the ask or message failed, in error messages"`
+}
+
+// chatLocals is what the upgrade handler passes on to the connection
+type chatLocals struct {
+	ctx    context.Context
+	info   requestInfo
+	tenant string
+}
+
+const chatLocalsKey = "chat"
+
+// HandleChatUpgrade authenticates chat clients before the connection is
+// upgraded, so that rejected clients get a problem document.
+func (t *fiberTransport) HandleChatUpgrade(c *fiber.Ctx) error {
+	if !websocket.IsWebSocketUpgrade(c) {
+		return problem.New(problem.CodeUpgradeRequired, "chat is only available over WebSocket")
+	}
+	if len(t.Chat.Tokens) == 0 {
+		return problem.New(problem.CodeNotImplemented, "chat is disabled, no access tokens are configured")
+	}
+	if !t.Chat.allowedOrigin(c) {
+		return problem.Errorf(problem.CodeForbidden, "origin %s may not connect", c.Get(fiber.HeaderOrigin))
+	}
+	if !t.Chat.authorized(c) {
+		c.Set(fiber.HeaderWWWAuthenticate, "Bearer")
+		return problem.New(problem.CodeUnauthorized, "a valid access token is required")
+	}
+
+	c.Locals(chatLocalsKey, chatLocals{
+		// The connection outlives the handler, and with it the request context
+		ctx:    context.WithoutCancel(c.UserContext()),
+		info:   newRequestInfo(c),
+		tenant: c.Get(HeaderTenant),
+	})
+	return c.Next()
+}
+
+// allowedOrigin lets clients without an Origin header in, browsers always
+// send one. Without configured origins only pages of this host may connect,
+// "*" allows every origin.
+func (o chatOptions) allowedOrigin(c *fiber.Ctx) bool {
+	origin := c.Get(fiber.HeaderOrigin)
+	if origin == "" {
+		return true
+	}
+	if len(o.Origins) == 0 {
+		u, err := url.Parse(origin)
+		return err == nil && strings.EqualFold(u.Host, string(c.Request().Host()))
+	}
+	return slices.Contains(o.Origins, "*") || slices.Contains(o.Origins, origin)
+}
+
+// authorized checks the bearer token, or the access_token query parameter
+// for browsers, which can't set headers on WebSocket requests. Without
+// tokens nobody is.
+func (o chatOptions) authorized(c *fiber.Ctx) bool {
+	token, ok := strings.CutPrefix(c.Get(fiber.HeaderAuthorization), "Bearer ")
+	if !ok {
+		token = c.Query("access_token")
+	}
+	if token == "" {
+		return false
+	}
+	for _, t := range o.Tokens {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(t)) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// HandleChat runs asks sent over a chat connection through the streaming ask
+// endpoint, a connection may run several at once.
+func (t *fiberTransport) HandleChat(conn *websocket.Conn) {
+	locals, _ := conn.Locals(chatLocalsKey).(chatLocals)
+	ctx, cancel := context.WithCancel(locals.ctx)
+
+	c := &chatConn{
+		conn:     conn,
+		opts:     t.Chat,
+		info:     locals.info,
+		tenant:   locals.tenant,
+		ask:      t.AskClaudeStream,
+		messages: t.Metrics.ChatMessages,
+		ctx:      ctx,
+		cancel:   cancel,
+		out:      make(chan ChatServerMessage, t.Chat.SendBuffer),
+		limiter:  concurrency.NewTokenBucket(t.Chat.RateRequests, t.Chat.RateInterval),
+		tracker:  t.Tracker,
+		finished: make(chan struct{}, 1),
+		asks:     make(map[string]context.CancelFunc),
+	}
+	c.touch()
+
+	connections := t.Metrics.ChatConnections
+	connections.Add(1)
+	defer connections.Add(-1)
+
+	c.run()
+}
+
+type chatConn struct {
+	conn     *websocket.Conn
+	opts     chatOptions
+	info     requestInfo
+	tenant   string
+	ask      func(context.Context, AskClaudeStreamRequest) (AskClaudeResponse, error)
+	messages metrics.Counter
+
+	// ctx is canceled when the connection is done, stopping every ask
+	ctx     context.Context
+	cancel  context.CancelFunc
+	out     chan ChatServerMessage
+	limiter *concurrency.TokenBucket
+	tracker *drain.Tracker
+	// finished is signaled when an ask ended, to close the connection once
+	// the last one ended while draining
+	finished chan struct{}
+
+	mu   sync.Mutex
+	asks map[string]context.CancelFunc
+	wg   sync.WaitGroup
+
+	// lastActive is the unix nano time of the last client message
+	lastActive atomic.Int64
+}
+
+func (c *chatConn) run() {
+	c.conn.SetReadLimit(int64(c.opts.MaxMessageBytes))
+	// Clients must answer pings, or at least send something, to stay connected
+	pongWait := 2 * c.opts.PingInterval
+	c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+
+	writerDone := make(chan struct{})
+	go func() {
+		defer close(writerDone)
+		c.writeLoop()
+	}()
+
+	for {
+		kind, data, err := c.conn.ReadMessage()
+		if err != nil {
+			break
+		}
+		c.conn.SetReadDeadline(time.Now().Add(pongWait))
+		c.touch()
+		if kind != websocket.TextMessage {
+			c.fail("", problem.New(problem.CodeUnsupportedMediaType, "messages must be JSON text frames"))
+			continue
+		}
+		c.handle(data)
+	}
+
+	c.cancel()
+	c.wg.Wait()
+	<-writerDone
+}
+
+func (c *chatConn) handle(data []byte) {
+	var msg ChatClientMessage
+	if err := validation.DecodeJSON(data, &msg); err != nil {
+		c.messages.With("direction", "in", "type", "invalid").Add(1)
+		c.fail("", err)
+		return
+	}
+	if err := validation.Struct(msg); err != nil {
+		c.messages.With("direction", "in", "type", "invalid").Add(1)
+		c.fail(msg.ID, err)
+		return
+	}
+	c.messages.With("direction", "in", "type", msg.Type).Add(1)
+
+	switch msg.Type {
+	case ChatPing:
+		c.send(ChatServerMessage{Type: ChatPong, ID: msg.ID})
+	case ChatCancel:
+		c.mu.Lock()
+		cancel, ok := c.asks[msg.ID]
+		c.mu.Unlock()
+		if !ok {
+			c.fail(msg.ID, problem.Errorf(problem.CodeNotFound, "no ask with id %q is running", msg.ID))
+			return
+		}
+		// The ask itself reports that it was canceled
+		cancel()
+	case ChatAsk:
+		c.start(msg.ID, *msg.Ask)
+	}
+}
+
+// start runs an ask in the background
+func (c *chatConn) start(id string, ask AskClaudeRequest) {
+	ctx, done, err := c.tracker.Track(c.ctx, drain.KindChat)
+	if err != nil {
+		c.fail(id, err)
+		return
+	}
+	ctx, cancel := context.WithCancel(ctx)
+
+	c.mu.Lock()
+	switch {
+	case c.asks[id] != nil:
+		err = problem.Errorf(problem.CodeConflict, "an ask with id %q is already running", id)
+	case len(c.asks) >= c.opts.MaxInflight:
+		err = problem.Errorf(problem.CodeRateLimited, "at most %d asks may run at once", c.opts.MaxInflight)
+	case !c.limiter.Allow():
+		err = problem.New(problem.CodeRateLimited, "too many asks, slow down")
+	default:
+		c.asks[id] = cancel
+		c.wg.Add(1)
+	}
+	c.mu.Unlock()
+	// Sending may block, so it must not hold the lock
+	if err != nil {
+		cancel()
+		done()
+		c.fail(id, err)
+		return
+	}
+	go func() {
+		defer c.wg.Done()
+		defer func() {
+			c.mu.Lock()
+			delete(c.asks, id)
+			c.mu.Unlock()
+			cancel()
+			done()
+			select {
+			case c.finished <- struct{}{}:
+			default:
+			}
+		}()
+
+		req := AskClaudeStreamRequest{AskClaudeRequest: ask}
+		req.Tenant = c.tenant
+		req.OnDelta = func(text string) error {
+			return c.send(ChatServerMessage{Type: ChatDelta, ID: id, Text: text})
+		}
+
+		response, err := c.ask(ctx, req)
+		if err != nil {
+			c.fail(id, abortCause(ctx, err))
+			return
+		}
+		c.send(ChatServerMessage{Type: ChatDone, ID: id, Response: &response})
+	}()
+}
+
+// fail sends the problem of err, id is empty for messages that couldn't be read
+func (c *chatConn) fail(id string, err error) {
+	c.send(ChatServerMessage{Type: ChatError, ID: id, Problem: c.info.problem(err)})
+}
+
+// send queues msg for the writer. It blocks while the queue is full, which
+// holds back the asks of clients that read slower than answers arrive.
+func (c *chatConn) send(msg ChatServerMessage) error {
+	select {
+	case c.out <- msg:
+		return nil
+	case <-c.ctx.Done():
+		return c.ctx.Err()
+	}
+}
+
+func (c *chatConn) touch() {
+	c.lastActive.Store(time.Now().UnixNano())
+}
+
+func (c *chatConn) running() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.asks)
+}
+
+func (c *chatConn) idle() bool {
+	return c.running() == 0 && time.Since(time.Unix(0, c.lastActive.Load())) > c.opts.IdleTimeout
+}
+
+// writeLoop is the only writer of the connection. A client that doesn't
+// take a message within the write timeout is disconnected. While the
+// server drains, the connection is closed once its last ask ended.
+func (c *chatConn) writeLoop() {
+	ticker := time.NewTicker(c.opts.PingInterval)
+	defer ticker.Stop()
+	// Closing unblocks the reader once writing failed
+	defer c.conn.Close()
+	defer c.cancel()
+
+	draining := c.tracker.Draining()
+	shuttingDown := false
+	for {
+		select {
+		case msg := <-c.out:
+			if c.write(msg) != nil {
+				return
+			}
+			continue
+		case <-ticker.C:
+			deadline := time.Now().Add(c.opts.WriteTimeout)
+			if c.idle() {
+				c.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, "idle timeout"), deadline)
+				return
+			}
+			if err := c.conn.WriteControl(websocket.PingMessage, nil, deadline); err != nil {
+				return
+			}
+			continue
+		case <-draining:
+			draining = nil
+			shuttingDown = true
+		case <-c.finished:
+		case <-c.ctx.Done():
+			return
+		}
+
+		if shuttingDown && c.running() == 0 {
+			// The last answers may still be queued
+			for len(c.out) > 0 {
+				if c.write(<-c.out) != nil {
+					return
+				}
+			}
+			deadline := time.Now().Add(c.opts.WriteTimeout)
+			c.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down"), deadline)
+			return
+		}
+	}
+}
+
+func (c *chatConn) write(msg ChatServerMessage) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	c.conn.SetWriteDeadline(time.Now().Add(c.opts.WriteTimeout))
+	if err := c.conn.WriteMessage(websocket.TextMessage, data); err != nil {
+		return err
+	}
+	c.messages.With("direction", "out", "type", msg.Type).Add(1)
+	return nil
+}
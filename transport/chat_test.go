@@ -0,0 +1,170 @@
+package transport
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	fastws "github.com/fasthttp/websocket"
+	"github.com/gofiber/contrib/websocket"
+	"github.com/gofiber/fiber/v2"
+
+	"kit-fiber-example/config"
+	"kit-fiber-example/drain"
+	"kit-fiber-example/metrics"
+)
+
+// testMetrics is shared by the tests, the collectors can only be
+// registered once
+var testMetrics = metrics.Setup()
+
+func TestNewChatOptions(t *testing.T) {
+	var cfg config.Config
+	o, err := newChatOptions(&cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o.MaxMessageBytes != defaultChatMaxMessageBytes || o.MaxInflight != defaultChatMaxInflight ||
+		o.SendBuffer != defaultChatSendBuffer || o.WriteTimeout != defaultChatWriteTimeout ||
+		o.PingInterval != defaultChatPingInterval || o.IdleTimeout != defaultChatIdleTimeout {
+		t.Errorf("defaults = %+v", o)
+	}
+
+	cfg.WebSocket.PingInterval = "soon"
+	if _, err := newChatOptions(&cfg); err == nil {
+		t.Error("invalid pingInterval accepted")
+	}
+}
+
+func TestHandleChatUpgrade(t *testing.T) {
+	tests := []struct {
+		name    string
+		tokens  []string
+		origins []string
+		header  map[string]string
+		target  string
+		status  int
+	}{
+		{"not an upgrade", []string{"secret"}, nil, map[string]string{"Authorization": "Bearer secret"}, "/ws/chat", 426},
+		{"no tokens configured", nil, nil, map[string]string{"Upgrade": "websocket", "Connection": "Upgrade"}, "/ws/chat", 501},
+		{"no token", []string{"secret"}, nil, map[string]string{"Upgrade": "websocket", "Connection": "Upgrade"}, "/ws/chat", 401},
+		{"wrong token", []string{"secret"}, nil, map[string]string{"Upgrade": "websocket", "Connection": "Upgrade", "Authorization": "Bearer guess"}, "/ws/chat", 401},
+		{"bearer token", []string{"other", "secret"}, nil, map[string]string{"Upgrade": "websocket", "Connection": "Upgrade", "Authorization": "Bearer secret"}, "/ws/chat", 200},
+		{"query token", []string{"secret"}, nil, map[string]string{"Upgrade": "websocket", "Connection": "Upgrade"}, "/ws/chat?access_token=secret", 200},
+		{"same origin", []string{"secret"}, nil, map[string]string{"Upgrade": "websocket", "Connection": "Upgrade", "Authorization": "Bearer secret", "Origin": "https://example.com"}, "/ws/chat", 200},
+		{"other origin", []string{"secret"}, nil, map[string]string{"Upgrade": "websocket", "Connection": "Upgrade", "Authorization": "Bearer secret", "Origin": "https://evil.test"}, "/ws/chat", 403},
+		{"configured origin", []string{"secret"}, []string{"https://app.test"}, map[string]string{"Upgrade": "websocket", "Connection": "Upgrade", "Authorization": "Bearer secret", "Origin": "https://app.test"}, "/ws/chat", 200},
+		{"same origin not configured", []string{"secret"}, []string{"https://app.test"}, map[string]string{"Upgrade": "websocket", "Connection": "Upgrade", "Authorization": "Bearer secret", "Origin": "https://example.com"}, "/ws/chat", 403},
+		{"any origin", []string{"secret"}, []string{"*"}, map[string]string{"Upgrade": "websocket", "Connection": "Upgrade", "Authorization": "Bearer secret", "Origin": "https://evil.test"}, "/ws/chat", 200},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr := &fiberTransport{Chat: chatOptions{Tokens: tt.tokens, Origins: tt.origins}}
+			app := fiber.New(fiber.Config{ErrorHandler: errorHandler})
+			app.Get("/ws/chat", tr.HandleChatUpgrade, func(c *fiber.Ctx) error { return c.SendStatus(200) })
+
+			req := httptest.NewRequest("GET", "http://example.com"+tt.target, nil)
+			for k, v := range tt.header {
+				req.Header.Set(k, v)
+			}
+			resp, err := app.Test(req, -1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tt.status {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.status)
+			}
+		})
+	}
+}
+
+// chatAsk answers "hello" in two deltas, or blocks until canceled when
+// asked to
+func chatAsk(ctx context.Context, req AskClaudeStreamRequest) (AskClaudeResponse, error) {
+	if req.Question == "block" {
+		<-ctx.Done()
+		return AskClaudeResponse{}, ctx.Err()
+	}
+	for _, delta := range []string{"hel", "lo"} {
+		if err := req.OnDelta(delta); err != nil {
+			return AskClaudeResponse{}, err
+		}
+	}
+	return AskClaudeResponse{Answer: "hello"}, nil
+}
+
+func TestHandleChat(t *testing.T) {
+	tr := &fiberTransport{
+		AskClaudeStream: chatAsk,
+		Chat: chatOptions{
+			Tokens:          []string{"secret"},
+			MaxMessageBytes: 1024,
+			MaxInflight:     1,
+			SendBuffer:      8,
+			WriteTimeout:    time.Second,
+			PingInterval:    time.Minute,
+			IdleTimeout:     time.Minute,
+		},
+		Tracker: drain.NewTracker(testMetrics),
+		Metrics: testMetrics,
+	}
+	app := fiber.New(fiber.Config{ErrorHandler: errorHandler, DisableStartupMessage: true})
+	app.Get("/ws/chat", tr.HandleChatUpgrade, websocket.New(tr.HandleChat))
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go app.Listener(ln)
+	defer app.Shutdown()
+
+	conn, _, err := fastws.DefaultDialer.Dial("ws://"+ln.Addr().String()+"/ws/chat", http.Header{"Authorization": {"Bearer secret"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// Each client message is answered by the server messages that follow it
+	exchanges := []struct {
+		name string
+		send string
+		want []ChatServerMessage
+	}{
+		{"ping", `{"type":"ping","id":"p"}`, []ChatServerMessage{{Type: ChatPong, ID: "p"}}},
+		{"ask", `{"type":"ask","id":"1","ask":{"question":"hi"}}`, []ChatServerMessage{
+			{Type: ChatDelta, ID: "1", Text: "hel"},
+			{Type: ChatDelta, ID: "1", Text: "lo"},
+			{Type: ChatDone, ID: "1", Response: &AskClaudeResponse{Answer: "hello"}},
+		}},
+		{"malformed", `{"type":`, []ChatServerMessage{{Type: ChatError}}},
+		{"unknown type", `{"type":"shout","id":"x"}`, []ChatServerMessage{{Type: ChatError, ID: "x"}}},
+		{"ask without a question", `{"type":"ask","id":"2"}`, []ChatServerMessage{{Type: ChatError, ID: "2"}}},
+		{"cancel of nothing", `{"type":"cancel","id":"3"}`, []ChatServerMessage{{Type: ChatError, ID: "3"}}},
+		{"blocking ask", `{"type":"ask","id":"4","ask":{"question":"block"}}`, nil},
+		{"ask over the limit", `{"type":"ask","id":"5","ask":{"question":"hi"}}`, []ChatServerMessage{{Type: ChatError, ID: "5"}}},
+		{"cancel", `{"type":"cancel","id":"4"}`, []ChatServerMessage{{Type: ChatError, ID: "4"}}},
+	}
+	for _, ex := range exchanges {
+		if err := conn.WriteMessage(fastws.TextMessage, []byte(ex.send)); err != nil {
+			t.Fatalf("%s: %v", ex.name, err)
+		}
+		for _, want := range ex.want {
+			conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+			var got ChatServerMessage
+			if err := conn.ReadJSON(&got); err != nil {
+				t.Fatalf("%s: %v", ex.name, err)
+			}
+			if got.Type != want.Type || got.ID != want.ID || got.Text != want.Text {
+				t.Errorf("%s: got %s %q %q, want %s %q %q", ex.name, got.Type, got.ID, got.Text, want.Type, want.ID, want.Text)
+			}
+			if want.Response != nil && (got.Response == nil || got.Response.Answer != want.Response.Answer) {
+				t.Errorf("%s: response = %+v, want %+v", ex.name, got.Response, want.Response)
+			}
+			if want.Type == ChatError && got.Problem == nil {
+				t.Errorf("%s: error without a problem", ex.name)
+			}
+		}
+	}
+}
@@ -3,66 +3,185 @@ package transport
 import (
 	"bytes"
 	"context"
-	"encoding/json"
 	"io"
 	"net/http"
 
 	"github.com/gofiber/fiber/v2"
+	"go.opentelemetry.io/otel/attribute"
 
+	"kit-fiber-example/codec"
 	"kit-fiber-example/middlewares"
+	"kit-fiber-example/problem"
+	"kit-fiber-example/service"
+	"kit-fiber-example/validation"
 )
 
+// HeaderTenant identifies the calling tenant for model routing
+const HeaderTenant = "X-Tenant-ID"
+
 // Transport extension
 type AskClaudeRequest struct {
-	Question string `json:"question"`
+	Question      string   `json:"question" validate:"required,max=200000"`
+	System        string   `json:"system,omitempty" validate:"max=100000"`
+	Model         string   `json:"model,omitempty" validate:"max=100"`
+	MaxTokens     int      `json:"max_tokens,omitempty" validate:"min=1"`
+	Temperature   *float64 `json:"temperature,omitempty" validate:"min=0"`
+	TopP          *float64 `json:"top_p,omitempty" validate:"min=0,max=1"`
+	TopK          *int     `json:"top_k,omitempty" validate:"min=1"`
+	StopSequences []string `json:"stop_sequences,omitempty" validate:"max=16"`
+	UserID        string   `json:"user_id,omitempty" validate:"max=256"`
+	// Tenant is taken from the X-Tenant-ID header, Template is set for
+	// prompt template calls; both only affect model routing. They are never
+	// read from a body, so callers can't route around their tenant.
+	Tenant   string `json:"-"`
+	Template string `json:"-"`
+	// Attachments are images or documents, sent either inline or by url
+	Attachments []Attachment `json:"attachments,omitempty" validate:"max=100"`
 }
 
-type AskClaudeResponse struct {
-	Answer string `json:"answer"`
-	Error  string `json:"error,omitempty"`
+// Attachment carries base64 encoded data in JSON
+type Attachment struct {
+	Name      string `json:"name,omitempty" validate:"max=256"`
+	MediaType string `json:"media_type,omitempty" validate:"max=100"`
+	Data      []byte `json:"data,omitempty"`
+	URL       string `json:"url,omitempty" validate:"url"`
 }
 
-func encodeClaudeRequest(_ context.Context, r *http.Request, request any) error {
-	var buf bytes.Buffer
-	if err := json.NewEncoder(&buf).Encode(request); err != nil {
-		return err
+// Validate requires exactly one of data and url
+func (a Attachment) Validate() error {
+	if (len(a.Data) == 0) == (a.URL == "") {
+		return validation.Errors{{Field: "data", Code: validation.CodeInvalid, Message: "exactly one of data and url is required"}}
 	}
-	r.Body = io.NopCloser(&buf)
 	return nil
 }
 
-func decodeClaudeResponse(_ context.Context, r *http.Response) (any, error) {
+func (r AskClaudeRequest) TraceAttributes() []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.String("model", r.Model),
+		attribute.Int("max_tokens", r.MaxTokens),
+		attribute.Int("attachments", len(r.Attachments)),
+	}
+}
+
+type AskClaudeResponse struct {
+	Answer       string `json:"answer"`
+	Model        string `json:"model,omitempty"`
+	StopReason   string `json:"stop_reason,omitempty"`
+	StopSequence string `json:"stop_sequence,omitempty"`
+	Usage        *Usage `json:"usage,omitempty"`
+}
+
+type Usage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+func EncodeClaudeRequest(ctx context.Context, r *http.Request, request any) error {
+	return EncodeRequest(codec.JSON)(ctx, r, request)
+}
+
+// EncodeRequest returns a request encoder that sends and accepts bodies in
+// the media type of cd
+func EncodeRequest(cd codec.Codec) func(context.Context, *http.Request, any) error {
+	return func(_ context.Context, r *http.Request, request any) error {
+		data, err := cd.Marshal(request)
+		if err != nil {
+			return err
+		}
+		r.Body = io.NopCloser(bytes.NewReader(data))
+		r.ContentLength = int64(len(data))
+		r.Header.Set("Content-Type", cd.MediaType())
+		r.Header.Set("Accept", cd.MediaType())
+		return nil
+	}
+}
+
+// DecodeClaudeResponse decodes an ask response in any media type of
+// codec.Default, error responses are returned as *problem.Error
+func DecodeClaudeResponse(_ context.Context, r *http.Response) (any, error) {
 	var response AskClaudeResponse
-	if err := json.NewDecoder(r.Body).Decode(&response); err != nil {
+	if err := DecodeResponse(r, &response); err != nil {
 		return nil, err
 	}
 	return response, nil
 }
 
+// DecodeResponse decodes a response body in any media type of
+// codec.Default into v, error responses are returned as *problem.Error
+func DecodeResponse(r *http.Response, v any) error {
+	if err := problem.Decode(r); err != nil {
+		return err
+	}
+	cd, err := codec.Default.ForContentType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return err
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return err
+	}
+	return cd.Unmarshal(body, v)
+}
+
 func makeAskClaudeEndpoint(svc StringService) middlewares.Endpoint[AskClaudeRequest, AskClaudeResponse] {
 	return func(ctx context.Context, req AskClaudeRequest) (AskClaudeResponse, error) {
-		answer, err := svc.AskClaude(ctx, req.Question)
+		resp, err := svc.AskClaude(ctx, req.toService())
 		if err != nil {
-			return AskClaudeResponse{"", err.Error()}, nil
+			return AskClaudeResponse{}, err
 		}
-		return AskClaudeResponse{answer, ""}, nil
+		return newAskClaudeResponse(resp), nil
+	}
+}
+
+func (r AskClaudeRequest) toService() service.AskRequest {
+	return service.AskRequest{
+		Question:      r.Question,
+		System:        r.System,
+		Model:         r.Model,
+		MaxTokens:     r.MaxTokens,
+		Temperature:   r.Temperature,
+		TopP:          r.TopP,
+		TopK:          r.TopK,
+		StopSequences: r.StopSequences,
+		UserID:        r.UserID,
+		Tenant:        r.Tenant,
+		Template:      r.Template,
+		Attachments:   toServiceAttachments(r.Attachments),
+	}
+}
+
+func newAskClaudeResponse(resp service.AskResponse) AskClaudeResponse {
+	return AskClaudeResponse{
+		Answer:       resp.Answer,
+		Model:        resp.Model,
+		StopReason:   resp.StopReason,
+		StopSequence: resp.StopSequence,
+		Usage: &Usage{
+			InputTokens:  resp.Usage.InputTokens,
+			OutputTokens: resp.Usage.OutputTokens,
+		},
 	}
 }
 
 func (t *fiberTransport) HandleAskClaude(c *fiber.Ctx) error {
 	var req AskClaudeRequest
-	if err := c.BodyParser(&req); err != nil {
-		return c.Status(400).JSON(fiber.Map{
-			"error": "Invalid request body",
-		})
+	if err := parseBody(c, &req); err != nil {
+		return err
 	}
+	req.Tenant = c.Get(HeaderTenant)
 
-	response, err := t.AskClaude(context.TODO(), req)
+	response, err := t.AskClaude(c.UserContext(), req)
 	if err != nil {
-		return c.Status(500).JSON(fiber.Map{
-			"error": err.Error(),
-		})
+		return err
 	}
 
-	return c.JSON(response)
+	return respond(c, response)
+}
+
+func toServiceAttachments(attachments []Attachment) []service.Attachment {
+	result := make([]service.Attachment, len(attachments))
+	for i, a := range attachments {
+		result[i] = service.Attachment(a)
+	}
+	return result
 }
@@ -0,0 +1,77 @@
+package transport
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+
+	"kit-fiber-example/jobs"
+)
+
+func TestHandleAskClaudeTenant(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   string
+		header string
+		tenant string
+	}{
+		{"header", `{"question":"hi"}`, "acme", "acme"},
+		{"no header", `{"question":"hi"}`, "", ""},
+		{"body can't choose the tenant", `{"question":"hi","tenant":"other","template":"admin"}`, "acme", "acme"},
+		{"body without a header", `{"question":"hi","tenant":"other"}`, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got *AskClaudeRequest
+			tr := &fiberTransport{AskClaude: func(_ context.Context, req AskClaudeRequest) (AskClaudeResponse, error) {
+				got = &req
+				return AskClaudeResponse{Answer: "ok"}, nil
+			}}
+			app := fiber.New(fiber.Config{ErrorHandler: errorHandler})
+			app.Post("/ask", tr.HandleAskClaude)
+
+			req := httptest.NewRequest("POST", "/ask", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			if tt.header != "" {
+				req.Header.Set(HeaderTenant, tt.header)
+			}
+			if _, err := app.Test(req, -1); err != nil {
+				t.Fatal(err)
+			}
+			// A body with routing fields may be rejected, but never obeyed
+			if got != nil && (got.Tenant != tt.tenant || got.Template != "") {
+				t.Errorf("tenant, template = %q, %q, want %q, none", got.Tenant, got.Template, tt.tenant)
+			}
+		})
+	}
+}
+
+func TestAskJobPayload(t *testing.T) {
+	payload, err := json.Marshal(askJobPayload{
+		AskClaudeRequest: AskClaudeRequest{Question: "hi", Tenant: "ignored"},
+		Tenant:           "acme",
+		Template:         "summary",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"question":"hi","tenant":"acme","template":"summary"}`; string(payload) != want {
+		t.Errorf("payload = %s, want %s", payload, want)
+	}
+
+	var got AskClaudeRequest
+	process := makeJobProcessor(func(_ context.Context, req AskClaudeRequest) (AskClaudeResponse, error) {
+		got = req
+		return AskClaudeResponse{}, nil
+	})
+	if _, err := process(context.Background(), &jobs.Job{Kind: jobKindAsk, Request: payload}); err != nil {
+		t.Fatal(err)
+	}
+	if got.Question != "hi" || got.Tenant != "acme" || got.Template != "summary" {
+		t.Errorf("processed request = %+v", got)
+	}
+}
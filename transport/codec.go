@@ -0,0 +1,58 @@
+package transport
+
+import (
+	"github.com/gofiber/fiber/v2"
+
+	"kit-fiber-example/codec"
+)
+
+const codecLocalsKey = "codec"
+
+// parseBody decodes the body into v with the codec of its Content-Type,
+// rejecting unknown fields and values of the wrong type. The rules of v are
+// checked by the endpoint.
+func parseBody(c *fiber.Ctx, v any) error {
+	cd, err := codec.Default.ForContentType(c.Get(fiber.HeaderContentType))
+	if err != nil {
+		return err
+	}
+	return cd.UnmarshalStrict(c.Body(), v)
+}
+
+// negotiate picks the codec of the response from the Accept header once
+// per request
+func negotiate(c *fiber.Ctx) (codec.Codec, error) {
+	if cd, ok := c.Locals(codecLocalsKey).(codec.Codec); ok {
+		return cd, nil
+	}
+	c.Vary(fiber.HeaderAccept)
+	cd, err := codec.Default.Negotiate(c.Get(fiber.HeaderAccept))
+	if err != nil {
+		return nil, err
+	}
+	c.Locals(codecLocalsKey, cd)
+	return cd, nil
+}
+
+// acceptable rejects requests whose response the client won't accept
+// before the handler does any work
+func acceptable(c *fiber.Ctx) error {
+	if _, err := negotiate(c); err != nil {
+		return err
+	}
+	return c.Next()
+}
+
+// respond sends v in the media type the client accepts
+func respond(c *fiber.Ctx, v any) error {
+	cd, err := negotiate(c)
+	if err != nil {
+		return err
+	}
+	data, err := cd.Marshal(v)
+	if err != nil {
+		return err
+	}
+	c.Set(fiber.HeaderContentType, cd.MediaType())
+	return c.Send(data)
+}
@@ -0,0 +1,63 @@
+package transport
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestAcceptable(t *testing.T) {
+	var ran bool
+	app := fiber.New(fiber.Config{ErrorHandler: errorHandler})
+	app.Post("/echo", acceptable, func(c *fiber.Ctx) error {
+		ran = true
+		var req TextRequest
+		if err := parseBody(c, &req); err != nil {
+			return err
+		}
+		return respond(c, TextResponse{V: req.S})
+	})
+
+	tests := []struct {
+		name        string
+		accept      string
+		contentType string
+		body        string
+		status      int
+		ran         bool
+		respType    string
+	}{
+		{"default", "", "application/json", `{"string":"hi"}`, 200, true, "application/json"},
+		{"other codec", "application/cbor", "application/json", `{"string":"hi"}`, 200, true, "application/cbor"},
+		{"not acceptable", "text/html", "application/json", `{"string":"hi"}`, 406, false, "application/problem+json"},
+		{"unsupported body", "", "text/plain", "hi", 415, true, "application/problem+json"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ran = false
+			req := httptest.NewRequest("POST", "/echo", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			resp, err := app.Test(req, -1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tt.status {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.status)
+			}
+			if ran != tt.ran {
+				t.Errorf("handler ran = %t, want %t", ran, tt.ran)
+			}
+			if got := resp.Header.Get("Content-Type"); !strings.HasPrefix(got, tt.respType) {
+				t.Errorf("content type = %q, want %q", got, tt.respType)
+			}
+			if got := resp.Header.Get("Vary"); got != "Accept" {
+				t.Errorf("Vary = %q, want Accept", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package transport
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"kit-fiber-example/middlewares"
+	"kit-fiber-example/service"
+)
+
+type DiffRequest struct {
+	A    string `json:"a,omitempty" validate:"max=100000"`
+	B    string `json:"b,omitempty" validate:"max=100000"`
+	Mode string `json:"mode,omitempty" validate:"enum=unified|word|char" doc:"unified by default"`
+}
+
+func (r DiffRequest) TraceAttributes() []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.String("mode", r.Mode),
+		attribute.Int("a_length", len(r.A)),
+		attribute.Int("b_length", len(r.B)),
+	}
+}
+
+type DiffOp struct {
+	Op   string `json:"op" doc:"equal, insert or delete"`
+	Text string `json:"text"`
+}
+
+type DiffResponse struct {
+	Ops     []DiffOp `json:"ops"`
+	Unified string   `json:"unified,omitempty"`
+}
+
+func makeDiffEndpoint(svc StringService) middlewares.Endpoint[DiffRequest, DiffResponse] {
+	return func(_ context.Context, req DiffRequest) (DiffResponse, error) {
+		result, err := svc.Diff(req.A, req.B, req.Mode)
+		if err != nil {
+			return DiffResponse{}, err
+		}
+		return newDiffResponse(result), nil
+	}
+}
+
+func newDiffResponse(result service.DiffResult) DiffResponse {
+	ops := make([]DiffOp, len(result.Ops))
+	for i, op := range result.Ops {
+		ops[i] = DiffOp(op)
+	}
+	return DiffResponse{Ops: ops, Unified: result.Unified}
+}
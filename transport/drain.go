@@ -0,0 +1,55 @@
+package transport
+
+import (
+	"context"
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+
+	"kit-fiber-example/drain"
+)
+
+// trackRequests lets shutdown wait for the requests in flight. Once the
+// drain began, new requests are refused and connections aren't kept alive,
+// so that clients move to another instance. Probes are never tracked.
+func trackRequests(tracker *drain.Tracker) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		switch c.Path() {
+		case "/health", "/ready":
+			return c.Next()
+		}
+
+		ctx, done, err := tracker.Track(c.UserContext(), drain.KindRequest)
+		if err != nil {
+			c.Context().SetConnectionClose()
+			return err
+		}
+		defer done()
+		c.SetUserContext(ctx)
+
+		err = abortCause(ctx, c.Next())
+		select {
+		case <-tracker.Draining():
+			c.Context().SetConnectionClose()
+		default:
+		}
+		return err
+	}
+}
+
+// trackStream tracks a response written by a body stream writer. The
+// writer runs after the handler returned, when the request context is gone,
+// so the returned context is only ended by the drain. Call done when the
+// writer returns.
+func (t *fiberTransport) trackStream(c *fiber.Ctx) (ctx context.Context, done func(), err error) {
+	return t.Tracker.Track(context.WithoutCancel(c.UserContext()), drain.KindStream)
+}
+
+// abortCause replaces the cancellation of work the drain aborted with
+// drain.ErrAborted, which tells clients to retry elsewhere.
+func abortCause(ctx context.Context, err error) error {
+	if errors.Is(err, context.Canceled) && errors.Is(context.Cause(ctx), drain.ErrAborted) {
+		return context.Cause(ctx)
+	}
+	return err
+}
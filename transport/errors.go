@@ -0,0 +1,128 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"go.opentelemetry.io/otel/trace"
+
+	"kit-fiber-example/codec"
+	"kit-fiber-example/drain"
+	"kit-fiber-example/jobs"
+	"kit-fiber-example/middlewares"
+	"kit-fiber-example/problem"
+	"kit-fiber-example/prompts"
+	"kit-fiber-example/service"
+	"kit-fiber-example/validation"
+)
+
+// ValidationProblem documents the 422 problem in the OpenAPI document
+type ValidationProblem struct {
+	problem.Problem
+	Fields []validation.FieldError `json:"fields"`
+}
+
+// problemFor maps an error of any layer to its problem code
+func problemFor(err error) *problem.Error {
+	var (
+		pe      *problem.Error
+		fields  validation.Errors
+		extract *service.ExtractError
+		vars    *prompts.VariableError
+		render  *prompts.RenderError
+		se      service.ServiceError
+		fe      *fiber.Error
+	)
+	switch {
+	case errors.As(err, &pe):
+		return pe
+	case errors.As(err, &fields):
+		return problem.Wrap(problem.CodeValidation, err).With("fields", []validation.FieldError(fields))
+	case errors.Is(err, validation.ErrMalformed):
+		return problem.Wrap(problem.CodeBadRequest, err)
+	case errors.Is(err, codec.ErrUnsupportedMediaType):
+		return problem.Wrap(problem.CodeUnsupportedMediaType, err).With("accepted", codec.Default.MediaTypes())
+	case errors.Is(err, codec.ErrNotAcceptable):
+		return problem.Wrap(problem.CodeNotAcceptable, err).With("available", codec.Default.MediaTypes())
+	case errors.As(err, &extract):
+		return problem.Wrap(problem.CodeExtractionFailed, err).
+			With("attempts", extract.Attempts).
+			With("problems", extract.Problems)
+	case errors.As(err, &vars):
+		return problem.Wrap(problem.CodeBadRequest, err).With("problems", vars.Problems)
+	case errors.As(err, &render):
+		return problem.Wrap(problem.CodeBadRequest, err).With("problems", []string{render.Err.Error()})
+	case errors.Is(err, jobs.ErrNotFound), errors.Is(err, prompts.ErrNotFound):
+		return problem.Wrap(problem.CodeNotFound, err)
+	case errors.Is(err, jobs.ErrQueueFull), errors.Is(err, jobs.ErrStopped):
+		return problem.Wrap(problem.CodeUnavailable, err)
+	case errors.Is(err, drain.ErrDraining), errors.Is(err, drain.ErrAborted):
+		return problem.Wrap(problem.CodeUnavailable, err)
+	case errors.Is(err, middlewares.ErrOverloaded):
+		return problem.Wrap(problem.CodeOverloaded, err)
+	case errors.As(err, &se):
+		e := problem.Wrap(problem.CodeFromStatus(se.Code), err)
+		e.Status = se.Code
+		return e
+	case errors.As(err, &fe):
+		return &problem.Error{Code: problem.CodeFromStatus(fe.Code), Status: fe.Code, Detail: fe.Message, Err: err}
+	case errors.Is(err, context.DeadlineExceeded):
+		return problem.Wrap(problem.CodeTimeout, err)
+	case errors.Is(err, context.Canceled):
+		return problem.Wrap(problem.CodeCanceled, err)
+	}
+	return problem.Wrap(problem.CodeInternal, err)
+}
+
+// requestInfo identifies a request in its problems. It's taken from the
+// Fiber context, so it can be used by stream writers after the handler
+// returned.
+type requestInfo struct {
+	path      string
+	requestID string
+	traceID   string
+}
+
+func newRequestInfo(c *fiber.Ctx) requestInfo {
+	// Fiber reuses the path buffer once the handler returned
+	info := requestInfo{path: strings.Clone(c.Path())}
+	info.requestID, _ = c.Locals("requestid").(string)
+	if sc := trace.SpanContextFromContext(c.UserContext()); sc.HasTraceID() {
+		info.traceID = sc.TraceID().String()
+	}
+	return info
+}
+
+// problem returns the problem document of err for this request
+func (r requestInfo) problem(err error) *problem.Problem {
+	p := problemFor(err).Problem()
+	p.Instance = r.path
+	p.RequestID = r.requestID
+	p.TraceID = r.traceID
+	return p
+}
+
+// Error handling middleware
+func errorHandler(c *fiber.Ctx, err error) error {
+	p := newRequestInfo(c).problem(err)
+	return c.Status(p.Status).JSON(p, problem.ContentType)
+}
+
+// requestSpan starts the span of the whole request, so that endpoint spans
+// share its trace and problems can report the trace ID
+func requestSpan(tracer trace.Tracer) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx, span := tracer.Start(c.UserContext(), c.Method()+" request", trace.WithSpanKind(trace.SpanKindServer))
+		defer span.End()
+		c.SetUserContext(ctx)
+
+		err := c.Next()
+		span.SetName(c.Method() + " " + c.Route().Path)
+		if err != nil {
+			span.RecordError(err)
+		}
+		return err
+	}
+}
@@ -0,0 +1,36 @@
+package transport
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"kit-fiber-example/problem"
+	"kit-fiber-example/prompts"
+)
+
+func TestProblemForPrompts(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		code     problem.Code
+		status   int
+		problems []string
+	}{
+		{"bad variables", &prompts.VariableError{Problems: []string{"text: is required"}}, problem.CodeBadRequest, http.StatusBadRequest, []string{"text: is required"}},
+		{"render failed", fmt.Errorf("run: %w", &prompts.RenderError{Err: errors.New("index out of range: 1")}), problem.CodeBadRequest, http.StatusBadRequest, []string{"index out of range: 1"}},
+		{"unknown template", prompts.ErrNotFound, problem.CodeNotFound, http.StatusNotFound, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := problemFor(tt.err).Problem()
+			if p.Code != tt.code || p.Status != tt.status {
+				t.Errorf("problem = %s %d, want %s %d", p.Code, p.Status, tt.code, tt.status)
+			}
+			if got := fmt.Sprint(p.Extensions["problems"]); tt.problems != nil && got != fmt.Sprint(tt.problems) {
+				t.Errorf("problems = %s, want %v", got, tt.problems)
+			}
+		})
+	}
+}
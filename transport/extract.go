@@ -0,0 +1,80 @@
+package transport
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/gofiber/fiber/v2"
+	"go.opentelemetry.io/otel/attribute"
+
+	"kit-fiber-example/middlewares"
+	"kit-fiber-example/service"
+)
+
+// Transport extension
+type ExtractRequest struct {
+	Input        string          `json:"input" validate:"required,max=200000"`
+	Schema       json.RawMessage `json:"schema" validate:"required"`
+	Instructions string          `json:"instructions,omitempty" validate:"max=100000"`
+	Model        string          `json:"model,omitempty" validate:"max=100"`
+	MaxTokens    int             `json:"max_tokens,omitempty" validate:"min=1"`
+	MaxAttempts  int             `json:"max_attempts,omitempty" validate:"min=1,max=10"`
+	UserID       string          `json:"user_id,omitempty" validate:"max=256"`
+}
+
+func (r ExtractRequest) TraceAttributes() []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.String("model", r.Model),
+		attribute.Int("max_attempts", r.MaxAttempts),
+	}
+}
+
+type ExtractResponse struct {
+	Data     json.RawMessage `json:"data,omitempty"`
+	Model    string          `json:"model,omitempty"`
+	Attempts int             `json:"attempts,omitempty"`
+	Usage    *Usage          `json:"usage,omitempty"`
+}
+
+func makeExtractEndpoint(svc StringService) middlewares.Endpoint[ExtractRequest, ExtractResponse] {
+	return func(ctx context.Context, req ExtractRequest) (ExtractResponse, error) {
+		resp, err := svc.Extract(ctx, service.ExtractRequest{
+			Input:        req.Input,
+			Schema:       req.Schema,
+			Instructions: req.Instructions,
+			Model:        req.Model,
+			MaxTokens:    req.MaxTokens,
+			MaxAttempts:  req.MaxAttempts,
+			UserID:       req.UserID,
+		})
+		if err != nil {
+			// ExtractErrors keep their attempts and schema problems as
+			// problem extensions
+			return ExtractResponse{}, err
+		}
+		return ExtractResponse{
+			Data:     resp.Data,
+			Model:    resp.Model,
+			Attempts: resp.Attempts,
+			Usage: &Usage{
+				InputTokens:  resp.Usage.InputTokens,
+				OutputTokens: resp.Usage.OutputTokens,
+			},
+		}, nil
+	}
+}
+
+// HandleExtract is the Fiber handler for the extract endpoint
+func (t *fiberTransport) HandleExtract(c *fiber.Ctx) error {
+	var req ExtractRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	response, err := t.Extract(c.UserContext(), req)
+	if err != nil {
+		return err
+	}
+
+	return respond(c, response)
+}
@@ -2,50 +2,211 @@ package transport
 
 import (
 	"context"
-	"errors"
+	"reflect"
 
+	"github.com/gofiber/contrib/websocket"
 	"github.com/gofiber/fiber/v2"
-	"github.com/gofiber/fiber/v2/middleware/adaptor"
 	"github.com/gofiber/fiber/v2/middleware/logger"
 	"github.com/gofiber/fiber/v2/middleware/recover"
-	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/gofiber/fiber/v2/middleware/requestid"
 	"go.opentelemetry.io/otel/trace"
 
-	"kit-fiber-example/health"
+	"kit-fiber-example/concurrency"
+	"kit-fiber-example/config"
+	"kit-fiber-example/drain"
+	"kit-fiber-example/idempotency"
+	"kit-fiber-example/jobs"
 	"kit-fiber-example/metrics"
 	"kit-fiber-example/middlewares"
+	"kit-fiber-example/prompts"
 	"kit-fiber-example/service"
+	"kit-fiber-example/validation"
 )
 
 // Service interface defines our business logic
 type StringService interface {
-	Uppercase(string) (string, error)
-	AskClaude(context.Context, string) (string, error)
+	Uppercase(s, lang string) (string, error)
+	Lowercase(s, lang string) (string, error)
+	Title(s, lang string) (string, error)
+	Count(string) (service.Counts, error)
+	Reverse(string) (string, error)
+	Normalize(s, form string) (string, error)
+	Trim(s string, collapse bool) (string, error)
+	Slugify(string) (string, error)
+	Transliterate(string) (string, error)
+	Diff(a, b, mode string) (service.DiffResult, error)
+	AskClaude(context.Context, service.AskRequest) (service.AskResponse, error)
+	AskClaudeStream(context.Context, service.AskRequest, func(string) error) (service.AskResponse, error)
+	CountTokens(context.Context, service.AskRequest) (int, error)
+	Extract(context.Context, service.ExtractRequest) (service.ExtractResponse, error)
+}
+
+// HealthChecker reports whether the service is alive and takes traffic
+type HealthChecker interface {
+	IsHealthy() bool
+	IsReady() bool
 }
 
 // Fiber transport layer?? or application layer?
 type fiberTransport struct {
 	Uppercase middlewares.Endpoint[UppercaseRequest, UppercaseResponse]
 	AskClaude middlewares.Endpoint[AskClaudeRequest, AskClaudeResponse]
+	Extract   middlewares.Endpoint[ExtractRequest, ExtractResponse]
+
+	Lowercase     middlewares.Endpoint[CaseRequest, TextResponse]
+	Title         middlewares.Endpoint[CaseRequest, TextResponse]
+	Count         middlewares.Endpoint[TextRequest, CountResponse]
+	Reverse       middlewares.Endpoint[TextRequest, TextResponse]
+	Normalize     middlewares.Endpoint[NormalizeRequest, TextResponse]
+	Trim          middlewares.Endpoint[TrimRequest, TextResponse]
+	Slugify       middlewares.Endpoint[TextRequest, TextResponse]
+	Transliterate middlewares.Endpoint[TextRequest, TextResponse]
+	Diff          middlewares.Endpoint[DiffRequest, DiffResponse]
+
+	AskClaudeStream middlewares.Endpoint[AskClaudeStreamRequest, AskClaudeResponse]
+	CountTokens     middlewares.Endpoint[AskClaudeRequest, CountTokensResponse]
+
 	//services    []Service
-	Metrics *metrics.Metrics // todo interface MetricsCollector
-	Health  *health.Health   // todo interface HealthChecker
+	Idempotency fiber.Handler
+	Jobs        *jobs.Queue
+	Prompts     *prompts.Registry
+	Batch       batchOptions
+	Chat        chatOptions
+	Tracker     *drain.Tracker
+	BodyLimit   int
+	// UploadBodyLimit replaces BodyLimit on /ask/upload
+	UploadBodyLimit int
+	Metrics         *metrics.Metrics // todo interface MetricsCollector
+	Health          HealthChecker
+	Tracer          trace.Tracer
 }
 
-func NewFiberTransport(svc StringService, h *health.Health, m *metrics.Metrics, t trace.Tracer) *fiberTransport {
+func NewFiberTransport(cfg *config.Config, svc StringService, h HealthChecker, m *metrics.Metrics, t trace.Tracer) (*fiberTransport, error) {
+	// Every endpoint gets its own bulkhead, so that long asks can't starve uppercase calls
+	uppercaseBulkhead, err := concurrency.NewBulkheadFromConfig(cfg, "uppercase")
+	if err != nil {
+		return nil, err
+	}
+	textBulkhead, err := concurrency.NewBulkheadFromConfig(cfg, "text")
+	if err != nil {
+		return nil, err
+	}
+	askBulkhead, err := concurrency.NewBulkheadFromConfig(cfg, "ask")
+	if err != nil {
+		return nil, err
+	}
+	extractBulkhead, err := concurrency.NewBulkheadFromConfig(cfg, "extract")
+	if err != nil {
+		return nil, err
+	}
+
 	uppercaseEndpoint := makeUppercaseEndpoint(svc)
-	uppercaseEndpoint = middlewares.LoggingMiddleware(uppercaseEndpoint)
-	uppercaseEndpoint = middlewares.WithMetrics(m, uppercaseEndpoint)
+	uppercaseEndpoint = middlewares.WithLogging("uppercase", uppercaseEndpoint)
+	uppercaseEndpoint = middlewares.WithMetrics("uppercase", m, uppercaseEndpoint)
+	uppercaseEndpoint = middlewares.WithConcurrencyLimit("uppercase", uppercaseBulkhead, m, uppercaseEndpoint)
+	uppercaseEndpoint = middlewares.WithValidation(uppercaseEndpoint)
 	uppercaseEndpoint = middlewares.WithTracing(t, uppercaseEndpoint)
 
+	contextGuard, err := service.NewContextGuardFromConfig(cfg, svc)
+	if err != nil {
+		return nil, err
+	}
+
 	askClaudeEndpoint := makeAskClaudeEndpoint(svc)
+	askClaudeEndpoint = middlewares.WithConcurrencyLimit("ask", askBulkhead, m, askClaudeEndpoint)
+	// Oversized asks are rejected before they take a concurrency slot
+	askClaudeEndpoint = WithContextGuard(contextGuard, m, askClaudeEndpoint)
+	askClaudeEndpoint = middlewares.WithValidation(askClaudeEndpoint)
+	askClaudeEndpoint = middlewares.WithTracing(t, askClaudeEndpoint)
+
+	// Streams are asks too, so they share the ask bulkhead
+	askClaudeStreamEndpoint := makeAskClaudeStreamEndpoint(svc)
+	askClaudeStreamEndpoint = middlewares.WithConcurrencyLimit("ask", askBulkhead, m, askClaudeStreamEndpoint)
+	askClaudeStreamEndpoint = WithContextGuard(contextGuard, m, askClaudeStreamEndpoint)
+	askClaudeStreamEndpoint = middlewares.WithValidation(askClaudeStreamEndpoint)
+	askClaudeStreamEndpoint = middlewares.WithTracing(t, askClaudeStreamEndpoint)
+
+	countTokensEndpoint := makeCountTokensEndpoint(svc)
+	countTokensEndpoint = middlewares.WithValidation(countTokensEndpoint)
+	countTokensEndpoint = middlewares.WithTracing(t, countTokensEndpoint)
+
+	extractEndpoint := makeExtractEndpoint(svc)
+	extractEndpoint = middlewares.WithConcurrencyLimit("extract", extractBulkhead, m, extractEndpoint)
+	extractEndpoint = middlewares.WithValidation(extractEndpoint)
+	extractEndpoint = middlewares.WithTracing(t, extractEndpoint)
+
+	idempotent := func(c *fiber.Ctx) error { return c.Next() }
+	if cfg.Idempotency.Enabled {
+		store, window, err := idempotency.NewStoreFromConfig(cfg)
+		if err != nil {
+			return nil, err
+		}
+		idempotent = idempotency.New(store, window)
+	}
+
+	tracker := drain.NewTracker(m)
+	jobQueue, err := jobs.NewQueueFromConfig(cfg, makeJobProcessor(askClaudeEndpoint), tracker)
+	if err != nil {
+		return nil, err
+	}
+
+	promptRegistry, err := prompts.NewRegistryFromConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	chat, err := newChatOptions(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	// Bodies the handlers validate themselves, the endpoints check theirs
+	for _, t := range []reflect.Type{
+		reflect.TypeFor[BatchItem](),
+		reflect.TypeFor[RunPromptRequest](),
+		reflect.TypeFor[AskJobRequest](),
+		reflect.TypeFor[ChatClientMessage](),
+	} {
+		if err := validation.Compile(t); err != nil {
+			return nil, err
+		}
+	}
+
+	uploadBodyLimit := cfg.Server.UploadBodyLimit
+	if uploadBodyLimit == 0 {
+		uploadBodyLimit = defaultUploadBodyLimit
+	}
 
 	return &fiberTransport{
 		Uppercase: uppercaseEndpoint,
 		AskClaude: askClaudeEndpoint,
-		Metrics:   m,
-		Health:    h,
-	}
+		Extract:   extractEndpoint,
+
+		Lowercase:     textEndpoint("lowercase", m, textBulkhead, t, makeCaseEndpoint(svc.Lowercase)),
+		Title:         textEndpoint("title", m, textBulkhead, t, makeCaseEndpoint(svc.Title)),
+		Count:         textEndpoint("count", m, textBulkhead, t, makeCountEndpoint(svc)),
+		Reverse:       textEndpoint("reverse", m, textBulkhead, t, makeTextEndpoint(svc.Reverse)),
+		Normalize:     textEndpoint("normalize", m, textBulkhead, t, makeNormalizeEndpoint(svc)),
+		Trim:          textEndpoint("trim", m, textBulkhead, t, makeTrimEndpoint(svc)),
+		Slugify:       textEndpoint("slugify", m, textBulkhead, t, makeTextEndpoint(svc.Slugify)),
+		Transliterate: textEndpoint("transliterate", m, textBulkhead, t, makeTextEndpoint(svc.Transliterate)),
+		Diff:          textEndpoint("diff", m, textBulkhead, t, makeDiffEndpoint(svc)),
+
+		AskClaudeStream: askClaudeStreamEndpoint,
+		CountTokens:     countTokensEndpoint,
+
+		Idempotency:     idempotent,
+		Jobs:            jobQueue,
+		Prompts:         promptRegistry,
+		Batch:           newBatchOptions(cfg),
+		Chat:            chat,
+		Tracker:         tracker,
+		BodyLimit:       cfg.Server.BodyLimit,
+		UploadBodyLimit: uploadBodyLimit,
+		Metrics:         m,
+		Health:          h,
+		Tracer:          t,
+	}, nil
 }
 
 // Health check handler
@@ -56,45 +217,79 @@ func (t *fiberTransport) HandleHealth(c *fiber.Ctx) error {
 	return c.SendStatus(503)
 }
 
-// Readiness check handler
+// Readiness check handler, fails while starting and draining
 func (t *fiberTransport) HandleReady(c *fiber.Ctx) error {
-	// Add your readiness check logic here
-	// For example, check database connections, external services, etc.
-	return c.SendStatus(200)
-}
-
-// Error handling middleware
-func errorHandler(c *fiber.Ctx, err error) error {
-	code := fiber.StatusInternalServerError
-
-	var e service.ServiceError
-	if errors.As(err, &e) {
-		code = e.Code
+	if t.Health.IsReady() {
+		return c.SendStatus(200)
 	}
-
-	return c.Status(code).JSON(fiber.Map{
-		"error": err.Error(),
-	})
+	return c.SendStatus(503)
 }
 
 func InitApp(transport *fiberTransport) *fiber.App {
+	bodyLimit := transport.BodyLimit
+	if bodyLimit == 0 {
+		bodyLimit = fiber.DefaultBodyLimit
+	}
 	app := fiber.New(fiber.Config{
 		ErrorHandler: errorHandler,
+		BodyLimit:    bodyLimit,
+		// Bodies over the limit are streamed, limitBody decides per route
+		StreamRequestBody: true,
+		// Uploads are parsed once their size was checked
+		DisablePreParseMultipartForm: true,
 	})
 
 	// Add fiber middleware
 	app.Use(recover.New())
+	app.Use(requestid.New())
 	app.Use(logger.New(logger.Config{
-		Format: "${time} ${method} ${path} ${status} ${latency}\n",
+		Format: "${time} ${method} ${path} ${status} ${latency} ${locals:requestid}\n",
+	}))
+	if transport.Tracer != nil {
+		app.Use(requestSpan(transport.Tracer))
+	}
+	if transport.Tracker != nil {
+		app.Use(trackRequests(transport.Tracker))
+	}
+	app.Use(limitBody(bodyLimit, map[string]int{
+		"/ask/upload": max(transport.UploadBodyLimit, bodyLimit),
 	}))
 
-	// Setup routes
-	app.Post("/uppercase", transport.HandleUppercase)
-	app.Post("/ask", transport.HandleAskClaude)
+	// Setup routes. Routes answering with respond negotiate the media type
+	// of the response first.
+	app.Post("/uppercase", acceptable, transport.Idempotency, transport.HandleUppercase)
+	app.Post("/lowercase", acceptable, handleText(transport.Lowercase))
+	app.Post("/title", acceptable, handleText(transport.Title))
+	app.Post("/count", acceptable, handleText(transport.Count))
+	app.Post("/reverse", acceptable, handleText(transport.Reverse))
+	app.Post("/normalize", acceptable, handleText(transport.Normalize))
+	app.Post("/trim", acceptable, handleText(transport.Trim))
+	app.Post("/slugify", acceptable, handleText(transport.Slugify))
+	app.Post("/transliterate", acceptable, handleText(transport.Transliterate))
+	app.Post("/diff", acceptable, handleText(transport.Diff))
+	app.Post("/ask", acceptable, transport.Idempotency, transport.HandleAskClaude)
+	app.Post("/ask/upload", acceptable, transport.HandleAskClaudeUpload)
+	app.Post("/ask/stream", transport.HandleAskClaudeStream)
+	app.Post("/ask/count_tokens", acceptable, transport.HandleCountTokens)
+	app.Post("/extract", acceptable, transport.Idempotency, transport.HandleExtract)
+	// Not idempotent: replays would buffer the NDJSON stream. NDJSON isn't a
+	// codec, the handler negotiates once it knows it doesn't stream.
+	app.Post("/batch", transport.HandleBatch)
+	app.Post("/jobs/ask", acceptable, transport.Idempotency, transport.HandleCreateAskJob)
+	app.Get("/jobs/:id", acceptable, transport.HandleGetJob)
+	app.Get("/prompts", transport.HandleListPrompts)
+	app.Post("/prompts/:name", acceptable, transport.Idempotency, transport.HandleRunPrompt)
+	// HandleChatUpgrade checks the origin, so that rejected pages get a problem
+	app.Get("/ws/chat", transport.HandleChatUpgrade, websocket.New(transport.HandleChat, websocket.Config{
+		Origins: []string{"*"},
+	}))
 	app.Get("/health", transport.HandleHealth)
 	app.Get("/ready", transport.HandleReady)
 
-	prometheusHandler := adaptor.HTTPHandler(promhttp.Handler())
-	app.Get("/metrics", prometheusHandler)
+	// Every route above must be documented in apiOperations
+	app.Get("/openapi.json", transport.HandleOpenAPI)
+	app.Get("/docs", transport.HandleDocs)
+	app.Get("/docs/redoc.standalone.js", transport.HandleDocsBundle)
+	app.Get("/schema.proto", transport.HandleProtoSchema)
 	return app
 }
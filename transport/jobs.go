@@ -0,0 +1,108 @@
+package transport
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/gofiber/fiber/v2"
+
+	"kit-fiber-example/jobs"
+	"kit-fiber-example/problem"
+	"kit-fiber-example/validation"
+)
+
+const jobKindAsk = "ask"
+
+// AskJobRequest is an AskClaudeRequest that runs in the background. When
+// CallbackURL is set, the finished job is posted there.
+type AskJobRequest struct {
+	AskClaudeRequest
+	CallbackURL string `json:"callbackUrl,omitempty" validate:"max=2048,url"`
+}
+
+// askJobPayload is the stored request of an ask job. The routing fields of
+// AskClaudeRequest aren't part of its JSON, so they are kept next to it.
+type askJobPayload struct {
+	AskClaudeRequest
+	Tenant   string `json:"tenant,omitempty"`
+	Template string `json:"template,omitempty"`
+}
+
+// makeJobProcessor runs queued jobs through the same endpoint chain as the
+// synchronous handlers.
+func makeJobProcessor(askClaude func(context.Context, AskClaudeRequest) (AskClaudeResponse, error)) jobs.Processor {
+	return func(ctx context.Context, job *jobs.Job) (json.RawMessage, error) {
+		switch job.Kind {
+		case jobKindAsk:
+			var payload askJobPayload
+			if err := json.Unmarshal(job.Request, &payload); err != nil {
+				return nil, jobs.Permanent(err)
+			}
+			req := payload.AskClaudeRequest
+			req.Tenant, req.Template = payload.Tenant, payload.Template
+			resp, err := askClaude(ctx, req)
+			if err != nil {
+				return nil, jobError(err)
+			}
+			return json.Marshal(resp)
+		default:
+			return nil, jobs.Permanent(fmt.Errorf("unknown job kind %q", job.Kind))
+		}
+	}
+}
+
+// jobError marks the errors of requests that are wrong, like invalid or
+// too large ones, as permanent. Timeouts, rate limits and upstream errors
+// may pass on a later attempt.
+func jobError(err error) error {
+	status := problemFor(err).HTTPStatus()
+	switch {
+	case status == fiber.StatusRequestTimeout, status == fiber.StatusTooManyRequests,
+		status == problem.StatusClientClosedRequest:
+		return err
+	case status >= 400 && status < 500:
+		return jobs.Permanent(err)
+	}
+	return err
+}
+
+// HandleCreateAskJob enqueues an ask and answers 202 with the job
+func (t *fiberTransport) HandleCreateAskJob(c *fiber.Ctx) error {
+	var req AskJobRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+	// The ask is only run later, reject it now rather than failing the job
+	if err := validation.Struct(req); err != nil {
+		return err
+	}
+	if err := t.Jobs.CheckCallbackURL(c.UserContext(), req.CallbackURL); err != nil {
+		return validation.Errors{{Field: "callbackUrl", Code: validation.CodeNotAllowed, Message: err.Error()}}
+	}
+
+	payload, err := json.Marshal(askJobPayload{
+		AskClaudeRequest: req.AskClaudeRequest,
+		Tenant:           c.Get(HeaderTenant),
+	})
+	if err != nil {
+		return err
+	}
+	job, err := t.Jobs.Enqueue(c.UserContext(), jobKindAsk, payload, req.CallbackURL)
+	if err != nil {
+		return err
+	}
+
+	c.Location("/jobs/" + job.ID)
+	c.Status(fiber.StatusAccepted)
+	return respond(c, job)
+}
+
+// HandleGetJob reports the status and result of a job
+func (t *fiberTransport) HandleGetJob(c *fiber.Ctx) error {
+	job, err := t.Jobs.Get(c.UserContext(), c.Params("id"))
+	if err != nil {
+		return err
+	}
+	return respond(c, job)
+}
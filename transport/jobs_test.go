@@ -0,0 +1,44 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"kit-fiber-example/jobs"
+	"kit-fiber-example/problem"
+	"kit-fiber-example/service"
+	"kit-fiber-example/validation"
+)
+
+func TestJobError(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		permanent bool
+	}{
+		{"validation", validation.Errors{{Field: "question", Code: validation.CodeRequired}}, true},
+		{"context too large", service.ServiceError{Code: http.StatusRequestEntityTooLarge, Message: "too long"}, true},
+		{"bad request problem", problem.New(problem.CodeBadRequest, "bad"), true},
+		{"rate limited", service.ServiceError{Code: http.StatusTooManyRequests, Message: "slow down"}, false},
+		{"upstream", service.ServiceError{Code: http.StatusBadGateway, Message: "down"}, false},
+		{"timeout", context.DeadlineExceeded, false},
+		{"canceled", context.Canceled, false},
+		{"unknown", errors.New("boom"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			process := makeJobProcessor(func(context.Context, AskClaudeRequest) (AskClaudeResponse, error) {
+				return AskClaudeResponse{}, tt.err
+			})
+			_, err := process(context.Background(), &jobs.Job{Kind: jobKindAsk, Request: []byte(`{"question":"hi"}`)})
+			if err == nil || err.Error() != tt.err.Error() {
+				t.Errorf("error = %v, want %v", err, tt.err)
+			}
+			if got := jobs.IsPermanent(err); got != tt.permanent {
+				t.Errorf("permanent = %t, want %t", got, tt.permanent)
+			}
+		})
+	}
+}
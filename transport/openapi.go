@@ -0,0 +1,412 @@
+package transport
+
+import (
+	"embed"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gofiber/fiber/v2"
+
+	"kit-fiber-example/codec"
+	"kit-fiber-example/idempotency"
+	"kit-fiber-example/jobs"
+	"kit-fiber-example/openapi"
+	"kit-fiber-example/problem"
+	"kit-fiber-example/prompts"
+)
+
+// APIVersion is the version of the HTTP API in the OpenAPI document
+const APIVersion = "1.0.0"
+
+const mimeJSON = "application/json"
+
+// apiOperation documents one route registered by InitApp
+type apiOperation struct {
+	Method  string
+	Path    string // fiber syntax, e.g. /jobs/:id
+	Tag     string
+	Summary string
+
+	Request     any    // nil for no body
+	RequestType string // every media type of codec.Default by default
+	Response    any    // nil for no body
+	// JSONOnly responses don't negotiate the media type
+	JSONOnly bool
+	// ResponseType is the content type of the success response, JSON by default
+	ResponseType string
+	// NDJSON is the line type when the response can also be streamed as NDJSON
+	NDJSON any
+	Status int // 200 by default
+
+	// Messages are exchanged over WebSocket, only their schemas are documented
+	Messages []any
+
+	Query      []openapi.Parameter
+	Idempotent bool  // accepts Idempotency-Key
+	Tenant     bool  // accepts X-Tenant-ID
+	Bearer     bool  // requires an access token
+	Errors     []int // besides 400 and 500
+}
+
+// apiOperations must list every route of InitApp, OpenAPI fails otherwise
+var apiOperations = []apiOperation{
+	{Method: "POST", Path: "/uppercase", Tag: "text", Summary: "Convert a string to upper case", Request: UppercaseRequest{}, Response: UppercaseResponse{}, Idempotent: true, Errors: []int{503}},
+	{Method: "POST", Path: "/lowercase", Tag: "text", Summary: "Convert a string to lower case", Request: CaseRequest{}, Response: TextResponse{}, Errors: []int{503}},
+	{Method: "POST", Path: "/title", Tag: "text", Summary: "Convert a string to title case", Request: CaseRequest{}, Response: TextResponse{}, Errors: []int{503}},
+	{Method: "POST", Path: "/count", Tag: "text", Summary: "Count bytes, runes, words and graphemes", Request: TextRequest{}, Response: CountResponse{}, Errors: []int{503}},
+	{Method: "POST", Path: "/reverse", Tag: "text", Summary: "Reverse a string by grapheme clusters", Request: TextRequest{}, Response: TextResponse{}, Errors: []int{503}},
+	{Method: "POST", Path: "/normalize", Tag: "text", Summary: "Apply a Unicode normalization form", Request: NormalizeRequest{}, Response: TextResponse{}, Errors: []int{503}},
+	{Method: "POST", Path: "/trim", Tag: "text", Summary: "Trim or collapse whitespace", Request: TrimRequest{}, Response: TextResponse{}, Errors: []int{503}},
+	{Method: "POST", Path: "/slugify", Tag: "text", Summary: "Make a URL slug", Request: TextRequest{}, Response: TextResponse{}, Errors: []int{503}},
+	{Method: "POST", Path: "/transliterate", Tag: "text", Summary: "Transliterate to ASCII", Request: TextRequest{}, Response: TextResponse{}, Errors: []int{503}},
+	{Method: "POST", Path: "/diff", Tag: "text", Summary: "Diff two strings by lines, words or characters", Request: DiffRequest{}, Response: DiffResponse{}, Errors: []int{503}},
+
+	{Method: "POST", Path: "/ask", Tag: "ask", Summary: "Ask Claude a question", Request: AskClaudeRequest{}, Response: AskClaudeResponse{}, Idempotent: true, Tenant: true, Errors: []int{413, 415, 503}},
+	{Method: "POST", Path: "/ask/upload", Tag: "ask", Summary: "Ask Claude about uploaded files", Request: AskUploadForm{}, RequestType: "multipart/form-data", Response: AskClaudeResponse{}, Tenant: true, Errors: []int{413, 415, 503}},
+	{Method: "POST", Path: "/ask/stream", Tag: "ask", Summary: "Ask Claude and stream the answer as server-sent events", Request: AskClaudeRequest{}, ResponseType: "text/event-stream", Tenant: true},
+	{Method: "POST", Path: "/ask/count_tokens", Tag: "ask", Summary: "Count the input tokens of an ask", Request: AskClaudeRequest{}, Response: CountTokensResponse{}},
+	{Method: "POST", Path: "/extract", Tag: "ask", Summary: "Extract JSON matching a schema", Request: ExtractRequest{}, Response: ExtractResponse{}, Idempotent: true, Errors: []int{503}},
+
+	{Method: "POST", Path: "/batch", Tag: "batch", Summary: "Run many operations in one call", Request: BatchRequest{}, Response: BatchResponse{}, NDJSON: BatchItemResult{}, Tenant: true, Errors: []int{413},
+		Query: []openapi.Parameter{{Name: "stream", In: "query", Description: "Stream results as NDJSON lines in completion order", Schema: &openapi.Schema{Type: "boolean"}}}},
+
+	{Method: "POST", Path: "/jobs/ask", Tag: "jobs", Summary: "Queue an ask as a background job", Request: AskJobRequest{}, Response: jobs.Job{}, Status: 202, Idempotent: true, Errors: []int{503}},
+	{Method: "GET", Path: "/jobs/:id", Tag: "jobs", Summary: "Get a job", Response: jobs.Job{}, Errors: []int{404}},
+
+	{Method: "GET", Path: "/prompts", Tag: "prompts", Summary: "List prompt templates", Response: []prompts.Template{}, JSONOnly: true},
+	{Method: "POST", Path: "/prompts/:name", Tag: "prompts", Summary: "Run a prompt template", Request: RunPromptRequest{}, Response: RunPromptResponse{}, Idempotent: true, Errors: []int{404, 503}},
+
+	{Method: "GET", Path: "/ws/chat", Tag: "chat", Summary: "Chat over WebSocket: ChatClientMessage frames in, ChatServerMessage frames out", Status: http.StatusSwitchingProtocols, Tenant: true, Bearer: true, Errors: []int{401, 403, 426, 501},
+		Messages: []any{ChatClientMessage{}, ChatServerMessage{}}},
+
+	{Method: "GET", Path: "/health", Tag: "ops", Summary: "Liveness check", Errors: []int{503}},
+	{Method: "GET", Path: "/ready", Tag: "ops", Summary: "Readiness check, fails while starting and draining", Errors: []int{503}},
+	{Method: "GET", Path: "/openapi.json", Tag: "ops", Summary: "This document", ResponseType: mimeJSON},
+	{Method: "GET", Path: "/schema.proto", Tag: "ops", Summary: "Protobuf messages of the application/x-protobuf bodies", ResponseType: "text/plain"},
+	{Method: "GET", Path: "/docs", Tag: "ops", Summary: "API reference UI", ResponseType: "text/html"},
+	{Method: "GET", Path: "/docs/redoc.standalone.js", Tag: "ops", Summary: "Redoc bundle of the API reference UI", ResponseType: fiber.MIMEApplicationJavaScript, Errors: []int{404}},
+}
+
+// AskUploadForm documents the multipart body of /ask/upload
+type AskUploadForm struct {
+	Question      string   `json:"question"`
+	System        string   `json:"system,omitempty"`
+	Model         string   `json:"model,omitempty"`
+	MaxTokens     int      `json:"max_tokens,omitempty"`
+	Temperature   float64  `json:"temperature,omitempty"`
+	TopP          float64  `json:"top_p,omitempty"`
+	TopK          int      `json:"top_k,omitempty"`
+	StopSequences []string `json:"stop_sequences,omitempty"`
+	UserID        string   `json:"user_id,omitempty"`
+	Files         [][]byte `json:"files,omitempty" doc:"Images or documents, the media type is sniffed"`
+}
+
+// OpenAPI describes the routes InitApp registers. It fails when a route is
+// registered but not documented in apiOperations, or the other way round.
+func OpenAPI() (*openapi.Document, error) {
+	app := InitApp(&fiberTransport{
+		Idempotency: func(c *fiber.Ctx) error { return c.Next() },
+	})
+	return newOpenAPI(app)
+}
+
+func newOpenAPI(app *fiber.App) (*openapi.Document, error) {
+	if err := checkRoutes(app); err != nil {
+		return nil, err
+	}
+
+	r := openapi.NewReflector()
+	doc := &openapi.Document{
+		OpenAPI: openapi.Version,
+		Info: openapi.Info{
+			Title:       "String service",
+			Version:     APIVersion,
+			Description: "Text operations and Claude backed question answering. Bodies can be JSON, MessagePack, CBOR or protobuf (see /schema.proto), errors are application/problem+json.",
+		},
+		Paths: make(map[string]openapi.PathItem),
+	}
+	errorSchema := r.Schema(problem.Problem{})
+	validationErrorSchema := r.Schema(ValidationProblem{})
+
+	for _, op := range apiOperations {
+		operation := &openapi.Operation{
+			OperationID: operationID(op.Method, op.Path),
+			Summary:     op.Summary,
+			Tags:        []string{op.Tag},
+			Parameters:  append(pathParameters(op.Path), op.Query...),
+			Responses:   make(map[string]*openapi.Response),
+		}
+		if op.Idempotent {
+			operation.Parameters = append(operation.Parameters, openapi.Parameter{
+				Name:        idempotency.HeaderKey,
+				In:          "header",
+				Description: "Retries with the same key replay the first response",
+				Schema:      &openapi.Schema{Type: "string"},
+			})
+		}
+		// Each requirement is an alternative, the empty one makes the tenant optional
+		switch {
+		case op.Bearer && op.Tenant:
+			operation.Security = []openapi.SecurityRequirement{
+				{securityBearer: {}, securityTenant: {}},
+				{securityAccessToken: {}, securityTenant: {}},
+				{securityBearer: {}},
+				{securityAccessToken: {}},
+			}
+		case op.Bearer:
+			operation.Security = []openapi.SecurityRequirement{{securityBearer: {}}, {securityAccessToken: {}}}
+		case op.Tenant:
+			operation.Security = []openapi.SecurityRequirement{{securityTenant: {}}, {}}
+		}
+
+		if op.Request != nil {
+			schema := r.Schema(op.Request)
+			content := map[string]openapi.MediaType{op.RequestType: {Schema: schema}}
+			if op.RequestType == "" {
+				content = negotiated(schema)
+			}
+			operation.RequestBody = &openapi.RequestBody{Required: true, Content: content}
+		}
+
+		status := op.Status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		success := &openapi.Response{Description: http.StatusText(status)}
+		switch {
+		case op.Response != nil && op.JSONOnly:
+			success.Content = map[string]openapi.MediaType{mimeJSON: {Schema: r.Schema(op.Response)}}
+		case op.Response != nil:
+			success.Content = negotiated(r.Schema(op.Response))
+		case op.ResponseType != "":
+			success.Content = map[string]openapi.MediaType{op.ResponseType: {}}
+		}
+		if op.NDJSON != nil {
+			success.Content[mimeNDJSON] = openapi.MediaType{Schema: r.Schema(op.NDJSON)}
+		}
+		if op.Idempotent {
+			success.Headers = map[string]openapi.Header{
+				idempotency.HeaderReplayed: {Description: "Set when the response is a replay", Schema: &openapi.Schema{Type: "boolean"}},
+			}
+		}
+		operation.Responses[strconv.Itoa(status)] = success
+
+		codes := []int{http.StatusInternalServerError}
+		if op.Request != nil || strings.Contains(op.Path, ":") {
+			codes = append(codes, http.StatusBadRequest)
+		}
+		if op.Request != nil && op.RequestType == "" {
+			codes = append(codes, http.StatusUnsupportedMediaType)
+		}
+		if op.Response != nil && !op.JSONOnly {
+			codes = append(codes, http.StatusNotAcceptable)
+		}
+		if op.Idempotent {
+			codes = append(codes, http.StatusConflict, http.StatusTooEarly)
+		}
+		for _, code := range append(codes, op.Errors...) {
+			operation.Responses[strconv.Itoa(code)] = &openapi.Response{
+				Description: http.StatusText(code),
+				Content:     map[string]openapi.MediaType{problem.ContentType: {Schema: errorSchema}},
+			}
+		}
+		if op.Request != nil {
+			operation.Responses[strconv.Itoa(http.StatusUnprocessableEntity)] = &openapi.Response{
+				Description: "The request failed validation",
+				Content:     map[string]openapi.MediaType{problem.ContentType: {Schema: validationErrorSchema}},
+			}
+		}
+
+		for _, msg := range op.Messages {
+			r.Schema(msg)
+		}
+
+		path := openAPIPath(op.Path)
+		if doc.Paths[path] == nil {
+			doc.Paths[path] = make(openapi.PathItem)
+		}
+		doc.Paths[path][strings.ToLower(op.Method)] = operation
+	}
+
+	doc.Components.Schemas = r.Schemas
+	doc.Components.SecuritySchemes = securitySchemes
+	return doc, nil
+}
+
+const (
+	securityTenant      = "tenant"
+	securityBearer      = "bearer"
+	securityAccessToken = "accessToken"
+)
+
+var securitySchemes = map[string]*openapi.SecurityScheme{
+	securityTenant: {
+		Type:        "apiKey",
+		In:          "header",
+		Name:        HeaderTenant,
+		Description: "Calling tenant, used for model routing",
+	},
+	securityBearer: {
+		Type:        "http",
+		Scheme:      "bearer",
+		Description: "One of the chat access tokens of the config",
+	},
+	securityAccessToken: {
+		Type:        "apiKey",
+		In:          "query",
+		Name:        "access_token",
+		Description: "The bearer token for browsers, which can't set headers on WebSocket requests",
+	},
+}
+
+// negotiated lists schema under every media type of codec.Default
+func negotiated(schema *openapi.Schema) map[string]openapi.MediaType {
+	content := make(map[string]openapi.MediaType)
+	for _, mediaType := range codec.Default.MediaTypes() {
+		content[mediaType] = openapi.MediaType{Schema: schema}
+	}
+	return content
+}
+
+// checkRoutes compares the routes of app with apiOperations
+func checkRoutes(app *fiber.App) error {
+	documented := make(map[string]bool, len(apiOperations))
+	for _, op := range apiOperations {
+		documented[op.Method+" "+op.Path] = true
+	}
+
+	var undocumented []string
+	for _, route := range app.GetRoutes(true) {
+		// Fiber adds a HEAD route for every GET
+		if route.Method == fiber.MethodHead {
+			continue
+		}
+		key := route.Method + " " + route.Path
+		if !documented[key] {
+			undocumented = append(undocumented, key)
+		}
+		delete(documented, key)
+	}
+
+	var missing []string
+	for key := range documented {
+		missing = append(missing, key)
+	}
+	sort.Strings(undocumented)
+	sort.Strings(missing)
+
+	switch {
+	case len(undocumented) > 0:
+		return fmt.Errorf("openapi: routes without documentation: %s", strings.Join(undocumented, ", "))
+	case len(missing) > 0:
+		return fmt.Errorf("openapi: documented routes that don't exist: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// openAPIPath converts /jobs/:id to /jobs/{id}
+func openAPIPath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, s := range segments {
+		if strings.HasPrefix(s, ":") {
+			segments[i] = "{" + s[1:] + "}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+func pathParameters(path string) []openapi.Parameter {
+	var params []openapi.Parameter
+	for _, s := range strings.Split(path, "/") {
+		if strings.HasPrefix(s, ":") {
+			params = append(params, openapi.Parameter{
+				Name:     s[1:],
+				In:       "path",
+				Required: true,
+				Schema:   &openapi.Schema{Type: "string"},
+			})
+		}
+	}
+	return params
+}
+
+// operationID turns POST /ask/count_tokens into postAskCountTokens
+func operationID(method, path string) string {
+	id := strings.ToLower(method)
+	for _, word := range strings.FieldsFunc(path, func(r rune) bool {
+		return r == '/' || r == '_' || r == ':' || r == '.'
+	}) {
+		id += strings.ToUpper(word[:1]) + word[1:]
+	}
+	return id
+}
+
+var (
+	specOnce sync.Once
+	spec     *openapi.Document
+	specErr  error
+)
+
+// HandleOpenAPI serves the OpenAPI document
+func (t *fiberTransport) HandleOpenAPI(c *fiber.Ctx) error {
+	specOnce.Do(func() { spec, specErr = OpenAPI() })
+	if specErr != nil {
+		return specErr
+	}
+	return c.JSON(spec)
+}
+
+// redocBundle is the file of the Redoc release in docs, fetched by go
+// generate and committed with the page
+const redocBundle = "redoc.standalone.js"
+
+//go:generate curl -fsSL -o docs/redoc.standalone.js https://cdn.redoc.ly/redoc/v2.1.5/bundles/redoc.standalone.js
+
+//go:embed docs
+var docsFS embed.FS
+
+// HandleProtoSchema serves the .proto file of the messages exchanged as
+// application/x-protobuf
+func (t *fiberTransport) HandleProtoSchema(c *fiber.Ctx) error {
+	var values []any
+	for _, op := range apiOperations {
+		if op.Request != nil && op.RequestType == "" {
+			values = append(values, op.Request)
+		}
+		if op.Response != nil && !op.JSONOnly {
+			values = append(values, op.Response)
+		}
+	}
+	schema, err := codec.ProtoSchema(values...)
+	if err != nil {
+		return err
+	}
+	c.Set(fiber.HeaderContentType, fiber.MIMETextPlainCharsetUTF8)
+	return c.SendString(schema)
+}
+
+// HandleDocs serves the API reference UI for /openapi.json
+func (t *fiberTransport) HandleDocs(c *fiber.Ctx) error {
+	page, err := docsFS.ReadFile("docs/index.html")
+	if err != nil {
+		return err
+	}
+	c.Set(fiber.HeaderContentType, fiber.MIMETextHTMLCharsetUTF8)
+	return c.Send(page)
+}
+
+// HandleDocsBundle serves the Redoc bundle the UI runs, so that the docs
+// work without reaching a CDN
+func (t *fiberTransport) HandleDocsBundle(c *fiber.Ctx) error {
+	bundle, err := docsFS.ReadFile("docs/" + redocBundle)
+	if err != nil {
+		return problem.Errorf(problem.CodeNotFound, "the Redoc bundle wasn't built in, run go generate ./transport")
+	}
+	c.Set(fiber.HeaderContentType, fiber.MIMEApplicationJavaScriptCharsetUTF8)
+	c.Set(fiber.HeaderCacheControl, "public, max-age=86400")
+	return c.Send(bundle)
+}
@@ -0,0 +1,52 @@
+package transport
+
+import "testing"
+
+func TestOpenAPI(t *testing.T) {
+	doc, err := OpenAPI()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Every scheme an operation requires must be declared
+	for path, item := range doc.Paths {
+		for method, op := range item {
+			for _, requirement := range op.Security {
+				for name := range requirement {
+					if doc.Components.SecuritySchemes[name] == nil {
+						t.Errorf("%s %s requires the undeclared scheme %q", method, path, name)
+					}
+				}
+			}
+		}
+	}
+
+	tests := []struct {
+		path, method string
+		wantSchemes  []string
+	}{
+		{"/ask", "post", []string{securityTenant}},
+		{"/ws/chat", "get", []string{securityBearer, securityAccessToken, securityTenant}},
+		{"/uppercase", "post", nil},
+	}
+	for _, tt := range tests {
+		op := doc.Paths[tt.path][tt.method]
+		if op == nil {
+			t.Fatalf("%s %s isn't documented", tt.method, tt.path)
+		}
+		got := make(map[string]bool)
+		for _, requirement := range op.Security {
+			for name := range requirement {
+				got[name] = true
+			}
+		}
+		if len(got) != len(tt.wantSchemes) {
+			t.Errorf("%s %s uses schemes %v, want %v", tt.method, tt.path, got, tt.wantSchemes)
+		}
+		for _, name := range tt.wantSchemes {
+			if !got[name] {
+				t.Errorf("%s %s doesn't use %q", tt.method, tt.path, name)
+			}
+		}
+	}
+}
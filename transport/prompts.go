@@ -0,0 +1,72 @@
+package transport
+
+import (
+	"fmt"
+
+	"github.com/gofiber/fiber/v2"
+
+	"kit-fiber-example/validation"
+)
+
+type RunPromptRequest struct {
+	Variables map[string]any `json:"variables,omitempty" validate:"max=100"`
+	UserID    string         `json:"user_id,omitempty" validate:"max=256"`
+}
+
+type RunPromptResponse struct {
+	Template string `json:"template"`
+	Version  string `json:"version"`
+	AskClaudeResponse
+}
+
+// HandleListPrompts lists the loaded templates with their variables
+func (t *fiberTransport) HandleListPrompts(c *fiber.Ctx) error {
+	return c.JSON(t.Prompts.List())
+}
+
+// HandleRunPrompt renders the named template and asks Claude with it
+func (t *fiberTransport) HandleRunPrompt(c *fiber.Ctx) error {
+	tmpl, err := t.Prompts.Get(c.Params("name"))
+	if err != nil {
+		return err
+	}
+
+	var req RunPromptRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+	if err := validation.Struct(req); err != nil {
+		return err
+	}
+
+	rendered, err := tmpl.Render(req.Variables)
+	if err != nil {
+		return err
+	}
+
+	response, err := t.AskClaude(c.UserContext(), AskClaudeRequest{
+		Question:    rendered.Prompt,
+		System:      rendered.System,
+		Model:       rendered.Model,
+		MaxTokens:   rendered.MaxTokens,
+		Temperature: rendered.Temperature,
+		UserID:      req.UserID,
+		Tenant:      c.Get(HeaderTenant),
+		Template:    tmpl.Name,
+	})
+	t.Metrics.PromptRequests.With("template", tmpl.Name, "version", tmpl.Version, "error", fmt.Sprint(err != nil)).Add(1)
+	if err != nil {
+		return err
+	}
+	if response.Usage != nil {
+		tokens := t.Metrics.PromptTokens.With("template", tmpl.Name, "version", tmpl.Version)
+		tokens.With("direction", "input").Add(float64(response.Usage.InputTokens))
+		tokens.With("direction", "output").Add(float64(response.Usage.OutputTokens))
+	}
+
+	return respond(c, RunPromptResponse{
+		Template:          tmpl.Name,
+		Version:           tmpl.Version,
+		AskClaudeResponse: response,
+	})
+}
@@ -0,0 +1,119 @@
+package transport
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/gofiber/fiber/v2"
+
+	"kit-fiber-example/middlewares"
+	"kit-fiber-example/validation"
+)
+
+// AskClaudeStreamRequest is an AskClaudeRequest whose answer is delivered
+// piece by piece to OnDelta before the endpoint returns.
+type AskClaudeStreamRequest struct {
+	AskClaudeRequest
+	OnDelta func(string) error `json:"-"`
+}
+
+type CountTokensResponse struct {
+	InputTokens int `json:"input_tokens"`
+}
+
+func makeAskClaudeStreamEndpoint(svc StringService) middlewares.Endpoint[AskClaudeStreamRequest, AskClaudeResponse] {
+	return func(ctx context.Context, req AskClaudeStreamRequest) (AskClaudeResponse, error) {
+		resp, err := svc.AskClaudeStream(ctx, req.toService(), req.OnDelta)
+		if err != nil {
+			return AskClaudeResponse{}, err
+		}
+		return newAskClaudeResponse(resp), nil
+	}
+}
+
+func makeCountTokensEndpoint(svc StringService) middlewares.Endpoint[AskClaudeRequest, CountTokensResponse] {
+	return func(ctx context.Context, req AskClaudeRequest) (CountTokensResponse, error) {
+		n, err := svc.CountTokens(ctx, req.toService())
+		if err != nil {
+			return CountTokensResponse{}, err
+		}
+		return CountTokensResponse{InputTokens: n}, nil
+	}
+}
+
+// HandleAskClaudeStream answers with server-sent events: a "delta" event per
+// chunk of the answer and a final "done" event with the full response, or
+// an "error" event with a problem document.
+func (t *fiberTransport) HandleAskClaudeStream(c *fiber.Ctx) error {
+	var req AskClaudeStreamRequest
+	if err := parseBody(c, &req.AskClaudeRequest); err != nil {
+		return err
+	}
+	// Errors after this point can only be sent as events
+	if err := validation.Struct(req); err != nil {
+		return err
+	}
+	req.Tenant = c.Get(HeaderTenant)
+
+	info := newRequestInfo(c)
+
+	ctx, done, err := t.trackStream(c)
+	if err != nil {
+		return err
+	}
+
+	c.Set(fiber.HeaderContentType, "text/event-stream")
+	c.Set(fiber.HeaderCacheControl, "no-cache")
+	c.Set(fiber.HeaderConnection, "keep-alive")
+
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer done()
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		req.OnDelta = func(text string) error {
+			if err := writeEvent(w, "delta", fiber.Map{"text": text}); err != nil {
+				// The client went away
+				cancel()
+				return err
+			}
+			return nil
+		}
+
+		response, err := t.AskClaudeStream(ctx, req)
+		if err != nil {
+			writeEvent(w, "error", info.problem(abortCause(ctx, err)))
+			return
+		}
+		writeEvent(w, "done", response)
+	})
+	return nil
+}
+
+// HandleCountTokens returns the number of input tokens an ask would use
+func (t *fiberTransport) HandleCountTokens(c *fiber.Ctx) error {
+	var req AskClaudeRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	response, err := t.CountTokens(c.UserContext(), req)
+	if err != nil {
+		return err
+	}
+
+	return respond(c, response)
+}
+
+func writeEvent(w *bufio.Writer, event string, data any) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, payload); err != nil {
+		return err
+	}
+	return w.Flush()
+}
@@ -13,7 +13,8 @@ import (
 
 // Transport extension
 type UppercaseRequest struct {
-	S string `json:"string"`
+	S    string `json:"string" validate:"required,max=100000" example:"hello"`
+	Lang string `json:"lang,omitempty" validate:"max=35" doc:"BCP-47 tag for language specific case rules" example:"tr"`
 }
 
 func decodeUppercaseRequest(_ context.Context, r *http.Request) (interface{}, error) {
@@ -27,17 +28,17 @@ func decodeUppercaseRequest(_ context.Context, r *http.Request) (interface{}, er
 func (r UppercaseRequest) TraceAttributes() []attribute.KeyValue {
 	return []attribute.KeyValue{
 		attribute.String("input", r.S),
+		attribute.String("lang", r.Lang),
 	}
 }
 
 type UppercaseResponse struct {
-	V   string `json:"result"`
-	Err string `json:"error,omitempty"`
+	V string `json:"result" example:"HELLO"`
 }
 
 func decodeUppercaseResponse(_ context.Context, r *http.Response) (interface{}, error) {
 	var response UppercaseResponse
-	if err := json.NewDecoder(r.Body).Decode(&response); err != nil {
+	if err := DecodeResponse(r, &response); err != nil {
 		return nil, err
 	}
 	return response, nil
@@ -45,29 +46,25 @@ func decodeUppercaseResponse(_ context.Context, r *http.Response) (interface{},
 
 func makeUppercaseEndpoint(svc StringService) middlewares.Endpoint[UppercaseRequest, UppercaseResponse] {
 	return func(_ context.Context, req UppercaseRequest) (UppercaseResponse, error) {
-		v, err := svc.Uppercase(req.S)
+		v, err := svc.Uppercase(req.S, req.Lang)
 		if err != nil {
-			return UppercaseResponse{v, err.Error()}, nil
+			return UppercaseResponse{}, err
 		}
-		return UppercaseResponse{v, ""}, nil
+		return UppercaseResponse{v}, nil
 	}
 }
 
 // HandleUppercase is the Fiber handler for the uppercase endpoint
 func (t *fiberTransport) HandleUppercase(c *fiber.Ctx) error {
 	var req UppercaseRequest
-	if err := c.BodyParser(&req); err != nil {
-		return c.Status(400).JSON(fiber.Map{
-			"error": "Invalid request body",
-		})
+	if err := parseBody(c, &req); err != nil {
+		return err
 	}
 
-	response, err := t.Uppercase(c.Context(), req)
+	response, err := t.Uppercase(c.UserContext(), req)
 	if err != nil {
-		return c.Status(500).JSON(fiber.Map{
-			"error": err.Error(),
-		})
+		return err
 	}
 
-	return c.JSON(response)
+	return respond(c, response)
 }
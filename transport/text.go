@@ -0,0 +1,161 @@
+package transport
+
+import (
+	"context"
+
+	"github.com/gofiber/fiber/v2"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"kit-fiber-example/concurrency"
+	"kit-fiber-example/metrics"
+	"kit-fiber-example/middlewares"
+	"kit-fiber-example/service"
+)
+
+// TextRequest is the input of the plain text operations: count, reverse,
+// slugify and transliterate
+type TextRequest struct {
+	S string `json:"string" validate:"required,max=100000"`
+}
+
+func (r TextRequest) TraceAttributes() []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.String("input", r.S),
+	}
+}
+
+type TextResponse struct {
+	V string `json:"result"`
+}
+
+// CaseRequest is the input of lowercase and title
+type CaseRequest struct {
+	S    string `json:"string" validate:"required,max=100000"`
+	Lang string `json:"lang,omitempty" validate:"max=35" doc:"BCP-47 tag for language specific case rules" example:"tr"`
+}
+
+func (r CaseRequest) TraceAttributes() []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.String("input", r.S),
+		attribute.String("lang", r.Lang),
+	}
+}
+
+type CountResponse struct {
+	Bytes     int `json:"bytes"`
+	Runes     int `json:"runes"`
+	Words     int `json:"words"`
+	Graphemes int `json:"graphemes"`
+}
+
+type NormalizeRequest struct {
+	S    string `json:"string" validate:"required,max=100000"`
+	Form string `json:"form,omitempty" validate:"enum=NFC|NFD|NFKC|NFKD" doc:"NFC by default"`
+}
+
+func (r NormalizeRequest) TraceAttributes() []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.String("input", r.S),
+		attribute.String("form", r.Form),
+	}
+}
+
+type TrimRequest struct {
+	S        string `json:"string" validate:"required,max=100000"`
+	Collapse bool   `json:"collapse,omitempty"`
+}
+
+func (r TrimRequest) TraceAttributes() []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.String("input", r.S),
+		attribute.Bool("collapse", r.Collapse),
+	}
+}
+
+func makeTextEndpoint(op func(string) (string, error)) middlewares.Endpoint[TextRequest, TextResponse] {
+	return func(_ context.Context, req TextRequest) (TextResponse, error) {
+		v, err := op(req.S)
+		if err != nil {
+			return TextResponse{}, err
+		}
+		return TextResponse{v}, nil
+	}
+}
+
+func makeCaseEndpoint(op func(s, lang string) (string, error)) middlewares.Endpoint[CaseRequest, TextResponse] {
+	return func(_ context.Context, req CaseRequest) (TextResponse, error) {
+		v, err := op(req.S, req.Lang)
+		if err != nil {
+			return TextResponse{}, err
+		}
+		return TextResponse{v}, nil
+	}
+}
+
+func makeCountEndpoint(svc StringService) middlewares.Endpoint[TextRequest, CountResponse] {
+	return func(_ context.Context, req TextRequest) (CountResponse, error) {
+		counts, err := svc.Count(req.S)
+		if err != nil {
+			return CountResponse{}, err
+		}
+		return newCountResponse(counts), nil
+	}
+}
+
+func newCountResponse(counts service.Counts) CountResponse {
+	return CountResponse{
+		Bytes:     counts.Bytes,
+		Runes:     counts.Runes,
+		Words:     counts.Words,
+		Graphemes: counts.Graphemes,
+	}
+}
+
+func makeNormalizeEndpoint(svc StringService) middlewares.Endpoint[NormalizeRequest, TextResponse] {
+	return func(_ context.Context, req NormalizeRequest) (TextResponse, error) {
+		v, err := svc.Normalize(req.S, req.Form)
+		if err != nil {
+			return TextResponse{}, err
+		}
+		return TextResponse{v}, nil
+	}
+}
+
+func makeTrimEndpoint(svc StringService) middlewares.Endpoint[TrimRequest, TextResponse] {
+	return func(_ context.Context, req TrimRequest) (TextResponse, error) {
+		v, err := svc.Trim(req.S, req.Collapse)
+		if err != nil {
+			return TextResponse{}, err
+		}
+		return TextResponse{v}, nil
+	}
+}
+
+// textEndpoint applies the middlewares shared by all text operations, name
+// labels the logs and metrics of the operation
+func textEndpoint[Req any, Res any](name string, m *metrics.Metrics, b *concurrency.Bulkhead, t trace.Tracer, endpoint middlewares.Endpoint[Req, Res]) middlewares.Endpoint[Req, Res] {
+	endpoint = middlewares.WithLogging(name, endpoint)
+	endpoint = middlewares.WithMetrics(name, m, endpoint)
+	endpoint = middlewares.WithConcurrencyLimit("text", b, m, endpoint)
+	endpoint = middlewares.WithValidation(endpoint)
+	endpoint = middlewares.WithTracing(t, endpoint)
+	return endpoint
+}
+
+// handleText is the Fiber handler for a text operation endpoint
+func handleText[Req any, Res any](endpoint middlewares.Endpoint[Req, Res]) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var req Req
+		if err := parseBody(c, &req); err != nil {
+			return err
+		}
+
+		response, err := endpoint(c.UserContext(), req)
+		if err != nil {
+			return err
+		}
+
+		return respond(c, response)
+	}
+}
@@ -0,0 +1,66 @@
+package transport
+
+import (
+	"context"
+	"errors"
+
+	"kit-fiber-example/metrics"
+	"kit-fiber-example/middlewares"
+	"kit-fiber-example/service"
+)
+
+// guardedRequest is an ask the context guard can check and truncate
+type guardedRequest[Req any] interface {
+	toService() service.AskRequest
+	withQuestion(question string) Req
+}
+
+// contextGuardMiddleware checks asks against the model context window before
+// they reach the service and exports estimated vs actual input tokens.
+func contextGuardMiddleware[Req guardedRequest[Req]](g *service.ContextGuard, m *metrics.Metrics) middlewares.Middleware[Req, AskClaudeResponse] {
+	return func(next middlewares.Endpoint[Req, AskClaudeResponse]) middlewares.Endpoint[Req, AskClaudeResponse] {
+		return func(ctx context.Context, req Req) (AskClaudeResponse, error) {
+			ask := req.toService()
+			model := g.Model(ask)
+
+			fitted, estimate, err := g.Fit(ctx, ask)
+			if err != nil {
+				var e service.ServiceError
+				if errors.As(err, &e) {
+					m.ContextGuardCount.With("model", model, "action", "rejected").Add(1)
+				}
+				return AskClaudeResponse{}, err
+			}
+			if fitted.Question != ask.Question {
+				m.ContextGuardCount.With("model", model, "action", "truncated").Add(1)
+				req = req.withQuestion(fitted.Question)
+			}
+
+			response, err := next(ctx, req)
+			if err == nil && response.Usage != nil {
+				m.InputTokens.With("model", model, "source", "estimated").Observe(float64(estimate))
+				m.InputTokens.With("model", model, "source", "actual").Observe(float64(response.Usage.InputTokens))
+			}
+			return response, err
+		}
+	}
+}
+
+// WithContextGuard runs an ask endpoint behind the context window guard.
+// A nil guard leaves the endpoint unchanged.
+func WithContextGuard[Req guardedRequest[Req]](g *service.ContextGuard, m *metrics.Metrics, endpoint middlewares.Endpoint[Req, AskClaudeResponse]) middlewares.Endpoint[Req, AskClaudeResponse] {
+	if g == nil {
+		return endpoint
+	}
+	return contextGuardMiddleware[Req](g, m)(endpoint)
+}
+
+func (r AskClaudeRequest) withQuestion(question string) AskClaudeRequest {
+	r.Question = question
+	return r
+}
+
+func (r AskClaudeStreamRequest) withQuestion(question string) AskClaudeStreamRequest {
+	r.Question = question
+	return r
+}
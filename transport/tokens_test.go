@@ -0,0 +1,135 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"kit-fiber-example/config"
+	"kit-fiber-example/metrics"
+	"kit-fiber-example/service"
+)
+
+// series records the adds and observations of a metric by label values
+type series map[string]float64
+
+type fakeCounter struct {
+	s      series
+	labels []string
+}
+
+func (c fakeCounter) With(lv ...string) metrics.Counter {
+	return fakeCounter{c.s, append(append([]string(nil), c.labels...), lv...)}
+}
+func (c fakeCounter) Add(d float64) { c.s[strings.Join(c.labels, ",")] += d }
+
+type fakeHistogram struct {
+	s      series
+	labels []string
+}
+
+func (h fakeHistogram) With(lv ...string) metrics.Histogram {
+	return fakeHistogram{h.s, append(append([]string(nil), h.labels...), lv...)}
+}
+func (h fakeHistogram) Observe(v float64) { h.s[strings.Join(h.labels, ",")] += v }
+
+func TestContextGuardMiddleware(t *testing.T) {
+	// 1000-500 = 500 input tokens, a question costs (bytes+3)/4 plus 10
+	cfg := &config.Config{}
+	cfg.Claude.Model = "m"
+	cfg.Claude.Defaults.MaxTokens = 500
+	cfg.Claude.Models = map[string]config.ModelLimits{"m": {ContextWindow: 1000}}
+
+	tests := []struct {
+		name     string
+		action   string
+		question string
+		// question seen by the endpoint, empty when it isn't called
+		passed  string
+		status  int
+		guarded series
+		tokens  series
+	}{
+		{
+			name: "fits", action: "reject", question: "hello",
+			passed:  "hello",
+			guarded: series{},
+			tokens:  series{"model,m,source,estimated": 12, "model,m,source,actual": 40},
+		},
+		{
+			name: "rejected", action: "reject", question: strings.Repeat("a", 1961),
+			status:  http.StatusRequestEntityTooLarge,
+			guarded: series{"model,m,action,rejected": 1},
+			tokens:  series{},
+		},
+		{
+			name: "truncated", action: "truncate", question: strings.Repeat("a", 1961),
+			passed:  strings.Repeat("a", 1957),
+			guarded: series{"model,m,action,truncated": 1},
+			tokens:  series{"model,m,source,estimated": 500, "model,m,source,actual": 40},
+		},
+		{
+			name: "truncated non-ASCII", action: "truncate", question: strings.Repeat("é", 1000),
+			// 1000 runes cost 750 tokens, 653 of them fit the 490 left
+			passed:  strings.Repeat("é", 653),
+			guarded: series{"model,m,action,truncated": 1},
+			tokens:  series{"model,m,source,estimated": 500, "model,m,source,actual": 40},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg.Claude.ContextGuard.Action = tt.action
+			g, err := service.NewContextGuardFromConfig(cfg, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			guarded, tokens := series{}, series{}
+			m := &metrics.Metrics{
+				ContextGuardCount: fakeCounter{s: guarded},
+				InputTokens:       fakeHistogram{s: tokens},
+			}
+
+			var passed string
+			endpoint := WithContextGuard(g, m, func(_ context.Context, req AskClaudeRequest) (AskClaudeResponse, error) {
+				passed = req.Question
+				return AskClaudeResponse{Usage: &Usage{InputTokens: 40}}, nil
+			})
+			_, err = endpoint(context.Background(), AskClaudeRequest{Question: tt.question})
+
+			var se service.ServiceError
+			switch {
+			case tt.status == 0 && err != nil:
+				t.Fatalf("endpoint error = %v", err)
+			case tt.status != 0 && (!errors.As(err, &se) || se.Code != tt.status):
+				t.Fatalf("endpoint error = %v, want status %d", err, tt.status)
+			}
+			if passed != tt.passed {
+				t.Errorf("endpoint got a %d byte question, want %d", len(passed), len(tt.passed))
+			}
+			for name, s := range map[string][2]series{"guard": {guarded, tt.guarded}, "tokens": {tokens, tt.tokens}} {
+				if got, want := s[0], s[1]; len(got) != len(want) {
+					t.Errorf("%s series = %v, want %v", name, got, want)
+				} else {
+					for k, v := range want {
+						if got[k] != v {
+							t.Errorf("%s series = %v, want %v", name, got, want)
+							break
+						}
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestWithContextGuardDisabled(t *testing.T) {
+	endpoint := WithContextGuard(nil, nil, func(_ context.Context, req AskClaudeRequest) (AskClaudeResponse, error) {
+		return AskClaudeResponse{Answer: req.Question}, nil
+	})
+	question := strings.Repeat("a", 1<<20)
+	if res, err := endpoint(context.Background(), AskClaudeRequest{Question: question}); err != nil || res.Answer != question {
+		t.Errorf("endpoint() = %d bytes, %v, want the question unchanged", len(res.Answer), err)
+	}
+}
@@ -0,0 +1,100 @@
+package transport
+
+import (
+	"io"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+
+	"kit-fiber-example/problem"
+	"kit-fiber-example/validation"
+)
+
+// HandleAskClaudeUpload is the multipart variant of HandleAskClaude. The
+// question and parameters come as form fields, attachments as "files".
+func (t *fiberTransport) HandleAskClaudeUpload(c *fiber.Ctx) error {
+	form, err := c.MultipartForm()
+	if err != nil {
+		return problem.Errorf(problem.CodeBadRequest, "invalid multipart body: %w", err)
+	}
+
+	req := AskClaudeRequest{
+		Question:      formValue(form.Value, "question"),
+		System:        formValue(form.Value, "system"),
+		Model:         formValue(form.Value, "model"),
+		StopSequences: form.Value["stop_sequences"],
+		UserID:        formValue(form.Value, "user_id"),
+		Tenant:        c.Get(HeaderTenant),
+	}
+	if req.MaxTokens, err = formInt(form.Value, "max_tokens"); err != nil {
+		return invalidField("max_tokens", "an integer")
+	}
+	if req.Temperature, err = formFloat(form.Value, "temperature"); err != nil {
+		return invalidField("temperature", "a number")
+	}
+	if req.TopP, err = formFloat(form.Value, "top_p"); err != nil {
+		return invalidField("top_p", "a number")
+	}
+	if topK, err := formInt(form.Value, "top_k"); err != nil {
+		return invalidField("top_k", "an integer")
+	} else if topK != 0 {
+		req.TopK = &topK
+	}
+
+	for _, fh := range form.File["files"] {
+		f, err := fh.Open()
+		if err != nil {
+			return err
+		}
+		data, err := io.ReadAll(f)
+		f.Close()
+		if err != nil {
+			return err
+		}
+		// The media type is sniffed by the service, the declared one is ignored
+		req.Attachments = append(req.Attachments, Attachment{
+			Name: fh.Filename,
+			Data: data,
+		})
+	}
+
+	response, err := t.AskClaude(c.UserContext(), req)
+	if err != nil {
+		return err
+	}
+
+	return respond(c, response)
+}
+
+// invalidField reports a form value that doesn't parse, like DecodeJSON
+// does for JSON bodies
+func invalidField(name, want string) error {
+	return validation.Errors{{Field: name, Code: validation.CodeInvalidType, Message: "must be " + want}}
+}
+
+func formValue(values map[string][]string, key string) string {
+	if v := values[key]; len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
+
+func formInt(values map[string][]string, key string) (int, error) {
+	v := formValue(values, key)
+	if v == "" {
+		return 0, nil
+	}
+	return strconv.Atoi(v)
+}
+
+func formFloat(values map[string][]string, key string) (*float64, error) {
+	v := formValue(values, key)
+	if v == "" {
+		return nil, nil
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
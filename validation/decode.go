@@ -0,0 +1,180 @@
+package validation
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// ErrMalformed is returned for bodies that can't be parsed at all
+var ErrMalformed = errors.New("malformed body")
+
+// DecodeJSON decodes data into v strictly: unknown fields and values of the
+// wrong type are reported as Errors, trailing data is malformed.
+func DecodeJSON(data []byte, v any) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+
+	err := dec.Decode(v)
+	if err == nil {
+		if _, err := dec.Token(); err != io.EOF {
+			return fmt.Errorf("%w: unexpected data after the body", ErrMalformed)
+		}
+		return nil
+	}
+
+	var (
+		typeErr   *json.UnmarshalTypeError
+		syntaxErr *json.SyntaxError
+	)
+	switch {
+	case errors.As(err, &typeErr):
+		return Errors{{
+			Field:   typeErr.Field,
+			Code:    CodeInvalidType,
+			Message: fmt.Sprintf("must be %s, not %s", jsonType(typeErr.Type.Kind().String()), typeErr.Value),
+		}}
+	case errors.As(err, &syntaxErr), errors.Is(err, io.ErrUnexpectedEOF), errors.Is(err, io.EOF):
+		return fmt.Errorf("%w: %v", ErrMalformed, err)
+	}
+	// encoding/json has no error type for unknown fields, so they are
+	// looked up in the body instead
+	if field, ok := unknownField(data, reflect.TypeOf(v)); ok {
+		return Errors{{
+			Field:   field,
+			Code:    CodeUnknownField,
+			Message: "is not a known field",
+		}}
+	}
+	return fmt.Errorf("%w: %v", ErrMalformed, err)
+}
+
+var unmarshalerType = reflect.TypeFor[json.Unmarshaler]()
+
+// unknownField returns the path of the first field of the JSON data that t
+// has no field for.
+func unknownField(data []byte, t reflect.Type) (string, bool) {
+	var value any
+	if json.Unmarshal(data, &value) != nil {
+		return "", false
+	}
+	return findUnknown(value, t, "")
+}
+
+func findUnknown(value any, t reflect.Type, path string) (string, bool) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	// Types decoding themselves accept what they like
+	if t.Implements(unmarshalerType) || reflect.PointerTo(t).Implements(unmarshalerType) {
+		return "", false
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		object, ok := value.(map[string]any)
+		if !ok {
+			return "", false
+		}
+		fields := jsonFields(t)
+		keys := make([]string, 0, len(object))
+		for key := range object {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			ft, ok := lookupField(fields, key)
+			if !ok {
+				return join(path, key), true
+			}
+			if field, ok := findUnknown(object[key], ft, join(path, key)); ok {
+				return field, true
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		items, _ := value.([]any)
+		for i, item := range items {
+			if field, ok := findUnknown(item, t.Elem(), fmt.Sprintf("%s[%d]", path, i)); ok {
+				return field, true
+			}
+		}
+	case reflect.Map:
+		object, _ := value.(map[string]any)
+		for key, item := range object {
+			if field, ok := findUnknown(item, t.Elem(), join(path, key)); ok {
+				return field, true
+			}
+		}
+	}
+	return "", false
+}
+
+// jsonFields maps the JSON names of the fields of t, including those of
+// embedded structs, to their types
+func jsonFields(t reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if f.Anonymous && name == "" {
+			embedded := f.Type
+			if embedded.Kind() == reflect.Pointer {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				for name, ft := range jsonFields(embedded) {
+					if _, ok := fields[name]; !ok {
+						fields[name] = ft
+					}
+				}
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fields[name] = f.Type
+	}
+	return fields
+}
+
+// lookupField matches key like encoding/json: exactly, or else ignoring case
+func lookupField(fields map[string]reflect.Type, key string) (reflect.Type, bool) {
+	if ft, ok := fields[key]; ok {
+		return ft, true
+	}
+	for name, ft := range fields {
+		if strings.EqualFold(name, key) {
+			return ft, true
+		}
+	}
+	return nil, false
+}
+
+// jsonType names Go kinds the way a JSON client knows them
+func jsonType(kind string) string {
+	switch {
+	case strings.HasPrefix(kind, "int"), strings.HasPrefix(kind, "uint"):
+		return "an integer"
+	case strings.HasPrefix(kind, "float"):
+		return "a number"
+	case kind == "string":
+		return "a string"
+	case kind == "bool":
+		return "a boolean"
+	case kind == "slice", kind == "array":
+		return "an array"
+	}
+	return "an object"
+}
@@ -0,0 +1,62 @@
+package validation
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+type decodeTarget struct {
+	base
+	Name    string            `json:"name"`
+	Count   int               `json:"count"`
+	Address *address          `json:"address"`
+	Items   []address         `json:"items"`
+	Labels  map[string]string `json:"labels"`
+	Raw     json.RawMessage   `json:"raw"`
+	Skipped string            `json:"-"`
+}
+
+func TestDecodeJSON(t *testing.T) {
+	tests := []struct {
+		name          string
+		data          string
+		wantField     string
+		wantCode      string
+		wantMalformed bool
+	}{
+		{name: "valid", data: `{"name":"a","count":1,"id":"x","labels":{"k":"v"},"raw":{"any":1}}`},
+		{name: "case-insensitive names", data: `{"NAME":"a"}`},
+		{name: "unknown field", data: `{"name":"a","nope":1}`, wantField: "nope", wantCode: CodeUnknownField},
+		{name: "unknown nested field", data: `{"address":{"city":"x","zip":"1"}}`, wantField: "address.zip", wantCode: CodeUnknownField},
+		{name: "unknown field in a slice", data: `{"items":[{"city":"x"},{"town":"y"}]}`, wantField: "items[1].town", wantCode: CodeUnknownField},
+		{name: "ignored field", data: `{"Skipped":"x"}`, wantField: "Skipped", wantCode: CodeUnknownField},
+		{name: "wrong type", data: `{"count":"1"}`, wantField: "count", wantCode: CodeInvalidType},
+		{name: "syntax error", data: `{"name":`, wantMalformed: true},
+		{name: "not json", data: `name=a`, wantMalformed: true},
+		{name: "empty", data: ``, wantMalformed: true},
+		{name: "trailing data", data: `{"name":"a"} {}`, wantMalformed: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var v decodeTarget
+			err := DecodeJSON([]byte(tt.data), &v)
+			switch {
+			case tt.wantMalformed:
+				if !errors.Is(err, ErrMalformed) {
+					t.Errorf("err = %v, want ErrMalformed", err)
+				}
+			case tt.wantCode != "":
+				var errs Errors
+				if !errors.As(err, &errs) || len(errs) != 1 {
+					t.Fatalf("err = %v, want one field error", err)
+				}
+				if errs[0].Field != tt.wantField || errs[0].Code != tt.wantCode {
+					t.Errorf("error = %s:%s, want %s:%s", errs[0].Field, errs[0].Code, tt.wantField, tt.wantCode)
+				}
+			case err != nil:
+				t.Errorf("err = %v", err)
+			}
+		})
+	}
+}
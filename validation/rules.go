@@ -0,0 +1,126 @@
+package validation
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// rule is a parsed rule of a validate tag
+type rule struct {
+	name    string
+	limit   float64  // max and min
+	allowed []string // enum
+}
+
+// fieldRules are the rules of one struct field
+type fieldRules struct {
+	index    int
+	name     string // json name
+	embedded bool
+	rules    []rule
+}
+
+// rulesCache holds the []fieldRules of every struct type seen so far
+var rulesCache sync.Map
+
+// Compile parses the validate tags of t and of every type it contains, and
+// reports the first malformed one. Call it when registering a type, so that
+// broken tags fail at startup rather than on the first request.
+func Compile(t reflect.Type) error {
+	return compile(t, make(map[reflect.Type]bool))
+}
+
+func compile(t reflect.Type, seen map[reflect.Type]bool) error {
+	if t == nil {
+		return nil
+	}
+	for t.Kind() == reflect.Pointer || t.Kind() == reflect.Slice || t.Kind() == reflect.Array || t.Kind() == reflect.Map {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || seen[t] {
+		return nil
+	}
+	seen[t] = true
+
+	fields, err := structRules(t)
+	if err != nil {
+		return err
+	}
+	for _, f := range fields {
+		if err := compile(t.Field(f.index).Type, seen); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// structRules returns the parsed rules of the fields of the struct type t
+func structRules(t reflect.Type) ([]fieldRules, error) {
+	if cached, ok := rulesCache.Load(t); ok {
+		return cached.([]fieldRules), nil
+	}
+
+	var fields []fieldRules
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		// Embedded structs count even when their type is unexported, like in JSON
+		if f.Anonymous && name == "" {
+			fields = append(fields, fieldRules{index: i, embedded: true})
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		rules, err := parseRules(f.Tag.Get("validate"))
+		if err != nil {
+			return nil, fmt.Errorf("validation: %s.%s: %w", t, f.Name, err)
+		}
+		fields = append(fields, fieldRules{index: i, name: name, rules: rules})
+	}
+
+	rulesCache.Store(t, fields)
+	return fields, nil
+}
+
+func parseRules(tag string) ([]rule, error) {
+	if tag == "" {
+		return nil, nil
+	}
+	var rules []rule
+	for _, text := range strings.Split(tag, ",") {
+		name, arg, hasArg := strings.Cut(text, "=")
+		r := rule{name: name}
+		switch name {
+		case "required", "url":
+			if hasArg {
+				return nil, fmt.Errorf("rule %q takes no argument", text)
+			}
+		case "max", "min":
+			limit, err := strconv.ParseFloat(arg, 64)
+			if err != nil {
+				return nil, fmt.Errorf("rule %q needs a number", text)
+			}
+			r.limit = limit
+		case "enum":
+			if arg == "" {
+				return nil, fmt.Errorf("rule %q needs values", text)
+			}
+			r.allowed = strings.Split(arg, "|")
+		default:
+			return nil, fmt.Errorf("unknown rule %q", text)
+		}
+		rules = append(rules, r)
+	}
+	return rules, nil
+}
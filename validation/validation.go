@@ -0,0 +1,261 @@
+// Package validation checks decoded requests against declarative rules in
+// struct tags and reports every problem as a field error.
+//
+// Rules are given in a validate tag next to the json tag:
+//
+//	required     the value must not be empty
+//	max=N        at most N runes, items or the value N
+//	min=N        at least N runes, items or the value N
+//	enum=a|b|c   one of the listed strings
+//	url          an absolute http or https URL
+//
+// Rules other than required skip empty values. Nested structs and slices
+// of structs are checked too, and values implementing Validator get their
+// Validate method called after the tag rules. Tags are parsed once per type,
+// Compile reports malformed ones when a type is registered.
+package validation
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strings"
+	"unicode/utf8"
+)
+
+// Machine readable error codes
+const (
+	CodeRequired     = "required"
+	CodeTooLong      = "too_long"
+	CodeTooShort     = "too_short"
+	CodeTooLarge     = "too_large"
+	CodeTooSmall     = "too_small"
+	CodeNotAllowed   = "not_allowed"
+	CodeInvalidURL   = "invalid_url"
+	CodeUnknownField = "unknown_field"
+	CodeInvalidType  = "invalid_type"
+	CodeInvalid      = "invalid"
+)
+
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Errors is the list of problems found in one request
+type Errors []FieldError
+
+func (e Errors) Error() string {
+	parts := make([]string, len(e))
+	for i, fe := range e {
+		parts[i] = fe.Field + ": " + fe.Message
+	}
+	return "invalid request: " + strings.Join(parts, "; ")
+}
+
+// Validator is implemented by types with checks that tags can't express.
+// Returning Errors reports fields relative to the value.
+type Validator interface {
+	Validate() error
+}
+
+// Struct checks v and returns Errors, or nil when v is valid. A malformed
+// rule in the tags of v is returned as a plain error, Compile reports those
+// up front.
+func Struct(v any) error {
+	if v == nil {
+		return nil
+	}
+	if err := Compile(reflect.TypeOf(v)); err != nil {
+		return err
+	}
+	var errs Errors
+	check(reflect.ValueOf(v), "", &errs)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func check(v reflect.Value, path string, errs *Errors) {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		checkFields(v, path, errs)
+		callValidate(v, path, errs)
+	case reflect.Slice, reflect.Array:
+		if !mayHaveRules(v.Type().Elem()) {
+			return
+		}
+		for i := 0; i < v.Len(); i++ {
+			check(v.Index(i), fmt.Sprintf("%s[%d]", path, i), errs)
+		}
+	}
+}
+
+func checkFields(v reflect.Value, path string, errs *Errors) {
+	fields, err := structRules(v.Type())
+	if err != nil {
+		// Values inside interfaces are only seen here
+		*errs = append(*errs, FieldError{Field: path, Code: CodeInvalid, Message: err.Error()})
+		return
+	}
+	for _, f := range fields {
+		fv := v.Field(f.index)
+
+		// Embedded structs share the path of their parent, like in JSON
+		if f.embedded {
+			check(fv, path, errs)
+			continue
+		}
+		fieldPath := join(path, f.name)
+
+		for _, r := range f.rules {
+			if fe, ok := r.apply(fv); !ok {
+				fe.Field = fieldPath
+				*errs = append(*errs, fe)
+				// One error per field is enough
+				break
+			}
+		}
+		check(fv, fieldPath, errs)
+	}
+}
+
+func callValidate(v reflect.Value, path string, errs *Errors) {
+	// Embedded values of unexported types can't be called
+	if !v.CanInterface() {
+		return
+	}
+	var validator Validator
+	if v.CanAddr() {
+		validator, _ = v.Addr().Interface().(Validator)
+	}
+	if validator == nil {
+		validator, _ = v.Interface().(Validator)
+	}
+	if validator == nil {
+		return
+	}
+
+	err := validator.Validate()
+	var fieldErrs Errors
+	switch {
+	case err == nil:
+	case errors.As(err, &fieldErrs):
+		for _, fe := range fieldErrs {
+			fe.Field = join(path, fe.Field)
+			*errs = append(*errs, fe)
+		}
+	default:
+		*errs = append(*errs, FieldError{Field: path, Code: CodeInvalid, Message: err.Error()})
+	}
+}
+
+// apply checks one rule and returns the error without its field
+func (r rule) apply(v reflect.Value) (FieldError, bool) {
+	if r.name == "required" {
+		if isEmpty(v) {
+			return FieldError{Code: CodeRequired, Message: "is required"}, false
+		}
+		return FieldError{}, true
+	}
+	if isEmpty(v) {
+		return FieldError{}, true
+	}
+	for v.Kind() == reflect.Pointer {
+		v = v.Elem()
+	}
+
+	switch r.name {
+	case "max", "min":
+		return checkLimit(r.name, r.limit, v)
+	case "enum":
+		for _, a := range r.allowed {
+			if v.String() == a {
+				return FieldError{}, true
+			}
+		}
+		return FieldError{Code: CodeNotAllowed, Message: "must be one of " + strings.Join(r.allowed, ", ")}, false
+	case "url":
+		u, err := url.Parse(v.String())
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return FieldError{Code: CodeInvalidURL, Message: "must be an absolute http or https URL"}, false
+		}
+	}
+	return FieldError{}, true
+}
+
+func checkLimit(name string, limit float64, v reflect.Value) (FieldError, bool) {
+	var (
+		n    float64
+		unit string
+	)
+	switch v.Kind() {
+	case reflect.String:
+		n, unit = float64(utf8.RuneCountInString(v.String())), " characters"
+	case reflect.Slice, reflect.Array, reflect.Map:
+		n, unit = float64(v.Len()), " items"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n = float64(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n = float64(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		n = v.Float()
+	default:
+		return FieldError{}, true
+	}
+
+	switch {
+	case name == "max" && n > limit:
+		code := CodeTooLarge
+		if unit != "" {
+			code = CodeTooLong
+		}
+		return FieldError{Code: code, Message: fmt.Sprintf("must be at most %g%s", limit, unit)}, false
+	case name == "min" && n < limit:
+		code := CodeTooSmall
+		if unit != "" {
+			code = CodeTooShort
+		}
+		return FieldError{Code: code, Message: fmt.Sprintf("must be at least %g%s", limit, unit)}, false
+	}
+	return FieldError{}, true
+}
+
+// mayHaveRules reports whether values of t can contain structs
+func mayHaveRules(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Struct, reflect.Pointer, reflect.Interface, reflect.Slice, reflect.Array:
+		return true
+	}
+	return false
+}
+
+func isEmpty(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Slice, reflect.Map, reflect.String, reflect.Array:
+		return v.Len() == 0
+	case reflect.Pointer, reflect.Interface:
+		return v.IsNil()
+	}
+	return v.IsZero()
+}
+
+func join(path, name string) string {
+	switch {
+	case path == "":
+		return name
+	case name == "":
+		return path
+	}
+	return path + "." + name
+}
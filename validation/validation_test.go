@@ -0,0 +1,138 @@
+package validation
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type address struct {
+	City string `json:"city" validate:"required"`
+}
+
+type base struct {
+	ID string `json:"id" validate:"max=4"`
+}
+
+type request struct {
+	base
+	Name      string    `json:"name" validate:"required,max=5"`
+	Count     int       `json:"count,omitempty" validate:"min=1,max=10"`
+	Ratio     *float64  `json:"ratio,omitempty" validate:"max=1"`
+	Mode      string    `json:"mode,omitempty" validate:"enum=fast|slow"`
+	Callback  string    `json:"callback,omitempty" validate:"url"`
+	Tags      []string  `json:"tags,omitempty" validate:"max=2"`
+	Address   *address  `json:"address,omitempty"`
+	Addresses []address `json:"addresses,omitempty"`
+	Ignored   string    `json:"-" validate:"required"`
+}
+
+type checked struct {
+	A, B int
+}
+
+func (c checked) Validate() error {
+	if c.A > c.B {
+		return Errors{{Field: "a", Code: CodeInvalid, Message: "must not be greater than b"}}
+	}
+	return nil
+}
+
+func TestStruct(t *testing.T) {
+	ratio := 1.5
+	tests := []struct {
+		name string
+		v    any
+		want []string // field:code
+	}{
+		{"valid", request{Name: "ok"}, nil},
+		{"required", request{}, []string{"name:required"}},
+		{"too long, in runes", request{Name: "äöüßéè"}, []string{"name:too_long"}},
+		{"runes within the limit", request{Name: "äöüßé"}, nil},
+		{"number limits", request{Name: "ok", Count: 11}, []string{"count:too_large"}},
+		{"zero skips min", request{Name: "ok", Count: 0}, nil},
+		{"pointer", request{Name: "ok", Ratio: &ratio}, []string{"ratio:too_large"}},
+		{"enum", request{Name: "ok", Mode: "medium"}, []string{"mode:not_allowed"}},
+		{"url", request{Name: "ok", Callback: "ftp://example.com"}, []string{"callback:invalid_url"}},
+		{"items", request{Name: "ok", Tags: []string{"a", "b", "c"}}, []string{"tags:too_long"}},
+		{"embedded", request{base: base{ID: "12345"}, Name: "ok"}, []string{"id:too_long"}},
+		{"nested", request{Name: "ok", Address: &address{}}, []string{"address.city:required"}},
+		{"slice of structs", request{Name: "ok", Addresses: []address{{City: "x"}, {}}}, []string{"addresses[1].city:required"}},
+		{"one error per field", request{Name: "toolong", Mode: "x"}, []string{"name:too_long", "mode:not_allowed"}},
+		{"validator", checked{A: 2, B: 1}, []string{"a:invalid"}},
+		{"nil pointer", (*request)(nil), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Struct(tt.v)
+			var got []string
+			var errs Errors
+			if errors.As(err, &errs) {
+				for _, fe := range errs {
+					got = append(got, fe.Field+":"+fe.Code)
+				}
+			} else if err != nil {
+				t.Fatalf("err = %v, want Errors", err)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("errors = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+type badMax struct {
+	Name string `json:"name" validate:"max=ten"`
+}
+
+type badRule struct {
+	Name string `json:"name" validate:"required,lowercase"`
+}
+
+type badEnum struct {
+	Name string `json:"name" validate:"enum="`
+}
+
+type nestedBad struct {
+	Items []badMax `json:"items"`
+}
+
+func TestCompile(t *testing.T) {
+	tests := []struct {
+		name    string
+		t       reflect.Type
+		wantErr string
+	}{
+		{"valid", reflect.TypeFor[request](), ""},
+		{"pointer", reflect.TypeFor[*request](), ""},
+		{"no struct", reflect.TypeFor[string](), ""},
+		{"bad limit", reflect.TypeFor[badMax](), `rule "max=ten" needs a number`},
+		{"unknown rule", reflect.TypeFor[badRule](), `unknown rule "lowercase"`},
+		{"enum without values", reflect.TypeFor[badEnum](), `rule "enum=" needs values`},
+		{"nested", reflect.TypeFor[nestedBad](), `validation.badMax.Name`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Compile(tt.t)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("err = %v, want it to mention %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestStructBadTag(t *testing.T) {
+	// Unregistered types with broken tags fail instead of panicking
+	err := Struct(badMax{Name: "x"})
+	var errs Errors
+	if err == nil || errors.As(err, &errs) {
+		t.Errorf("err = %v, want a plain error", err)
+	}
+}